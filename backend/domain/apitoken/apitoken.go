@@ -0,0 +1,65 @@
+package apitoken
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"time"
+)
+
+type Scope string
+
+const (
+	ScopeRead   Scope = "read"
+	ScopeWrite  Scope = "write"
+	ScopeDelete Scope = "delete"
+	// 全ての操作を許可する
+	ScopeAdmin Scope = "admin"
+)
+
+func ScopeFromString(value string) (Scope, bool) {
+	switch Scope(value) {
+	case ScopeRead, ScopeWrite, ScopeDelete, ScopeAdmin:
+		return Scope(value), true
+	}
+
+	return "", false
+}
+
+// 他の秘密情報と見分けられるよう、発行するトークンの先頭に付ける
+const Prefix = "ys_"
+
+type ApiToken struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	// 一覧で見分けるためのトークンの先頭の数文字
+	Hint string `json:"hint"`
+	// トークンそのものは保存せず、SHA-256のみを保存する
+	TokenHash string  `json:"-"`
+	Scopes    []Scope `json:"scopes"`
+	// 指定した場合、このディレクトリ配下のみを操作できる
+	DirectoryID *string    `json:"directory_id"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (t ApiToken) HasScope(scope Scope) bool {
+	return slices.Contains(t.Scopes, scope) || slices.Contains(t.Scopes, ScopeAdmin)
+}
+
+func (t ApiToken) IsExpired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+func (t ApiToken) IsDirectoryRestricted() bool {
+	return t.DirectoryID != nil
+}
+
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"slices"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/apitoken"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+)
+
+// 認証に使った方法
+type Method string
+
+const (
+	MethodSession Method = "session"
+	MethodBearer  Method = "bearer"
+	MethodBasic   Method = "basic"
+	MethodS3      Method = "s3"
+)
+
+// ユーザー本人として操作する場合の権限。管理者の権限は含まない
+var userScopes = []apitoken.Scope{apitoken.ScopeRead, apitoken.ScopeWrite, apitoken.ScopeDelete}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	User   user.User
+	Method Method
+	Scopes []apitoken.Scope
+	// APIトークンで認証した場合のみ
+	ApiToken *apitoken.ApiToken
+}
+
+// セッションやアクセスキーのように、トークンを使わない認証
+func NewUserPrincipal(u user.User, method Method) Principal {
+	return Principal{
+		User:   u,
+		Method: method,
+		Scopes: userScopes,
+	}
+}
+
+func NewApiTokenPrincipal(u user.User, method Method, apiToken apitoken.ApiToken) Principal {
+	return Principal{
+		User:     u,
+		Method:   method,
+		Scopes:   apiToken.Scopes,
+		ApiToken: &apiToken,
+	}
+}
+
+func (p Principal) HasScope(scope apitoken.Scope) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, apitoken.ScopeAdmin)
+}
@@ -0,0 +1,68 @@
+package file
+
+import "strings"
+
+type ArchiveFormat string
+
+const (
+	ArchiveZip   ArchiveFormat = "zip"
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+	// 展開のみ対応
+	ArchiveTar ArchiveFormat = "tar"
+)
+
+// 未指定の場合はZIPとする
+func ArchiveFormatFromString(value string) (ArchiveFormat, bool) {
+	switch ArchiveFormat(value) {
+	case "", ArchiveZip:
+		return ArchiveZip, true
+	case ArchiveTarGz:
+		return ArchiveTarGz, true
+	}
+
+	return "", false
+}
+
+// 展開できるアーカイブの形式をファイル名から判定する
+func ArchiveFormatFromFilename(filename string) (ArchiveFormat, bool) {
+	lower := strings.ToLower(filename)
+
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return ArchiveZip, true
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return ArchiveTarGz, true
+	case strings.HasSuffix(lower, ".tar"):
+		return ArchiveTar, true
+	}
+
+	return "", false
+}
+
+// ファイル名からアーカイブの拡張子を除く
+func TrimArchiveExtension(filename string) string {
+	lower := strings.ToLower(filename)
+
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(lower, ext) && len(filename) > len(ext) {
+			return filename[:len(filename)-len(ext)]
+		}
+	}
+
+	return filename
+}
+
+func (format ArchiveFormat) Extension() string {
+	return "." + string(format)
+}
+
+func (format ArchiveFormat) MimeType() string {
+	switch format {
+	case ArchiveTarGz:
+		return "application/gzip"
+	case ArchiveTar:
+		return "application/x-tar"
+	}
+
+	return "application/zip"
+}
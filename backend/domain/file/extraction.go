@@ -0,0 +1,19 @@
+package file
+
+// アーカイブを展開する際の上限。展開後のサイズや件数が極端に大きいアーカイブ(zip bomb)を拒否する
+type ExtractionSetting struct {
+	// 展開後の合計サイズ(バイト)
+	MaxTotalSize int64 `yaml:"max_total_size"`
+	// ディレクトリを含むエントリ数
+	MaxEntries int `yaml:"max_entries"`
+	// 展開後のサイズ / アーカイブのサイズ
+	MaxCompressionRatio float64 `yaml:"max_compression_ratio"`
+}
+
+func DefaultExtractionSetting() ExtractionSetting {
+	return ExtractionSetting{
+		MaxTotalSize:        10 * 1024 * 1024 * 1024,
+		MaxEntries:          10000,
+		MaxCompressionRatio: 100,
+	}
+}
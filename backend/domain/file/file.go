@@ -9,11 +9,21 @@ type File struct {
 	UserID            string  `json:"user_id"`
 	ParentDirectoryID *string `json:"parent_directory_id"`
 	// Embedding         *vector.Vector `json:"embedding"`
-	Kind      string    `json:"kind"`
-	Url       *string   `json:"url"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Kind string  `json:"kind"`
+	Url  *string `json:"url"`
+	// 自ストレージ上の実体のID。アップロードの処理のみが設定し、外部のURLのみのファイルはnil
+	BlobID   *string `json:"blob_id"`
+	Name     string  `json:"name"`
+	MimeType *string `json:"mime_type"`
+	// 実体の大きさ(バイト)。ディレクトリと外部のURLは0
+	Size                int64      `json:"size"`
+	CompressionDisabled bool       `json:"compression_disabled"` // ディレクトリの場合は配下の全ての動画を圧縮しない
+	TakenAt             *time.Time `json:"taken_at"`
+	Width               *int       `json:"width"`
+	Height              *int       `json:"height"`
+	Metadata            *Metadata  `json:"metadata"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
 }
 
 type PaginationFiles struct {
@@ -0,0 +1,53 @@
+package file
+
+import (
+	"net"
+	"slices"
+	"strings"
+)
+
+// URLからファイルを取り込む際の上限と接続を許可する宛先
+type ImportSetting struct {
+	// 取り込むファイルの大きさの上限(バイト)
+	MaxSize int64 `yaml:"max_size"`
+	// ダウンロード全体の制限時間(秒)
+	TimeoutSeconds int `yaml:"timeout_seconds"`
+	// プライベートなアドレスに解決されても接続を許可するホスト名
+	AllowedHosts []string `yaml:"allowed_hosts"`
+	// 接続を許可するプライベートなネットワーク(CIDR)
+	AllowedNetworks []string `yaml:"allowed_networks"`
+}
+
+func DefaultImportSetting() ImportSetting {
+	return ImportSetting{
+		MaxSize:        5 * 1024 * 1024 * 1024,
+		TimeoutSeconds: 600,
+	}
+}
+
+func (setting ImportSetting) IsAllowedHost(host string) bool {
+	return slices.ContainsFunc(setting.AllowedHosts, func(allowed string) bool {
+		return strings.EqualFold(allowed, host)
+	})
+}
+
+// ループバック・プライベート・リンクローカルなどのアドレスは、許可したネットワークに含まれる場合のみ接続できる
+func (setting ImportSetting) IsAllowedIP(ip net.IP) bool {
+	if ip.IsGlobalUnicast() && !ip.IsPrivate() && !isSharedAddress(ip) {
+		return true
+	}
+
+	for _, cidr := range setting.AllowedNetworks {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// キャリアグレードNATの共有アドレス(100.64.0.0/10)
+func isSharedAddress(ip net.IP) bool {
+	ip4 := ip.To4()
+	return ip4 != nil && ip4[0] == 100 && ip4[1]&0xc0 == 64
+}
@@ -1,5 +1,11 @@
 package file
 
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
 type FileKind int
 
 const (
@@ -82,3 +88,60 @@ func FileKindFromEnString(en string) FileKind {
 		return Unknown
 	}
 }
+
+var fileKindExtensions = map[FileKind][]string{
+	Word:       {".doc", ".docx"},
+	Excel:      {".xls", ".xlsx"},
+	PowerPoint: {".ppt", ".pptx"},
+	PDF:        {".pdf"},
+	Video:      {".mp4", ".avi", ".mov", ".wmv", ".flv", ".webm", ".mkv", ".m4v", ".3gp", ".mts", ".m2ts"},
+	Image:      {".jpg", ".jpeg", ".png", ".gif", ".webp", ".heic", ".bmp"},
+	Zip:        {".zip", ".rar", ".7z", ".tar", ".gz", ".tgz"},
+}
+
+func FileKindFromFilename(filename string) FileKind {
+	ext := strings.ToLower(filepath.Ext(filename))
+
+	for fileKind, extensions := range fileKindExtensions {
+		if slices.Contains(extensions, ext) {
+			return fileKind
+		}
+	}
+
+	return Unknown
+}
+
+var fileKindMimeTypes = map[string]FileKind{
+	"application/pdf":               PDF,
+	"application/msword":            Word,
+	"application/vnd.ms-excel":      Excel,
+	"application/vnd.ms-powerpoint": PowerPoint,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   Word,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         Excel,
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": PowerPoint,
+	"application/zip":              Zip,
+	"application/x-gzip":           Zip,
+	"application/gzip":             Zip,
+	"application/x-tar":            Zip,
+	"application/x-rar-compressed": Zip,
+	"application/x-7z-compressed":  Zip,
+}
+
+// 拡張子から判別できないファイルの種類を、内容から推定したMIMEタイプで判別する
+func FileKindFromMimeType(mimeType string) FileKind {
+	mimeType, _, _ = strings.Cut(strings.ToLower(mimeType), ";")
+	mimeType = strings.TrimSpace(mimeType)
+
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return Image
+	case strings.HasPrefix(mimeType, "video/"):
+		return Video
+	}
+
+	if fileKind, ok := fileKindMimeTypes[mimeType]; ok {
+		return fileKind
+	}
+
+	return Unknown
+}
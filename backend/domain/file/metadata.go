@@ -0,0 +1,16 @@
+package file
+
+// 画像のEXIFから抽出したメタデータ
+type Metadata struct {
+	Make        string `json:"make,omitempty"`
+	Model       string `json:"model,omitempty"`
+	LensModel   string `json:"lens_model,omitempty"`
+	Orientation int    `json:"orientation,omitempty"`
+	GPS         *GPS   `json:"gps,omitempty"`
+}
+
+type GPS struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Altitude  *float64 `json:"altitude,omitempty"`
+}
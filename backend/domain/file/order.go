@@ -0,0 +1,20 @@
+package file
+
+// ファイル一覧の並び順
+type Order string
+
+const (
+	OrderDefault   Order = ""
+	OrderCreatedAt Order = "created_at"
+	OrderTakenAt   Order = "taken_at"
+	OrderName      Order = "name"
+)
+
+func OrderFromString(order string) (Order, bool) {
+	switch Order(order) {
+	case OrderDefault, OrderCreatedAt, OrderTakenAt, OrderName:
+		return Order(order), true
+	default:
+		return OrderDefault, false
+	}
+}
@@ -0,0 +1,15 @@
+package file
+
+import "slices"
+
+// サムネイルとして生成するWebPの長辺サイズ(px)
+var ThumbnailSizes = []int{64, 256, 1024}
+
+func IsThumbnailSize(size int) bool {
+	return slices.Contains(ThumbnailSizes, size)
+}
+
+// 派生ファイルを生成できる種類か
+func (fileKind FileKind) HasDerivatives() bool {
+	return fileKind == Image || fileKind == Video || fileKind == PDF
+}
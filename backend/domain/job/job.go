@@ -0,0 +1,113 @@
+package job
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Type string
+
+const (
+	TypeGenerateDerivatives Type = "generate_derivatives"
+	TypeTranscodeVideo      Type = "transcode_video"
+	TypeCreateArchive       Type = "create_archive"
+	TypeExtractArchive      Type = "extract_archive"
+	TypeImportUrl           Type = "import_url"
+)
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusCancelled Status = "cancelled"
+	// リトライ上限に達したジョブ
+	StatusDead Status = "dead"
+)
+
+func (status Status) IsFinished() bool {
+	return status == StatusSucceeded || status == StatusCancelled || status == StatusDead
+}
+
+type Job struct {
+	ID              string          `json:"id"`
+	UserID          string          `json:"user_id"`
+	Type            Type            `json:"type"`
+	Status          Status          `json:"status"`
+	Payload         json.RawMessage `json:"payload"`
+	Result          json.RawMessage `json:"result"`
+	Progress        *Progress       `json:"progress"`
+	Attempts        int             `json:"attempts"`
+	MaxAttempts     int             `json:"max_attempts"`
+	LastError       *string         `json:"last_error"`
+	CancelRequested bool            `json:"cancel_requested"`
+	RunAt           time.Time       `json:"run_at"`
+	StartedAt       *time.Time      `json:"started_at"`
+	FinishedAt      *time.Time      `json:"finished_at"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// 実行中のジョブの進捗。Totalが0の場合は全体量が不明
+type Progress struct {
+	Current int64  `json:"current"`
+	Total   int64  `json:"total"`
+	Unit    string `json:"unit"`
+}
+
+type PaginationJobs struct {
+	Jobs             []Job `json:"jobs"`
+	PageSize         int   `json:"page_size"`
+	CurrentPageCount int   `json:"current_page_count"`
+	Total            int   `json:"total"`
+}
+
+// アップロードされた実体を対象とするジョブのペイロード
+type BlobPayload struct {
+	BlobID   string `json:"blob_id"`
+	Filename string `json:"filename"`
+	Kind     string `json:"kind"`
+}
+
+// 選択したファイル・ディレクトリをまとめたアーカイブを作成するジョブのペイロード
+type ArchivePayload struct {
+	FileIDs []string `json:"file_ids"`
+	Format  string   `json:"format"`
+}
+
+type ArchiveResult struct {
+	Name      string    `json:"name"`
+	Format    string    `json:"format"`
+	Size      int64     `json:"size"`
+	FileCount int       `json:"file_count"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// アップロードされたアーカイブを展開するジョブのペイロード
+type ExtractPayload struct {
+	FileID            string  `json:"file_id"`
+	ParentDirectoryID *string `json:"parent_directory_id"`
+}
+
+type ExtractResult struct {
+	DirectoryID    string   `json:"directory_id"`
+	FileCount      int      `json:"file_count"`
+	DirectoryCount int      `json:"directory_count"`
+	TotalSize      int64    `json:"total_size"`
+	Skipped        []string `json:"skipped"`
+}
+
+// 外部URLのファイルを取り込むジョブのペイロード
+type ImportPayload struct {
+	FileID string `json:"file_id"`
+	Url    string `json:"url"`
+}
+
+type ImportResult struct {
+	FileID   string `json:"file_id"`
+	Url      string `json:"url"`
+	MimeType string `json:"mime_type"`
+	Kind     string `json:"kind"`
+	Size     int64  `json:"size"`
+}
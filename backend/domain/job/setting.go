@@ -0,0 +1,45 @@
+package job
+
+import "time"
+
+type TypeSetting struct {
+	Concurrency int `yaml:"concurrency"`
+	MaxAttempts int `yaml:"max_attempts"`
+}
+
+type Setting map[Type]TypeSetting
+
+func DefaultSetting() Setting {
+	return Setting{
+		TypeGenerateDerivatives: {Concurrency: 2, MaxAttempts: 3},
+		TypeTranscodeVideo:      {Concurrency: 1, MaxAttempts: 3},
+		TypeCreateArchive:       {Concurrency: 1, MaxAttempts: 2},
+		TypeExtractArchive:      {Concurrency: 1, MaxAttempts: 2},
+		TypeImportUrl:           {Concurrency: 2, MaxAttempts: 3},
+	}
+}
+
+func (s Setting) Get(jobType Type) TypeSetting {
+	typeSetting, ok := s[jobType]
+	if !ok {
+		typeSetting = TypeSetting{Concurrency: 1, MaxAttempts: 3}
+	}
+	if typeSetting.Concurrency < 1 {
+		typeSetting.Concurrency = 1
+	}
+	if typeSetting.MaxAttempts < 1 {
+		typeSetting.MaxAttempts = 1
+	}
+
+	return typeSetting
+}
+
+// attempts回目の失敗後、次に実行するまでの待機時間(30秒から倍々で最大1時間)
+func Backoff(attempts int) time.Duration {
+	backoff := 30 * time.Second
+	for i := 1; i < attempts && backoff < time.Hour; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, time.Hour)
+}
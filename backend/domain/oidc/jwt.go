@@ -0,0 +1,285 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// 時計のずれを許容する幅
+const clockSkew = time.Minute
+
+// JWKSに無い鍵で署名されている。鍵の更新に追従するため、JWKSを取得し直してから再度検証する
+var ErrUnknownKey = errors.New("unknown signing key")
+
+type JsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type JsonWebKeySet struct {
+	Keys []JsonWebKey `json:"keys"`
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// IDトークン(JWS)の署名を検証し、クレームを取り出す。発行者や有効期限は Claims.Validate で確認する
+func ParseIdToken(raw string, keys JsonWebKeySet, groupsClaim string) (*Claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed id token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	key, err := keys.find(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var payload map[string]json.RawMessage
+	if err := decodeSegment(parts[1], &payload); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return claimsFromPayload(payload, groupsClaim)
+}
+
+func (c Claims) Validate(issuer string, clientID string, nonce string, now time.Time) error {
+	if c.Issuer != issuer {
+		return errors.Newf("unexpected issuer: %s", c.Issuer)
+	}
+	if !slices.Contains(c.Audience, clientID) {
+		return errors.New("id token is not issued for this client")
+	}
+	// 複数の宛先がある場合は、認可された当事者が自身であることも確認する
+	if len(c.Audience) > 1 && c.AuthorizedParty != clientID {
+		return errors.New("unexpected authorized party")
+	}
+	if !now.Before(c.ExpiresAt.Add(clockSkew)) {
+		return errors.New("id token is expired")
+	}
+	if c.IssuedAt.After(now.Add(clockSkew)) {
+		return errors.New("id token is issued in the future")
+	}
+	if c.Nonce == "" || c.Nonce != nonce {
+		return errors.New("nonce mismatch")
+	}
+	if c.Subject == "" {
+		return errors.New("id token has no subject")
+	}
+
+	return nil
+}
+
+func (s JsonWebKeySet) find(kid string) (JsonWebKey, error) {
+	for _, key := range s.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		// kidが無いトークンは鍵が1つの場合のみ受け付ける
+		if key.Kid == kid || (kid == "" && len(s.Keys) == 1) {
+			return key, nil
+		}
+	}
+
+	return JsonWebKey{}, errors.WithStack(ErrUnknownKey)
+}
+
+// 対称鍵(HS256)と "none" は受け付けない
+func verifySignature(alg string, key JsonWebKey, signingInput []byte, signature []byte) error {
+	if len(alg) != 5 {
+		return errors.Newf("unsupported alg: %s", alg)
+	}
+
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return errors.Newf("unsupported alg: %s", alg)
+	}
+
+	h := hash.New()
+	h.Write(signingInput)
+	digest := h.Sum(nil)
+
+	switch {
+	case strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS"):
+		publicKey, err := key.rsaPublicKey()
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(alg, "PS") {
+			return rsa.VerifyPSS(publicKey, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		return rsa.VerifyPKCS1v15(publicKey, hash, digest, signature)
+	case strings.HasPrefix(alg, "ES"):
+		publicKey, err := key.ecdsaPublicKey()
+		if err != nil {
+			return err
+		}
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		if len(signature) != size*2 {
+			return errors.New("invalid signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(publicKey, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+
+	return errors.Newf("unsupported alg: %s", alg)
+}
+
+func (k JsonWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	if k.Kty != "RSA" {
+		return nil, errors.Newf("unexpected key type: %s", k.Kty)
+	}
+
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+func (k JsonWebKey) ecdsaPublicKey() (*ecdsa.PublicKey, error) {
+	if k.Kty != "EC" {
+		return nil, errors.Newf("unexpected key type: %s", k.Kty)
+	}
+
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, errors.Newf("unsupported curve: %s", k.Crv)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+func claimsFromPayload(payload map[string]json.RawMessage, groupsClaim string) (*Claims, error) {
+	var claims Claims
+	var exp, iat float64
+	var emailVerified any
+
+	fields := map[string]any{
+		"iss":            &claims.Issuer,
+		"sub":            &claims.Subject,
+		"azp":            &claims.AuthorizedParty,
+		"nonce":          &claims.Nonce,
+		"email":          &claims.Email,
+		"picture":        &claims.Picture,
+		"exp":            &exp,
+		"iat":            &iat,
+		"email_verified": &emailVerified,
+	}
+	for name, dest := range fields {
+		if raw, ok := payload[name]; ok {
+			if err := json.Unmarshal(raw, dest); err != nil {
+				return nil, errors.Wrapf(err, "invalid claim: %s", name)
+			}
+		}
+	}
+
+	audience, err := stringOrList(payload["aud"])
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid claim: aud")
+	}
+	claims.Audience = audience
+
+	// グループのクレームが無い、または形式が違う場合はグループに所属していないものとする
+	if groups, err := stringOrList(payload[groupsClaim]); err == nil {
+		claims.Groups = groups
+	}
+
+	claims.ExpiresAt = time.Unix(int64(exp), 0)
+	claims.IssuedAt = time.Unix(int64(iat), 0)
+	// 文字列の "true" を返すIdPもある
+	claims.EmailVerified = emailVerified == true || emailVerified == "true"
+
+	return &claims, nil
+}
+
+func stringOrList(raw json.RawMessage) ([]string, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}, nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
@@ -0,0 +1,110 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"time"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+)
+
+// 認可リクエストからコールバックまでの猶予
+const AuthorizationTimeout = 10 * time.Minute
+
+// storage_config.yaml の oidc。クライアントIDなどの秘密情報は環境変数で指定する
+type Setting struct {
+	Scopes []string `yaml:"scopes"`
+	// グループの一覧が入るIDトークンのクレーム
+	GroupsClaim string `yaml:"groups_claim"`
+	// グループ名とロールの対応。どれにも当てはまらない場合は DefaultRole になる
+	RoleMapping map[string]user.Role `yaml:"role_mapping"`
+	DefaultRole user.Role            `yaml:"default_role"`
+	// 無効にすると、既存のユーザーに紐付けられないアカウントではログインできない
+	AutoProvision bool `yaml:"auto_provision"`
+}
+
+func DefaultSetting() Setting {
+	return Setting{
+		Scopes:        []string{"openid", "email", "profile"},
+		GroupsClaim:   "groups",
+		RoleMapping:   map[string]user.Role{},
+		DefaultRole:   user.RoleUser,
+		AutoProvision: true,
+	}
+}
+
+// グループからロールを決める。管理者に対応するグループが1つでもあれば管理者にする
+// 対応を設定していない場合は、ロールを変更しないようfalseを返す
+func (s Setting) MapRole(groups []string) (user.Role, bool) {
+	if len(s.RoleMapping) == 0 {
+		return "", false
+	}
+
+	role := s.DefaultRole
+	for _, group := range groups {
+		if mapped, ok := s.RoleMapping[group]; ok && mapped == user.RoleAdmin {
+			return user.RoleAdmin, true
+		} else if ok {
+			role = mapped
+		}
+	}
+
+	return role, true
+}
+
+// /.well-known/openid-configuration のうち使用する項目
+type ProviderMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+// 認可リクエストごとに作り、コールバックまでCookieのセッションに保存する
+type AuthorizationRequest struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+func NewAuthorizationRequest(now time.Time) (*AuthorizationRequest, error) {
+	values := make([]string, 3)
+	for i := range values {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		values[i] = base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	return &AuthorizationRequest{
+		State:        values[0],
+		Nonce:        values[1],
+		CodeVerifier: values[2],
+		ExpiresAt:    now.Add(AuthorizationTimeout),
+	}, nil
+}
+
+// PKCEのS256のコードチャレンジ
+func (r AuthorizationRequest) CodeChallenge() string {
+	sum := sha256.Sum256([]byte(r.CodeVerifier))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// IDトークンのクレームのうち使用する項目
+type Claims struct {
+	Issuer          string
+	Subject         string
+	Audience        []string
+	AuthorizedParty string
+	ExpiresAt       time.Time
+	IssuedAt        time.Time
+	Nonce           string
+	Email           string
+	EmailVerified   bool
+	Picture         string
+	Groups          []string
+}
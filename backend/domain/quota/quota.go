@@ -0,0 +1,85 @@
+package quota
+
+import (
+	"slices"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+)
+
+// storage_config.yaml の quota
+type Setting struct {
+	// ユーザーごとの容量の既定値(バイト)。0の場合は無制限
+	DefaultBytes int64 `yaml:"default_bytes"`
+	// 使用量が容量のこの割合(%)を超えたときに警告する
+	WarningPercents []int `yaml:"warning_percents"`
+}
+
+func DefaultSetting() Setting {
+	return Setting{
+		DefaultBytes:    0,
+		WarningPercents: []int{80, 95},
+	}
+}
+
+// ユーザーの容量。管理者が設定していない場合は既定値で、0の場合は無制限
+func (setting Setting) QuotaBytes(owner user.User) int64 {
+	if owner.QuotaBytes != nil {
+		return *owner.QuotaBytes
+	}
+
+	return setting.DefaultBytes
+}
+
+// 使用量がbeforeからafterに増えたときに超えた、最も大きい警告の割合を返す
+func (setting Setting) CrossedWarningPercent(before int64, after int64, quotaBytes int64) (int, bool) {
+	if quotaBytes <= 0 {
+		return 0, false
+	}
+
+	percents := slices.Clone(setting.WarningPercents)
+	slices.Sort(percents)
+	slices.Reverse(percents)
+
+	for _, percent := range percents {
+		threshold := quotaBytes * int64(percent) / 100
+		if before < threshold && threshold <= after {
+			return percent, true
+		}
+	}
+
+	return 0, false
+}
+
+type Usage struct {
+	UsedBytes int64 `json:"used_bytes"`
+	// 0の場合は無制限
+	QuotaBytes int64 `json:"quota_bytes"`
+	// FileKindごとの使用量
+	Kinds map[string]int64 `json:"kinds"`
+}
+
+// kindsにないFileKindは0として含める
+func NewUsage(kinds map[string]int64, quotaBytes int64) Usage {
+	usage := Usage{
+		QuotaBytes: quotaBytes,
+		Kinds:      map[string]int64{},
+	}
+
+	for _, kind := range []file.FileKind{file.Unknown, file.Word, file.Excel, file.PowerPoint, file.PDF, file.Video, file.Image, file.Zip} {
+		usage.Kinds[kind.ToEnString()] = 0
+	}
+	for kind, bytes := range kinds {
+		usage.Kinds[kind] += bytes
+		usage.UsedBytes += bytes
+	}
+
+	return usage
+}
+
+// 使用量が警告の割合を超えたときに、WebSocketで接続しているユーザーに送る
+type Warning struct {
+	Percent    int   `json:"percent"`
+	UsedBytes  int64 `json:"used_bytes"`
+	QuotaBytes int64 `json:"quota_bytes"`
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import "time"
+
+// 固定の時間枠あたりの回数の上限
+type Rule struct {
+	Limit         int64 `yaml:"limit"`
+	WindowSeconds int   `yaml:"window_seconds"`
+}
+
+func (r Rule) Window() time.Duration {
+	return time.Duration(r.WindowSeconds) * time.Second
+}
+
+type LoginSetting struct {
+	// IPアドレスごとのログインの試行回数
+	PerIp Rule `yaml:"per_ip"`
+	// アカウントごとの失敗回数。上限に達するとロックする
+	PerAccount Rule `yaml:"per_account"`
+	// この回数を超えて失敗すると、次の試行まで待たせる時間を倍にしていく
+	FreeAttempts    int64 `yaml:"free_attempts"`
+	MaxDelaySeconds int   `yaml:"max_delay_seconds"`
+	LockoutSeconds  int   `yaml:"lockout_seconds"`
+}
+
+// 失敗した回数に応じて、次の試行まで待たせる時間
+func (s LoginSetting) Delay(failures int64) time.Duration {
+	if failures >= s.PerAccount.Limit {
+		return time.Duration(s.LockoutSeconds) * time.Second
+	}
+	if failures <= s.FreeAttempts {
+		return 0
+	}
+
+	maxDelay := time.Duration(s.MaxDelaySeconds) * time.Second
+	delay := time.Second
+	for i := s.FreeAttempts + 1; i < failures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxDelay)
+}
+
+type ApiSetting struct {
+	// セッションで操作するユーザーごと
+	PerUser Rule `yaml:"per_user"`
+	// APIトークンごと
+	PerToken Rule `yaml:"per_token"`
+}
+
+type SharePasswordSetting struct {
+	// IPアドレスごとのパスワードの入力回数
+	PerIp Rule `yaml:"per_ip"`
+	// 共有リンクごとのパスワードの失敗回数
+	PerShare Rule `yaml:"per_share"`
+}
+
+// storage_config.yaml の rate_limit
+type Setting struct {
+	Login LoginSetting `yaml:"login"`
+	// IPアドレスごとのユーザー登録の回数
+	Registration Rule `yaml:"registration"`
+	// IPアドレスごとのパスワードの再設定メールの送信回数
+	PasswordReset Rule `yaml:"password_reset"`
+	// IPアドレスごとのAPIトークンの認証の失敗回数
+	TokenAuth Rule `yaml:"token_auth"`
+	// 共有リンクのパスワードの入力
+	SharePassword SharePasswordSetting `yaml:"share_password"`
+	Api           ApiSetting           `yaml:"api"`
+}
+
+func DefaultSetting() Setting {
+	return Setting{
+		Login: LoginSetting{
+			PerIp:           Rule{Limit: 30, WindowSeconds: 60},
+			PerAccount:      Rule{Limit: 10, WindowSeconds: 900},
+			FreeAttempts:    3,
+			MaxDelaySeconds: 30,
+			LockoutSeconds:  900,
+		},
+		Registration:  Rule{Limit: 5, WindowSeconds: 3600},
+		PasswordReset: Rule{Limit: 5, WindowSeconds: 3600},
+		TokenAuth:     Rule{Limit: 20, WindowSeconds: 300},
+		SharePassword: SharePasswordSetting{
+			PerIp:    Rule{Limit: 10, WindowSeconds: 60},
+			PerShare: Rule{Limit: 30, WindowSeconds: 900},
+		},
+		Api: ApiSetting{
+			PerUser:  Rule{Limit: 1200, WindowSeconds: 60},
+			PerToken: Rule{Limit: 600, WindowSeconds: 60},
+		},
+	}
+}
@@ -0,0 +1,30 @@
+package s3
+
+import "time"
+
+type AccessKey struct {
+	ID          string `json:"id"`
+	UserID      string `json:"user_id"`
+	AccessKeyID string `json:"access_key_id"`
+	// 作成時のレスポンス以外では返さない
+	SecretAccessKey string    `json:"-"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// 完了または中止されていないマルチパートアップロード。IDをUploadIdとして返す
+type MultipartUpload struct {
+	ID        string
+	UserID    string
+	Bucket    string
+	Key       string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// 完了していないマルチパートアップロードを保持する期間
+const MultipartUploadTTL = 7 * 24 * time.Hour
+
+func (upload MultipartUpload) IsExpired(now time.Time) bool {
+	return !now.Before(upload.CreatedAt.Add(MultipartUploadTTL))
+}
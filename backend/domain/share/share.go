@@ -0,0 +1,57 @@
+package share
+
+import "time"
+
+type Permission string
+
+const (
+	// ブラウザ上での閲覧のみ許可する
+	PermissionView Permission = "view"
+	// 添付ファイルとしてのダウンロードも許可する
+	PermissionDownload Permission = "download"
+)
+
+func PermissionFromString(value string) (Permission, bool) {
+	switch Permission(value) {
+	case PermissionView, PermissionDownload:
+		return Permission(value), true
+	}
+
+	return "", false
+}
+
+type Share struct {
+	ID            string     `json:"id"`
+	UserID        string     `json:"user_id"`
+	FileID        string     `json:"file_id"`
+	Token         string     `json:"token"`
+	Url           string     `json:"url"`
+	Permission    Permission `json:"permission"`
+	PasswordHash  *string    `json:"-"`
+	HasPassword   bool       `json:"has_password"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	MaxDownloads  *int       `json:"max_downloads"`
+	DownloadCount int        `json:"download_count"`
+	RevokedAt     *time.Time `json:"revoked_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (s Share) IsRevoked() bool {
+	return s.RevokedAt != nil
+}
+
+func (s Share) IsExpired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+func (s Share) IsDownloadLimitReached() bool {
+	return s.MaxDownloads != nil && s.DownloadCount >= *s.MaxDownloads
+}
+
+type PaginationShares struct {
+	Shares           []Share `json:"shares"`
+	PageSize         int     `json:"page_size"`
+	CurrentPageCount int     `json:"current_page_count"`
+	Total            int     `json:"total"`
+}
@@ -0,0 +1,36 @@
+package upload
+
+import "time"
+
+// tusで再開可能なアップロード、またはWebSocketで書き込みを終えて登録を待つ実体。
+// IDは完了時に作成するファイルと実体のIDにもなる
+type Upload struct {
+	ID                string
+	UserID            string
+	ParentDirectoryID *string
+	Name              string
+	Length            int64
+	Offset            int64
+	// Upload-Metadataヘッダをそのまま保持し、HEADで返す
+	Metadata  string
+	LocalPath string
+	// 完了して作成したファイルのID
+	FileID    *string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// 最後に書き込んでから破棄するまでの期間
+const TTL = 24 * time.Hour
+
+// 1つのアップロードの最大の大きさ
+const MaxSize = 5 * 1024 * 1024 * 1024
+
+func (u Upload) IsExpired(now time.Time) bool {
+	return !now.Before(u.ExpiresAt)
+}
+
+func (u Upload) IsCompleted() bool {
+	return u.FileID != nil
+}
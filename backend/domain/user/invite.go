@@ -0,0 +1,16 @@
+package user
+
+import "time"
+
+// 招待コードの有効期限の既定値
+const InviteTimeout = 7 * 24 * time.Hour
+
+type Invite struct {
+	ID string `json:"id"`
+	// 指定した場合は、このメールアドレスでのみ登録できる
+	Email     *string    `json:"email"`
+	CreatedBy string     `json:"created_by"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package user
+
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
+func RoleFromString(value string) (Role, bool) {
+	switch Role(value) {
+	case RoleUser, RoleAdmin:
+		return Role(value), true
+	}
+
+	return "", false
+}
@@ -0,0 +1,31 @@
+package user
+
+import "time"
+
+const (
+	// 最後に使用してからこの時間が経過したセッションは無効になる
+	SessionIdleTimeout = 24 * time.Hour
+	// 使用し続けても、ログインからこの時間が経過したセッションは無効になる
+	SessionAbsoluteTimeout = 30 * 24 * time.Hour
+	// 管理者のなりすましは、この時間が経過すると無効になる
+	ImpersonationTimeout = time.Hour
+)
+
+// ログインした端末ごとのセッション
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	UserAgent  string    `json:"user_agent"`
+	IpAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// 管理者がなりすましている場合は管理者のID
+	ImpersonatorID *string `json:"impersonator_id"`
+	// リクエストしたセッションかどうか
+	Current bool `json:"current"`
+}
+
+func (s Session) IsExpired(now time.Time) bool {
+	return !now.Before(s.LastSeenAt.Add(SessionIdleTimeout)) || !now.Before(s.ExpiresAt)
+}
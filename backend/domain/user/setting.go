@@ -0,0 +1,30 @@
+package user
+
+type RegistrationMode string
+
+const (
+	// 誰でも登録できる
+	RegistrationModeOpen RegistrationMode = "open"
+	// 管理者が発行した招待コードが必要
+	RegistrationModeInvite RegistrationMode = "invite"
+	// 登録できない
+	RegistrationModeDisabled RegistrationMode = "disabled"
+)
+
+// storage_config.yaml の auth
+type AuthSetting struct {
+	// 有効にすると、二段階認証を設定するまでセッションで操作できない
+	RequireTwoFactor bool `yaml:"require_two_factor"`
+	// 有効にすると、パスワードでのログイン・登録・再設定をできなくし、OpenID Connectでのみログインさせる
+	DisablePasswordLogin bool `yaml:"disable_password_login"`
+	// /users/registration での登録
+	Registration RegistrationMode `yaml:"registration"`
+}
+
+func DefaultAuthSetting() AuthSetting {
+	return AuthSetting{
+		RequireTwoFactor:     false,
+		DisablePasswordLogin: false,
+		Registration:         RegistrationModeOpen,
+	}
+}
@@ -0,0 +1,44 @@
+package user
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// メールで送る使い捨てのトークンの用途
+type TokenPurpose string
+
+const (
+	TokenPurposePasswordReset     TokenPurpose = "password_reset"
+	TokenPurposeEmailVerification TokenPurpose = "email_verification"
+)
+
+const (
+	PasswordResetTokenTimeout     = time.Hour
+	EmailVerificationTokenTimeout = 24 * time.Hour
+)
+
+func (p TokenPurpose) Timeout() time.Duration {
+	if p == TokenPurposePasswordReset {
+		return PasswordResetTokenTimeout
+	}
+
+	return EmailVerificationTokenTimeout
+}
+
+type Token struct {
+	ID        string
+	UserID    string
+	Purpose   TokenPurpose
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,148 @@
+package user
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	TotpIssuer = "yappi_storage"
+	TotpDigits = 6
+	TotpPeriod = 30 * time.Second
+	// 端末の時計のずれを考慮し、前後のステップのコードも受け付ける
+	TotpSkew = 1
+
+	RecoveryCodeCount = 10
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// 認証アプリに登録する秘密鍵(160bit)
+func GenerateTotpSecret() (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// 認証アプリがQRコードから読み取るURI
+func TotpProvisioningUri(secret string, email string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", TotpIssuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TotpDigits))
+	query.Set("period", fmt.Sprint(int(TotpPeriod.Seconds())))
+
+	return (&url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + TotpIssuer + ":" + email,
+		RawQuery: query.Encode(),
+	}).String()
+}
+
+func TotpCounter(now time.Time) int64 {
+	return now.Unix() / int64(TotpPeriod.Seconds())
+}
+
+// RFC 6238 のコード
+func TotpCode(secret string, counter int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", TotpDigits, value%uint32(math.Pow10(TotpDigits))), nil
+}
+
+// コードが一致したステップを返す。lastCounter以前のステップは再利用を防ぐため受け付けない
+func VerifyTotp(secret string, code string, now time.Time, lastCounter int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != TotpDigits {
+		return 0, false
+	}
+
+	current := TotpCounter(now)
+	for counter := current - TotpSkew; counter <= current+TotpSkew; counter++ {
+		if counter <= lastCounter {
+			continue
+		}
+
+		expected, err := TotpCode(secret, counter)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+
+	return 0, false
+}
+
+// xxxxx-xxxxx 形式の使い捨てのコード
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	for range RecoveryCodeCount {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+
+		code := strings.ToLower(hex.EncodeToString(b))
+		codes = append(codes, code[:5]+"-"+code[5:])
+	}
+
+	return codes, nil
+}
+
+// 区切りや大文字小文字の違いを無視してハッシュにする
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+
+	return hex.EncodeToString(sum[:])
+}
+
+const (
+	// パスワードを確認してから、二段階認証のコードを入力するまでの期限
+	TwoFactorChallengeTimeout = 5 * time.Minute
+	// 失敗がこの回数に達すると、パスワードの入力からやり直す
+	TwoFactorChallengeMaxAttempts = 5
+)
+
+// パスワードを確認した後、二段階認証のコードを待っているログイン
+type TwoFactorChallenge struct {
+	Challenge string    `json:"challenge"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// 認証アプリに登録する情報
+type TotpEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningUri string `json:"provisioning_uri"`
+}
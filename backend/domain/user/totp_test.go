@@ -0,0 +1,93 @@
+package user
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 の付録Bの秘密鍵 "12345678901234567890"
+const testTotpSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTotpCode(t *testing.T) {
+	// RFC 6238 の付録BのSHA1のコードの下6桁
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := TotpCode(testTotpSecret, TotpCounter(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("TotpCode(%d) returned an error: %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("TotpCode(%d) = %q, want %q", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyTotp(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := TotpCounter(now)
+
+	codeAt := func(counter int64) string {
+		code, err := TotpCode(testTotpSecret, counter)
+		if err != nil {
+			t.Fatalf("TotpCode(%d) returned an error: %v", counter, err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name        string
+		code        string
+		lastCounter int64
+		wantCounter int64
+		wantOK      bool
+	}{
+		{name: "現在のステップ", code: "050471", wantCounter: current, wantOK: true},
+		{name: "前後の空白は無視する", code: " 050471 ", wantCounter: current, wantOK: true},
+		{name: "1つ前のステップ", code: "081804", wantCounter: current - 1, wantOK: true},
+		{name: "1つ後のステップ", code: codeAt(current + 1), wantCounter: current + 1, wantOK: true},
+		{name: "2つ前のステップ", code: codeAt(current - 2), wantOK: false},
+		{name: "2つ後のステップ", code: codeAt(current + 2), wantOK: false},
+		{name: "使用済みのステップ", code: "050471", lastCounter: current, wantOK: false},
+		{name: "使用済みより前のステップ", code: "081804", lastCounter: current, wantOK: false},
+		{name: "使用済みより後のステップ", code: "050471", lastCounter: current - 1, wantCounter: current, wantOK: true},
+		{name: "桁数が違う", code: "50471", wantOK: false},
+		{name: "一致しない", code: "123456", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter, ok := VerifyTotp(testTotpSecret, tt.code, now, tt.lastCounter)
+			if ok != tt.wantOK {
+				t.Fatalf("VerifyTotp(%q) ok = %v, want %v", tt.code, ok, tt.wantOK)
+			}
+			if ok && counter != tt.wantCounter {
+				t.Errorf("VerifyTotp(%q) counter = %d, want %d", tt.code, counter, tt.wantCounter)
+			}
+		})
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	want := HashRecoveryCode("abcde-12345")
+
+	for _, code := range []string{"abcde12345", "ABCDE-12345", " abcde 12345 "} {
+		if got := HashRecoveryCode(code); got != want {
+			t.Errorf("HashRecoveryCode(%q) = %q, want %q", code, got, want)
+		}
+	}
+
+	if HashRecoveryCode("abcde-12346") == want {
+		t.Errorf("HashRecoveryCode returned the same hash for different codes")
+	}
+}
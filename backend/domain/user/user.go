@@ -3,9 +3,38 @@ package user
 import "time"
 
 type User struct {
-	ID        string    `json:"id"`
-	Email     string    `json:"email"`
-	Password  string    `json:"password"`
-	Icon      string    `json:"icon"`
-	CreatedAt time.Time `json:"created_at"`
+	ID       string `json:"id"`
+	Email    string `json:"email"`
+	Password string `json:"-"`
+	Icon     string `json:"icon"`
+	Role     Role   `json:"role"`
+	// 配信する元ファイルから位置情報を取り除く
+	StripLocationMetadata bool `json:"strip_location_metadata"`
+	TwoFactorEnabled      bool `json:"two_factor_enabled"`
+	// 有効化する前の登録中の秘密鍵も入る
+	TotpSecret string `json:"-"`
+	// 最後に使用したTOTPのステップ。同じコードの再利用を防ぐ
+	TotpLastCounter int64 `json:"-"`
+	// 確認メールのリンクを開くまではnil
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// 管理者が無効にしたユーザーはログインできない
+	DisabledAt *time.Time `json:"disabled_at"`
+	// 保存できる容量。nilの場合は設定の既定値
+	QuotaBytes *int64    `json:"quota_bytes"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (u User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+func (u User) IsDisabled() bool {
+	return u.DisabledAt != nil
+}
+
+type PaginationUsers struct {
+	Users            []User `json:"users"`
+	PageSize         int    `json:"page_size"`
+	CurrentPageCount int    `json:"current_page_count"`
+	Total            int    `json:"total"`
 }
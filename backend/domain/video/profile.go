@@ -0,0 +1,90 @@
+package video
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// 圧縮をスキップする条件。既に効率的にエンコードされている動画は再エンコードしない
+type SkipSetting struct {
+	// ffprobeのcodec_name (h264, hevc など)
+	Codecs []string `yaml:"codecs" json:"codecs"`
+	// 1ピクセル・1フレームあたりのビット数がこの値以下であればスキップする
+	MaxBitsPerPixel float64 `yaml:"max_bits_per_pixel" json:"max_bits_per_pixel"`
+}
+
+type HardwareAccelerationSetting struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// ffmpegの -hwaccel に渡す値 (auto, cuda, vaapi など)
+	Hwaccel string `yaml:"hwaccel" json:"hwaccel"`
+	// 有効時に使うエンコーダー (h264_nvenc, h264_vaapi など)
+	VideoCodec string `yaml:"video_codec" json:"video_codec"`
+}
+
+type Profile struct {
+	Name       string   `yaml:"name" json:"name"`
+	Extensions []string `yaml:"extensions" json:"extensions"`
+	// "video/*" のようなワイルドカードを使える
+	MimeTypes  []string `yaml:"mime_types" json:"mime_types"`
+	VideoCodec string   `yaml:"video_codec" json:"video_codec"`
+	CRF        int      `yaml:"crf" json:"crf"`
+	// 指定した場合はCRFの代わりに固定ビットレートでエンコードする
+	VideoBitrate         string                      `yaml:"video_bitrate" json:"video_bitrate"`
+	Preset               string                      `yaml:"preset" json:"preset"`
+	MaxHeight            int                         `yaml:"max_height" json:"max_height"`
+	AudioCodec           string                      `yaml:"audio_codec" json:"audio_codec"`
+	AudioBitrate         string                      `yaml:"audio_bitrate" json:"audio_bitrate"`
+	AudioChannels        int                         `yaml:"audio_channels" json:"audio_channels"`
+	HardwareAcceleration HardwareAccelerationSetting `yaml:"hardware_acceleration" json:"hardware_acceleration"`
+	Skip                 SkipSetting                 `yaml:"skip" json:"skip"`
+}
+
+func DefaultProfile() Profile {
+	return Profile{
+		Name:          "default",
+		Extensions:    []string{".mp4", ".avi", ".mov", ".wmv", ".flv", ".webm", ".mkv", ".m4v", ".3gp", ".mts", ".m2ts"},
+		MimeTypes:     []string{"video/*"},
+		VideoCodec:    "libx264",
+		CRF:           23,
+		Preset:        "medium",
+		AudioCodec:    "aac",
+		AudioBitrate:  "128k",
+		AudioChannels: 2,
+		Skip: SkipSetting{
+			Codecs:          []string{"h264", "hevc", "vp9", "av1"},
+			MaxBitsPerPixel: 0.1,
+		},
+	}
+}
+
+func (p Profile) Matches(filename string, mimeType string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext != "" && slices.ContainsFunc(p.Extensions, func(e string) bool { return strings.EqualFold(e, ext) }) {
+		return true
+	}
+
+	mimeType = strings.ToLower(mimeType)
+	for _, pattern := range p.MimeTypes {
+		pattern = strings.ToLower(pattern)
+		if pattern == mimeType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(mimeType, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 先頭から順に、拡張子またはMIMEタイプが一致する最初のプロファイルを返す
+func (s Setting) FindProfile(filename string, mimeType string) (*Profile, bool) {
+	for _, profile := range s.Profiles {
+		if profile.Matches(filename, mimeType) {
+			return &profile, true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,78 @@
+package video
+
+import (
+	"strconv"
+	"strings"
+)
+
+type Rendition struct {
+	Name         string `yaml:"name" json:"name"`
+	Height       int    `yaml:"height" json:"height"`
+	VideoBitrate string `yaml:"video_bitrate" json:"video_bitrate"`
+	AudioBitrate string `yaml:"audio_bitrate" json:"audio_bitrate"`
+}
+
+// マスタープレイリストのBANDWIDTHに使う合計ビットレート(bps)
+func (r Rendition) Bandwidth() int {
+	return parseBitrate(r.VideoBitrate) + parseBitrate(r.AudioBitrate)
+}
+
+type HLSSetting struct {
+	SegmentSeconds int         `yaml:"segment_seconds"`
+	Renditions     []Rendition `yaml:"renditions"`
+}
+
+type Setting struct {
+	// falseの場合、元ファイルは圧縮済みのMP4に置き換えられる
+	KeepOriginal bool       `yaml:"keep_original"`
+	HLS          HLSSetting `yaml:"hls"`
+	// 元ファイルを置き換える際の圧縮設定
+	Profiles []Profile `yaml:"profiles"`
+}
+
+func DefaultSetting() Setting {
+	return Setting{
+		KeepOriginal: true,
+		HLS: HLSSetting{
+			SegmentSeconds: 6,
+			Renditions: []Rendition{
+				{Name: "360p", Height: 360, VideoBitrate: "800k", AudioBitrate: "96k"},
+				{Name: "720p", Height: 720, VideoBitrate: "2800k", AudioBitrate: "128k"},
+				{Name: "1080p", Height: 1080, VideoBitrate: "5000k", AudioBitrate: "192k"},
+			},
+		},
+		Profiles: []Profile{DefaultProfile()},
+	}
+}
+
+func (s Setting) FindRendition(name string) (*Rendition, bool) {
+	for _, rendition := range s.HLS.Renditions {
+		if rendition.Name == name {
+			return &rendition, true
+		}
+	}
+
+	return nil, false
+}
+
+// "800k" や "5M" 形式のビットレートをbpsに変換する
+func parseBitrate(bitrate string) int {
+	bitrate = strings.TrimSpace(strings.ToLower(bitrate))
+	multiplier := 1
+
+	switch {
+	case strings.HasSuffix(bitrate, "k"):
+		multiplier = 1000
+		bitrate = strings.TrimSuffix(bitrate, "k")
+	case strings.HasSuffix(bitrate, "m"):
+		multiplier = 1000 * 1000
+		bitrate = strings.TrimSuffix(bitrate, "m")
+	}
+
+	value, err := strconv.ParseFloat(bitrate, 64)
+	if err != nil {
+		return 0
+	}
+
+	return int(value * float64(multiplier))
+}
@@ -0,0 +1,23 @@
+package helper
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+// アーカイブ内のファイル名をUTF-8に変換する。
+// 日本語版Windowsで作成されたZIPはUTF-8フラグが無く、ファイル名がShift_JISで格納されている
+func DecodeArchiveFilename(name string, isUTF8 bool) string {
+	if isUTF8 || utf8.ValidString(name) {
+		return strings.ToValidUTF8(name, "_")
+	}
+
+	decoded, err := japanese.ShiftJIS.NewDecoder().String(name)
+	if err != nil {
+		return strings.ToValidUTF8(name, "_")
+	}
+
+	return decoded
+}
@@ -0,0 +1,456 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+var ErrNoExif = errors.New("exif: no exif data")
+
+const (
+	tagMake               = 0x010F
+	tagModel              = 0x0110
+	tagOrientation        = 0x0112
+	tagDateTime           = 0x0132
+	tagExifIFD            = 0x8769
+	tagGPSIFD             = 0x8825
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+	tagPixelXDimension    = 0xA002
+	tagPixelYDimension    = 0xA003
+	tagLensModel          = 0xA434
+	tagGPSLatitudeRef     = 0x0001
+	tagGPSLatitude        = 0x0002
+	tagGPSLongitudeRef    = 0x0003
+	tagGPSLongitude       = 0x0004
+	tagGPSAltitudeRef     = 0x0005
+	tagGPSAltitude        = 0x0006
+)
+
+// TIFFの型ごとの1要素あたりのバイト数
+var typeSizes = map[uint16]uint32{
+	1:  1, // BYTE
+	2:  1, // ASCII
+	3:  2, // SHORT
+	4:  4, // LONG
+	5:  8, // RATIONAL
+	6:  1, // SBYTE
+	7:  1, // UNDEFINED
+	8:  2, // SSHORT
+	9:  4, // SLONG
+	10: 8, // SRATIONAL
+	11: 4, // FLOAT
+	12: 8, // DOUBLE
+}
+
+type GPS struct {
+	Latitude  float64
+	Longitude float64
+	Altitude  *float64
+}
+
+type Exif struct {
+	Make        string
+	Model       string
+	LensModel   string
+	Orientation int
+	TakenAt     *time.Time
+	Width       int
+	Height      int
+	GPS         *GPS
+}
+
+// Read extracts the EXIF data of a JPEG or TIFF file.
+// Width and Height fall back to the decoded image size when the EXIF data does not contain them.
+func Read(path string) (*Exif, error) {
+	data, err := readHead(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Exif{Orientation: 1}
+
+	// EXIFが壊れている場合でも画像の大きさは取得する
+	if tiff, ok := findTIFF(data); ok {
+		_ = result.parse(tiff)
+	}
+
+	if result.Width == 0 || result.Height == 0 {
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err == nil {
+			result.Width = config.Width
+			result.Height = config.Height
+		}
+	}
+
+	return result, nil
+}
+
+// JPEGのEXIFは先頭64KB以内のAPP1に収まるため、巨大なファイル全体は読まない。
+// TIFFは値がファイル中のどこにでも置かれ得るため全体を読む
+const headSize = 1 << 20
+
+func readHead(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, headSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if isTIFF(data) && len(data) == headSize {
+		return os.ReadFile(path)
+	}
+
+	return data, nil
+}
+
+// DisplaySize returns the size after applying the orientation
+func (e *Exif) DisplaySize() (int, int) {
+	// 5〜8は90度回転しているため縦横が入れ替わる
+	if e.Orientation >= 5 && e.Orientation <= 8 {
+		return e.Height, e.Width
+	}
+
+	return e.Width, e.Height
+}
+
+// findTIFF returns the TIFF structure that holds the EXIF data of a JPEG (APP1) or TIFF file
+func findTIFF(data []byte) ([]byte, bool) {
+	if isTIFF(data) {
+		return data, true
+	}
+
+	segment, _, ok := findExifSegment(data)
+	if !ok {
+		return nil, false
+	}
+
+	return segment, true
+}
+
+func isTIFF(data []byte) bool {
+	return len(data) >= 8 && (bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*")))
+}
+
+// findExifSegment returns the TIFF payload of the EXIF APP1 segment and its offset in the JPEG
+func findExifSegment(data []byte) ([]byte, int, bool) {
+	found := false
+	var payload []byte
+	var offset int
+
+	walkJPEG(data, func(marker byte, start int, end int) bool {
+		body := data[start:end]
+		if marker == 0xE1 && bytes.HasPrefix(body, []byte("Exif\x00\x00")) {
+			payload = body[6:]
+			offset = start + 6
+			found = true
+			return false
+		}
+		return true
+	})
+
+	return payload, offset, found
+}
+
+// walkJPEG calls fn with the marker and the body range of every segment before the image data
+func walkJPEG(data []byte, fn func(marker byte, start int, end int) bool) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return -1
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return -1
+		}
+
+		marker := data[pos+1]
+		// SOS以降は画像データなので走査を終える
+		if marker == 0xDA {
+			return pos
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if length < 2 || pos+2+length > len(data) {
+			return -1
+		}
+
+		if !fn(marker, pos+4, pos+2+length) {
+			return pos
+		}
+
+		pos += 2 + length
+	}
+
+	return -1
+}
+
+type ifdEntry struct {
+	tag         uint16
+	typ         uint16
+	count       uint32
+	valueOffset uint32
+	size        uint32
+}
+
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+func newTIFFReader(data []byte) (*tiffReader, error) {
+	if len(data) < 8 {
+		return nil, ErrNoExif
+	}
+
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, ErrNoExif
+	}
+
+	return &tiffReader{data: data, order: order}, nil
+}
+
+func (r *tiffReader) firstIFD() uint32 {
+	return r.order.Uint32(r.data[4:8])
+}
+
+func (r *tiffReader) entries(offset uint32) ([]ifdEntry, error) {
+	if offset == 0 || int(offset)+2 > len(r.data) {
+		return nil, ErrNoExif
+	}
+
+	count := uint32(r.order.Uint16(r.data[offset : offset+2]))
+	if int(offset)+2+int(count)*12 > len(r.data) {
+		return nil, ErrNoExif
+	}
+
+	entries := make([]ifdEntry, 0, count)
+	for i := range count {
+		pos := offset + 2 + i*12
+		entry := ifdEntry{
+			tag:   r.order.Uint16(r.data[pos : pos+2]),
+			typ:   r.order.Uint16(r.data[pos+2 : pos+4]),
+			count: r.order.Uint32(r.data[pos+4 : pos+8]),
+		}
+
+		typeSize, ok := typeSizes[entry.typ]
+		if !ok || entry.count > math.MaxUint32/typeSize {
+			continue
+		}
+		entry.size = typeSize * entry.count
+
+		// 4バイト以下の値はエントリ内に直接格納される
+		if entry.size <= 4 {
+			entry.valueOffset = pos + 8
+		} else {
+			entry.valueOffset = r.order.Uint32(r.data[pos+8 : pos+12])
+		}
+
+		if uint64(entry.valueOffset)+uint64(entry.size) > uint64(len(r.data)) {
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (r *tiffReader) value(entry ifdEntry) []byte {
+	return r.data[entry.valueOffset : entry.valueOffset+entry.size]
+}
+
+func (r *tiffReader) string(entry ifdEntry) string {
+	return strings.TrimSpace(strings.TrimRight(string(r.value(entry)), "\x00"))
+}
+
+func (r *tiffReader) uint(entry ifdEntry) int {
+	value := r.value(entry)
+	if len(value) == 0 {
+		return 0
+	}
+
+	switch entry.typ {
+	case 1, 7:
+		return int(value[0])
+	case 3:
+		return int(r.order.Uint16(value))
+	case 4:
+		return int(r.order.Uint32(value))
+	}
+
+	return 0
+}
+
+func (r *tiffReader) rationals(entry ifdEntry) []float64 {
+	if entry.typ != 5 {
+		return nil
+	}
+
+	value := r.value(entry)
+	rationals := make([]float64, 0, entry.count)
+	for i := uint32(0); i < entry.count; i++ {
+		numerator := r.order.Uint32(value[i*8 : i*8+4])
+		denominator := r.order.Uint32(value[i*8+4 : i*8+8])
+		if denominator == 0 {
+			rationals = append(rationals, 0)
+			continue
+		}
+		rationals = append(rationals, float64(numerator)/float64(denominator))
+	}
+
+	return rationals
+}
+
+func (e *Exif) parse(tiff []byte) error {
+	r, err := newTIFFReader(tiff)
+	if err != nil {
+		return err
+	}
+
+	ifd0, err := r.entries(r.firstIFD())
+	if err != nil {
+		return err
+	}
+
+	var dateTime, dateTimeOriginal, offsetTimeOriginal string
+
+	for _, entry := range ifd0 {
+		switch entry.tag {
+		case tagMake:
+			e.Make = r.string(entry)
+		case tagModel:
+			e.Model = r.string(entry)
+		case tagOrientation:
+			if orientation := r.uint(entry); orientation >= 1 && orientation <= 8 {
+				e.Orientation = orientation
+			}
+		case tagDateTime:
+			dateTime = r.string(entry)
+		case tagExifIFD:
+			exifIFD, err := r.entries(uint32(r.uint(entry)))
+			if err != nil {
+				continue
+			}
+
+			for _, exifEntry := range exifIFD {
+				switch exifEntry.tag {
+				case tagDateTimeOriginal:
+					dateTimeOriginal = r.string(exifEntry)
+				case tagOffsetTimeOriginal:
+					offsetTimeOriginal = r.string(exifEntry)
+				case tagPixelXDimension:
+					e.Width = r.uint(exifEntry)
+				case tagPixelYDimension:
+					e.Height = r.uint(exifEntry)
+				case tagLensModel:
+					e.LensModel = r.string(exifEntry)
+				}
+			}
+		case tagGPSIFD:
+			gpsIFD, err := r.entries(uint32(r.uint(entry)))
+			if err != nil {
+				continue
+			}
+
+			e.GPS = parseGPS(r, gpsIFD)
+		}
+	}
+
+	// 撮影日時が無い場合は更新日時で代用する
+	if dateTimeOriginal == "" {
+		dateTimeOriginal = dateTime
+	}
+	e.TakenAt = parseDateTime(dateTimeOriginal, offsetTimeOriginal)
+
+	return nil
+}
+
+func parseGPS(r *tiffReader, entries []ifdEntry) *GPS {
+	var latitudeRef, longitudeRef string
+	var latitude, longitude, altitude []float64
+	altitudeBelowSeaLevel := false
+
+	for _, entry := range entries {
+		switch entry.tag {
+		case tagGPSLatitudeRef:
+			latitudeRef = r.string(entry)
+		case tagGPSLatitude:
+			latitude = r.rationals(entry)
+		case tagGPSLongitudeRef:
+			longitudeRef = r.string(entry)
+		case tagGPSLongitude:
+			longitude = r.rationals(entry)
+		case tagGPSAltitudeRef:
+			altitudeBelowSeaLevel = r.uint(entry) == 1
+		case tagGPSAltitude:
+			altitude = r.rationals(entry)
+		}
+	}
+
+	if len(latitude) != 3 || len(longitude) != 3 {
+		return nil
+	}
+
+	gps := &GPS{
+		Latitude:  latitude[0] + latitude[1]/60 + latitude[2]/3600,
+		Longitude: longitude[0] + longitude[1]/60 + longitude[2]/3600,
+	}
+	if latitudeRef == "S" {
+		gps.Latitude = -gps.Latitude
+	}
+	if longitudeRef == "W" {
+		gps.Longitude = -gps.Longitude
+	}
+	if len(altitude) == 1 {
+		value := altitude[0]
+		if altitudeBelowSeaLevel {
+			value = -value
+		}
+		gps.Altitude = &value
+	}
+
+	return gps
+}
+
+// parseDateTime parses "2006:01:02 15:04:05". Without an offset the time is treated as UTC.
+func parseDateTime(value string, offset string) *time.Time {
+	if value == "" {
+		return nil
+	}
+
+	location := time.UTC
+	if offset != "" {
+		if t, err := time.Parse("-07:00", offset); err == nil {
+			location = t.Location()
+		}
+	}
+
+	t, err := time.ParseInLocation("2006:01:02 15:04:05", value, location)
+	if err != nil {
+		return nil
+	}
+
+	return &t
+}
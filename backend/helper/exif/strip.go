@@ -0,0 +1,133 @@
+package exif
+
+import (
+	"bytes"
+	"os"
+)
+
+// XMPのAPP1セグメントの識別子
+var xmpHeader = []byte("http://ns.adobe.com/xap/1.0/\x00")
+
+// StripLocation writes a copy of the JPEG or TIFF file without GPS data to outputPath.
+// The GPS IFD and its values are zeroed in place so that the other metadata and the image data are kept as is,
+// and XMP packets containing GPS data are removed.
+// It returns false without writing anything when the file has no location data.
+func StripLocation(inputPath string, outputPath string) (bool, error) {
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return false, err
+	}
+
+	var stripped []byte
+	var changed bool
+
+	if isTIFF(data) {
+		stripped = bytes.Clone(data)
+		changed = zeroGPS(stripped)
+	} else {
+		stripped, changed = stripJPEG(data)
+	}
+
+	if !changed {
+		return false, nil
+	}
+
+	if err := os.WriteFile(outputPath, stripped, 0644); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// HasLocation reports whether the JPEG or TIFF file contains GPS data in EXIF or XMP
+func HasLocation(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	if isTIFF(data) {
+		return zeroGPS(bytes.Clone(data)), nil
+	}
+
+	_, changed := stripJPEG(data)
+
+	return changed, nil
+}
+
+func stripJPEG(data []byte) ([]byte, bool) {
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:min(2, len(data))]...)
+	changed := false
+	last := 2
+
+	end := walkJPEG(data, func(marker byte, start int, segmentEnd int) bool {
+		segment := bytes.Clone(data[start-4 : segmentEnd])
+		body := segment[4:]
+
+		if marker == 0xE1 {
+			switch {
+			case bytes.HasPrefix(body, []byte("Exif\x00\x00")):
+				if zeroGPS(body[6:]) {
+					changed = true
+				}
+			case bytes.HasPrefix(body, xmpHeader) && bytes.Contains(body, []byte("GPS")):
+				// 位置情報を含むXMPはセグメントごと取り除く
+				changed = true
+				last = segmentEnd
+				return true
+			}
+		}
+
+		out = append(out, segment...)
+		last = segmentEnd
+		return true
+	})
+	if end < 0 {
+		return data, false
+	}
+
+	out = append(out, data[last:]...)
+
+	return out, changed
+}
+
+// zeroGPS clears the GPS IFD of the TIFF structure and reports whether there was any GPS entry
+func zeroGPS(tiff []byte) bool {
+	r, err := newTIFFReader(tiff)
+	if err != nil {
+		return false
+	}
+
+	ifd0, err := r.entries(r.firstIFD())
+	if err != nil {
+		return false
+	}
+
+	for _, entry := range ifd0 {
+		if entry.tag != tagGPSIFD {
+			continue
+		}
+
+		gpsOffset := uint32(r.uint(entry))
+		gpsEntries, err := r.entries(gpsOffset)
+		if err != nil || len(gpsEntries) == 0 {
+			return false
+		}
+
+		// エントリ外に格納された値を先に消す
+		for _, gpsEntry := range gpsEntries {
+			if gpsEntry.size > 4 {
+				clear(tiff[gpsEntry.valueOffset : gpsEntry.valueOffset+gpsEntry.size])
+			}
+		}
+
+		count := uint32(r.order.Uint16(tiff[gpsOffset : gpsOffset+2]))
+		// 件数・エントリ・次のIFDへのオフセットを全て0にして空のIFDにする
+		clear(tiff[gpsOffset : gpsOffset+2+count*12+min(4, uint32(len(tiff))-(gpsOffset+2+count*12))])
+
+		return true
+	}
+
+	return false
+}
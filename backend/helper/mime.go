@@ -0,0 +1,35 @@
+package helper
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ファイルの先頭512バイトからMIMEタイプを判定し、判定できない場合は拡張子から推測する
+func DetectMimeType(path string) string {
+	sniffed := "application/octet-stream"
+
+	if f, err := os.Open(path); err == nil {
+		defer f.Close()
+
+		buf := make([]byte, 512)
+		n, err := io.ReadFull(f, buf)
+		if err == nil || err == io.ErrUnexpectedEOF {
+			sniffed = http.DetectContentType(buf[:n])
+		}
+	}
+
+	if sniffed != "application/octet-stream" {
+		return sniffed
+	}
+
+	if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(path))); byExt != "" {
+		return byExt
+	}
+
+	return sniffed
+}
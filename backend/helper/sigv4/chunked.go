@@ -0,0 +1,172 @@
+package sigv4
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidChunk          = errors.New("invalid aws-chunked encoding")
+	ErrChunkSignatureInvalid = errors.New("chunk signature does not match")
+)
+
+// チャンク1つの上限。SDKは64KiB程度で送る
+const maxChunkSize = 16 * 1024 * 1024
+
+// ChunkedReader decodes an aws-chunked body.
+// When signingKey is set, the signature of each chunk is verified against the previous one, starting from the seed signature.
+type ChunkedReader struct {
+	r          *bufio.Reader
+	signingKey []byte
+	signature  Signature
+	previous   string
+
+	chunk    []byte
+	offset   int
+	done     bool
+	trailers map[string]string
+}
+
+func NewChunkedReader(r io.Reader, signingKey []byte, seed Signature) *ChunkedReader {
+	return &ChunkedReader{
+		r:          bufio.NewReaderSize(r, 64*1024),
+		signingKey: signingKey,
+		signature:  seed,
+		previous:   seed.Signature,
+		trailers:   map[string]string{},
+	}
+}
+
+// 本文の末尾に付いていたヘッダ(x-amz-checksum-* など)。本文を読み終えた後に参照する
+func (reader *ChunkedReader) Trailers() map[string]string {
+	return reader.trailers
+}
+
+func (reader *ChunkedReader) Read(p []byte) (int, error) {
+	for reader.offset >= len(reader.chunk) {
+		if reader.done {
+			return 0, io.EOF
+		}
+		if err := reader.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, reader.chunk[reader.offset:])
+	reader.offset += n
+
+	return n, nil
+}
+
+func (reader *ChunkedReader) next() error {
+	line, err := reader.readLine()
+	if err != nil {
+		return err
+	}
+
+	sizeHex, extension, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeHex), 16, 64)
+	if err != nil || size < 0 || size > maxChunkSize {
+		return ErrInvalidChunk
+	}
+
+	chunk := make([]byte, size)
+	if _, err := io.ReadFull(reader.r, chunk); err != nil {
+		return ErrInvalidChunk
+	}
+
+	if reader.signingKey != nil {
+		chunkSignature, ok := strings.CutPrefix(extension, "chunk-signature=")
+		if !ok || !Equal(reader.chunkSignature(chunk), chunkSignature) {
+			return ErrChunkSignatureInvalid
+		}
+		reader.previous = chunkSignature
+	}
+
+	if size == 0 {
+		reader.done = true
+		return reader.readTrailers()
+	}
+
+	if crlf, err := reader.readLine(); err != nil || crlf != "" {
+		return ErrInvalidChunk
+	}
+
+	reader.chunk = chunk
+	reader.offset = 0
+
+	return nil
+}
+
+func (reader *ChunkedReader) chunkSignature(chunk []byte) string {
+	stringToSign := strings.Join([]string{
+		Algorithm + "-PAYLOAD",
+		reader.signature.Time.UTC().Format(TimeFormat),
+		reader.signature.Credential.Scope(),
+		reader.previous,
+		EmptyPayloadHash,
+		HashHex(chunk),
+	}, "\n")
+
+	return hex.EncodeToString(hmacSHA256(reader.signingKey, stringToSign))
+}
+
+// 最後のチャンクの後のトレーラーを空行まで読む。署名付きの場合はトレーラーの署名も検証する
+func (reader *ChunkedReader) readTrailers() error {
+	canonical := ""
+	for {
+		line, err := reader.readLine()
+		if err == io.EOF || (err == nil && line == "") {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return ErrInvalidChunk
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		reader.trailers[key] = value
+
+		if key != "x-amz-trailer-signature" {
+			canonical += key + ":" + value + "\n"
+		}
+	}
+
+	if reader.signingKey == nil || canonical == "" {
+		return nil
+	}
+
+	stringToSign := strings.Join([]string{
+		Algorithm + "-TRAILER",
+		reader.signature.Time.UTC().Format(TimeFormat),
+		reader.signature.Credential.Scope(),
+		reader.previous,
+		HashHex([]byte(canonical)),
+	}, "\n")
+	if !Equal(hex.EncodeToString(hmacSHA256(reader.signingKey, stringToSign)), reader.trailers["x-amz-trailer-signature"]) {
+		return ErrChunkSignatureInvalid
+	}
+
+	return nil
+}
+
+func (reader *ChunkedReader) readLine() (string, error) {
+	line, err := reader.r.ReadSlice('\n')
+	if err == io.EOF && len(line) == 0 {
+		return "", io.EOF
+	}
+	if err != nil {
+		return "", ErrInvalidChunk
+	}
+
+	return string(bytes.TrimRight(line, "\r\n")), nil
+}
@@ -0,0 +1,278 @@
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	Algorithm = "AWS4-HMAC-SHA256"
+	// 日時の形式(ISO 8601の基本形式)
+	TimeFormat = "20060102T150405Z"
+	DateFormat = "20060102"
+
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// aws-chunked形式で、チャンクごとに署名された本文
+	StreamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	// aws-chunked形式で、末尾にチェックサムが付く本文
+	StreamingPayloadTrailer         = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	StreamingUnsignedPayloadTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+	// 署名付きURLの有効期間の上限
+	MaxPresignExpires = 7 * 24 * time.Hour
+	// 署名の日時と現在時刻のずれの許容範囲
+	MaxClockSkew = 15 * time.Minute
+)
+
+// 空の本文のSHA-256
+var EmptyPayloadHash = HashHex(nil)
+
+type Credential struct {
+	AccessKeyID string
+	Date        string
+	Region      string
+	Service     string
+}
+
+func (c Credential) Scope() string {
+	return strings.Join([]string{c.Date, c.Region, c.Service, "aws4_request"}, "/")
+}
+
+// Authorization ヘッダまたは署名付きURLのクエリから取り出した署名の情報
+type Signature struct {
+	Credential    Credential
+	SignedHeaders []string
+	Signature     string
+	Time          time.Time
+	// 署名付きURLの場合のみ設定される
+	Expires   time.Duration
+	Presigned bool
+}
+
+type ParseError struct {
+	Message string
+}
+
+func (e ParseError) Error() string {
+	return e.Message
+}
+
+// Request is the part of an HTTP request covered by the signature.
+type Request struct {
+	Method string
+	// 送信されたままのエスケープされたパス
+	RawPath     string
+	RawQuery    string
+	Header      func(name string) string
+	PayloadHash string
+}
+
+func parseCredential(value string) (Credential, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) != 5 || parts[4] != "aws4_request" {
+		return Credential{}, ParseError{Message: "invalid credential scope"}
+	}
+
+	return Credential{AccessKeyID: parts[0], Date: parts[1], Region: parts[2], Service: parts[3]}, nil
+}
+
+// ParseAuthorization parses "AWS4-HMAC-SHA256 Credential=..., SignedHeaders=..., Signature=...".
+func ParseAuthorization(authorization string, amzDate string) (*Signature, error) {
+	algorithm, rest, ok := strings.Cut(authorization, " ")
+	if !ok || algorithm != Algorithm {
+		return nil, ParseError{Message: "unsupported authorization algorithm"}
+	}
+
+	fields := map[string]string{}
+	for _, field := range strings.Split(rest, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return nil, ParseError{Message: "invalid authorization header"}
+		}
+		fields[key] = value
+	}
+
+	credential, err := parseCredential(fields["Credential"])
+	if err != nil {
+		return nil, err
+	}
+
+	signedAt, err := time.Parse(TimeFormat, amzDate)
+	if err != nil {
+		return nil, ParseError{Message: "invalid x-amz-date"}
+	}
+
+	if fields["SignedHeaders"] == "" || fields["Signature"] == "" {
+		return nil, ParseError{Message: "invalid authorization header"}
+	}
+
+	return &Signature{
+		Credential:    credential,
+		SignedHeaders: strings.Split(fields["SignedHeaders"], ";"),
+		Signature:     fields["Signature"],
+		Time:          signedAt,
+	}, nil
+}
+
+// ParsePresigned parses the X-Amz-* query parameters of a presigned URL.
+func ParsePresigned(query url.Values) (*Signature, error) {
+	if query.Get("X-Amz-Algorithm") != Algorithm {
+		return nil, ParseError{Message: "unsupported X-Amz-Algorithm"}
+	}
+
+	credential, err := parseCredential(query.Get("X-Amz-Credential"))
+	if err != nil {
+		return nil, err
+	}
+
+	signedAt, err := time.Parse(TimeFormat, query.Get("X-Amz-Date"))
+	if err != nil {
+		return nil, ParseError{Message: "invalid X-Amz-Date"}
+	}
+
+	expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || expires <= 0 || time.Duration(expires)*time.Second > MaxPresignExpires {
+		return nil, ParseError{Message: "invalid X-Amz-Expires"}
+	}
+
+	if query.Get("X-Amz-SignedHeaders") == "" || query.Get("X-Amz-Signature") == "" {
+		return nil, ParseError{Message: "invalid presigned url"}
+	}
+
+	return &Signature{
+		Credential:    credential,
+		SignedHeaders: strings.Split(query.Get("X-Amz-SignedHeaders"), ";"),
+		Signature:     query.Get("X-Amz-Signature"),
+		Time:          signedAt,
+		Expires:       time.Duration(expires) * time.Second,
+		Presigned:     true,
+	}, nil
+}
+
+// 署名に使う鍵を導出する
+func SigningKey(secretAccessKey string, credential Credential) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), credential.Date)
+	key = hmacSHA256(key, credential.Region)
+	key = hmacSHA256(key, credential.Service)
+
+	return hmacSHA256(key, "aws4_request")
+}
+
+// Sign computes the signature of the request with the signing key.
+func Sign(signingKey []byte, signature Signature, req Request) string {
+	stringToSign := strings.Join([]string{
+		Algorithm,
+		signature.Time.UTC().Format(TimeFormat),
+		signature.Credential.Scope(),
+		HashHex([]byte(CanonicalRequest(signature, req))),
+	}, "\n")
+
+	return hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+}
+
+func CanonicalRequest(signature Signature, req Request) string {
+	headers := make([]string, 0, len(signature.SignedHeaders))
+	for _, name := range signature.SignedHeaders {
+		headers = append(headers, name+":"+normalizeHeaderValue(req.Header(name))+"\n")
+	}
+
+	return strings.Join([]string{
+		req.Method,
+		canonicalPath(req.RawPath),
+		canonicalQuery(req.RawQuery, signature.Presigned),
+		strings.Join(headers, ""),
+		strings.Join(signature.SignedHeaders, ";"),
+		req.PayloadHash,
+	}, "\n")
+}
+
+// S3ではパスを正規化せず、送信されたエスケープのままで署名する
+func canonicalPath(rawPath string) string {
+	if rawPath == "" {
+		return "/"
+	}
+
+	return rawPath
+}
+
+func canonicalQuery(rawQuery string, presigned bool) string {
+	type pair struct{ key, value string }
+	pairs := []pair{}
+
+	for _, part := range strings.Split(rawQuery, "&") {
+		if part == "" {
+			continue
+		}
+
+		key, value, _ := strings.Cut(part, "=")
+		key, _ = url.QueryUnescape(key)
+		value, _ = url.QueryUnescape(value)
+		if presigned && key == "X-Amz-Signature" {
+			continue
+		}
+
+		pairs = append(pairs, pair{Encode(key, true), Encode(value, true)})
+	}
+
+	slices.SortFunc(pairs, func(a, b pair) int {
+		if c := strings.Compare(a.key, b.key); c != 0 {
+			return c
+		}
+		return strings.Compare(a.value, b.value)
+	})
+
+	encoded := make([]string, 0, len(pairs))
+	for _, p := range pairs {
+		encoded = append(encoded, p.key+"="+p.value)
+	}
+
+	return strings.Join(encoded, "&")
+}
+
+// 前後の空白を除き、連続する空白を1つにまとめる
+func normalizeHeaderValue(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// Encode escapes everything except the unreserved characters of RFC 3986. The slash is kept unless encodeSlash is true.
+func Encode(value string, encodeSlash bool) string {
+	var builder strings.Builder
+
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			builder.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			builder.WriteByte(b)
+		default:
+			fmt.Fprintf(&builder, "%%%02X", b)
+		}
+	}
+
+	return builder.String()
+}
+
+func HashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
+
+// 署名を定数時間で比較する
+func Equal(a string, b string) bool {
+	return hmac.Equal([]byte(a), []byte(b))
+}
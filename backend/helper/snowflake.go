@@ -6,3 +6,18 @@ func GenerateSnowflake() (*string, error) {
 
 	return &result, nil
 }
+
+// 数字のみのIDかを返す。ファイル名やパスに使う前に確認する
+func IsSnowflake(id string) bool {
+	if id == "" {
+		return false
+	}
+
+	for _, c := range id {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,16 @@
+package helper
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
+// 推測できないURLセーフなランダム文字列を返す
+func GenerateRandomToken(byteLength int) (string, error) {
+	buf := make([]byte, byteLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/apitoken"
+)
+
+type ApiToken struct {
+	ID          string         `db:"id"`
+	UserID      string         `db:"user_id"`
+	Name        string         `db:"name"`
+	Hint        string         `db:"hint"`
+	TokenHash   string         `db:"token_hash"`
+	Scopes      pq.StringArray `db:"scopes"`
+	DirectoryID *string        `db:"directory_id"`
+	ExpiresAt   *time.Time     `db:"expires_at"`
+	LastUsedAt  *time.Time     `db:"last_used_at"`
+	CreatedAt   time.Time      `db:"created_at"`
+	UpdatedAt   time.Time      `db:"updated_at"`
+}
+
+func (t *ApiToken) ToEntity() apitoken.ApiToken {
+	scopes := make([]apitoken.Scope, 0, len(t.Scopes))
+	for _, scope := range t.Scopes {
+		scopes = append(scopes, apitoken.Scope(scope))
+	}
+
+	return apitoken.ApiToken{
+		ID:          t.ID,
+		UserID:      t.UserID,
+		Name:        t.Name,
+		Hint:        t.Hint,
+		TokenHash:   t.TokenHash,
+		Scopes:      scopes,
+		DirectoryID: t.DirectoryID,
+		ExpiresAt:   t.ExpiresAt,
+		LastUsedAt:  t.LastUsedAt,
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+	}
+}
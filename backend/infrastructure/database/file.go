@@ -1,6 +1,7 @@
 package database
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
@@ -11,23 +12,52 @@ type File struct {
 	UserID            string  `db:"user_id"`
 	ParentDirectoryID *string `db:"parent_directory_id"`
 	// Embedding         *Vector   `db:"embedding"`
-	Kind      string    `db:"kind"`
-	Url       *string   `db:"url"`
-	Name      string    `db:"name"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
+	Kind                string     `db:"kind"`
+	Url                 *string    `db:"url"`
+	BlobID              *string    `db:"blob_id"`
+	Name                string     `db:"name"`
+	MimeType            *string    `db:"mime_type"`
+	Size                *int64     `db:"size"`
+	CompressionDisabled bool       `db:"compression_disabled"`
+	TakenAt             *time.Time `db:"taken_at"`
+	Width               *int       `db:"width"`
+	Height              *int       `db:"height"`
+	Metadata            []byte     `db:"metadata"`
+	CreatedAt           time.Time  `db:"created_at"`
+	UpdatedAt           time.Time  `db:"updated_at"`
 }
 
 func (f *File) ToEntity() file.File {
+	var metadata *file.Metadata
+	if len(f.Metadata) > 0 {
+		metadata = &file.Metadata{}
+		if err := json.Unmarshal(f.Metadata, metadata); err != nil {
+			metadata = nil
+		}
+	}
+
+	var size int64
+	if f.Size != nil {
+		size = *f.Size
+	}
+
 	return file.File{
 		ID:                f.ID,
 		UserID:            f.UserID,
 		ParentDirectoryID: f.ParentDirectoryID,
 		// Embedding:         (*vector.Vector)(f.Embedding),
-		Kind:      f.Kind,
-		Name:      f.Name,
-		Url:       f.Url,
-		CreatedAt: f.CreatedAt,
-		UpdatedAt: f.UpdatedAt,
+		Kind:                f.Kind,
+		Name:                f.Name,
+		Url:                 f.Url,
+		BlobID:              f.BlobID,
+		MimeType:            f.MimeType,
+		Size:                size,
+		CompressionDisabled: f.CompressionDisabled,
+		TakenAt:             f.TakenAt,
+		Width:               f.Width,
+		Height:              f.Height,
+		Metadata:            metadata,
+		CreatedAt:           f.CreatedAt,
+		UpdatedAt:           f.UpdatedAt,
 	}
 }
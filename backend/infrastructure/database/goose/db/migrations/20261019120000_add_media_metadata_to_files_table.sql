@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE files ADD COLUMN taken_at TIMESTAMP;
+ALTER TABLE files ADD COLUMN width INT;
+ALTER TABLE files ADD COLUMN height INT;
+ALTER TABLE files ADD COLUMN metadata JSONB;
+CREATE INDEX files_user_id_parent_directory_id_taken_at_index ON files (user_id, parent_directory_id, taken_at);
+ALTER TABLE users ADD COLUMN strip_location_metadata BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN strip_location_metadata;
+DROP INDEX files_user_id_parent_directory_id_taken_at_index;
+ALTER TABLE files DROP COLUMN metadata;
+ALTER TABLE files DROP COLUMN height;
+ALTER TABLE files DROP COLUMN width;
+ALTER TABLE files DROP COLUMN taken_at;
+-- +goose StatementEnd
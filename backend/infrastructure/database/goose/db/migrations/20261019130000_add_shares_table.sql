@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE shares (
+    id BIGINT NOT NULL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    file_id BIGINT NOT NULL,
+    token VARCHAR(64) NOT NULL UNIQUE,
+    permission VARCHAR(32) NOT NULL,
+    password_hash VARCHAR(255),
+    expires_at TIMESTAMP NOT NULL,
+    max_downloads INT,
+    download_count INT NOT NULL DEFAULT 0,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX shares_user_id_created_at_index ON shares (user_id, created_at DESC);
+CREATE INDEX shares_file_id_index ON shares (file_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE shares;
+-- +goose StatementEnd
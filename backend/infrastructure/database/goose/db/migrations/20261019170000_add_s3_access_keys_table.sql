@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- SigV4の署名の検証には秘密鍵そのものが必要なため、ハッシュ化せずに保存する
+CREATE TABLE s3_access_keys (
+    id BIGINT NOT NULL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    access_key_id VARCHAR(32) NOT NULL UNIQUE,
+    secret_access_key VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX s3_access_keys_user_id_index ON s3_access_keys (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE s3_access_keys;
+-- +goose StatementEnd
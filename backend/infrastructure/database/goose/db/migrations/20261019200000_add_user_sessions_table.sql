@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE user_sessions (
+    id BIGINT NOT NULL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    user_agent VARCHAR(512) NOT NULL,
+    ip_address VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
+CREATE INDEX user_sessions_user_id_index ON user_sessions (user_id);
+
+-- ログイン中のセッションは端末が不明なセッションとして引き継ぐ
+INSERT INTO user_sessions (id, user_id, user_agent, ip_address, expires_at)
+SELECT session_id::BIGINT, id, '', '', CURRENT_TIMESTAMP + INTERVAL '30 days'
+FROM users
+WHERE session_id IS NOT NULL AND session_id <> '';
+
+ALTER TABLE users DROP COLUMN session_id;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN session_id VARCHAR(19);
+DROP TABLE user_sessions;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Cookieのセッションの保存先(SESSION_STORE=postgresの場合に使う)
+CREATE TABLE sessions (
+    k VARCHAR(64) NOT NULL PRIMARY KEY,
+    v BYTEA NOT NULL,
+    e BIGINT NOT NULL DEFAULT 0
+);
+CREATE INDEX sessions_e_index ON sessions (e);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE sessions;
+-- +goose StatementEnd
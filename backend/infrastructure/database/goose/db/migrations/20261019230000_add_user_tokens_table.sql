@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+-- 既存のユーザーは確認済みとして扱う
+UPDATE users SET email_verified_at = created_at;
+
+-- パスワードの再設定・メールアドレスの確認に使う使い捨てのトークン。SHA-256のみを保存する
+CREATE TABLE user_tokens (
+    id BIGINT NOT NULL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    purpose VARCHAR(32) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX user_tokens_user_id_purpose_index ON user_tokens (user_id, purpose);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE user_tokens;
+ALTER TABLE users DROP COLUMN email_verified_at;
+-- +goose StatementEnd
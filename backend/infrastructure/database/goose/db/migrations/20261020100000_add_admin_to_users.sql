@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP;
+-- NULLの場合は storage_config.yaml の既定の容量になる
+ALTER TABLE users ADD COLUMN quota_bytes BIGINT;
+
+-- 管理者がなりすましている場合は管理者のID
+ALTER TABLE user_sessions ADD COLUMN impersonator_id BIGINT;
+
+-- 招待制の登録で使う招待コード。SHA-256のみを保存する
+CREATE TABLE user_invites (
+    id BIGINT NOT NULL PRIMARY KEY,
+    code_hash VARCHAR(64) NOT NULL UNIQUE,
+    email VARCHAR(255),
+    created_by BIGINT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE user_invites;
+ALTER TABLE user_sessions DROP COLUMN impersonator_id;
+ALTER TABLE users DROP COLUMN quota_bytes;
+ALTER TABLE users DROP COLUMN disabled_at;
+-- +goose StatementEnd
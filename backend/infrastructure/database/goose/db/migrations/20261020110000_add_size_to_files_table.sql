@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- NULLの場合は大きさを未計測。起動時に実体から計測する
+ALTER TABLE files ADD COLUMN size BIGINT;
+UPDATE files SET size = 0 WHERE kind = 'Directory' OR url IS NULL;
+
+-- ユーザーのFileKindごとの使用量。files の行を登録・更新・削除する際に増減させる
+CREATE TABLE user_usages (
+    user_id BIGINT NOT NULL,
+    kind VARCHAR(255) NOT NULL,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, kind)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE user_usages;
+ALTER TABLE files DROP COLUMN size;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- 自ストレージ上の実体のID。アップロードの処理のみが設定し、利用者が指定したURLからは設定しない
+ALTER TABLE files ADD COLUMN blob_id BIGINT;
+CREATE INDEX files_blob_id_index ON files (blob_id);
+-- 既存の行は未解決(-1)として、起動時に1度だけ BASE_URL のURLから設定する
+UPDATE files SET blob_id = -1 WHERE url IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX files_blob_id_index;
+ALTER TABLE files DROP COLUMN blob_id;
+-- +goose StatementEnd
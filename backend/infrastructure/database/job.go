@@ -0,0 +1,57 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/job"
+)
+
+type Job struct {
+	ID              string     `db:"id"`
+	UserID          string     `db:"user_id"`
+	Type            string     `db:"type"`
+	Status          string     `db:"status"`
+	Payload         []byte     `db:"payload"`
+	Result          []byte     `db:"result"`
+	Progress        []byte     `db:"progress"`
+	Attempts        int        `db:"attempts"`
+	MaxAttempts     int        `db:"max_attempts"`
+	LastError       *string    `db:"last_error"`
+	CancelRequested bool       `db:"cancel_requested"`
+	RunAt           time.Time  `db:"run_at"`
+	LockedAt        *time.Time `db:"locked_at"`
+	StartedAt       *time.Time `db:"started_at"`
+	FinishedAt      *time.Time `db:"finished_at"`
+	CreatedAt       time.Time  `db:"created_at"`
+	UpdatedAt       time.Time  `db:"updated_at"`
+}
+
+func (j *Job) ToEntity() job.Job {
+	var progress *job.Progress
+	if len(j.Progress) > 0 {
+		progress = &job.Progress{}
+		if err := json.Unmarshal(j.Progress, progress); err != nil {
+			progress = nil
+		}
+	}
+
+	return job.Job{
+		ID:              j.ID,
+		UserID:          j.UserID,
+		Type:            job.Type(j.Type),
+		Status:          job.Status(j.Status),
+		Payload:         json.RawMessage(j.Payload),
+		Result:          json.RawMessage(j.Result),
+		Progress:        progress,
+		Attempts:        j.Attempts,
+		MaxAttempts:     j.MaxAttempts,
+		LastError:       j.LastError,
+		CancelRequested: j.CancelRequested,
+		RunAt:           j.RunAt,
+		StartedAt:       j.StartedAt,
+		FinishedAt:      j.FinishedAt,
+		CreatedAt:       j.CreatedAt,
+		UpdatedAt:       j.UpdatedAt,
+	}
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"time"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/s3"
+)
+
+type S3AccessKey struct {
+	ID              string    `db:"id"`
+	UserID          string    `db:"user_id"`
+	AccessKeyID     string    `db:"access_key_id"`
+	SecretAccessKey string    `db:"secret_access_key"`
+	CreatedAt       time.Time `db:"created_at"`
+	UpdatedAt       time.Time `db:"updated_at"`
+}
+
+func (k *S3AccessKey) ToEntity() s3.AccessKey {
+	return s3.AccessKey{
+		ID:              k.ID,
+		UserID:          k.UserID,
+		AccessKeyID:     k.AccessKeyID,
+		SecretAccessKey: k.SecretAccessKey,
+		CreatedAt:       k.CreatedAt,
+		UpdatedAt:       k.UpdatedAt,
+	}
+}
+
+type S3MultipartUpload struct {
+	ID        string    `db:"id"`
+	UserID    string    `db:"user_id"`
+	Bucket    string    `db:"bucket"`
+	ObjectKey string    `db:"object_key"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func (u *S3MultipartUpload) ToEntity() s3.MultipartUpload {
+	return s3.MultipartUpload{
+		ID:        u.ID,
+		UserID:    u.UserID,
+		Bucket:    u.Bucket,
+		Key:       u.ObjectKey,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+	}
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"time"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/share"
+)
+
+type Share struct {
+	ID            string     `db:"id"`
+	UserID        string     `db:"user_id"`
+	FileID        string     `db:"file_id"`
+	Token         string     `db:"token"`
+	Permission    string     `db:"permission"`
+	PasswordHash  *string    `db:"password_hash"`
+	ExpiresAt     time.Time  `db:"expires_at"`
+	MaxDownloads  *int       `db:"max_downloads"`
+	DownloadCount int        `db:"download_count"`
+	RevokedAt     *time.Time `db:"revoked_at"`
+	CreatedAt     time.Time  `db:"created_at"`
+	UpdatedAt     time.Time  `db:"updated_at"`
+}
+
+func (s *Share) ToEntity() share.Share {
+	return share.Share{
+		ID:            s.ID,
+		UserID:        s.UserID,
+		FileID:        s.FileID,
+		Token:         s.Token,
+		Permission:    share.Permission(s.Permission),
+		PasswordHash:  s.PasswordHash,
+		HasPassword:   s.PasswordHash != nil,
+		ExpiresAt:     s.ExpiresAt,
+		MaxDownloads:  s.MaxDownloads,
+		DownloadCount: s.DownloadCount,
+		RevokedAt:     s.RevokedAt,
+		CreatedAt:     s.CreatedAt,
+		UpdatedAt:     s.UpdatedAt,
+	}
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"time"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/upload"
+)
+
+type Upload struct {
+	ID                string    `db:"id"`
+	UserID            string    `db:"user_id"`
+	ParentDirectoryID *string   `db:"parent_directory_id"`
+	Name              string    `db:"name"`
+	Length            int64     `db:"length"`
+	UploadOffset      int64     `db:"upload_offset"`
+	Metadata          string    `db:"metadata"`
+	LocalPath         string    `db:"local_path"`
+	FileID            *string   `db:"file_id"`
+	ExpiresAt         time.Time `db:"expires_at"`
+	CreatedAt         time.Time `db:"created_at"`
+	UpdatedAt         time.Time `db:"updated_at"`
+}
+
+func (u *Upload) ToEntity() upload.Upload {
+	return upload.Upload{
+		ID:                u.ID,
+		UserID:            u.UserID,
+		ParentDirectoryID: u.ParentDirectoryID,
+		Name:              u.Name,
+		Length:            u.Length,
+		Offset:            u.UploadOffset,
+		Metadata:          u.Metadata,
+		LocalPath:         u.LocalPath,
+		FileID:            u.FileID,
+		ExpiresAt:         u.ExpiresAt,
+		CreatedAt:         u.CreatedAt,
+		UpdatedAt:         u.UpdatedAt,
+	}
+}
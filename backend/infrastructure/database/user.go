@@ -1,27 +1,57 @@
 package database
 
 import (
+	"database/sql"
 	"time"
 
 	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
 )
 
 type User struct {
-	ID        string    `db:"id"`
-	Email     string    `db:"email"`
-	Password  string    `db:"password"`
-	SessionID *string   `db:"session_id"`
-	Token     *string   `db:"token"`
-	Icon      string    `db:"icon"`
-	CreatedAt time.Time `db:"created_at"`
+	ID                    string         `db:"id"`
+	Email                 string         `db:"email"`
+	Password              string         `db:"password"`
+	Icon                  string         `db:"icon"`
+	Role                  string         `db:"role"`
+	StripLocationMetadata bool           `db:"strip_location_metadata"`
+	TwoFactorEnabled      bool           `db:"two_factor_enabled"`
+	TotpSecret            sql.NullString `db:"totp_secret"`
+	TotpLastCounter       int64          `db:"totp_last_counter"`
+	EmailVerifiedAt       sql.NullTime   `db:"email_verified_at"`
+	DisabledAt            sql.NullTime   `db:"disabled_at"`
+	QuotaBytes            sql.NullInt64  `db:"quota_bytes"`
+	CreatedAt             time.Time      `db:"created_at"`
 }
 
 func (u *User) ToEntity() user.User {
+	var emailVerifiedAt *time.Time
+	if u.EmailVerifiedAt.Valid {
+		emailVerifiedAt = &u.EmailVerifiedAt.Time
+	}
+
+	var disabledAt *time.Time
+	if u.DisabledAt.Valid {
+		disabledAt = &u.DisabledAt.Time
+	}
+
+	var quotaBytes *int64
+	if u.QuotaBytes.Valid {
+		quotaBytes = &u.QuotaBytes.Int64
+	}
+
 	return user.User{
-		ID:        u.ID,
-		Email:     u.Email,
-		Password:  u.Password,
-		Icon:      u.Icon,
-		CreatedAt: u.CreatedAt,
+		ID:                    u.ID,
+		Email:                 u.Email,
+		Password:              u.Password,
+		Icon:                  u.Icon,
+		Role:                  user.Role(u.Role),
+		StripLocationMetadata: u.StripLocationMetadata,
+		TwoFactorEnabled:      u.TwoFactorEnabled,
+		TotpSecret:            u.TotpSecret.String,
+		TotpLastCounter:       u.TotpLastCounter,
+		EmailVerifiedAt:       emailVerifiedAt,
+		DisabledAt:            disabledAt,
+		QuotaBytes:            quotaBytes,
+		CreatedAt:             u.CreatedAt,
 	}
 }
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+)
+
+type UserInvite struct {
+	ID        string         `db:"id"`
+	CodeHash  string         `db:"code_hash"`
+	Email     sql.NullString `db:"email"`
+	CreatedBy string         `db:"created_by"`
+	ExpiresAt time.Time      `db:"expires_at"`
+	UsedAt    sql.NullTime   `db:"used_at"`
+	CreatedAt time.Time      `db:"created_at"`
+}
+
+func (i *UserInvite) ToEntity() user.Invite {
+	var email *string
+	if i.Email.Valid {
+		email = &i.Email.String
+	}
+
+	var usedAt *time.Time
+	if i.UsedAt.Valid {
+		usedAt = &i.UsedAt.Time
+	}
+
+	return user.Invite{
+		ID:        i.ID,
+		Email:     email,
+		CreatedBy: i.CreatedBy,
+		ExpiresAt: i.ExpiresAt,
+		UsedAt:    usedAt,
+		CreatedAt: i.CreatedAt,
+	}
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+)
+
+type UserSession struct {
+	ID             string         `db:"id"`
+	UserID         string         `db:"user_id"`
+	UserAgent      string         `db:"user_agent"`
+	IpAddress      string         `db:"ip_address"`
+	CreatedAt      time.Time      `db:"created_at"`
+	LastSeenAt     time.Time      `db:"last_seen_at"`
+	ExpiresAt      time.Time      `db:"expires_at"`
+	ImpersonatorID sql.NullString `db:"impersonator_id"`
+}
+
+func (s *UserSession) ToEntity() user.Session {
+	var impersonatorID *string
+	if s.ImpersonatorID.Valid {
+		impersonatorID = &s.ImpersonatorID.String
+	}
+
+	return user.Session{
+		ID:             s.ID,
+		UserID:         s.UserID,
+		UserAgent:      s.UserAgent,
+		IpAddress:      s.IpAddress,
+		CreatedAt:      s.CreatedAt,
+		LastSeenAt:     s.LastSeenAt,
+		ExpiresAt:      s.ExpiresAt,
+		ImpersonatorID: impersonatorID,
+	}
+}
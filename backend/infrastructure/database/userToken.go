@@ -0,0 +1,35 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+)
+
+type UserToken struct {
+	ID        string       `db:"id"`
+	UserID    string       `db:"user_id"`
+	Purpose   string       `db:"purpose"`
+	TokenHash string       `db:"token_hash"`
+	ExpiresAt time.Time    `db:"expires_at"`
+	UsedAt    sql.NullTime `db:"used_at"`
+	CreatedAt time.Time    `db:"created_at"`
+}
+
+func (t *UserToken) ToEntity() user.Token {
+	var usedAt *time.Time
+	if t.UsedAt.Valid {
+		usedAt = &t.UsedAt.Time
+	}
+
+	return user.Token{
+		ID:        t.ID,
+		UserID:    t.UserID,
+		Purpose:   user.TokenPurpose(t.Purpose),
+		TokenHash: t.TokenHash,
+		ExpiresAt: t.ExpiresAt,
+		UsedAt:    usedAt,
+		CreatedAt: t.CreatedAt,
+	}
+}
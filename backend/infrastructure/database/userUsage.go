@@ -0,0 +1,7 @@
+package database
+
+type UserUsage struct {
+	UserID string `db:"user_id"`
+	Kind   string `db:"kind"`
+	Bytes  int64  `db:"bytes"`
+}
@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+)
+
+// 開発用。送信せずに .eml ファイルとして保存し、本文をログに出力する
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (m *FileMailer) Send(message Message) error {
+	now := time.Now()
+
+	msg, err := buildMessage(m.From, message, now)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := os.MkdirAll(m.Dir, 0755); err != nil {
+		return errors.WithStack(err)
+	}
+
+	suffix, err := helper.GenerateRandomToken(6)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	path := filepath.Join(m.Dir, fmt.Sprintf("%s-%s.eml", now.Format("20060102150405"), suffix))
+	if err := os.WriteFile(path, msg, 0600); err != nil {
+		return errors.WithStack(err)
+	}
+
+	log.Printf("Mail to %s saved to %s\nSubject: %s\n\n%s", message.To, path, message.Subject, message.Body)
+
+	return nil
+}
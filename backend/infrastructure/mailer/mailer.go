@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"time"
+
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	// プレーンテキストの本文
+	Body string
+}
+
+// Mailer sends a message. SmtpMailer is used in production and FileMailer in development.
+type Mailer interface {
+	Send(message Message) error
+}
+
+// 日本語の件名・本文をそのまま送れるよう、MIMEでエンコードしたメールを組み立てる
+func buildMessage(from string, message Message, now time.Time) ([]byte, error) {
+	messageID, err := helper.GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", message.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", message.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@yappi_storage>\r\n", messageID)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+	buf.WriteString("\r\n")
+
+	// 1行76文字までに折り返す
+	body := base64.StdEncoding.EncodeToString([]byte(message.Body))
+	for len(body) > 76 {
+		buf.WriteString(body[:76] + "\r\n")
+		body = body[76:]
+	}
+	buf.WriteString(body + "\r\n")
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,36 @@
+package mailer
+
+import (
+	"net"
+	"net/smtp"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// MailHogのような認証の無いローカルのサーバーにも送れるよう、Usernameが空の場合は認証しない
+type SmtpMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SmtpMailer) Send(message Message) error {
+	msg, err := buildMessage(m.From, message, time.Now())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	if err := smtp.SendMail(net.JoinHostPort(m.Host, m.Port), auth, m.From, []string{message.To}, msg); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/apitoken"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/database"
+)
+
+type ApiTokenRepositoryInterface interface {
+	RegistrationApiToken(tx *sqlx.Tx, token apitoken.ApiToken) (*apitoken.ApiToken, error)
+	GetApiTokens(conn *sqlx.DB, user user.User) ([]apitoken.ApiToken, error)
+	GetApiTokenByHash(conn *sqlx.DB, tokenHash string) (*apitoken.ApiToken, error)
+	UpdateApiTokenLastUsedAt(conn *sqlx.DB, id string, lastUsedAt time.Time) error
+	DeleteApiToken(tx *sqlx.Tx, user user.User, id string) error
+}
+
+type ApiTokenRepository struct {
+}
+
+func (repo *ApiTokenRepository) RegistrationApiToken(tx *sqlx.Tx, token apitoken.ApiToken) (*apitoken.ApiToken, error) {
+	scopes := make(pq.StringArray, 0, len(token.Scopes))
+	for _, scope := range token.Scopes {
+		scopes = append(scopes, string(scope))
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO api_tokens
+			(
+				id,
+				user_id,
+				name,
+				hint,
+				token_hash,
+				scopes,
+				directory_id,
+				expires_at,
+				created_at,
+				updated_at
+			)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		token.ID,
+		token.UserID,
+		token.Name,
+		token.Hint,
+		token.TokenHash,
+		scopes,
+		token.DirectoryID,
+		token.ExpiresAt,
+		token.CreatedAt,
+		token.UpdatedAt,
+	)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return &token, nil
+}
+
+func (repo *ApiTokenRepository) GetApiTokens(conn *sqlx.DB, user user.User) ([]apitoken.ApiToken, error) {
+	rows, err := conn.Queryx("SELECT * FROM api_tokens WHERE user_id = $1 ORDER BY created_at DESC, id DESC", user.ID)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+	defer rows.Close()
+
+	tokens := make([]apitoken.ApiToken, 0)
+	for rows.Next() {
+		var t database.ApiToken
+		if err := rows.StructScan(&t); err != nil {
+			return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "スキャンエラー"}, err))
+		}
+
+		tokens = append(tokens, t.ToEntity())
+	}
+
+	return tokens, nil
+}
+
+func (repo *ApiTokenRepository) GetApiTokenByHash(conn *sqlx.DB, tokenHash string) (*apitoken.ApiToken, error) {
+	var result database.ApiToken
+	err := conn.QueryRowx("SELECT * FROM api_tokens WHERE token_hash = $1", tokenHash).StructScan(&result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.WithStack(NotFoundError{Code: 404, Message: "トークンが見つかりません。"})
+	}
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	t := result.ToEntity()
+
+	return &t, nil
+}
+
+func (repo *ApiTokenRepository) UpdateApiTokenLastUsedAt(conn *sqlx.DB, id string, lastUsedAt time.Time) error {
+	if _, err := conn.Exec("UPDATE api_tokens SET last_used_at = $1 WHERE id = $2", lastUsedAt, id); err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
+
+func (repo *ApiTokenRepository) DeleteApiToken(tx *sqlx.Tx, user user.User, id string) error {
+	result, err := tx.Exec("DELETE FROM api_tokens WHERE id = $1 AND user_id = $2", id, user.ID)
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+	if affected == 0 {
+		return errors.WithStack(NotFoundError{Code: 404, Message: "トークンが見つかりません。"})
+	}
+
+	return nil
+}
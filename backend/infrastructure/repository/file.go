@@ -2,11 +2,18 @@ package repository
 
 import (
 	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cockroachdb/errors"
@@ -14,6 +21,8 @@ import (
 	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
 	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
 	"github.com/YahiroRyo/yappi_storage/backend/domain/vector"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/video"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
 	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/database"
 	"github.com/go-redis/cache/v9"
 	yaml "github.com/goccy/go-yaml"
@@ -29,7 +38,7 @@ type UploadResult struct {
 
 type FileRepositoryInterface interface {
 	DeleteCache(userID string) error
-	GetFiles(db *sqlx.DB, user user.User, parentDirectoryId *string, currentPageCount int, pageSize int) (*file.PaginationFiles, error)
+	GetFiles(db *sqlx.DB, user user.User, parentDirectoryId *string, order file.Order, currentPageCount int, pageSize int) (*file.PaginationFiles, error)
 	GetFileByID(db *sqlx.DB, user user.User, id string) (*file.File, error)
 	SearchFiles(
 		db *sqlx.DB,
@@ -41,9 +50,34 @@ type FileRepositoryInterface interface {
 	RegistrationFile(tx *sqlx.Tx, user user.User, file file.File) (*file.File, error)
 	UploadFileChunk(file []byte, dirname string) (*UploadResult, error)
 	UpdateFile(tx *sqlx.Tx, user user.User, file file.File) (*file.File, error)
-	DeleteFile(tx *sqlx.Tx, user user.User, id string) error
+	DeleteFile(tx *sqlx.Tx, user user.User, id string) ([]string, error)
+	IsBlobReferenced(db *sqlx.DB, blobID string) (bool, error)
+	DeleteBlob(blobID string) error
 	GetStorageSetting() ([]string, error)
 	GetStoreStoragePath() (string, error)
+	FindBlobPath(blobID string) (string, error)
+	GetLocalPath(file file.File) (string, error)
+	GetDerivativeDir(localPath string) (string, error)
+	GetUrl(localPath string) string
+	UpdateBlobFiles(tx *sqlx.Tx, blobID string, url string, mimeType string, size int64) ([]string, error)
+	UpdateCompressionDisabled(tx *sqlx.Tx, user user.User, ids []string, compressionDisabled bool) error
+	IsCompressionDisabled(db *sqlx.DB, blobID string) (bool, error)
+	GetFileByBlobID(db *sqlx.DB, user user.User, blobID string) (*file.File, error)
+	GetContentHash(localPath string) (string, error)
+	SetContentHash(localPath string, contentHash string) error
+	IsDescendantFile(db *sqlx.DB, user user.User, ancestorID string, id string) (bool, error)
+	GetDescendantFiles(db *sqlx.DB, user user.User, id string) ([]file.File, error)
+	GetChildFiles(db *sqlx.DB, user user.User, parentDirectoryID *string) ([]file.File, error)
+	GetChildFileByName(db *sqlx.DB, user user.User, parentDirectoryID *string, name string) (*file.File, error)
+	UpdateFileContent(tx *sqlx.Tx, user user.User, file file.File) (*file.File, error)
+	GetFilesWithoutSize(db *sqlx.DB, limit int) ([]file.File, error)
+	UpdateFileSize(tx *sqlx.Tx, file file.File, size int64) error
+	RewriteLegacyStaticUrls(tx *sqlx.Tx) (int64, error)
+	ResolveLegacyBlobIDs(tx *sqlx.Tx) (int64, error)
+	HasLegacyBlobIDs(db *sqlx.DB) (bool, error)
+	GetVideoSetting() video.Setting
+	GetExtractionSetting() file.ExtractionSetting
+	GetImportSetting() file.ImportSetting
 }
 
 type FileRepository struct {
@@ -52,18 +86,53 @@ type FileRepository struct {
 }
 
 var storePaths []string
+var videoSetting video.Setting
+var extractionSetting file.ExtractionSetting
+var importSetting file.ImportSetting
 
 func init() {
-	storageConfigFile, err := os.ReadFile("./storage_config.yaml")
-	if err != nil {
-		log.Fatalf("error reading file: %v", errors.WithStack(err))
-	}
+	storageConfigFile := readStorageConfig()
 	storageConfig := make(map[string]interface{})
 	if err := yaml.Unmarshal(storageConfigFile, &storageConfig); err != nil {
 		log.Fatalf("error unmarshaling yaml: %v", errors.WithStack(err))
 	}
 
-	for _, mount := range storageConfig["mounts"].([]interface{}) {
+	// リストは既定値とマージされないよう、未指定の場合のみ既定値を使う
+	defaultVideoSetting := video.DefaultSetting()
+	videoConfig := struct {
+		Video video.Setting `yaml:"video"`
+	}{Video: defaultVideoSetting}
+	videoConfig.Video.HLS.Renditions = nil
+	videoConfig.Video.Profiles = nil
+	if err := yaml.Unmarshal(storageConfigFile, &videoConfig); err != nil {
+		log.Fatalf("error unmarshaling video config: %v", errors.WithStack(err))
+	}
+	videoSetting = videoConfig.Video
+	if len(videoSetting.HLS.Renditions) == 0 {
+		videoSetting.HLS.Renditions = defaultVideoSetting.HLS.Renditions
+	}
+	if len(videoSetting.Profiles) == 0 {
+		videoSetting.Profiles = defaultVideoSetting.Profiles
+	}
+
+	extractionConfig := struct {
+		Extraction file.ExtractionSetting `yaml:"extraction"`
+	}{Extraction: file.DefaultExtractionSetting()}
+	if err := yaml.Unmarshal(storageConfigFile, &extractionConfig); err != nil {
+		log.Fatalf("error unmarshaling extraction config: %v", errors.WithStack(err))
+	}
+	extractionSetting = extractionConfig.Extraction
+
+	importConfig := struct {
+		Import file.ImportSetting `yaml:"import"`
+	}{Import: file.DefaultImportSetting()}
+	if err := yaml.Unmarshal(storageConfigFile, &importConfig); err != nil {
+		log.Fatalf("error unmarshaling import config: %v", errors.WithStack(err))
+	}
+	importSetting = importConfig.Import
+
+	mounts, _ := storageConfig["mounts"].([]interface{})
+	for _, mount := range mounts {
 		mountMap := mount.(map[string]interface{})
 
 		if mountMap["dirname"] == nil {
@@ -109,11 +178,11 @@ func (repo *FileRepository) DeleteCache(userID string) error {
 	return nil
 }
 
-func (repo *FileRepository) GetFiles(db *sqlx.DB, user user.User, parentDirectoryId *string, currentPageCount int, pageSize int) (*file.PaginationFiles, error) {
+func (repo *FileRepository) GetFiles(db *sqlx.DB, user user.User, parentDirectoryId *string, order file.Order, currentPageCount int, pageSize int) (*file.PaginationFiles, error) {
 	var pagenationFiles file.PaginationFiles
 
 	err := repo.Cache.Once(&cache.Item{
-		Key:   fmt.Sprintf("files:%s:%s:%d:%d", user.ID, *parentDirectoryId, currentPageCount, pageSize),
+		Key:   fmt.Sprintf("files:%s:%s:%s:%d:%d", user.ID, *parentDirectoryId, order, currentPageCount, pageSize),
 		TTL:   time.Minute,
 		Value: &pagenationFiles,
 		Do: func(c *cache.Item) (interface{}, error) {
@@ -134,6 +203,16 @@ func (repo *FileRepository) GetFiles(db *sqlx.DB, user user.User, parentDirector
 				}
 
 				if isGeneratePaginationSql {
+					switch order {
+					case file.OrderCreatedAt:
+						q += `ORDER BY created_at DESC, id DESC `
+					case file.OrderTakenAt:
+						// 撮影日時が無いファイルは後ろに並べる
+						q += `ORDER BY taken_at DESC NULLS LAST, created_at DESC, id DESC `
+					case file.OrderName:
+						q += `ORDER BY name, id `
+					}
+
 					q += `LIMIT :page_size OFFSET :offset`
 				}
 
@@ -281,6 +360,17 @@ func (repo *FileRepository) SearchFiles(
 }
 
 func (repo *FileRepository) RegistrationFile(tx *sqlx.Tx, user user.User, file file.File) (*file.File, error) {
+	// JSONBには文字列として渡す
+	var metadata *string
+	if file.Metadata != nil {
+		encoded, err := json.Marshal(file.Metadata)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		encodedString := string(encoded)
+		metadata = &encodedString
+	}
+
 	_, err := tx.Exec(`
 		INSERT INTO files
 			(
@@ -290,23 +380,43 @@ func (repo *FileRepository) RegistrationFile(tx *sqlx.Tx, user user.User, file f
 				kind,
 				url,
 				name,
+				mime_type,
+				compression_disabled,
+				taken_at,
+				width,
+				height,
+				metadata,
+				size,
 				created_at,
-				updated_at
+				updated_at,
+				blob_id
 			)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`,
 		file.ID,
 		file.UserID,
 		file.ParentDirectoryID,
 		file.Kind,
 		file.Url,
 		file.Name,
+		file.MimeType,
+		file.CompressionDisabled,
+		file.TakenAt,
+		file.Width,
+		file.Height,
+		metadata,
+		file.Size,
 		file.CreatedAt,
 		file.UpdatedAt,
+		file.BlobID,
 	)
 	if err != nil {
 		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
 	}
 
+	if err := addUsage(tx, file.UserID, file.Kind, file.Size); err != nil {
+		return nil, err
+	}
+
 	return &file, nil
 }
 
@@ -323,15 +433,7 @@ func (repo *FileRepository) UploadFileChunk(file []byte, filename string) (*Uplo
 		return nil, err
 	}
 
-	// 開発環境では /static でアクセス、本番環境では /files/secure/ でアクセス
-	var url string
-	if os.Getenv("ENVIRONMENT") == "production" {
-		// 本番環境ではfileIDから拡張子を取り除いてIDを取得
-		fileID := filename[:len(filename)-len(filepath.Ext(filename))]
-		url = fmt.Sprintf("%s/files/secure/%s", os.Getenv("BASE_URL"), fileID)
-	} else {
-		url = fmt.Sprintf("%s/static/%s/%s", os.Getenv("BASE_URL"), storagePath, filename)
-	}
+	url := repo.GetUrl(fullPath)
 
 	defer f.Close()
 
@@ -351,17 +453,21 @@ func (repo *FileRepository) UploadFileChunk(file []byte, filename string) (*Uplo
 }
 
 func (repo *FileRepository) UpdateFile(tx *sqlx.Tx, user user.User, file file.File) (*file.File, error) {
-	_, err := tx.Exec(`
-		UPDATE files
+	// 名前の変更で種類が変わった場合は、使用量も移す
+	rows, err := tx.Queryx(`
+		UPDATE files f
 		SET
 			parent_directory_id = $1,
 			kind = $2,
 			url = $3,
 			name = $4,
 			updated_at = $5
+		FROM files old
 		WHERE
-			id = $6
-			AND user_id = $7`,
+			f.id = old.id
+			AND f.id = $6
+			AND f.user_id = $7
+		RETURNING old.user_id, old.kind, COALESCE(old.size, 0)`,
 		file.ParentDirectoryID,
 		file.Kind,
 		file.Url,
@@ -374,20 +480,98 @@ func (repo *FileRepository) UpdateFile(tx *sqlx.Tx, user user.User, file file.Fi
 		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
 	}
 
+	oldRows, err := scanFileSizes(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, old := range oldRows {
+		if old.Kind == file.Kind {
+			continue
+		}
+		if err := addUsage(tx, user.ID, old.Kind, -old.Size); err != nil {
+			return nil, err
+		}
+		if err := addUsage(tx, user.ID, file.Kind, old.Size); err != nil {
+			return nil, err
+		}
+	}
+
 	return &file, nil
 }
 
-func (repo *FileRepository) DeleteFile(tx *sqlx.Tx, user user.User, id string) error {
-	_, err := tx.Exec(`
+// 削除したファイルの実体のIDを返す。実体はコミットした後に、参照するファイルが無くなった場合のみ削除する
+func (repo *FileRepository) DeleteFile(tx *sqlx.Tx, user user.User, id string) ([]string, error) {
+	rows, err := tx.Queryx(`
 		DELETE FROM files
 		WHERE
 			id = $1
-			AND user_id = $2`,
+			AND user_id = $2
+		RETURNING kind, COALESCE(size, 0), blob_id`,
 		id,
 		user.ID,
 	)
 	if err != nil {
-		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+	defer rows.Close()
+
+	type deletedFile struct {
+		Kind   string
+		Size   int64
+		BlobID sql.NullInt64
+	}
+	deletedRows := []deletedFile{}
+	for rows.Next() {
+		var deleted deletedFile
+		if err := rows.Scan(&deleted.Kind, &deleted.Size, &deleted.BlobID); err != nil {
+			return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "スキャンエラー"}, err))
+		}
+		deletedRows = append(deletedRows, deleted)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	blobIDs := []string{}
+	for _, deleted := range deletedRows {
+		if err := addUsage(tx, user.ID, deleted.Kind, -deleted.Size); err != nil {
+			return nil, err
+		}
+		// 実体の無いディレクトリ・外部のURLと、実体を解決できなかったファイル(-1)は除く
+		if deleted.BlobID.Valid && deleted.BlobID.Int64 > 0 {
+			blobIDs = append(blobIDs, strconv.FormatInt(deleted.BlobID.Int64, 10))
+		}
+	}
+
+	return blobIDs, nil
+}
+
+// 実体を参照しているファイルがあるか
+func (repo *FileRepository) IsBlobReferenced(db *sqlx.DB, blobID string) (bool, error) {
+	var referenced bool
+	if err := db.Get(&referenced, "SELECT EXISTS (SELECT 1 FROM files WHERE blob_id = $1)", blobID); err != nil {
+		return false, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return referenced, nil
+}
+
+// 実体と派生ファイルを削除する。既に無い場合は何もしない
+func (repo *FileRepository) DeleteBlob(blobID string) error {
+	localPath, err := repo.FindBlobPath(blobID)
+	if errors.As(err, new(NotFoundError)) {
+		return nil
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := os.RemoveAll(strings.TrimSuffix(localPath, filepath.Ext(localPath)) + ".derivatives"); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.Remove(localPath); err != nil && !os.IsNotExist(err) {
+		return errors.WithStack(err)
 	}
 
 	return nil
@@ -430,3 +614,537 @@ func (repo *FileRepository) GetStoreStoragePath() (string, error) {
 
 	return result.Path, nil
 }
+
+// ストレージ上のファイル名(blobID + 拡張子)からローカルパスを探す
+func (repo *FileRepository) FindBlobPath(blobID string) (string, error) {
+	// globのパターンとして解釈されないよう、IDの形式のみ受け付ける
+	if !helper.IsSnowflake(blobID) {
+		return "", errors.WithStack(NotFoundError{Code: 404, Message: "ファイルが見つかりません。"})
+	}
+
+	for _, storePath := range storePaths {
+		matches, err := filepath.Glob(fmt.Sprintf("storage/files/%s/%s.*", storePath, blobID))
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil || info.IsDir() {
+				continue
+			}
+
+			return match, nil
+		}
+	}
+
+	return "", errors.WithStack(NotFoundError{Code: 404, Message: "ファイルが見つかりません。"})
+}
+
+// ファイルの実体のIDから自ストレージ上のパスを解決する。利用者が指定できるURLは使わない
+func (repo *FileRepository) GetLocalPath(file file.File) (string, error) {
+	if file.BlobID == nil {
+		return "", errors.WithStack(NotFoundError{Code: 404, Message: "ファイルが見つかりません。"})
+	}
+
+	return repo.FindBlobPath(*file.BlobID)
+}
+
+// 派生ファイル(サムネイル等)は実体と同じマウントの <blobID>.derivatives に保存する
+func (repo *FileRepository) GetDerivativeDir(localPath string) (string, error) {
+	dir := strings.TrimSuffix(localPath, filepath.Ext(localPath)) + ".derivatives"
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return dir, nil
+}
+
+// ローカルパスから配信用のURLを生成する
+func (repo *FileRepository) GetUrl(localPath string) string {
+	// 実体は全て認可を確認するハンドラから配信する。IDはストレージ上のファイル名から拡張子を除いたもの
+	filename := filepath.Base(localPath)
+	blobID := strings.TrimSuffix(filename, filepath.Ext(filename))
+
+	return fmt.Sprintf("%s/files/secure/%s", os.Getenv("BASE_URL"), blobID)
+}
+
+// 実体を差し替えた際に、その実体を参照している全てのファイルのURLと大きさを更新する
+func (repo *FileRepository) UpdateBlobFiles(tx *sqlx.Tx, blobID string, url string, mimeType string, size int64) ([]string, error) {
+	rows, err := tx.Queryx(`
+		UPDATE files f
+		SET
+			url = $1,
+			mime_type = $2,
+			size = $3,
+			updated_at = $4
+		FROM files old
+		WHERE
+			f.id = old.id
+			AND f.blob_id = $5
+		RETURNING
+			old.user_id, old.kind, COALESCE(old.size, 0)`,
+		url,
+		mimeType,
+		size,
+		time.Now(),
+		blobID,
+	)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	updatedRows, err := scanFileSizes(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := []string{}
+	for _, updated := range updatedRows {
+		if err := addUsage(tx, updated.UserID, updated.Kind, size-updated.Size); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, updated.UserID)
+	}
+
+	return userIDs, nil
+}
+
+func (repo *FileRepository) UpdateCompressionDisabled(tx *sqlx.Tx, user user.User, ids []string, compressionDisabled bool) error {
+	q, args, err := sqlx.In(`
+		UPDATE files
+		SET
+			compression_disabled = ?,
+			updated_at = ?
+		WHERE
+			id IN (?)
+			AND user_id = ?`,
+		compressionDisabled,
+		time.Now(),
+		ids,
+		user.ID,
+	)
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	if _, err := tx.Exec(tx.Rebind(q), args...); err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
+
+// 実体を参照しているファイルのいずれかで、自身または祖先のディレクトリが圧縮を無効にしているかを返す
+func (repo *FileRepository) IsCompressionDisabled(db *sqlx.DB, blobID string) (bool, error) {
+	var disabled bool
+	err := db.Get(&disabled, `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_directory_id, compression_disabled FROM files WHERE blob_id = $1
+			UNION
+			SELECT f.id, f.parent_directory_id, f.compression_disabled
+			FROM files f
+			INNER JOIN ancestors a ON f.id = a.parent_directory_id
+		)
+		SELECT COALESCE(BOOL_OR(compression_disabled), FALSE) FROM ancestors`,
+		blobID,
+	)
+	if err != nil {
+		return false, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return disabled, nil
+}
+
+// 同じ実体を参照する行が複数ある場合は最も新しいものを返す
+// ユーザーが所有する、実体を参照しているファイルのうち最新のもの
+func (repo *FileRepository) GetFileByBlobID(db *sqlx.DB, user user.User, blobID string) (*file.File, error) {
+	if !helper.IsSnowflake(blobID) {
+		return nil, errors.WithStack(NotFoundError{Code: 404, Message: "ファイルが見つかりません。"})
+	}
+
+	var result database.File
+	err := db.QueryRowx("SELECT * FROM files WHERE user_id = $1 AND blob_id = $2 ORDER BY created_at DESC, id DESC LIMIT 1", user.ID, blobID).StructScan(&result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.WithStack(NotFoundError{Code: 404, Message: "ファイルが見つかりません。"})
+	}
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	f := result.ToEntity()
+
+	return &f, nil
+}
+
+func contentHashCacheKey(localPath string, stat os.FileInfo) string {
+	return fmt.Sprintf("content_hash:%s:%d:%d", localPath, stat.Size(), stat.ModTime().UnixNano())
+}
+
+// 実体のSHA-256を返す。パス・大きさ・更新日時が同じ間は計算結果をキャッシュする
+func (repo *FileRepository) GetContentHash(localPath string) (string, error) {
+	stat, err := os.Stat(localPath)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	var contentHash string
+	err = repo.Cache.Once(&cache.Item{
+		Key:   contentHashCacheKey(localPath, stat),
+		TTL:   30 * 24 * time.Hour,
+		Value: &contentHash,
+		Do: func(c *cache.Item) (interface{}, error) {
+			f, err := os.Open(localPath)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			defer f.Close()
+
+			hash := sha256.New()
+			if _, err := io.Copy(hash, f); err != nil {
+				return nil, errors.WithStack(err)
+			}
+
+			return hex.EncodeToString(hash.Sum(nil)), nil
+		},
+	})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return contentHash, nil
+}
+
+// 書き込みながら計算したSHA-256をキャッシュし、配信時に読み直さないようにする
+func (repo *FileRepository) SetContentHash(localPath string, contentHash string) error {
+	stat, err := os.Stat(localPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := repo.Cache.Set(&cache.Item{
+		Key:   contentHashCacheKey(localPath, stat),
+		TTL:   30 * 24 * time.Hour,
+		Value: contentHash,
+	}); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// idのファイルがancestorIDのディレクトリ配下(自身を含む)にあるかを返す
+func (repo *FileRepository) IsDescendantFile(db *sqlx.DB, user user.User, ancestorID string, id string) (bool, error) {
+	var isDescendant bool
+	err := db.Get(&isDescendant, `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_directory_id FROM files WHERE id = $1 AND user_id = $2
+			UNION
+			SELECT f.id, f.parent_directory_id
+			FROM files f
+			INNER JOIN ancestors a ON f.id = a.parent_directory_id
+			WHERE f.user_id = $2
+		)
+		SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $3)`,
+		id,
+		user.ID,
+		ancestorID,
+	)
+	if err != nil {
+		return false, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return isDescendant, nil
+}
+
+// idのディレクトリ配下の全てのファイル・ディレクトリを返す
+func (repo *FileRepository) GetDescendantFiles(db *sqlx.DB, user user.User, id string) ([]file.File, error) {
+	rows, err := db.Queryx(`
+		WITH RECURSIVE descendants AS (
+			SELECT id FROM files WHERE parent_directory_id = $1 AND user_id = $2
+			UNION
+			SELECT f.id
+			FROM files f
+			INNER JOIN descendants d ON f.parent_directory_id = d.id
+			WHERE f.user_id = $2
+		)
+		SELECT * FROM files WHERE id IN (SELECT id FROM descendants)`,
+		id,
+		user.ID,
+	)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+	defer rows.Close()
+
+	files := make([]file.File, 0)
+	for rows.Next() {
+		var f database.File
+		if err := rows.StructScan(&f); err != nil {
+			return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "スキャンエラー"}, err))
+		}
+
+		files = append(files, f.ToEntity())
+	}
+
+	return files, nil
+}
+
+// ディレクトリ直下のファイル・ディレクトリを作成順に返す。parentDirectoryIDがnilの場合は最上位
+func (repo *FileRepository) GetChildFiles(db *sqlx.DB, user user.User, parentDirectoryID *string) ([]file.File, error) {
+	rows, err := db.Queryx(`
+		SELECT * FROM files
+		WHERE
+			user_id = $1
+			AND parent_directory_id IS NOT DISTINCT FROM $2
+		ORDER BY created_at, id`,
+		user.ID,
+		parentDirectoryID,
+	)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+	defer rows.Close()
+
+	files := make([]file.File, 0)
+	for rows.Next() {
+		var f database.File
+		if err := rows.StructScan(&f); err != nil {
+			return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "スキャンエラー"}, err))
+		}
+
+		files = append(files, f.ToEntity())
+	}
+
+	return files, nil
+}
+
+// 同じ名前のファイルが複数ある場合は最も古いものを返す
+func (repo *FileRepository) GetChildFileByName(db *sqlx.DB, user user.User, parentDirectoryID *string, name string) (*file.File, error) {
+	var result database.File
+	err := db.QueryRowx(`
+		SELECT * FROM files
+		WHERE
+			user_id = $1
+			AND parent_directory_id IS NOT DISTINCT FROM $2
+			AND name = $3
+		ORDER BY created_at, id
+		LIMIT 1`,
+		user.ID,
+		parentDirectoryID,
+		name,
+	).StructScan(&result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.WithStack(NotFoundError{Code: 404, Message: "ファイルが見つかりません。"})
+	}
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	f := result.ToEntity()
+
+	return &f, nil
+}
+
+// ファイルの実体を差し替え、実体から取り込んだ情報を更新する
+func (repo *FileRepository) UpdateFileContent(tx *sqlx.Tx, user user.User, file file.File) (*file.File, error) {
+	var metadata *string
+	if file.Metadata != nil {
+		encoded, err := json.Marshal(file.Metadata)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		encodedString := string(encoded)
+		metadata = &encodedString
+	}
+
+	rows, err := tx.Queryx(`
+		UPDATE files f
+		SET
+			kind = $1,
+			url = $2,
+			mime_type = $3,
+			taken_at = $4,
+			width = $5,
+			height = $6,
+			metadata = $7,
+			size = $8,
+			updated_at = $9,
+			blob_id = $10
+		FROM files old
+		WHERE
+			f.id = old.id
+			AND f.id = $11
+			AND f.user_id = $12
+		RETURNING old.user_id, old.kind, COALESCE(old.size, 0)`,
+		file.Kind,
+		file.Url,
+		file.MimeType,
+		file.TakenAt,
+		file.Width,
+		file.Height,
+		metadata,
+		file.Size,
+		file.UpdatedAt,
+		file.BlobID,
+		file.ID,
+		user.ID,
+	)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	oldRows, err := scanFileSizes(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// 差し替える前の実体の分を減らし、新しい実体の分を増やす
+	for _, old := range oldRows {
+		if err := addUsage(tx, user.ID, old.Kind, -old.Size); err != nil {
+			return nil, err
+		}
+		if err := addUsage(tx, user.ID, file.Kind, file.Size); err != nil {
+			return nil, err
+		}
+	}
+
+	return &file, nil
+}
+
+func (repo *FileRepository) GetVideoSetting() video.Setting {
+	return videoSetting
+}
+
+func (repo *FileRepository) GetExtractionSetting() file.ExtractionSetting {
+	return extractionSetting
+}
+
+func (repo *FileRepository) GetImportSetting() file.ImportSetting {
+	return importSetting
+}
+
+// 大きさを計測していないファイル
+func (repo *FileRepository) GetFilesWithoutSize(db *sqlx.DB, limit int) ([]file.File, error) {
+	var results []database.File
+	if err := db.Select(&results, "SELECT * FROM files WHERE size IS NULL ORDER BY id LIMIT $1", limit); err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	files := make([]file.File, 0, len(results))
+	for _, result := range results {
+		files = append(files, result.ToEntity())
+	}
+
+	return files, nil
+}
+
+// 大きさを計測していないファイルの大きさを記録し、使用量に加える
+func (repo *FileRepository) UpdateFileSize(tx *sqlx.Tx, file file.File, size int64) error {
+	result, err := tx.Exec("UPDATE files SET size = $1 WHERE id = $2 AND size IS NULL", size, file.ID)
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+	if affected == 0 {
+		return nil
+	}
+
+	return addUsage(tx, file.UserID, file.Kind, size)
+}
+
+// 実体のIDを記録する前に登録されたファイルの {BASE_URL}/static/{mount}/{blobID}{ext} を {BASE_URL}/files/secure/{blobID} に書き換える
+// 他のホストの /static/ のURLは外部のファイルのため書き換えない
+func (repo *FileRepository) RewriteLegacyStaticUrls(tx *sqlx.Tx) (int64, error) {
+	result, err := tx.Exec(`
+		UPDATE files
+		SET
+			url = $1 || regexp_replace(substring(url from length($1) + 1), '^/static/[^/]+/([0-9]+)[^/]*$', '/files/secure/\1'),
+			updated_at = $2
+		WHERE
+			blob_id = -1
+			AND left(url, length($1)) = $1
+			AND substring(url from length($1) + 1) ~ '^/static/[^/]+/[0-9]+(\.[^/]*)?$'`,
+		os.Getenv("BASE_URL"),
+		time.Now(),
+	)
+	if err != nil {
+		return 0, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return affected, nil
+}
+
+// 実体のIDを記録する前に登録されたファイル(blob_id = -1)に、BASE_URL 以下のURLが指す実体のIDを設定する
+// 同じ実体を先に登録した別の利用者がいる場合や、自ストレージ上にない場合はNULLにする
+func (repo *FileRepository) ResolveLegacyBlobIDs(tx *sqlx.Tx) (int64, error) {
+	result, err := tx.Exec(`
+		UPDATE files f
+		SET blob_id = CASE
+			WHEN left(f.url, length($1)) = $1
+				AND substring(f.url from length($1) + 1) ~ '^/files/secure/[0-9]+$'
+				AND NOT EXISTS (
+					SELECT 1 FROM files o
+					WHERE o.url = f.url AND o.id < f.id AND o.user_id <> f.user_id
+				)
+			THEN CAST(substring(f.url from length($1) + 1 + length('/files/secure/')) AS BIGINT)
+		END
+		WHERE f.blob_id = -1`,
+		os.Getenv("BASE_URL"),
+	)
+	if err != nil {
+		return 0, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return affected, nil
+}
+
+// 実体のIDが未解決(blob_id = -1)のファイルがあるか
+func (repo *FileRepository) HasLegacyBlobIDs(db *sqlx.DB) (bool, error) {
+	var exists bool
+	if err := db.Get(&exists, "SELECT EXISTS (SELECT 1 FROM files WHERE blob_id = -1)"); err != nil {
+		return false, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return exists, nil
+}
+
+// RETURNINGで返した、更新・削除する前の所有者・種類・大きさ。次のクエリの前に全て読み込む
+type fileSize struct {
+	UserID string
+	Kind   string
+	Size   int64
+}
+
+func scanFileSizes(rows *sqlx.Rows) ([]fileSize, error) {
+	defer rows.Close()
+
+	sizes := []fileSize{}
+	for rows.Next() {
+		var s fileSize
+		if err := rows.Scan(&s.UserID, &s.Kind, &s.Size); err != nil {
+			return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "スキャンエラー"}, err))
+		}
+		sizes = append(sizes, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return sizes, nil
+}
@@ -0,0 +1,379 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/job"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/database"
+	yaml "github.com/goccy/go-yaml"
+	"github.com/jmoiron/sqlx"
+)
+
+type JobRepositoryInterface interface {
+	RegistrationJob(tx *sqlx.Tx, job job.Job) (*job.Job, error)
+	ClaimJob(conn *sqlx.DB, jobType job.Type) (*job.Job, error)
+	HeartbeatJob(conn *sqlx.DB, id string) (bool, error)
+	CompleteJob(conn *sqlx.DB, id string, result []byte) error
+	UpdateJobProgress(conn *sqlx.DB, id string, progress job.Progress) error
+	FailJob(conn *sqlx.DB, job job.Job, message string) error
+	FinishCancelledJob(conn *sqlx.DB, id string) error
+	CancelJob(tx *sqlx.Tx, user user.User, id string) (*job.Job, error)
+	RequeueStaleJobs(conn *sqlx.DB, staleBefore time.Time) (int64, error)
+	GetJobs(conn *sqlx.DB, user user.User, currentPageCount int, pageSize int) (*job.PaginationJobs, error)
+	GetJobByID(conn *sqlx.DB, user user.User, id string) (*job.Job, error)
+	GetJobSetting() job.Setting
+}
+
+type JobRepository struct {
+}
+
+var jobSetting job.Setting
+
+func init() {
+	storageConfigFile := readStorageConfig()
+
+	jobConfig := struct {
+		Jobs job.Setting `yaml:"jobs"`
+	}{}
+	if err := yaml.Unmarshal(storageConfigFile, &jobConfig); err != nil {
+		log.Fatalf("error unmarshaling job config: %v", errors.WithStack(err))
+	}
+
+	jobSetting = job.DefaultSetting()
+	for jobType, typeSetting := range jobConfig.Jobs {
+		jobSetting[jobType] = typeSetting
+	}
+}
+
+func (repo *JobRepository) RegistrationJob(tx *sqlx.Tx, job job.Job) (*job.Job, error) {
+	_, err := tx.Exec(`
+		INSERT INTO jobs
+			(
+				id,
+				user_id,
+				type,
+				status,
+				payload,
+				max_attempts,
+				run_at,
+				created_at,
+				updated_at
+			)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		job.ID,
+		job.UserID,
+		job.Type,
+		job.Status,
+		string(job.Payload),
+		job.MaxAttempts,
+		job.RunAt,
+		job.CreatedAt,
+		job.UpdatedAt,
+	)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return &job, nil
+}
+
+// 実行可能なジョブを1件取得して実行中にする。他のワーカーが取得中の行はスキップする
+func (repo *JobRepository) ClaimJob(conn *sqlx.DB, jobType job.Type) (*job.Job, error) {
+	now := time.Now()
+
+	var result database.Job
+	err := conn.QueryRowx(`
+		UPDATE jobs
+		SET
+			status = $1,
+			attempts = attempts + 1,
+			locked_at = $2,
+			started_at = $2,
+			updated_at = $2
+		WHERE
+			id = (
+				SELECT id FROM jobs
+				WHERE
+					type = $3
+					AND status = $4
+					AND run_at <= $2
+				ORDER BY run_at
+				LIMIT 1
+				FOR UPDATE SKIP LOCKED
+			)
+		RETURNING *`,
+		job.StatusRunning,
+		now,
+		jobType,
+		job.StatusQueued,
+	).StructScan(&result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	claimed := result.ToEntity()
+
+	return &claimed, nil
+}
+
+// 実行中であることを記録し、キャンセルが要求されているかを返す
+func (repo *JobRepository) HeartbeatJob(conn *sqlx.DB, id string) (bool, error) {
+	var cancelRequested bool
+	err := conn.QueryRowx(`
+		UPDATE jobs
+		SET
+			locked_at = $1
+		WHERE
+			id = $2
+		RETURNING
+			cancel_requested`,
+		time.Now(),
+		id,
+	).Scan(&cancelRequested)
+	if err != nil {
+		return false, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return cancelRequested, nil
+}
+
+func (repo *JobRepository) CompleteJob(conn *sqlx.DB, id string, result []byte) error {
+	now := time.Now()
+
+	_, err := conn.Exec(`
+		UPDATE jobs
+		SET
+			status = $1,
+			result = $2,
+			locked_at = NULL,
+			finished_at = $3,
+			updated_at = $3
+		WHERE
+			id = $4`,
+		job.StatusSucceeded,
+		string(result),
+		now,
+		id,
+	)
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
+
+func (repo *JobRepository) UpdateJobProgress(conn *sqlx.DB, id string, progress job.Progress) error {
+	encoded, err := json.Marshal(progress)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = conn.Exec(`
+		UPDATE jobs
+		SET
+			progress = $1,
+			updated_at = $2
+		WHERE
+			id = $3`,
+		string(encoded),
+		time.Now(),
+		id,
+	)
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
+
+// リトライ上限に達していなければバックオフ後に再実行し、達していればデッドレターにする
+func (repo *JobRepository) FailJob(conn *sqlx.DB, failedJob job.Job, message string) error {
+	now := time.Now()
+
+	status := job.StatusQueued
+	runAt := now.Add(job.Backoff(failedJob.Attempts))
+	var finishedAt *time.Time
+	if failedJob.Attempts >= failedJob.MaxAttempts {
+		status = job.StatusDead
+		finishedAt = &now
+	}
+
+	_, err := conn.Exec(`
+		UPDATE jobs
+		SET
+			status = $1,
+			last_error = $2,
+			run_at = $3,
+			locked_at = NULL,
+			finished_at = $4,
+			updated_at = $5
+		WHERE
+			id = $6`,
+		status,
+		message,
+		runAt,
+		finishedAt,
+		now,
+		failedJob.ID,
+	)
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
+
+func (repo *JobRepository) FinishCancelledJob(conn *sqlx.DB, id string) error {
+	now := time.Now()
+
+	_, err := conn.Exec(`
+		UPDATE jobs
+		SET
+			status = $1,
+			locked_at = NULL,
+			finished_at = $2,
+			updated_at = $2
+		WHERE
+			id = $3`,
+		job.StatusCancelled,
+		now,
+		id,
+	)
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
+
+// 待機中のジョブは即座にキャンセルし、実行中のジョブにはキャンセルを要求する
+func (repo *JobRepository) CancelJob(tx *sqlx.Tx, user user.User, id string) (*job.Job, error) {
+	now := time.Now()
+
+	var result database.Job
+	err := tx.QueryRowx(`
+		UPDATE jobs
+		SET
+			status = CASE WHEN status = $1 THEN $2 ELSE status END,
+			finished_at = CASE WHEN status = $1 THEN $3 ELSE finished_at END,
+			cancel_requested = TRUE,
+			updated_at = $3
+		WHERE
+			id = $4
+			AND user_id = $5
+		RETURNING *`,
+		job.StatusQueued,
+		job.StatusCancelled,
+		now,
+		id,
+		user.ID,
+	).StructScan(&result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.WithStack(NotFoundError{Code: 404, Message: "ジョブが見つかりません。"})
+	}
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	cancelled := result.ToEntity()
+
+	return &cancelled, nil
+}
+
+// プロセスの停止などでハートビートが途絶えたジョブを待機中に戻す。リトライ上限に達していればデッドレターにする
+func (repo *JobRepository) RequeueStaleJobs(conn *sqlx.DB, staleBefore time.Time) (int64, error) {
+	now := time.Now()
+
+	res, err := conn.Exec(`
+		UPDATE jobs
+		SET
+			status = CASE WHEN attempts >= max_attempts THEN $1 ELSE $2 END,
+			last_error = COALESCE(last_error, $3),
+			finished_at = CASE WHEN attempts >= max_attempts THEN $4 ELSE NULL END,
+			locked_at = NULL,
+			updated_at = $4
+		WHERE
+			status = $5
+			AND locked_at < $6`,
+		job.StatusDead,
+		job.StatusQueued,
+		"worker stopped while running the job",
+		now,
+		job.StatusRunning,
+		staleBefore,
+	)
+	if err != nil {
+		return 0, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return res.RowsAffected()
+}
+
+func (repo *JobRepository) GetJobs(conn *sqlx.DB, user user.User, currentPageCount int, pageSize int) (*job.PaginationJobs, error) {
+	rows, err := conn.Queryx(`
+		SELECT * FROM jobs
+		WHERE
+			user_id = $1
+		ORDER BY
+			created_at DESC
+		LIMIT $2
+		OFFSET $3`,
+		user.ID,
+		pageSize,
+		pageSize*(currentPageCount-1),
+	)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+	defer rows.Close()
+
+	jobs := make([]job.Job, 0)
+	for rows.Next() {
+		var j database.Job
+		if err := rows.StructScan(&j); err != nil {
+			return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "スキャンエラー"}, err))
+		}
+
+		jobs = append(jobs, j.ToEntity())
+	}
+
+	var total int
+	if err := conn.Get(&total, "SELECT COUNT(*) FROM jobs WHERE user_id = $1", user.ID); err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return &job.PaginationJobs{
+		Jobs:             jobs,
+		PageSize:         pageSize,
+		CurrentPageCount: currentPageCount,
+		Total:            total,
+	}, nil
+}
+
+func (repo *JobRepository) GetJobByID(conn *sqlx.DB, user user.User, id string) (*job.Job, error) {
+	var result database.Job
+	err := conn.QueryRowx("SELECT * FROM jobs WHERE id = $1 AND user_id = $2", id, user.ID).StructScan(&result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.WithStack(NotFoundError{Code: 404, Message: "ジョブが見つかりません。"})
+	}
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	j := result.ToEntity()
+
+	return &j, nil
+}
+
+func (repo *JobRepository) GetJobSetting() job.Setting {
+	return jobSetting
+}
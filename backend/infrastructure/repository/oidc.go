@@ -0,0 +1,266 @@
+package repository
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	yaml "github.com/goccy/go-yaml"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/oidc"
+)
+
+const (
+	// IdPの設定と公開鍵を取得し直す間隔
+	oidcProviderCacheTtl = time.Hour
+	// 未知の鍵で署名されていた場合に、公開鍵を取得し直す最短の間隔
+	oidcJwksRefreshInterval = 10 * time.Second
+)
+
+type OidcRepositoryInterface interface {
+	IsEnabled() bool
+	GetIssuer() string
+	AuthorizationUrl(request oidc.AuthorizationRequest) (string, error)
+	ExchangeCode(code string, codeVerifier string) (string, error)
+	VerifyIdToken(rawIdToken string, nonce string, now time.Time) (*oidc.Claims, error)
+	GetOidcSetting() oidc.Setting
+}
+
+// OIDC_ISSUER を指定していない場合は無効
+type OidcRepository struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectUrl  string
+	HttpClient   *http.Client
+	cache        *oidcProviderCache
+}
+
+type oidcProviderCache struct {
+	mu                sync.Mutex
+	metadata          *oidc.ProviderMetadata
+	metadataFetchedAt time.Time
+	keys              oidc.JsonWebKeySet
+	keysFetchedAt     time.Time
+}
+
+var oidcSetting oidc.Setting
+
+func init() {
+	storageConfigFile := readStorageConfig()
+
+	oidcConfig := struct {
+		Oidc oidc.Setting `yaml:"oidc"`
+	}{Oidc: oidc.DefaultSetting()}
+	if err := yaml.Unmarshal(storageConfigFile, &oidcConfig); err != nil {
+		log.Fatalf("error unmarshaling oidc config: %v", errors.WithStack(err))
+	}
+	oidcSetting = oidcConfig.Oidc
+}
+
+func NewOidcRepository(issuer string, clientID string, clientSecret string, redirectUrl string) OidcRepository {
+	return OidcRepository{
+		Issuer:       strings.TrimSuffix(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectUrl:  redirectUrl,
+		HttpClient:   &http.Client{Timeout: 10 * time.Second},
+		cache:        &oidcProviderCache{},
+	}
+}
+
+func (repo *OidcRepository) IsEnabled() bool {
+	return repo.Issuer != "" && repo.ClientID != ""
+}
+
+func (repo *OidcRepository) GetIssuer() string {
+	return repo.Issuer
+}
+
+func (repo *OidcRepository) GetOidcSetting() oidc.Setting {
+	return oidcSetting
+}
+
+func (repo *OidcRepository) AuthorizationUrl(request oidc.AuthorizationRequest) (string, error) {
+	metadata, err := repo.providerMetadata()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", repo.ClientID)
+	query.Set("redirect_uri", repo.RedirectUrl)
+	query.Set("scope", strings.Join(oidcSetting.Scopes, " "))
+	query.Set("state", request.State)
+	query.Set("nonce", request.Nonce)
+	query.Set("code_challenge", request.CodeChallenge())
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// 認可コードをトークンエンドポイントで交換し、IDトークンを返す
+func (repo *OidcRepository) ExchangeCode(code string, codeVerifier string) (string, error) {
+	metadata, err := repo.providerMetadata()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", repo.RedirectUrl)
+	form.Set("client_id", repo.ClientID)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequest(http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// 公開クライアントの場合はPKCEのみで認証する
+	if repo.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(repo.ClientID), url.QueryEscape(repo.ClientSecret))
+	}
+
+	var result struct {
+		IdToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := repo.doJson(req, &result)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if status != http.StatusOK || result.Error != "" {
+		return "", errors.Newf("token endpoint returned %d: %s %s", status, result.Error, result.ErrorDescription)
+	}
+	if result.IdToken == "" {
+		return "", errors.New("token endpoint returned no id token")
+	}
+
+	return result.IdToken, nil
+}
+
+func (repo *OidcRepository) VerifyIdToken(rawIdToken string, nonce string, now time.Time) (*oidc.Claims, error) {
+	keys, err := repo.jwks(false)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	claims, err := oidc.ParseIdToken(rawIdToken, keys, oidcSetting.GroupsClaim)
+	if errors.Is(err, oidc.ErrUnknownKey) {
+		// IdPが鍵を更新した直後は、取得し直した公開鍵で検証する
+		if keys, err = repo.jwks(true); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		claims, err = oidc.ParseIdToken(rawIdToken, keys, oidcSetting.GroupsClaim)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := claims.Validate(repo.Issuer, repo.ClientID, nonce, now); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return claims, nil
+}
+
+func (repo *OidcRepository) providerMetadata() (*oidc.ProviderMetadata, error) {
+	repo.cache.mu.Lock()
+	defer repo.cache.mu.Unlock()
+
+	if repo.cache.metadata != nil && time.Since(repo.cache.metadataFetchedAt) < oidcProviderCacheTtl {
+		return repo.cache.metadata, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, repo.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var metadata oidc.ProviderMetadata
+	status, err := repo.doJson(req, &metadata)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if status != http.StatusOK {
+		return nil, errors.Newf("openid configuration returned %d", status)
+	}
+	// 別のIdPの設定を使わないよう、発行者が一致することを確認する
+	if strings.TrimSuffix(metadata.Issuer, "/") != repo.Issuer {
+		return nil, errors.Newf("unexpected issuer in openid configuration: %s", metadata.Issuer)
+	}
+
+	repo.cache.metadata = &metadata
+	repo.cache.metadataFetchedAt = time.Now()
+
+	return &metadata, nil
+}
+
+func (repo *OidcRepository) jwks(refresh bool) (oidc.JsonWebKeySet, error) {
+	metadata, err := repo.providerMetadata()
+	if err != nil {
+		return oidc.JsonWebKeySet{}, errors.WithStack(err)
+	}
+
+	repo.cache.mu.Lock()
+	defer repo.cache.mu.Unlock()
+
+	age := time.Since(repo.cache.keysFetchedAt)
+	if len(repo.cache.keys.Keys) > 0 && age < oidcProviderCacheTtl && (!refresh || age < oidcJwksRefreshInterval) {
+		return repo.cache.keys, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, metadata.JwksUri, nil)
+	if err != nil {
+		return oidc.JsonWebKeySet{}, errors.WithStack(err)
+	}
+
+	var keys oidc.JsonWebKeySet
+	status, err := repo.doJson(req, &keys)
+	if err != nil {
+		return oidc.JsonWebKeySet{}, errors.WithStack(err)
+	}
+	if status != http.StatusOK {
+		return oidc.JsonWebKeySet{}, errors.Newf("jwks returned %d", status)
+	}
+
+	repo.cache.keys = keys
+	repo.cache.keysFetchedAt = time.Now()
+
+	return keys, nil
+}
+
+func (repo *OidcRepository) doJson(req *http.Request, v any) (int, error) {
+	res, err := repo.HttpClient.Do(req)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil && res.StatusCode == http.StatusOK {
+		return 0, errors.WithStack(err)
+	}
+
+	return res.StatusCode, nil
+}
@@ -0,0 +1,273 @@
+package repository
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/oidc"
+)
+
+const testOidcClientID = "yappi-storage"
+
+// 設定と公開鍵のみを返すIdP
+type testIdentityProvider struct {
+	server *httptest.Server
+	keys   oidc.JsonWebKeySet
+}
+
+func newTestIdentityProvider(t *testing.T, keys oidc.JsonWebKeySet) *testIdentityProvider {
+	t.Helper()
+
+	idp := &testIdentityProvider{keys: keys}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidc.ProviderMetadata{
+			Issuer:                idp.server.URL,
+			AuthorizationEndpoint: idp.server.URL + "/authorize",
+			TokenEndpoint:         idp.server.URL + "/token",
+			JwksUri:               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(idp.keys)
+	})
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func rsaJsonWebKey(kid string, key *rsa.PrivateKey) oidc.JsonWebKey {
+	return oidc.JsonWebKey{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecdsaJsonWebKey(kid string, key *ecdsa.PrivateKey) oidc.JsonWebKey {
+	return oidc.JsonWebKey{
+		Kty: "EC",
+		Kid: kid,
+		Use: "sig",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+// 署名の方法を差し替えられるIDトークン
+func signTestIdToken(t *testing.T, header map[string]any, claims map[string]any, sign func(signingInput []byte) []byte) string {
+	t.Helper()
+
+	encode := func(v any) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("failed to encode token: %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	signingInput := encode(header) + "." + encode(claims)
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(signingInput)))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey) func([]byte) []byte {
+	return func(signingInput []byte) []byte {
+		digest := sha256.Sum256(signingInput)
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+		return signature
+	}
+}
+
+func signES256(t *testing.T, key *ecdsa.PrivateKey) func([]byte) []byte {
+	return func(signingInput []byte) []byte {
+		digest := sha256.Sum256(signingInput)
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+		return append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+}
+
+func TestOidcRepositoryVerifyIdToken(t *testing.T) {
+	now := time.Unix(1760000000, 0)
+	nonce := "nonce"
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	otherRsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	idp := newTestIdentityProvider(t, oidc.JsonWebKeySet{Keys: []oidc.JsonWebKey{
+		rsaJsonWebKey("rsa", rsaKey),
+		ecdsaJsonWebKey("ec", ecdsaKey),
+	}})
+
+	// 既定では正しいIDトークンになるクレーム
+	claimsWith := func(overrides map[string]any) map[string]any {
+		claims := map[string]any{
+			"iss":    idp.server.URL,
+			"sub":    "subject",
+			"aud":    testOidcClientID,
+			"exp":    now.Add(5 * time.Minute).Unix(),
+			"iat":    now.Unix(),
+			"nonce":  nonce,
+			"email":  "user@example.com",
+			"groups": []string{"storage-users"},
+		}
+		for name, value := range overrides {
+			if value == nil {
+				delete(claims, name)
+			} else {
+				claims[name] = value
+			}
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{
+			name:  "RS256",
+			token: signTestIdToken(t, map[string]any{"alg": "RS256", "kid": "rsa"}, claimsWith(nil), signRS256(t, rsaKey)),
+		},
+		{
+			name:  "ES256",
+			token: signTestIdToken(t, map[string]any{"alg": "ES256", "kid": "ec"}, claimsWith(nil), signES256(t, ecdsaKey)),
+		},
+		{
+			name:    "alg none",
+			token:   signTestIdToken(t, map[string]any{"alg": "none", "kid": "rsa"}, claimsWith(nil), func([]byte) []byte { return nil }),
+			wantErr: true,
+		},
+		{
+			name: "公開鍵を共通鍵にしたHS256",
+			token: signTestIdToken(t, map[string]any{"alg": "HS256", "kid": "rsa"}, claimsWith(nil), func(signingInput []byte) []byte {
+				mac := hmac.New(sha256.New, []byte(rsaJsonWebKey("rsa", rsaKey).N))
+				mac.Write(signingInput)
+				return mac.Sum(nil)
+			}),
+			wantErr: true,
+		},
+		{
+			name:    "鍵の種類と違うalg",
+			token:   signTestIdToken(t, map[string]any{"alg": "ES256", "kid": "rsa"}, claimsWith(nil), signES256(t, ecdsaKey)),
+			wantErr: true,
+		},
+		{
+			name:    "別の鍵で署名",
+			token:   signTestIdToken(t, map[string]any{"alg": "RS256", "kid": "rsa"}, claimsWith(nil), signRS256(t, otherRsaKey)),
+			wantErr: true,
+		},
+		{
+			name:    "JWKSに無いkid",
+			token:   signTestIdToken(t, map[string]any{"alg": "RS256", "kid": "unknown"}, claimsWith(nil), signRS256(t, rsaKey)),
+			wantErr: true,
+		},
+		{
+			name:    "鍵が複数ある場合にkidが無い",
+			token:   signTestIdToken(t, map[string]any{"alg": "RS256"}, claimsWith(nil), signRS256(t, rsaKey)),
+			wantErr: true,
+		},
+		{
+			name:    "nonceが違う",
+			token:   signTestIdToken(t, map[string]any{"alg": "RS256", "kid": "rsa"}, claimsWith(map[string]any{"nonce": "other"}), signRS256(t, rsaKey)),
+			wantErr: true,
+		},
+		{
+			name:    "nonceが無い",
+			token:   signTestIdToken(t, map[string]any{"alg": "RS256", "kid": "rsa"}, claimsWith(map[string]any{"nonce": nil}), signRS256(t, rsaKey)),
+			wantErr: true,
+		},
+		{
+			name:    "別のクライアント宛て",
+			token:   signTestIdToken(t, map[string]any{"alg": "RS256", "kid": "rsa"}, claimsWith(map[string]any{"aud": "other"}), signRS256(t, rsaKey)),
+			wantErr: true,
+		},
+		{
+			name:  "複数の宛先で自身が認可された当事者",
+			token: signTestIdToken(t, map[string]any{"alg": "RS256", "kid": "rsa"}, claimsWith(map[string]any{"aud": []string{"other", testOidcClientID}, "azp": testOidcClientID}), signRS256(t, rsaKey)),
+		},
+		{
+			name:    "複数の宛先で別の当事者",
+			token:   signTestIdToken(t, map[string]any{"alg": "RS256", "kid": "rsa"}, claimsWith(map[string]any{"aud": []string{"other", testOidcClientID}, "azp": "other"}), signRS256(t, rsaKey)),
+			wantErr: true,
+		},
+		{
+			name:    "別の発行者",
+			token:   signTestIdToken(t, map[string]any{"alg": "RS256", "kid": "rsa"}, claimsWith(map[string]any{"iss": "https://idp.example.com"}), signRS256(t, rsaKey)),
+			wantErr: true,
+		},
+		{
+			name:  "時計のずれの範囲内で期限切れ",
+			token: signTestIdToken(t, map[string]any{"alg": "RS256", "kid": "rsa"}, claimsWith(map[string]any{"exp": now.Add(-30 * time.Second).Unix()}), signRS256(t, rsaKey)),
+		},
+		{
+			name:    "期限切れ",
+			token:   signTestIdToken(t, map[string]any{"alg": "RS256", "kid": "rsa"}, claimsWith(map[string]any{"exp": now.Add(-2 * time.Minute).Unix()}), signRS256(t, rsaKey)),
+			wantErr: true,
+		},
+		{
+			name:    "未来に発行",
+			token:   signTestIdToken(t, map[string]any{"alg": "RS256", "kid": "rsa"}, claimsWith(map[string]any{"iat": now.Add(2 * time.Minute).Unix()}), signRS256(t, rsaKey)),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewOidcRepository(idp.server.URL, testOidcClientID, "", idp.server.URL+"/callback")
+
+			claims, err := repo.VerifyIdToken(tt.token, nonce, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("VerifyIdToken accepted the token: %+v", claims)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyIdToken returned an error: %v", err)
+			}
+
+			if claims.Subject != "subject" || claims.Email != "user@example.com" {
+				t.Errorf("claims = %+v", claims)
+			}
+			if len(claims.Groups) != 1 || claims.Groups[0] != "storage-users" {
+				t.Errorf("groups = %v, want [storage-users]", claims.Groups)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"log"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/quota"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/database"
+	yaml "github.com/goccy/go-yaml"
+	"github.com/jmoiron/sqlx"
+)
+
+type QuotaRepositoryInterface interface {
+	GetUsage(conn *sqlx.DB, owner user.User) (map[string]int64, error)
+	GetReservedBytes(conn *sqlx.DB, owner user.User, now time.Time) (int64, error)
+	LockUsedBytes(tx *sqlx.Tx, owner user.User, now time.Time, excludedUploadID string) (int64, error)
+	GetQuotaSetting() quota.Setting
+}
+
+type QuotaRepository struct {
+}
+
+var quotaSetting quota.Setting
+
+func init() {
+	storageConfigFile := readStorageConfig()
+
+	// リストは既定値とマージされないよう、未指定の場合のみ既定値を使う
+	defaultQuotaSetting := quota.DefaultSetting()
+	quotaConfig := struct {
+		Quota quota.Setting `yaml:"quota"`
+	}{Quota: defaultQuotaSetting}
+	quotaConfig.Quota.WarningPercents = nil
+	if err := yaml.Unmarshal(storageConfigFile, &quotaConfig); err != nil {
+		log.Fatalf("error unmarshaling quota config: %v", errors.WithStack(err))
+	}
+	quotaSetting = quotaConfig.Quota
+	if len(quotaSetting.WarningPercents) == 0 {
+		quotaSetting.WarningPercents = defaultQuotaSetting.WarningPercents
+	}
+}
+
+// FileKindごとの使用量
+func (repo *QuotaRepository) GetUsage(conn *sqlx.DB, owner user.User) (map[string]int64, error) {
+	var results []database.UserUsage
+	if err := conn.Select(&results, "SELECT * FROM user_usages WHERE user_id = $1", owner.ID); err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	kinds := map[string]int64{}
+	for _, result := range results {
+		kinds[result.Kind] = result.Bytes
+	}
+
+	return kinds, nil
+}
+
+// 書き込み中または登録を待つアップロードの大きさの合計。書き込んだ分も登録するまで使用量に含まれないため、全体の大きさを予約する
+func (repo *QuotaRepository) GetReservedBytes(conn *sqlx.DB, owner user.User, now time.Time) (int64, error) {
+	var reservedBytes int64
+	if err := conn.Get(&reservedBytes, reservedBytesQuery, owner.ID, now, ""); err != nil {
+		return 0, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return reservedBytes, nil
+}
+
+// 同時に登録して容量を超えないよう、トランザクションが終わるまでユーザーの行をロックしてから使用量を返す
+// 使用量には予約しているアップロードの分も含む。登録する実体のアップロードは、使用量に変わるためexcludedUploadIDで除く
+func (repo *QuotaRepository) LockUsedBytes(tx *sqlx.Tx, owner user.User, now time.Time, excludedUploadID string) (int64, error) {
+	if _, err := tx.Exec("SELECT id FROM users WHERE id = $1 FOR UPDATE", owner.ID); err != nil {
+		return 0, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	var usedBytes int64
+	if err := tx.Get(&usedBytes, "SELECT COALESCE(SUM(bytes), 0) FROM user_usages WHERE user_id = $1", owner.ID); err != nil {
+		return 0, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	var reservedBytes int64
+	if err := tx.Get(&reservedBytes, reservedBytesQuery, owner.ID, now, excludedUploadID); err != nil {
+		return 0, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return usedBytes + reservedBytes, nil
+}
+
+// ファイルとして登録されておらず、期限の切れていないアップロード
+const reservedBytesQuery = `
+	SELECT COALESCE(SUM(length), 0)
+	FROM uploads
+	WHERE
+		user_id = $1
+		AND file_id IS NULL
+		AND expires_at > $2
+		AND id::TEXT <> $3`
+
+func (repo *QuotaRepository) GetQuotaSetting() quota.Setting {
+	return quotaSetting
+}
+
+// files の行の登録・更新・削除と同じトランザクションで使用量を増減させる
+func addUsage(tx *sqlx.Tx, userID string, kind string, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO user_usages (user_id, kind, bytes) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, kind) DO UPDATE SET bytes = user_usages.bytes + EXCLUDED.bytes`,
+		userID,
+		kind,
+		delta,
+	)
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	yaml "github.com/goccy/go-yaml"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/ratelimit"
+)
+
+const rateLimitKeyPrefix = "ratelimit:"
+
+type RateLimitRepositoryInterface interface {
+	Hit(key string, window time.Duration) (int64, time.Duration, error)
+	GetCount(key string) (int64, time.Duration, error)
+	Lock(key string, duration time.Duration) error
+	GetLockedFor(key string) (time.Duration, error)
+	Reset(keys ...string) error
+	GetRateLimitSetting() ratelimit.Setting
+}
+
+// 複数台で動かしても上限を共有できるよう、Redisで数える
+type RateLimitRepository struct {
+	Redis *redis.Client
+}
+
+var rateLimitSetting ratelimit.Setting
+
+func init() {
+	storageConfigFile := readStorageConfig()
+
+	rateLimitConfig := struct {
+		RateLimit ratelimit.Setting `yaml:"rate_limit"`
+	}{RateLimit: ratelimit.DefaultSetting()}
+	if err := yaml.Unmarshal(storageConfigFile, &rateLimitConfig); err != nil {
+		log.Fatalf("error unmarshaling rate limit config: %v", errors.WithStack(err))
+	}
+	rateLimitSetting = rateLimitConfig.RateLimit
+}
+
+// 時間枠の回数を1つ増やし、増やした後の回数と時間枠の残りを返す
+func (repo *RateLimitRepository) Hit(key string, window time.Duration) (int64, time.Duration, error) {
+	ctx := context.Background()
+	key = rateLimitKeyPrefix + key
+
+	pipe := repo.Redis.TxPipeline()
+	pipe.SetNX(ctx, key, 0, window)
+	count := pipe.Incr(ctx, key)
+	ttl := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, 0, errors.WithStack(err)
+	}
+
+	return count.Val(), max(ttl.Val(), 0), nil
+}
+
+func (repo *RateLimitRepository) GetCount(key string) (int64, time.Duration, error) {
+	ctx := context.Background()
+	key = rateLimitKeyPrefix + key
+
+	count, err := repo.Redis.Get(ctx, key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, errors.WithStack(err)
+	}
+
+	ttl, err := repo.Redis.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, 0, errors.WithStack(err)
+	}
+
+	return count, max(ttl, 0), nil
+}
+
+func (repo *RateLimitRepository) Lock(key string, duration time.Duration) error {
+	if err := repo.Redis.Set(context.Background(), rateLimitKeyPrefix+key, 1, duration).Err(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// ロックの残り時間。ロックされていない場合は0
+func (repo *RateLimitRepository) GetLockedFor(key string) (time.Duration, error) {
+	ttl, err := repo.Redis.PTTL(context.Background(), rateLimitKeyPrefix+key).Result()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	return max(ttl, 0), nil
+}
+
+func (repo *RateLimitRepository) Reset(keys ...string) error {
+	prefixed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		prefixed = append(prefixed, rateLimitKeyPrefix+key)
+	}
+
+	if err := repo.Redis.Del(context.Background(), prefixed...).Err(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+func (repo *RateLimitRepository) GetRateLimitSetting() ratelimit.Setting {
+	return rateLimitSetting
+}
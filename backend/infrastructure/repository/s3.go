@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/s3"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/database"
+)
+
+type S3RepositoryInterface interface {
+	RegistrationAccessKey(tx *sqlx.Tx, key s3.AccessKey) (*s3.AccessKey, error)
+	GetAccessKeys(conn *sqlx.DB, user user.User) ([]s3.AccessKey, error)
+	GetAccessKeyByAccessKeyID(conn *sqlx.DB, accessKeyID string) (*s3.AccessKey, error)
+	DeleteAccessKey(tx *sqlx.Tx, user user.User, id string) error
+	RegistrationMultipartUpload(tx *sqlx.Tx, upload s3.MultipartUpload) (*s3.MultipartUpload, error)
+	GetMultipartUpload(conn *sqlx.DB, user user.User, id string) (*s3.MultipartUpload, error)
+	GetExpiredMultipartUploads(conn *sqlx.DB, user user.User, now time.Time) ([]s3.MultipartUpload, error)
+	DeleteMultipartUpload(tx *sqlx.Tx, user user.User, id string) error
+}
+
+type S3Repository struct {
+}
+
+func (repo *S3Repository) RegistrationAccessKey(tx *sqlx.Tx, key s3.AccessKey) (*s3.AccessKey, error) {
+	_, err := tx.Exec(`
+		INSERT INTO s3_access_keys
+			(
+				id,
+				user_id,
+				access_key_id,
+				secret_access_key,
+				created_at,
+				updated_at
+			)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		key.ID,
+		key.UserID,
+		key.AccessKeyID,
+		key.SecretAccessKey,
+		key.CreatedAt,
+		key.UpdatedAt,
+	)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return &key, nil
+}
+
+func (repo *S3Repository) GetAccessKeys(conn *sqlx.DB, user user.User) ([]s3.AccessKey, error) {
+	rows, err := conn.Queryx("SELECT * FROM s3_access_keys WHERE user_id = $1 ORDER BY created_at DESC, id DESC", user.ID)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+	defer rows.Close()
+
+	keys := make([]s3.AccessKey, 0)
+	for rows.Next() {
+		var k database.S3AccessKey
+		if err := rows.StructScan(&k); err != nil {
+			return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "スキャンエラー"}, err))
+		}
+
+		keys = append(keys, k.ToEntity())
+	}
+
+	return keys, nil
+}
+
+func (repo *S3Repository) GetAccessKeyByAccessKeyID(conn *sqlx.DB, accessKeyID string) (*s3.AccessKey, error) {
+	var result database.S3AccessKey
+	err := conn.QueryRowx("SELECT * FROM s3_access_keys WHERE access_key_id = $1", accessKeyID).StructScan(&result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.WithStack(NotFoundError{Code: 404, Message: "アクセスキーが見つかりません。"})
+	}
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	k := result.ToEntity()
+
+	return &k, nil
+}
+
+func (repo *S3Repository) DeleteAccessKey(tx *sqlx.Tx, user user.User, id string) error {
+	result, err := tx.Exec("DELETE FROM s3_access_keys WHERE id = $1 AND user_id = $2", id, user.ID)
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+	if affected == 0 {
+		return errors.WithStack(NotFoundError{Code: 404, Message: "アクセスキーが見つかりません。"})
+	}
+
+	return nil
+}
+
+func (repo *S3Repository) RegistrationMultipartUpload(tx *sqlx.Tx, upload s3.MultipartUpload) (*s3.MultipartUpload, error) {
+	_, err := tx.Exec(`
+		INSERT INTO s3_multipart_uploads
+			(
+				id,
+				user_id,
+				bucket,
+				object_key,
+				created_at,
+				updated_at
+			)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		upload.ID,
+		upload.UserID,
+		upload.Bucket,
+		upload.Key,
+		upload.CreatedAt,
+		upload.UpdatedAt,
+	)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return &upload, nil
+}
+
+func (repo *S3Repository) GetMultipartUpload(conn *sqlx.DB, user user.User, id string) (*s3.MultipartUpload, error) {
+	var result database.S3MultipartUpload
+	err := conn.QueryRowx("SELECT * FROM s3_multipart_uploads WHERE id = $1 AND user_id = $2", id, user.ID).StructScan(&result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.WithStack(NotFoundError{Code: 404, Message: "アップロードが見つかりません。"})
+	}
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	u := result.ToEntity()
+
+	return &u, nil
+}
+
+func (repo *S3Repository) GetExpiredMultipartUploads(conn *sqlx.DB, user user.User, now time.Time) ([]s3.MultipartUpload, error) {
+	rows, err := conn.Queryx(
+		"SELECT * FROM s3_multipart_uploads WHERE user_id = $1 AND created_at <= $2 ORDER BY created_at, id",
+		user.ID,
+		now.Add(-s3.MultipartUploadTTL),
+	)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+	defer rows.Close()
+
+	uploads := make([]s3.MultipartUpload, 0)
+	for rows.Next() {
+		var u database.S3MultipartUpload
+		if err := rows.StructScan(&u); err != nil {
+			return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "スキャンエラー"}, err))
+		}
+
+		uploads = append(uploads, u.ToEntity())
+	}
+
+	return uploads, nil
+}
+
+func (repo *S3Repository) DeleteMultipartUpload(tx *sqlx.Tx, user user.User, id string) error {
+	if _, err := tx.Exec("DELETE FROM s3_multipart_uploads WHERE id = $1 AND user_id = $2", id, user.ID); err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
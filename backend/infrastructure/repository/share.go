@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/share"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/database"
+	"github.com/jmoiron/sqlx"
+)
+
+type ShareRepositoryInterface interface {
+	RegistrationShare(tx *sqlx.Tx, share share.Share) (*share.Share, error)
+	GetShares(conn *sqlx.DB, user user.User, fileID *string, currentPageCount int, pageSize int) (*share.PaginationShares, error)
+	GetShareByToken(conn *sqlx.DB, token string) (*share.Share, error)
+	RevokeShare(tx *sqlx.Tx, user user.User, id string) (*share.Share, error)
+	IncrementDownloadCount(conn *sqlx.DB, id string) (bool, error)
+}
+
+type ShareRepository struct {
+}
+
+func shareUrl(token string) string {
+	return fmt.Sprintf("%s/s/%s", os.Getenv("BASE_URL"), token)
+}
+
+func (repo *ShareRepository) toEntity(s database.Share) share.Share {
+	e := s.ToEntity()
+	e.Url = shareUrl(e.Token)
+
+	return e
+}
+
+func (repo *ShareRepository) RegistrationShare(tx *sqlx.Tx, s share.Share) (*share.Share, error) {
+	_, err := tx.Exec(`
+		INSERT INTO shares
+			(
+				id,
+				user_id,
+				file_id,
+				token,
+				permission,
+				password_hash,
+				expires_at,
+				max_downloads,
+				created_at,
+				updated_at
+			)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		s.ID,
+		s.UserID,
+		s.FileID,
+		s.Token,
+		s.Permission,
+		s.PasswordHash,
+		s.ExpiresAt,
+		s.MaxDownloads,
+		s.CreatedAt,
+		s.UpdatedAt,
+	)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	s.HasPassword = s.PasswordHash != nil
+	s.Url = shareUrl(s.Token)
+
+	return &s, nil
+}
+
+// fileIDを指定した場合はそのファイルの共有リンクのみを返す
+func (repo *ShareRepository) GetShares(conn *sqlx.DB, user user.User, fileID *string, currentPageCount int, pageSize int) (*share.PaginationShares, error) {
+	rows, err := conn.Queryx(`
+		SELECT * FROM shares
+		WHERE
+			user_id = $1
+			AND ($2::BIGINT IS NULL OR file_id = $2)
+		ORDER BY
+			created_at DESC,
+			id DESC
+		LIMIT $3
+		OFFSET $4`,
+		user.ID,
+		fileID,
+		pageSize,
+		pageSize*(currentPageCount-1),
+	)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+	defer rows.Close()
+
+	shares := make([]share.Share, 0)
+	for rows.Next() {
+		var s database.Share
+		if err := rows.StructScan(&s); err != nil {
+			return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "スキャンエラー"}, err))
+		}
+
+		shares = append(shares, repo.toEntity(s))
+	}
+
+	var total int
+	if err := conn.Get(&total, "SELECT COUNT(*) FROM shares WHERE user_id = $1 AND ($2::BIGINT IS NULL OR file_id = $2)", user.ID, fileID); err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return &share.PaginationShares{
+		Shares:           shares,
+		PageSize:         pageSize,
+		CurrentPageCount: currentPageCount,
+		Total:            total,
+	}, nil
+}
+
+func (repo *ShareRepository) GetShareByToken(conn *sqlx.DB, token string) (*share.Share, error) {
+	var result database.Share
+	err := conn.QueryRowx("SELECT * FROM shares WHERE token = $1", token).StructScan(&result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.WithStack(NotFoundError{Code: 404, Message: "共有リンクが見つかりません。"})
+	}
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	s := repo.toEntity(result)
+
+	return &s, nil
+}
+
+func (repo *ShareRepository) RevokeShare(tx *sqlx.Tx, user user.User, id string) (*share.Share, error) {
+	now := time.Now()
+
+	var result database.Share
+	err := tx.QueryRowx(`
+		UPDATE shares
+		SET
+			revoked_at = COALESCE(revoked_at, $1),
+			updated_at = $1
+		WHERE
+			id = $2
+			AND user_id = $3
+		RETURNING *`,
+		now,
+		id,
+		user.ID,
+	).StructScan(&result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.WithStack(NotFoundError{Code: 404, Message: "共有リンクが見つかりません。"})
+	}
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	s := repo.toEntity(result)
+
+	return &s, nil
+}
+
+// 上限に達していなければダウンロード回数を1増やす。同時にダウンロードされても上限を超えないよう条件付きで更新する
+func (repo *ShareRepository) IncrementDownloadCount(conn *sqlx.DB, id string) (bool, error) {
+	res, err := conn.Exec(`
+		UPDATE shares
+		SET
+			download_count = download_count + 1,
+			updated_at = $1
+		WHERE
+			id = $2
+			AND (max_downloads IS NULL OR download_count < max_downloads)`,
+		time.Now(),
+		id,
+	)
+	if err != nil {
+		return false, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	return affected == 1, nil
+}
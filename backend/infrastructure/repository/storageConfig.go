@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"log"
+	"os"
+
+	"github.com/cockroachdb/errors"
+)
+
+// storage_config.yaml の内容。ファイルが無い場合(パッケージのテストなど)は全て既定値を使う
+func readStorageConfig() []byte {
+	storageConfigFile, err := os.ReadFile("./storage_config.yaml")
+	if errors.Is(err, os.ErrNotExist) {
+		return []byte("{}")
+	}
+	if err != nil {
+		log.Fatalf("error reading file: %v", errors.WithStack(err))
+	}
+
+	return storageConfigFile
+}
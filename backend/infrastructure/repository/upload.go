@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/upload"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/database"
+)
+
+type UploadRepositoryInterface interface {
+	RegistrationUpload(tx *sqlx.Tx, upload upload.Upload) (*upload.Upload, error)
+	GetUpload(conn *sqlx.DB, user user.User, id string) (*upload.Upload, error)
+	UpdateUploadProgress(tx *sqlx.Tx, user user.User, upload upload.Upload) error
+	GetExpiredUploads(conn *sqlx.DB, now time.Time, limit int) ([]upload.Upload, error)
+	DeleteUpload(tx *sqlx.Tx, user user.User, id string) error
+	CompleteUpload(tx *sqlx.Tx, user user.User, id string, fileID string, now time.Time) (bool, error)
+}
+
+type UploadRepository struct {
+}
+
+func (repo *UploadRepository) RegistrationUpload(tx *sqlx.Tx, upload upload.Upload) (*upload.Upload, error) {
+	_, err := tx.Exec(`
+		INSERT INTO uploads
+			(
+				id,
+				user_id,
+				parent_directory_id,
+				name,
+				length,
+				upload_offset,
+				metadata,
+				local_path,
+				file_id,
+				expires_at,
+				created_at,
+				updated_at
+			)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		upload.ID,
+		upload.UserID,
+		upload.ParentDirectoryID,
+		upload.Name,
+		upload.Length,
+		upload.Offset,
+		upload.Metadata,
+		upload.LocalPath,
+		upload.FileID,
+		upload.ExpiresAt,
+		upload.CreatedAt,
+		upload.UpdatedAt,
+	)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return &upload, nil
+}
+
+func (repo *UploadRepository) GetUpload(conn *sqlx.DB, user user.User, id string) (*upload.Upload, error) {
+	var result database.Upload
+	err := conn.QueryRowx("SELECT * FROM uploads WHERE id = $1 AND user_id = $2", id, user.ID).StructScan(&result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.WithStack(NotFoundError{Code: 404, Message: "アップロードが見つかりません。"})
+	}
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	u := result.ToEntity()
+
+	return &u, nil
+}
+
+// 書き込んだ位置・完了して作成したファイル・期限を更新する
+func (repo *UploadRepository) UpdateUploadProgress(tx *sqlx.Tx, user user.User, upload upload.Upload) error {
+	_, err := tx.Exec(`
+		UPDATE uploads
+		SET
+			upload_offset = $1,
+			file_id = $2,
+			expires_at = $3,
+			updated_at = $4
+		WHERE id = $5 AND user_id = $6`,
+		upload.Offset,
+		upload.FileID,
+		upload.ExpiresAt,
+		upload.UpdatedAt,
+		upload.ID,
+		user.ID,
+	)
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
+
+// 全てのユーザーの期限を過ぎたアップロードを、期限の古い順にlimit件まで返す
+func (repo *UploadRepository) GetExpiredUploads(conn *sqlx.DB, now time.Time, limit int) ([]upload.Upload, error) {
+	rows, err := conn.Queryx("SELECT * FROM uploads WHERE expires_at <= $1 ORDER BY expires_at, id LIMIT $2", now, limit)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+	defer rows.Close()
+
+	uploads := make([]upload.Upload, 0)
+	for rows.Next() {
+		var u database.Upload
+		if err := rows.StructScan(&u); err != nil {
+			return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "スキャンエラー"}, err))
+		}
+
+		uploads = append(uploads, u.ToEntity())
+	}
+
+	return uploads, nil
+}
+
+func (repo *UploadRepository) DeleteUpload(tx *sqlx.Tx, user user.User, id string) error {
+	if _, err := tx.Exec("DELETE FROM uploads WHERE id = $1 AND user_id = $2", id, user.ID); err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
+
+// 書き込みを終えたアップロードをファイルとして登録したことを記録する。期限切れや登録済みの場合はfalse
+func (repo *UploadRepository) CompleteUpload(tx *sqlx.Tx, user user.User, id string, fileID string, now time.Time) (bool, error) {
+	result, err := tx.Exec(`
+		UPDATE uploads
+		SET
+			file_id = $1,
+			updated_at = $2
+		WHERE
+			id = $3
+			AND user_id = $4
+			AND file_id IS NULL
+			AND upload_offset = length
+			AND expires_at > $2`,
+		fileID,
+		now,
+		id,
+		user.ID,
+	)
+	if err != nil {
+		return false, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return affected > 0, nil
+}
@@ -1,43 +1,74 @@
 package repository
 
 import (
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
 	"github.com/cockroachdb/errors"
 
 	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
 	"github.com/YahiroRyo/yappi_storage/backend/helper"
 	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/database"
-	"github.com/gofiber/fiber/v2/middleware/session"
+	yaml "github.com/goccy/go-yaml"
 	"github.com/jmoiron/sqlx"
 )
 
 type UserRepositoryInterface interface {
-	GetLoggedInUser(conn *sqlx.DB, sess *session.Session) (*user.User, error)
-	GetUserByToken(conn *sqlx.DB, token string) (*user.User, error)
-	Login(tx *sqlx.Tx, email string, password string, sessionId string) (*user.User, error)
+	Login(tx *sqlx.Tx, email string, password string) (*user.User, error)
 	Registration(tx *sqlx.Tx, email string, password string, icon string) error
-	Logout(tx *sqlx.Tx, user user.User) error
-	GenerateToken(tx *sqlx.Tx, user user.User) (*string, error)
+	RegistrationWithoutPassword(tx *sqlx.Tx, email string, icon string, role user.Role, emailVerifiedAt *time.Time) (string, error)
+	GetUserByID(conn *sqlx.DB, id string) (*user.User, error)
+	GetUserByEmail(conn *sqlx.DB, email string) (*user.User, error)
+	UpdatePassword(tx *sqlx.Tx, user user.User, hashedPassword string) error
+	UpdateEmailVerifiedAt(tx *sqlx.Tx, user user.User, verifiedAt time.Time) error
+	UpdateRole(tx *sqlx.Tx, user user.User, role user.Role) error
+	UpdateDisabledAt(tx *sqlx.Tx, user user.User, disabledAt *time.Time) error
+	UpdateQuotaBytes(tx *sqlx.Tx, user user.User, quotaBytes *int64) error
+	GetUsers(conn *sqlx.DB, currentPageCount int, pageSize int) (*user.PaginationUsers, error)
+	ExistsAdmin(conn *sqlx.DB) (bool, error)
+	DeleteUser(tx *sqlx.Tx, user user.User) ([]string, error)
+	UpdateUserSetting(tx *sqlx.Tx, user user.User) error
+	UpdateTotpSecret(tx *sqlx.Tx, user user.User, secret *string) error
+	UpdateTwoFactorEnabled(tx *sqlx.Tx, user user.User, enabled bool) error
+	UpdateTotpLastCounter(tx *sqlx.Tx, user user.User, counter int64) (bool, error)
+	GetAuthSetting() user.AuthSetting
 }
 
 type UserRepository struct {
 }
 
-func (repo *UserRepository) GetLoggedInUser(conn *sqlx.DB, sess *session.Session) (*user.User, error) {
-	id := sess.Get("id")
+var authSetting user.AuthSetting
 
-	if id == nil || id == "" {
-		return nil, errors.WithStack(NotFoundError{Code: 404, Message: "IDが存在しません。"})
-	}
+func init() {
+	storageConfigFile := readStorageConfig()
 
-	row := conn.QueryRowx("SELECT * FROM users WHERE session_id = $1", id)
-	if row == nil {
-		return nil, errors.WithStack(errors.Join(NotFoundError{Code: 404, Message: "データが存在しません。"}, row.Err()))
+	authConfig := struct {
+		Auth user.AuthSetting `yaml:"auth"`
+	}{Auth: user.DefaultAuthSetting()}
+	if err := yaml.Unmarshal(storageConfigFile, &authConfig); err != nil {
+		log.Fatalf("error unmarshaling auth config: %v", errors.WithStack(err))
 	}
+	authSetting = authConfig.Auth
+}
 
+// メールアドレスとパスワードを確認し、ユーザーを返す
+// メールアドレスが存在するかを推測されないよう、どちらが違う場合も同じエラーを返す
+func (repo *UserRepository) Login(tx *sqlx.Tx, email string, password string) (*user.User, error) {
 	var result database.User
-	err := row.StructScan(&result)
+	err := tx.QueryRowx("SELECT * FROM users WHERE email = $1", email).StructScan(&result)
+	if errors.Is(err, sql.ErrNoRows) {
+		// 存在しない場合もパスワードを比較し、応答時間の差をなくす
+		helper.CompareHashPassword(dummyPasswordHash(), password)
+		return nil, errors.WithStack(loginFailedError())
+	}
 	if err != nil {
-		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, row.Err()))
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	if err := helper.CompareHashPassword(result.Password, password); err != nil {
+		return nil, errors.WithStack(errors.Join(loginFailedError(), err))
 	}
 
 	user := result.ToEntity()
@@ -45,43 +76,77 @@ func (repo *UserRepository) GetLoggedInUser(conn *sqlx.DB, sess *session.Session
 	return &user, nil
 }
 
-func (repo *UserRepository) GetUserByToken(conn *sqlx.DB, token string) (*user.User, error) {
-	row := conn.QueryRowx("SELECT * FROM users WHERE token = $1", token)
-	if row == nil {
-		return nil, errors.WithStack(errors.Join(NotFoundError{Code: 404, Message: "データが存在しません。"}, row.Err()))
+func loginFailedError() NotFoundError {
+	return NotFoundError{Code: 401, Message: "メールアドレスまたはパスワードが違います。"}
+}
+
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := helper.EncryptPassword("dummy-password")
+	if err != nil {
+		log.Fatalf("error generating dummy password hash: %v", errors.WithStack(err))
 	}
+	return hash
+})
 
-	var result database.User
-	err := row.StructScan(&result)
+func (repo *UserRepository) Registration(tx *sqlx.Tx, email string, password string, icon string) error {
+	id, err := helper.GenerateSnowflake()
 	if err != nil {
-		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, row.Err()))
+		return errors.WithStack(err)
 	}
 
-	user := result.ToEntity()
+	_, err = tx.Exec("INSERT INTO users (id, email, password, icon) VALUES ($1, $2, $3, $4)", id, email, password, icon)
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
 
-	return &user, nil
+	return nil
 }
 
-func (repo *UserRepository) Login(tx *sqlx.Tx, email string, password string, sessionId string) (*user.User, error) {
-	row := tx.QueryRowx("SELECT * FROM users WHERE email = $1", email)
-	if row == nil {
-		return nil, errors.WithStack(errors.Join(NotFoundError{Code: 404, Message: "メールアドレスが存在しません。"}, row.Err()))
+// OpenID Connectで作成するユーザー。パスワードが空のため、パスワードではログインできない
+func (repo *UserRepository) RegistrationWithoutPassword(tx *sqlx.Tx, email string, icon string, role user.Role, emailVerifiedAt *time.Time) (string, error) {
+	id, err := helper.GenerateSnowflake()
+	if err != nil {
+		return "", errors.WithStack(err)
 	}
 
-	var result database.User
-	err := row.StructScan(&result)
+	_, err = tx.Exec(
+		"INSERT INTO users (id, email, password, icon, role, email_verified_at) VALUES ($1, $2, '', $3, $4, $5)",
+		id,
+		email,
+		icon,
+		role,
+		emailVerifiedAt,
+	)
 	if err != nil {
-		println(err.Error())
-		return nil, errors.WithStack(errors.Join(NotFoundError{Code: 404, Message: "スキャンに失敗しました。"}, row.Err()))
+		return "", errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
 	}
 
-	if err := helper.CompareHashPassword(result.Password, password); err != nil {
-		return nil, errors.WithStack(errors.Join(NotFoundError{Code: 404, Message: "パスワードが違います。"}, row.Err()))
+	return *id, nil
+}
+
+func (repo *UserRepository) GetUserByID(conn *sqlx.DB, id string) (*user.User, error) {
+	var result database.User
+	err := conn.QueryRowx("SELECT * FROM users WHERE id = $1", id).StructScan(&result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.WithStack(NotFoundError{Code: 404, Message: "ユーザーが存在しません。"})
+	}
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
 	}
 
-	_, err = tx.Exec("UPDATE users SET session_id = $1 WHERE id = $2", sessionId, result.ID)
+	user := result.ToEntity()
+
+	return &user, nil
+}
+
+func (repo *UserRepository) GetUserByEmail(conn *sqlx.DB, email string) (*user.User, error) {
+	var result database.User
+	err := conn.QueryRowx("SELECT * FROM users WHERE email = $1", email).StructScan(&result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.WithStack(NotFoundError{Code: 404, Message: "ユーザーが存在しません。"})
+	}
 	if err != nil {
-		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, row.Err()))
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
 	}
 
 	user := result.ToEntity()
@@ -89,13 +154,17 @@ func (repo *UserRepository) Login(tx *sqlx.Tx, email string, password string, se
 	return &user, nil
 }
 
-func (repo *UserRepository) Registration(tx *sqlx.Tx, email string, password string, icon string) error {
-	id, err := helper.GenerateSnowflake()
+func (repo *UserRepository) UpdatePassword(tx *sqlx.Tx, user user.User, hashedPassword string) error {
+	_, err := tx.Exec("UPDATE users SET password = $1 WHERE id = $2", hashedPassword, user.ID)
 	if err != nil {
-		return errors.WithStack(err)
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
 	}
 
-	_, err = tx.Exec("INSERT INTO users (id, email, password, icon) VALUES ($1, $2, $3, $4)", id, email, password, icon)
+	return nil
+}
+
+func (repo *UserRepository) UpdateEmailVerifiedAt(tx *sqlx.Tx, user user.User, verifiedAt time.Time) error {
+	_, err := tx.Exec("UPDATE users SET email_verified_at = $1 WHERE id = $2", verifiedAt, user.ID)
 	if err != nil {
 		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
 	}
@@ -103,8 +172,8 @@ func (repo *UserRepository) Registration(tx *sqlx.Tx, email string, password str
 	return nil
 }
 
-func (repo *UserRepository) Logout(tx *sqlx.Tx, user user.User) error {
-	_, err := tx.Exec("UPDATE users SET session_id = NULL")
+func (repo *UserRepository) UpdateRole(tx *sqlx.Tx, user user.User, role user.Role) error {
+	_, err := tx.Exec("UPDATE users SET role = $1 WHERE id = $2", role, user.ID)
 	if err != nil {
 		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
 	}
@@ -112,21 +181,142 @@ func (repo *UserRepository) Logout(tx *sqlx.Tx, user user.User) error {
 	return nil
 }
 
-func (repo *UserRepository) GenerateToken(tx *sqlx.Tx, user user.User) (*string, error) {
-	token, err := helper.GenerateSnowflake()
+func (repo *UserRepository) UpdateDisabledAt(tx *sqlx.Tx, user user.User, disabledAt *time.Time) error {
+	_, err := tx.Exec("UPDATE users SET disabled_at = $1 WHERE id = $2", disabledAt, user.ID)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
 	}
 
-	args := map[string]any{
-		"token":   token,
-		"user_id": user.ID,
+	return nil
+}
+
+func (repo *UserRepository) UpdateQuotaBytes(tx *sqlx.Tx, user user.User, quotaBytes *int64) error {
+	_, err := tx.Exec("UPDATE users SET quota_bytes = $1 WHERE id = $2", quotaBytes, user.ID)
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
 	}
 
-	_, err = tx.NamedExec("UPDATE users SET token = :token WHERE id = :user_id", args)
+	return nil
+}
+
+func (repo *UserRepository) GetUsers(conn *sqlx.DB, currentPageCount int, pageSize int) (*user.PaginationUsers, error) {
+	var results []database.User
+	err := conn.Select(&results, "SELECT * FROM users ORDER BY created_at, id LIMIT $1 OFFSET $2", pageSize, pageSize*(currentPageCount-1))
 	if err != nil {
 		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
 	}
 
-	return token, nil
+	var total int
+	if err := conn.Get(&total, "SELECT COUNT(*) FROM users"); err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	users := make([]user.User, 0, len(results))
+	for _, result := range results {
+		users = append(users, result.ToEntity())
+	}
+
+	return &user.PaginationUsers{
+		Users:            users,
+		PageSize:         pageSize,
+		CurrentPageCount: currentPageCount,
+		Total:            total,
+	}, nil
+}
+
+func (repo *UserRepository) ExistsAdmin(conn *sqlx.DB) (bool, error) {
+	var exists bool
+	if err := conn.Get(&exists, "SELECT EXISTS (SELECT 1 FROM users WHERE role = $1)", user.RoleAdmin); err != nil {
+		return false, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return exists, nil
+}
+
+// ユーザーと、ユーザーが所有する行を全て削除する
+// ファイルと登録されていないアップロードの実体のIDを返す。実体はコミットした後に、参照するファイルが無くなった場合のみ削除する
+func (repo *UserRepository) DeleteUser(tx *sqlx.Tx, user user.User) ([]string, error) {
+	blobIDs := []string{}
+	err := tx.Select(&blobIDs, `
+		SELECT blob_id::TEXT FROM files WHERE user_id = $1 AND blob_id > 0
+		UNION
+		SELECT id::TEXT FROM uploads WHERE user_id = $1 AND file_id IS NULL`,
+		user.ID,
+	)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	tables := []string{
+		"files",
+		"jobs",
+		"shares",
+		"s3_access_keys",
+		"s3_multipart_uploads",
+		"uploads",
+		"api_tokens",
+		"user_sessions",
+		"user_recovery_codes",
+		"user_tokens",
+		"user_identities",
+		"user_usages",
+	}
+	for _, table := range tables {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE user_id = $1", user.ID); err != nil {
+			return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM users WHERE id = $1", user.ID); err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return blobIDs, nil
+}
+
+func (repo *UserRepository) UpdateUserSetting(tx *sqlx.Tx, user user.User) error {
+	_, err := tx.Exec("UPDATE users SET strip_location_metadata = $1 WHERE id = $2", user.StripLocationMetadata, user.ID)
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
+
+// 登録中の秘密鍵を保存する。nilの場合は削除する
+func (repo *UserRepository) UpdateTotpSecret(tx *sqlx.Tx, user user.User, secret *string) error {
+	_, err := tx.Exec("UPDATE users SET totp_secret = $1, totp_last_counter = 0 WHERE id = $2", secret, user.ID)
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
+
+func (repo *UserRepository) UpdateTwoFactorEnabled(tx *sqlx.Tx, user user.User, enabled bool) error {
+	_, err := tx.Exec("UPDATE users SET two_factor_enabled = $1 WHERE id = $2", enabled, user.ID)
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
+
+// 使用したステップを記録する。同時に同じコードが使われた場合は、先に記録した方のみtrueを返す
+func (repo *UserRepository) UpdateTotpLastCounter(tx *sqlx.Tx, user user.User, counter int64) (bool, error) {
+	result, err := tx.Exec("UPDATE users SET totp_last_counter = $1 WHERE id = $2 AND totp_last_counter < $1", counter, user.ID)
+	if err != nil {
+		return false, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return affected == 1, nil
+}
+
+func (repo *UserRepository) GetAuthSetting() user.AuthSetting {
+	return authSetting
 }
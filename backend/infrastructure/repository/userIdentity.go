@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+)
+
+type UserIdentityRepositoryInterface interface {
+	GetUserIDByIdentity(conn *sqlx.DB, issuer string, subject string) (string, error)
+	RegistrationUserIdentity(tx *sqlx.Tx, owner user.User, issuer string, subject string) error
+}
+
+type UserIdentityRepository struct {
+}
+
+func (repo *UserIdentityRepository) GetUserIDByIdentity(conn *sqlx.DB, issuer string, subject string) (string, error) {
+	var userID string
+	err := conn.QueryRowx("SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2", issuer, subject).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errors.WithStack(NotFoundError{Code: 404, Message: "アカウントが紐付けられていません。"})
+	}
+	if err != nil {
+		return "", errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return userID, nil
+}
+
+func (repo *UserIdentityRepository) RegistrationUserIdentity(tx *sqlx.Tx, owner user.User, issuer string, subject string) error {
+	id, err := helper.GenerateSnowflake()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = tx.Exec("INSERT INTO user_identities (id, user_id, issuer, subject) VALUES ($1, $2, $3, $4)", id, owner.ID, issuer, subject)
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/database"
+)
+
+type UserInviteRepositoryInterface interface {
+	RegistrationUserInvite(tx *sqlx.Tx, creator user.User, codeHash string, email *string, expiresAt time.Time) (*user.Invite, error)
+	GetUserInvites(conn *sqlx.DB) ([]user.Invite, error)
+	UseUserInvite(tx *sqlx.Tx, codeHash string, email string, now time.Time) error
+	DeleteUserInvite(tx *sqlx.Tx, id string) error
+}
+
+type UserInviteRepository struct {
+}
+
+func (repo *UserInviteRepository) RegistrationUserInvite(tx *sqlx.Tx, creator user.User, codeHash string, email *string, expiresAt time.Time) (*user.Invite, error) {
+	id, err := helper.GenerateSnowflake()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var result database.UserInvite
+	err = tx.QueryRowx(
+		"INSERT INTO user_invites (id, code_hash, email, created_by, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING *",
+		id,
+		codeHash,
+		email,
+		creator.ID,
+		expiresAt,
+	).StructScan(&result)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	invite := result.ToEntity()
+
+	return &invite, nil
+}
+
+func (repo *UserInviteRepository) GetUserInvites(conn *sqlx.DB) ([]user.Invite, error) {
+	var results []database.UserInvite
+	if err := conn.Select(&results, "SELECT * FROM user_invites ORDER BY created_at DESC, id DESC"); err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	invites := make([]user.Invite, 0, len(results))
+	for _, result := range results {
+		invites = append(invites, result.ToEntity())
+	}
+
+	return invites, nil
+}
+
+// 未使用で期限内の招待コードを使用済みにする。メールアドレスを指定した招待は、そのメールアドレスでのみ使える
+func (repo *UserInviteRepository) UseUserInvite(tx *sqlx.Tx, codeHash string, email string, now time.Time) error {
+	var id string
+	err := tx.QueryRowx(`
+		UPDATE user_invites
+		SET used_at = $1
+		WHERE code_hash = $2 AND used_at IS NULL AND expires_at > $1 AND (email IS NULL OR LOWER(email) = LOWER($3))
+		RETURNING id`,
+		now,
+		codeHash,
+		email,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.WithStack(NotFoundError{Code: 400, Message: "招待コードが無効か、有効期限が切れています。"})
+	}
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
+
+func (repo *UserInviteRepository) DeleteUserInvite(tx *sqlx.Tx, id string) error {
+	result, err := tx.Exec("DELETE FROM user_invites WHERE id = $1", id)
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+	if affected == 0 {
+		return errors.WithStack(NotFoundError{Code: 404, Message: "招待が見つかりません。"})
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/jmoiron/sqlx"
+)
+
+type UserRecoveryCodeRepositoryInterface interface {
+	ReplaceRecoveryCodes(tx *sqlx.Tx, user user.User, codeHashes []string) error
+	UseRecoveryCode(tx *sqlx.Tx, user user.User, codeHash string, usedAt time.Time) (bool, error)
+	DeleteRecoveryCodes(tx *sqlx.Tx, user user.User) error
+}
+
+type UserRecoveryCodeRepository struct {
+}
+
+// 以前のコードを全て削除し、新しいコードに置き換える
+func (repo *UserRecoveryCodeRepository) ReplaceRecoveryCodes(tx *sqlx.Tx, user user.User, codeHashes []string) error {
+	if err := repo.DeleteRecoveryCodes(tx, user); err != nil {
+		return errors.WithStack(err)
+	}
+
+	for _, codeHash := range codeHashes {
+		id, err := helper.GenerateSnowflake()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		_, err = tx.Exec("INSERT INTO user_recovery_codes (id, user_id, code_hash) VALUES ($1, $2, $3)", id, user.ID, codeHash)
+		if err != nil {
+			return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+		}
+	}
+
+	return nil
+}
+
+// 未使用のコードであれば使用済みにしてtrueを返す
+func (repo *UserRecoveryCodeRepository) UseRecoveryCode(tx *sqlx.Tx, user user.User, codeHash string, usedAt time.Time) (bool, error) {
+	result, err := tx.Exec(
+		"UPDATE user_recovery_codes SET used_at = $1 WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL",
+		usedAt,
+		user.ID,
+		codeHash,
+	)
+	if err != nil {
+		return false, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return affected > 0, nil
+}
+
+func (repo *UserRecoveryCodeRepository) DeleteRecoveryCodes(tx *sqlx.Tx, user user.User) error {
+	if _, err := tx.Exec("DELETE FROM user_recovery_codes WHERE user_id = $1", user.ID); err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/database"
+)
+
+type UserSessionRepositoryInterface interface {
+	RegistrationUserSession(tx *sqlx.Tx, session user.Session) (*user.Session, error)
+	GetUserSession(conn *sqlx.DB, id string) (*user.Session, error)
+	GetUserSessions(conn *sqlx.DB, user user.User) ([]user.Session, error)
+	UpdateUserSessionLastSeenAt(conn *sqlx.DB, id string, lastSeenAt time.Time) error
+	DeleteUserSession(tx *sqlx.Tx, user user.User, id string) error
+	DeleteOtherUserSessions(tx *sqlx.Tx, user user.User, exceptID string) error
+	DeleteUserSessions(tx *sqlx.Tx, user user.User) error
+	DeleteExpiredUserSessions(tx *sqlx.Tx, user user.User, now time.Time) error
+}
+
+type UserSessionRepository struct {
+}
+
+func (repo *UserSessionRepository) RegistrationUserSession(tx *sqlx.Tx, session user.Session) (*user.Session, error) {
+	_, err := tx.Exec(`
+		INSERT INTO user_sessions
+			(
+				id,
+				user_id,
+				user_agent,
+				ip_address,
+				created_at,
+				last_seen_at,
+				expires_at,
+				impersonator_id
+			)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		session.ID,
+		session.UserID,
+		session.UserAgent,
+		session.IpAddress,
+		session.CreatedAt,
+		session.LastSeenAt,
+		session.ExpiresAt,
+		session.ImpersonatorID,
+	)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return &session, nil
+}
+
+func (repo *UserSessionRepository) GetUserSession(conn *sqlx.DB, id string) (*user.Session, error) {
+	var result database.UserSession
+	err := conn.QueryRowx("SELECT * FROM user_sessions WHERE id = $1", id).StructScan(&result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.WithStack(NotFoundError{Code: 404, Message: "セッションが見つかりません。"})
+	}
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	s := result.ToEntity()
+
+	return &s, nil
+}
+
+func (repo *UserSessionRepository) GetUserSessions(conn *sqlx.DB, owner user.User) ([]user.Session, error) {
+	rows, err := conn.Queryx("SELECT * FROM user_sessions WHERE user_id = $1 ORDER BY last_seen_at DESC, id DESC", owner.ID)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+	defer rows.Close()
+
+	sessions := make([]user.Session, 0)
+	for rows.Next() {
+		var s database.UserSession
+		if err := rows.StructScan(&s); err != nil {
+			return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "スキャンエラー"}, err))
+		}
+
+		sessions = append(sessions, s.ToEntity())
+	}
+
+	return sessions, nil
+}
+
+func (repo *UserSessionRepository) UpdateUserSessionLastSeenAt(conn *sqlx.DB, id string, lastSeenAt time.Time) error {
+	if _, err := conn.Exec("UPDATE user_sessions SET last_seen_at = $1 WHERE id = $2", lastSeenAt, id); err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
+
+func (repo *UserSessionRepository) DeleteUserSession(tx *sqlx.Tx, user user.User, id string) error {
+	result, err := tx.Exec("DELETE FROM user_sessions WHERE id = $1 AND user_id = $2", id, user.ID)
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+	if affected == 0 {
+		return errors.WithStack(NotFoundError{Code: 404, Message: "セッションが見つかりません。"})
+	}
+
+	return nil
+}
+
+func (repo *UserSessionRepository) DeleteOtherUserSessions(tx *sqlx.Tx, user user.User, exceptID string) error {
+	if _, err := tx.Exec("DELETE FROM user_sessions WHERE user_id = $1 AND id <> $2", user.ID, exceptID); err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
+
+func (repo *UserSessionRepository) DeleteUserSessions(tx *sqlx.Tx, user user.User) error {
+	if _, err := tx.Exec("DELETE FROM user_sessions WHERE user_id = $1", user.ID); err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
+
+func (repo *UserSessionRepository) DeleteExpiredUserSessions(tx *sqlx.Tx, owner user.User, now time.Time) error {
+	_, err := tx.Exec(
+		"DELETE FROM user_sessions WHERE user_id = $1 AND (last_seen_at <= $2 OR expires_at <= $3)",
+		owner.ID,
+		now.Add(-user.SessionIdleTimeout),
+		now,
+	)
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
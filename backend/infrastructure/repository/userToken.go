@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/database"
+)
+
+type UserTokenRepositoryInterface interface {
+	RegistrationUserToken(tx *sqlx.Tx, owner user.User, purpose user.TokenPurpose, tokenHash string, expiresAt time.Time) error
+	UseUserToken(tx *sqlx.Tx, purpose user.TokenPurpose, tokenHash string, now time.Time) (*user.Token, error)
+	DeleteUserTokens(tx *sqlx.Tx, owner user.User, purpose user.TokenPurpose) error
+}
+
+type UserTokenRepository struct {
+}
+
+func (repo *UserTokenRepository) RegistrationUserToken(tx *sqlx.Tx, owner user.User, purpose user.TokenPurpose, tokenHash string, expiresAt time.Time) error {
+	id, err := helper.GenerateSnowflake()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO user_tokens (id, user_id, purpose, token_hash, expires_at) VALUES ($1, $2, $3, $4, $5)",
+		id,
+		owner.ID,
+		purpose,
+		tokenHash,
+		expiresAt,
+	)
+	if err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
+
+// 未使用で期限内のトークンを使用済みにして返す。同時に使われても1回しか成功しない
+func (repo *UserTokenRepository) UseUserToken(tx *sqlx.Tx, purpose user.TokenPurpose, tokenHash string, now time.Time) (*user.Token, error) {
+	var result database.UserToken
+	err := tx.QueryRowx(`
+		UPDATE user_tokens
+		SET used_at = $1
+		WHERE purpose = $2 AND token_hash = $3 AND used_at IS NULL AND expires_at > $1
+		RETURNING *`,
+		now,
+		purpose,
+		tokenHash,
+	).StructScan(&result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.WithStack(NotFoundError{Code: 400, Message: "リンクが無効か、有効期限が切れています。"})
+	}
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	token := result.ToEntity()
+
+	return &token, nil
+}
+
+func (repo *UserTokenRepository) DeleteUserTokens(tx *sqlx.Tx, owner user.User, purpose user.TokenPurpose) error {
+	if _, err := tx.Exec("DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2", owner.ID, purpose); err != nil {
+		return errors.WithStack(errors.Join(FieldSQLError{Code: 500, Message: "エラーが発生しました。"}, err))
+	}
+
+	return nil
+}
@@ -1,8 +1,11 @@
 package route
 
 import (
+	"github.com/YahiroRyo/yappi_storage/backend/domain/apitoken"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/auth"
 	"github.com/YahiroRyo/yappi_storage/backend/presentation/api"
 	"github.com/YahiroRyo/yappi_storage/backend/presentation/controller"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/handling"
 	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
 	"github.com/YahiroRyo/yappi_storage/backend/presentation/ws"
 	"github.com/gofiber/contrib/websocket"
@@ -10,39 +13,161 @@ import (
 )
 
 func SetRoutes(app *fiber.App, controller controller.Controller, api api.Api, wsController ws.WsController, middleware middleware.Middleware, secureFileController controller.SecureFileController) {
+	// 認証した主体はLocalsに保存され、ハンドラではmiddleware.PrincipalFromLocalsで取得する
+	sessionAuth := middleware.Authenticate(auth.MethodSession)
+	// 二段階認証を必須にしている場合、設定していないユーザーは登録のルートのみ使える
+	requireTwoFactor := middleware.RequireTwoFactorEnrollment
+	// OpenID Connectのみでログインさせる場合に、パスワードを扱うルートを無効にする
+	requirePasswordLogin := middleware.RequirePasswordLogin
+	// 認証した主体ごとのリクエストの回数の上限
+	limitRequests := middleware.LimitRequests
+	// 管理者のみが使えるルート
+	requireAdmin := middleware.RequireAdmin
+
 	app.Get("/", func(ctx *fiber.Ctx) error {
 		return ctx.Send(([]byte)("hello"))
 	})
 
-	files := app.Group("/files").Use(middleware.AuthenticateLoggedInUserMiddleware)
+	files := app.Group("/files").Use(sessionAuth, requireTwoFactor, limitRequests)
 	{
 		files.Get("/", controller.GetFiles)
 		files.Post("/", controller.RegistrationFiles)
 		files.Post("/directory", controller.RegistrationDirectory)
 		files.Put("/move", controller.MoveFiles)
 		files.Put("/rename", controller.RenameFile)
+		files.Put("/compression", controller.UpdateCompression)
+		files.Post("/archive", controller.CreateArchive)
+		files.Get("/archive/:job_id", controller.GetArchive)
 		files.Delete("/", controller.DeleteFiles)
 		files.Delete("/delete-cache", controller.DeleteCache)
 		files.Get("/file/:file_id", controller.GetFile)
-		// 本番環境でのセキュアファイルアクセス
+		files.Get("/file/:file_id/thumbnail", controller.GetThumbnail)
+		files.Get("/file/:file_id/sprite", controller.GetSprite)
+		files.Get("/file/:file_id/hls/master.m3u8", controller.GetHLSMasterPlaylist)
+		files.Post("/:file_id/shares", controller.CreateShare)
+		files.Get("/:file_id/shares", controller.GetFileShares)
+		files.Post("/:file_id/extract", controller.ExtractArchive)
+		// 全ての実体は所有権を確認してから配信する
 		files.Get("/secure/:id", secureFileController.GetSecureFile)
 	}
 
+	// HLSのプレイリスト・セグメントは署名付きURLで配信
+	hls := app.Group("/hls").Use(middleware.VerifySignedUrlMiddleware)
+	{
+		hls.Get("/:user_id/:file_id/:rendition/:name", controller.GetHLSResource)
+	}
+
+	shares := app.Group("/shares").Use(sessionAuth, requireTwoFactor, limitRequests)
+	{
+		shares.Get("/", controller.GetShares)
+		shares.Delete("/:share_id", controller.RevokeShare)
+	}
+
+	// 共有リンク(認証なし)
+	sharedLinks := app.Group("/s")
+	{
+		sharedLinks.Get("/:token", controller.GetSharedFile)
+		sharedLinks.Post("/:token/password", controller.UnlockShare)
+		sharedLinks.Get("/:token/files/:file_id", controller.GetSharedFile)
+	}
+
+	jobs := app.Group("/jobs").Use(sessionAuth, requireTwoFactor, limitRequests)
+	{
+		jobs.Get("/", controller.GetJobs)
+		jobs.Get("/:job_id", controller.GetJob)
+		jobs.Delete("/:job_id", controller.CancelJob)
+	}
+
 	users := app.Group("/users")
 	{
+		users.Get("/auth/methods", controller.GetAuthMethods)
+		users.Get("/oidc/login", controller.OidcLogin)
+		users.Get("/oidc/callback", controller.OidcCallback)
+		users.Post("/login", requirePasswordLogin, controller.Login)
+		// シングルサインオンでも二段階認証のチャレンジを返すため、パスワードでのログインが無効でも使える
+		users.Post("/login/verify", controller.VerifyLogin)
+		users.Post("/registration", requirePasswordLogin, controller.Registration)
+		users.Post("/password/forgot", requirePasswordLogin, controller.ForgotPassword)
+		users.Post("/password/reset", requirePasswordLogin, controller.ResetPassword)
+		users.Post("/verify", controller.VerifyEmail)
+		// なりすましのセッションの期限が切れていても管理者に戻れるよう、認証の前に登録する
+		users.Delete("/impersonation", controller.StopImpersonation)
+		users.Use(sessionAuth)
 		users.Get("", controller.GetLoggedInUser)
-		users.Post("/login", controller.Login)
-		users.Post("/registration", controller.Registration)
-		users.Use(middleware.AuthenticateLoggedInUserMiddleware).Post("/logout", controller.Logout)
-		users.Use(middleware.AuthenticateLoggedInUserMiddleware).Post("/generate/token", controller.GenerateToken)
+		users.Post("/logout", controller.Logout)
+		users.Post("/2fa/totp", controller.EnrollTotp)
+		users.Post("/2fa/totp/enable", controller.EnableTotp)
+		users.Delete("/2fa/totp", controller.DisableTotp)
+		users.Post("/2fa/recovery-codes", controller.RegenerateRecoveryCodes)
+		users.Use(requireTwoFactor)
+		users.Post("/generate/token", controller.GenerateToken)
+		users.Post("/tokens", controller.CreateApiToken)
+		users.Get("/tokens", controller.GetApiTokens)
+		users.Delete("/tokens/:id", controller.RevokeApiToken)
+		users.Get("/sessions", controller.GetUserSessions)
+		users.Delete("/sessions", controller.RevokeOtherUserSessions)
+		users.Delete("/sessions/:id", controller.RevokeUserSession)
+		users.Put("/settings", controller.UpdateUserSetting)
+		users.Get("/usage", controller.GetUsage)
+		users.Put("/password", requirePasswordLogin, controller.ChangePassword)
+		users.Post("/verify/resend", controller.ResendEmailVerification)
+		users.Post("/s3/access-keys", controller.CreateS3AccessKey)
+		users.Get("/s3/access-keys", controller.GetS3AccessKeys)
+		users.Delete("/s3/access-keys/:id", controller.DeleteS3AccessKey)
+	}
+
+	// 管理者のAPI(セッションでのみ使える)
+	admin := app.Group("/admin").Use(sessionAuth, requireTwoFactor, requireAdmin)
+	{
+		admin.Get("/users", controller.GetUsers)
+		admin.Put("/users/:id/disabled", controller.UpdateUserDisabled)
+		admin.Delete("/users/:id", controller.DeleteUser)
+		admin.Put("/users/:id/role", controller.UpdateUserRole)
+		admin.Put("/users/:id/quota", controller.UpdateUserQuota)
+		admin.Delete("/users/:id/2fa", controller.ResetUserTwoFactor)
+		admin.Post("/users/:id/impersonate", controller.ImpersonateUser)
+		admin.Post("/invites", controller.CreateUserInvite)
+		admin.Get("/invites", controller.GetUserInvites)
+		admin.Delete("/invites/:id", controller.RevokeUserInvite)
 	}
 
 	ws := app.Group("/ws")
-	ws.Use(middleware.AuthenticateLoggedInUserMiddleware).Get("", websocket.New(wsController.Ws))
+	ws.Use(sessionAuth, requireTwoFactor).Get("", websocket.New(wsController.Ws))
+
+	// WebDAV(Basic認証のパスワードにAPIトークンを使う)
+	dav := app.Group("/dav").Use(
+		middleware.Authenticate(auth.MethodBasic),
+		limitRequests,
+		middleware.RejectDirectoryRestrictedApiToken,
+		middleware.RequireApiTokenScopeByMethod,
+	)
+	dav.All("/*", controller.Dav)
+
+	// S3互換API(パス形式のみ。SigV4の署名をアクセスキーで検証する)
+	s3 := app.Group("/s3").Use(handling.S3ErrorHandler, middleware.AuthenticateS3Middleware)
+	{
+		s3.Get("/", controller.S3ListBuckets)
+		s3.All("/:bucket", controller.S3Bucket)
+		s3.All("/:bucket/*", controller.S3Object)
+	}
+
+	// tusによる再開可能なアップロード(/v1より先に登録し、セッションでも認証できるようにする)
+	uploads := app.Group("/v1/uploads").Use(middleware.TusResumableMiddleware)
+	{
+		uploads.Options("", controller.TusOptions)
+		uploads.Options("/:id", controller.TusOptions)
+		uploads.Use(middleware.Authenticate(auth.MethodSession, auth.MethodBearer), requireTwoFactor, limitRequests, middleware.RequireApiTokenScope(apitoken.ScopeWrite))
+		uploads.Post("", controller.CreateTusUpload)
+		uploads.Head("/:id", controller.GetTusUpload)
+		uploads.Patch("/:id", controller.PatchTusUpload)
+		uploads.Delete("/:id", controller.DeleteTusUpload)
+	}
 
-	v1 := app.Group("/v1").Use(middleware.AuthenticateLoggedInUserMiddlewareByToken)
+	v1 := app.Group("/v1").Use(middleware.Authenticate(auth.MethodBearer), limitRequests)
 	{
-		v1.Post("/files", api.RegistrationFiles)
-		v1.Get("/ws", websocket.New(wsController.Ws))
+		v1.Post("/files", middleware.RequireApiTokenScope(apitoken.ScopeWrite), api.RegistrationFiles)
+		v1.Put("/files/content", middleware.RequireApiTokenScope(apitoken.ScopeWrite), api.PutFileContent)
+		v1.Post("/files/upload", middleware.RequireApiTokenScope(apitoken.ScopeWrite), api.UploadFile)
+		v1.Get("/ws", middleware.RequireApiTokenScope(apitoken.ScopeWrite), websocket.New(wsController.Ws))
 	}
 }
@@ -0,0 +1,112 @@
+package sessionstore
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+)
+
+// 期限切れのセッションを削除する間隔
+const postgresGCInterval = 10 * time.Minute
+
+// PostgresStorage stores fiber sessions in the sessions table.
+type PostgresStorage struct {
+	Conn *sqlx.DB
+	done chan struct{}
+}
+
+func NewPostgresStorage(conn *sqlx.DB) *PostgresStorage {
+	storage := &PostgresStorage{
+		Conn: conn,
+		done: make(chan struct{}),
+	}
+	go storage.gc()
+
+	return storage
+}
+
+func (storage *PostgresStorage) Get(key string) ([]byte, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	var val []byte
+	err := storage.Conn.Get(&val, "SELECT v FROM sessions WHERE k = $1 AND (e = 0 OR e > $2)", key, time.Now().Unix())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return val, nil
+}
+
+func (storage *PostgresStorage) Set(key string, val []byte, exp time.Duration) error {
+	if key == "" || len(val) == 0 {
+		return nil
+	}
+
+	var expiresAt int64
+	if exp > 0 {
+		expiresAt = time.Now().Add(exp).Unix()
+	}
+
+	_, err := storage.Conn.Exec(
+		"INSERT INTO sessions (k, v, e) VALUES ($1, $2, $3) ON CONFLICT (k) DO UPDATE SET v = EXCLUDED.v, e = EXCLUDED.e",
+		key,
+		val,
+		expiresAt,
+	)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+func (storage *PostgresStorage) Delete(key string) error {
+	if key == "" {
+		return nil
+	}
+
+	if _, err := storage.Conn.Exec("DELETE FROM sessions WHERE k = $1", key); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+func (storage *PostgresStorage) Reset() error {
+	if _, err := storage.Conn.Exec("DELETE FROM sessions"); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// 接続は他でも使っているため、削除の処理だけを止める
+func (storage *PostgresStorage) Close() error {
+	close(storage.done)
+
+	return nil
+}
+
+func (storage *PostgresStorage) gc() {
+	ticker := time.NewTicker(postgresGCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-storage.done:
+			return
+		case now := <-ticker.C:
+			if _, err := storage.Conn.Exec("DELETE FROM sessions WHERE e <> 0 AND e <= $1", now.Unix()); err != nil {
+				log.Printf("Failed to delete expired sessions: %+v", err)
+			}
+		}
+	}
+}
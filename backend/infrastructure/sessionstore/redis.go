@@ -0,0 +1,78 @@
+package sessionstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+const redisKeyPrefix = "sessions:"
+
+// RedisStorage stores fiber sessions in Redis so that every replica shares them.
+type RedisStorage struct {
+	Redis *redis.Client
+}
+
+func (storage *RedisStorage) Get(key string) ([]byte, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	val, err := storage.Redis.Get(context.Background(), redisKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return val, nil
+}
+
+func (storage *RedisStorage) Set(key string, val []byte, exp time.Duration) error {
+	if key == "" || len(val) == 0 {
+		return nil
+	}
+
+	if err := storage.Redis.Set(context.Background(), redisKeyPrefix+key, val, exp).Err(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+func (storage *RedisStorage) Delete(key string) error {
+	if key == "" {
+		return nil
+	}
+
+	if err := storage.Redis.Del(context.Background(), redisKeyPrefix+key).Err(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// 他の用途のキーは残し、セッションのキーだけを削除する
+func (storage *RedisStorage) Reset() error {
+	ctx := context.Background()
+
+	iter := storage.Redis.Scan(ctx, 0, redisKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		if err := storage.Redis.Del(ctx, iter.Val()).Err(); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// クライアントは他でも使っているため閉じない
+func (storage *RedisStorage) Close() error {
+	return nil
+}
@@ -1,18 +1,25 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/YahiroRyo/yappi_storage/backend/domain/job"
 	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/database"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/mailer"
 	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
 	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/route"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/sessionstore"
 	"github.com/YahiroRyo/yappi_storage/backend/presentation/api"
 	"github.com/YahiroRyo/yappi_storage/backend/presentation/controller"
 	"github.com/YahiroRyo/yappi_storage/backend/presentation/handling"
 	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/session"
 	"github.com/YahiroRyo/yappi_storage/backend/presentation/ws"
 	"github.com/YahiroRyo/yappi_storage/backend/service"
 	"github.com/cockroachdb/errors"
@@ -27,7 +34,7 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-func diController(conn *sqlx.DB, userRepo repository.UserRepository, fileRepo repository.FileRepository, chatGPTRepo repository.ChatGPTRepository) controller.Controller {
+func diController(conn *sqlx.DB, userRepo repository.UserRepository, fileRepo repository.FileRepository, chatGPTRepo repository.ChatGPTRepository, jobRepo repository.JobRepository, shareRepo repository.ShareRepository, s3Repo repository.S3Repository, uploadRepo repository.UploadRepository, apiTokenRepo repository.ApiTokenRepository, userSessionRepo repository.UserSessionRepository, userRecoveryCodeRepo repository.UserRecoveryCodeRepository, rateLimitRepo repository.RateLimitRepository, userTokenRepo repository.UserTokenRepository, userIdentityRepo repository.UserIdentityRepository, userInviteRepo repository.UserInviteRepository, oidcRepo repository.OidcRepository, quotaRepo repository.QuotaRepository, quotaWarningBroker *service.QuotaWarningBroker, mail mailer.Mailer, thumbnailService service.ThumbnailService) controller.Controller {
 	return controller.Controller{
 		DeleteCacheService: service.DeleteCacheService{
 			FileRepo: &fileRepo,
@@ -56,6 +63,20 @@ func diController(conn *sqlx.DB, userRepo repository.UserRepository, fileRepo re
 			UserRepo:    &userRepo,
 			FileRepo:    &fileRepo,
 			ChatGPTRepo: &chatGPTRepo,
+			UploadRepo:  &uploadRepo,
+			EnqueueJobService: service.EnqueueJobService{
+				Conn:    conn,
+				JobRepo: &jobRepo,
+			},
+			AuthorizeApiTokenDirectoryService: service.AuthorizeApiTokenDirectoryService{
+				Conn:     conn,
+				FileRepo: &fileRepo,
+			},
+			QuotaService: service.QuotaService{
+				Conn:               conn,
+				QuotaRepo:          &quotaRepo,
+				QuotaWarningBroker: quotaWarningBroker,
+			},
 		},
 		RenameFileService: service.RenameFileService{
 			Conn:     conn,
@@ -69,66 +90,458 @@ func diController(conn *sqlx.DB, userRepo repository.UserRepository, fileRepo re
 			Conn:     conn,
 			FileRepo: &fileRepo,
 		},
-
-		GetLoggedInUserService: service.GetLoggedInUserService{
+		UpdateCompressionService: service.UpdateCompressionService{
+			Conn:     conn,
+			FileRepo: &fileRepo,
+		},
+		GetThumbnailService: service.GetThumbnailService{
+			Conn:             conn,
+			FileRepo:         &fileRepo,
+			ThumbnailService: thumbnailService,
+		},
+		GetSpriteService: service.GetSpriteService{
+			Conn:             conn,
+			FileRepo:         &fileRepo,
+			ThumbnailService: thumbnailService,
+		},
+		GetHLSResourceService: service.GetHLSResourceService{
+			Conn:     conn,
+			FileRepo: &fileRepo,
+		},
+		GetJobsService: service.GetJobsService{
+			Conn:    conn,
+			JobRepo: &jobRepo,
+		},
+		GetJobService: service.GetJobService{
+			Conn:    conn,
+			JobRepo: &jobRepo,
+		},
+		CancelJobService: service.CancelJobService{
+			Conn:    conn,
+			JobRepo: &jobRepo,
+		},
+		CreateShareService: service.CreateShareService{
+			Conn:      conn,
+			FileRepo:  &fileRepo,
+			ShareRepo: &shareRepo,
+		},
+		GetSharesService: service.GetSharesService{
+			Conn:      conn,
+			ShareRepo: &shareRepo,
+		},
+		RevokeShareService: service.RevokeShareService{
+			Conn:      conn,
+			ShareRepo: &shareRepo,
+		},
+		GetSharedFileService: service.GetSharedFileService{
+			Conn:      conn,
+			UserRepo:  &userRepo,
+			FileRepo:  &fileRepo,
+			ShareRepo: &shareRepo,
+		},
+		GetSharedFilesService: service.GetSharedFilesService{
+			Conn:     conn,
+			FileRepo: &fileRepo,
+		},
+		DownloadSharedFileService: service.DownloadSharedFileService{
+			Conn:      conn,
+			FileRepo:  &fileRepo,
+			ShareRepo: &shareRepo,
+			GetServedOriginalService: service.GetServedOriginalService{
+				FileRepo: &fileRepo,
+			},
+		},
+		UnlockShareService: service.UnlockShareService{
+			Conn:      conn,
+			ShareRepo: &shareRepo,
+			RateLimitService: service.RateLimitService{
+				RateLimitRepo: &rateLimitRepo,
+			},
+		},
+		ArchiveFilesService: service.ArchiveFilesService{
 			Conn:     conn,
 			UserRepo: &userRepo,
+			FileRepo: &fileRepo,
+			GetServedOriginalService: service.GetServedOriginalService{
+				FileRepo: &fileRepo,
+			},
 		},
-		LoginService: service.LoginService{
+		GetArchiveService: service.GetArchiveService{
+			Conn:    conn,
+			JobRepo: &jobRepo,
+		},
+		ExtractArchiveService: service.ExtractArchiveService{
 			Conn:     conn,
 			UserRepo: &userRepo,
+			FileRepo: &fileRepo,
+			JobRepo:  &jobRepo,
+			EnqueueJobService: service.EnqueueJobService{
+				Conn:    conn,
+				JobRepo: &jobRepo,
+			},
+			QuotaService: service.QuotaService{
+				Conn:               conn,
+				QuotaRepo:          &quotaRepo,
+				QuotaWarningBroker: quotaWarningBroker,
+			},
 		},
-		RegistrationUserService: service.RegistrationUserService{
+		DavService: service.DavService{
 			Conn:     conn,
+			FileRepo: &fileRepo,
+			RegistrationDirectoryService: service.RegistrationDirectoryService{
+				Conn:     conn,
+				UserRepo: &userRepo,
+				FileRepo: &fileRepo,
+			},
+			StoreBlobService: service.StoreBlobService{
+				Conn:     conn,
+				FileRepo: &fileRepo,
+				EnqueueJobService: service.EnqueueJobService{
+					Conn:    conn,
+					JobRepo: &jobRepo,
+				},
+				QuotaService: service.QuotaService{
+					Conn:               conn,
+					QuotaRepo:          &quotaRepo,
+					QuotaWarningBroker: quotaWarningBroker,
+				},
+			},
+		},
+		S3Service: service.S3Service{
+			Conn:     conn,
+			FileRepo: &fileRepo,
+			S3Repo:   &s3Repo,
+			RegistrationDirectoryService: service.RegistrationDirectoryService{
+				Conn:     conn,
+				UserRepo: &userRepo,
+				FileRepo: &fileRepo,
+			},
+			StoreBlobService: service.StoreBlobService{
+				Conn:     conn,
+				FileRepo: &fileRepo,
+				EnqueueJobService: service.EnqueueJobService{
+					Conn:    conn,
+					JobRepo: &jobRepo,
+				},
+				QuotaService: service.QuotaService{
+					Conn:               conn,
+					QuotaRepo:          &quotaRepo,
+					QuotaWarningBroker: quotaWarningBroker,
+				},
+			},
+		},
+		TusService: service.TusService{
+			Conn:       conn,
+			FileRepo:   &fileRepo,
+			UploadRepo: &uploadRepo,
+			StoreBlobService: service.StoreBlobService{
+				Conn:     conn,
+				FileRepo: &fileRepo,
+				EnqueueJobService: service.EnqueueJobService{
+					Conn:    conn,
+					JobRepo: &jobRepo,
+				},
+				QuotaService: service.QuotaService{
+					Conn:               conn,
+					QuotaRepo:          &quotaRepo,
+					QuotaWarningBroker: quotaWarningBroker,
+				},
+			},
+			AuthorizeApiTokenDirectoryService: service.AuthorizeApiTokenDirectoryService{
+				Conn:     conn,
+				FileRepo: &fileRepo,
+			},
+		},
+		EnqueueJobService: service.EnqueueJobService{
+			Conn:    conn,
+			JobRepo: &jobRepo,
+		},
+
+		LoginService: service.LoginService{
+			Conn:            conn,
+			UserRepo:        &userRepo,
+			UserSessionRepo: &userSessionRepo,
+			VerifyTwoFactorService: service.VerifyTwoFactorService{
+				Conn:                 conn,
+				UserRepo:             &userRepo,
+				UserRecoveryCodeRepo: &userRecoveryCodeRepo,
+			},
+			RateLimitService: service.RateLimitService{
+				RateLimitRepo: &rateLimitRepo,
+			},
+		},
+		RegistrationUserService: service.RegistrationUserService{
+			Conn:           conn,
+			UserRepo:       &userRepo,
+			UserInviteRepo: &userInviteRepo,
+			RateLimitService: service.RateLimitService{
+				RateLimitRepo: &rateLimitRepo,
+			},
+			SendEmailVerificationService: service.SendEmailVerificationService{
+				Conn:          conn,
+				UserTokenRepo: &userTokenRepo,
+				Mailer:        mail,
+			},
+		},
+		ForgotPasswordService: service.ForgotPasswordService{
+			Conn:          conn,
+			UserRepo:      &userRepo,
+			UserTokenRepo: &userTokenRepo,
+			Mailer:        mail,
+			RateLimitService: service.RateLimitService{
+				RateLimitRepo: &rateLimitRepo,
+			},
+		},
+		ResetPasswordService: service.ResetPasswordService{
+			Conn:            conn,
+			UserRepo:        &userRepo,
+			UserTokenRepo:   &userTokenRepo,
+			UserSessionRepo: &userSessionRepo,
+			RateLimitService: service.RateLimitService{
+				RateLimitRepo: &rateLimitRepo,
+			},
+		},
+		ChangePasswordService: service.ChangePasswordService{
+			Conn:            conn,
+			UserRepo:        &userRepo,
+			UserTokenRepo:   &userTokenRepo,
+			UserSessionRepo: &userSessionRepo,
+		},
+		SendEmailVerificationService: service.SendEmailVerificationService{
+			Conn:          conn,
+			UserTokenRepo: &userTokenRepo,
+			Mailer:        mail,
+		},
+		VerifyEmailService: service.VerifyEmailService{
+			Conn:          conn,
+			UserRepo:      &userRepo,
+			UserTokenRepo: &userTokenRepo,
+		},
+		GetAuthMethodsService: service.GetAuthMethodsService{
 			UserRepo: &userRepo,
+			OidcRepo: &oidcRepo,
+		},
+		StartOidcLoginService: service.StartOidcLoginService{
+			OidcRepo: &oidcRepo,
+		},
+		OidcLoginService: service.OidcLoginService{
+			Conn:             conn,
+			UserRepo:         &userRepo,
+			UserIdentityRepo: &userIdentityRepo,
+			OidcRepo:         &oidcRepo,
+			LoginService: service.LoginService{
+				Conn:            conn,
+				UserRepo:        &userRepo,
+				UserSessionRepo: &userSessionRepo,
+				RateLimitService: service.RateLimitService{
+					RateLimitRepo: &rateLimitRepo,
+				},
+			},
 		},
 		LogoutService: service.LogoutService{
+			Conn:            conn,
+			UserSessionRepo: &userSessionRepo,
+		},
+		GetUserSessionsService: service.GetUserSessionsService{
+			Conn:            conn,
+			UserSessionRepo: &userSessionRepo,
+		},
+		RevokeUserSessionService: service.RevokeUserSessionService{
+			Conn:            conn,
+			UserSessionRepo: &userSessionRepo,
+		},
+		EnrollTotpService: service.EnrollTotpService{
+			Conn:     conn,
+			UserRepo: &userRepo,
+		},
+		EnableTotpService: service.EnableTotpService{
+			Conn:                 conn,
+			UserRepo:             &userRepo,
+			UserRecoveryCodeRepo: &userRecoveryCodeRepo,
+		},
+		DisableTotpService: service.DisableTotpService{
+			Conn:                 conn,
+			UserRepo:             &userRepo,
+			UserRecoveryCodeRepo: &userRecoveryCodeRepo,
+			VerifyTwoFactorService: service.VerifyTwoFactorService{
+				Conn:                 conn,
+				UserRepo:             &userRepo,
+				UserRecoveryCodeRepo: &userRecoveryCodeRepo,
+			},
+		},
+		RegenerateRecoveryCodesService: service.RegenerateRecoveryCodesService{
+			Conn:                 conn,
+			UserRecoveryCodeRepo: &userRecoveryCodeRepo,
+			VerifyTwoFactorService: service.VerifyTwoFactorService{
+				Conn:                 conn,
+				UserRepo:             &userRepo,
+				UserRecoveryCodeRepo: &userRecoveryCodeRepo,
+			},
+		},
+		CreateApiTokenService: service.CreateApiTokenService{
+			Conn:         conn,
+			FileRepo:     &fileRepo,
+			ApiTokenRepo: &apiTokenRepo,
+		},
+		GetApiTokensService: service.GetApiTokensService{
+			Conn:         conn,
+			ApiTokenRepo: &apiTokenRepo,
+		},
+		RevokeApiTokenService: service.RevokeApiTokenService{
+			Conn:         conn,
+			ApiTokenRepo: &apiTokenRepo,
+		},
+		UpdateUserSettingService: service.UpdateUserSettingService{
+			Conn:     conn,
+			UserRepo: &userRepo,
+		},
+		CreateS3AccessKeyService: service.CreateS3AccessKeyService{
+			Conn:   conn,
+			S3Repo: &s3Repo,
+		},
+		GetS3AccessKeysService: service.GetS3AccessKeysService{
+			Conn:   conn,
+			S3Repo: &s3Repo,
+		},
+		DeleteS3AccessKeyService: service.DeleteS3AccessKeyService{
+			Conn:   conn,
+			S3Repo: &s3Repo,
+		},
+		GetUsersService: service.GetUsersService{
+			Conn:     conn,
+			UserRepo: &userRepo,
+		},
+		UpdateUserDisabledService: service.UpdateUserDisabledService{
+			Conn:            conn,
+			UserRepo:        &userRepo,
+			UserSessionRepo: &userSessionRepo,
+		},
+		DeleteUserService: service.DeleteUserService{
+			Conn:     conn,
+			UserRepo: &userRepo,
+			FileRepo: &fileRepo,
+		},
+		UpdateUserRoleService: service.UpdateUserRoleService{
 			Conn:     conn,
 			UserRepo: &userRepo,
 		},
-		GenerateTokenService: service.GenerateTokenService{
+		UpdateUserQuotaService: service.UpdateUserQuotaService{
 			Conn:     conn,
 			UserRepo: &userRepo,
 		},
+		ResetUserTwoFactorService: service.ResetUserTwoFactorService{
+			Conn:                 conn,
+			UserRepo:             &userRepo,
+			UserRecoveryCodeRepo: &userRecoveryCodeRepo,
+		},
+		ImpersonateUserService: service.ImpersonateUserService{
+			Conn:            conn,
+			UserRepo:        &userRepo,
+			UserSessionRepo: &userSessionRepo,
+		},
+		CreateUserInviteService: service.CreateUserInviteService{
+			Conn:           conn,
+			UserInviteRepo: &userInviteRepo,
+		},
+		GetUserInvitesService: service.GetUserInvitesService{
+			Conn:           conn,
+			UserInviteRepo: &userInviteRepo,
+		},
+		RevokeUserInviteService: service.RevokeUserInviteService{
+			Conn:           conn,
+			UserInviteRepo: &userInviteRepo,
+		},
+		QuotaService: service.QuotaService{
+			Conn:               conn,
+			QuotaRepo:          &quotaRepo,
+			QuotaWarningBroker: quotaWarningBroker,
+		},
 	}
 }
 
-func diApi(conn *sqlx.DB, userRepo repository.UserRepository, fileRepo repository.FileRepository, chatGPTRepo repository.ChatGPTRepository) api.Api {
+func diApi(conn *sqlx.DB, userRepo repository.UserRepository, fileRepo repository.FileRepository, chatGPTRepo repository.ChatGPTRepository, jobRepo repository.JobRepository, uploadRepo repository.UploadRepository, quotaRepo repository.QuotaRepository, quotaWarningBroker *service.QuotaWarningBroker) api.Api {
 	return api.Api{
-		GetUserByTokenService: service.GetUserByTokenService{
-			Conn:     conn,
-			UserRepo: &userRepo,
-		},
 		RegistrationFilesService: service.RegistrationFilesService{
 			Conn:        conn,
 			UserRepo:    &userRepo,
 			FileRepo:    &fileRepo,
 			ChatGPTRepo: &chatGPTRepo,
+			UploadRepo:  &uploadRepo,
+			EnqueueJobService: service.EnqueueJobService{
+				Conn:    conn,
+				JobRepo: &jobRepo,
+			},
+			AuthorizeApiTokenDirectoryService: service.AuthorizeApiTokenDirectoryService{
+				Conn:     conn,
+				FileRepo: &fileRepo,
+			},
+			QuotaService: service.QuotaService{
+				Conn:               conn,
+				QuotaRepo:          &quotaRepo,
+				QuotaWarningBroker: quotaWarningBroker,
+			},
+		},
+		UploadFileService: service.UploadFileService{
+			Conn:     conn,
+			FileRepo: &fileRepo,
+			StoreBlobService: service.StoreBlobService{
+				Conn:     conn,
+				FileRepo: &fileRepo,
+				EnqueueJobService: service.EnqueueJobService{
+					Conn:    conn,
+					JobRepo: &jobRepo,
+				},
+				QuotaService: service.QuotaService{
+					Conn:               conn,
+					QuotaRepo:          &quotaRepo,
+					QuotaWarningBroker: quotaWarningBroker,
+				},
+			},
+			AuthorizeApiTokenDirectoryService: service.AuthorizeApiTokenDirectoryService{
+				Conn:     conn,
+				FileRepo: &fileRepo,
+			},
 		},
 	}
 }
 
-func diMiddleware(conn *sqlx.DB, userRepo repository.UserRepository, fileRepo repository.FileRepository, chatGPTRepo repository.ChatGPTRepository) middleware.Middleware {
+func diMiddleware(conn *sqlx.DB, userRepo repository.UserRepository, fileRepo repository.FileRepository, chatGPTRepo repository.ChatGPTRepository, s3Repo repository.S3Repository, apiTokenRepo repository.ApiTokenRepository, userSessionRepo repository.UserSessionRepository, rateLimitRepo repository.RateLimitRepository) middleware.Middleware {
 	return middleware.Middleware{
 		GetLoggedInUserService: service.GetLoggedInUserService{
-			Conn:     conn,
+			Conn:            conn,
+			UserRepo:        &userRepo,
+			UserSessionRepo: &userSessionRepo,
+		},
+		GetAuthSettingService: service.GetAuthSettingService{
 			UserRepo: &userRepo,
 		},
-		GetUserByTokenService: service.GetUserByTokenService{
+		AuthenticateApiTokenService: service.AuthenticateApiTokenService{
+			Conn:         conn,
+			UserRepo:     &userRepo,
+			ApiTokenRepo: &apiTokenRepo,
+		},
+		AuthenticateS3RequestService: service.AuthenticateS3RequestService{
 			Conn:     conn,
 			UserRepo: &userRepo,
+			S3Repo:   &s3Repo,
+		},
+		RateLimitService: service.RateLimitService{
+			RateLimitRepo: &rateLimitRepo,
 		},
 	}
 }
 
-func diWs(conn *sqlx.DB, userRepo repository.UserRepository, fileRepo repository.FileRepository, chatGPTRepo repository.ChatGPTRepository) ws.WsController {
+func diWs(conn *sqlx.DB, userRepo repository.UserRepository, fileRepo repository.FileRepository, chatGPTRepo repository.ChatGPTRepository, jobRepo repository.JobRepository, uploadRepo repository.UploadRepository, quotaRepo repository.QuotaRepository, quotaWarningBroker *service.QuotaWarningBroker) ws.WsController {
 	return ws.WsController{
 		UploadFileChunkService: service.UploadFileChunkService{
-			FileRepo: &fileRepo,
-		},
-		GetLoggedInUserService: service.GetLoggedInUserService{
-			Conn:     conn,
-			UserRepo: &userRepo,
+			Conn:       conn,
+			FileRepo:   &fileRepo,
+			UploadRepo: &uploadRepo,
+			QuotaService: service.QuotaService{
+				Conn:               conn,
+				QuotaRepo:          &quotaRepo,
+				QuotaWarningBroker: quotaWarningBroker,
+			},
 		},
 		GetStorageSettingService: service.GetStorageSettingService{
 			FileRepo: &fileRepo,
@@ -136,19 +549,198 @@ func diWs(conn *sqlx.DB, userRepo repository.UserRepository, fileRepo repository
 		GetStoreStoragePathService: service.GetStoreStoragePathService{
 			FileRepo: &fileRepo,
 		},
-		VideoCompressionService: service.NewVideoCompressionService(),
+		QuotaService: service.QuotaService{
+			Conn:               conn,
+			QuotaRepo:          &quotaRepo,
+			QuotaWarningBroker: quotaWarningBroker,
+		},
+	}
+}
+
+func diJobRunner(conn *sqlx.DB, userRepo repository.UserRepository, fileRepo repository.FileRepository, jobRepo repository.JobRepository, quotaRepo repository.QuotaRepository, quotaWarningBroker *service.QuotaWarningBroker, thumbnailService service.ThumbnailService, videoCompressionService service.VideoCompressionService) *service.JobRunner {
+	generateDerivativesService := service.GenerateDerivativesService{
+		FileRepo:         &fileRepo,
+		ThumbnailService: thumbnailService,
+	}
+	transcodeVideoService := service.TranscodeVideoService{
+		Conn:                    conn,
+		FileRepo:                &fileRepo,
+		VideoCompressionService: videoCompressionService,
+	}
+
+	archiveFilesService := service.ArchiveFilesService{
+		Conn:     conn,
+		UserRepo: &userRepo,
+		FileRepo: &fileRepo,
+		GetServedOriginalService: service.GetServedOriginalService{
+			FileRepo: &fileRepo,
+		},
+	}
+
+	extractArchiveService := service.ExtractArchiveService{
+		Conn:     conn,
+		UserRepo: &userRepo,
+		FileRepo: &fileRepo,
+		JobRepo:  &jobRepo,
+		EnqueueJobService: service.EnqueueJobService{
+			Conn:    conn,
+			JobRepo: &jobRepo,
+		},
+		QuotaService: service.QuotaService{
+			Conn:               conn,
+			QuotaRepo:          &quotaRepo,
+			QuotaWarningBroker: quotaWarningBroker,
+		},
+	}
+
+	importUrlService := service.ImportUrlService{
+		Conn:     conn,
+		UserRepo: &userRepo,
+		FileRepo: &fileRepo,
+		JobRepo:  &jobRepo,
+		StoreBlobService: service.StoreBlobService{
+			Conn:     conn,
+			FileRepo: &fileRepo,
+			EnqueueJobService: service.EnqueueJobService{
+				Conn:    conn,
+				JobRepo: &jobRepo,
+			},
+			QuotaService: service.QuotaService{
+				Conn:               conn,
+				QuotaRepo:          &quotaRepo,
+				QuotaWarningBroker: quotaWarningBroker,
+			},
+		},
 	}
+
+	runner := &service.JobRunner{
+		Conn:    conn,
+		JobRepo: &jobRepo,
+	}
+	runner.Register(job.TypeGenerateDerivatives, generateDerivativesService.Handle)
+	runner.Register(job.TypeTranscodeVideo, transcodeVideoService.Handle)
+	runner.Register(job.TypeCreateArchive, archiveFilesService.Handle)
+	runner.Register(job.TypeExtractArchive, extractArchiveService.Handle)
+	runner.Register(job.TypeImportUrl, importUrlService.Handle)
+
+	return runner
 }
 
 func diSecureFileController(conn *sqlx.DB, userRepo repository.UserRepository, fileRepo repository.FileRepository, chatGPTRepo repository.ChatGPTRepository) controller.SecureFileController {
 	return controller.SecureFileController{
-		GetFileService: &service.GetFileService{
+		GetSecureFileService: &service.GetSecureFileService{
 			Conn:     conn,
 			FileRepo: &fileRepo,
+			GetServedOriginalService: service.GetServedOriginalService{
+				FileRepo: &fileRepo,
+			},
 		},
 	}
 }
 
+// SESSION_STORE でCookieのセッションの保存先を選ぶ。複数台で動かせるようにローカルには保存しない
+func diSessionStorage(conn *sqlx.DB, redisClient *redis.Client) fiber.Storage {
+	switch os.Getenv("SESSION_STORE") {
+	case "", "redis":
+		return &sessionstore.RedisStorage{Redis: redisClient}
+	case "postgres":
+		return sessionstore.NewPostgresStorage(conn)
+	default:
+		panic(errors.Newf("unknown session store: %s", os.Getenv("SESSION_STORE")))
+	}
+}
+
+// MAIL_DRIVER でメールの送信方法を選ぶ。開発環境では送信せずにファイルとログに出力する
+func diMailer() mailer.Mailer {
+	switch os.Getenv("MAIL_DRIVER") {
+	case "", "file":
+		return &mailer.FileMailer{Dir: "./storage/mails", From: os.Getenv("MAIL_FROM")}
+	case "smtp":
+		return &mailer.SmtpMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+	default:
+		panic(errors.Newf("unknown mail driver: %s", os.Getenv("MAIL_DRIVER")))
+	}
+}
+
+// OIDC_ISSUER を指定するとOpenID Connectでログインできる。リダイレクトURIは省略すると BASE_URL から決める
+func diOidcRepository() repository.OidcRepository {
+	redirectUrl := os.Getenv("OIDC_REDIRECT_URL")
+	if redirectUrl == "" {
+		redirectUrl = os.Getenv("BASE_URL") + "/users/oidc/callback"
+	}
+
+	return repository.NewOidcRepository(
+		os.Getenv("OIDC_ISSUER"),
+		os.Getenv("OIDC_CLIENT_ID"),
+		os.Getenv("OIDC_CLIENT_SECRET"),
+		redirectUrl,
+	)
+}
+
+// TRUSTED_PROXIES に指定したプロキシからのリクエストのみ X-Forwarded-For を信用する
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	return proxies
+}
+
+func proxyHeader() string {
+	if len(trustedProxies()) == 0 {
+		return ""
+	}
+
+	return fiber.HeaderXForwardedFor
+}
+
+// ADMIN_EMAIL と ADMIN_PASSWORD を指定すると、管理者がいない場合のみ起動時に作成する
+func bootstrapAdmin(conn *sqlx.DB, userRepo repository.UserRepository) {
+	email := os.Getenv("ADMIN_EMAIL")
+	if email == "" {
+		return
+	}
+
+	bootstrapAdminService := service.BootstrapAdminService{
+		Conn:     conn,
+		UserRepo: &userRepo,
+	}
+	if err := bootstrapAdminService.ExecuteIfNoAdmin(email, os.Getenv("ADMIN_PASSWORD")); err != nil {
+		log.Fatalf("error bootstrapping admin: %+v", err)
+	}
+}
+
+// ./backend create-admin <email>
+// パスワードは履歴に残らないよう標準入力から読む。登録済みのメールアドレスは管理者にするのみ
+func runCommand(conn *sqlx.DB, userRepo repository.UserRepository, args []string) {
+	if args[0] != "create-admin" || len(args) != 2 {
+		log.Fatalf("usage: %s create-admin <email>", os.Args[0])
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		log.Fatalf("error reading password: %v", errors.WithStack(err))
+	}
+
+	bootstrapAdminService := service.BootstrapAdminService{
+		Conn:     conn,
+		UserRepo: &userRepo,
+	}
+	if err := bootstrapAdminService.Execute(args[1], strings.TrimRight(password, "\r\n"), time.Now()); err != nil {
+		log.Fatalf("error creating admin: %+v", err)
+	}
+}
+
 func main() {
 	redisClient := redis.NewClient(&redis.Options{
 		Addr:     "redis:6379",
@@ -164,12 +756,21 @@ func main() {
 		}),
 	}
 	chatGPTRepo := repository.ChatGPTRepository{}
-
-	app := fiber.New(fiber.Config{
-		JSONEncoder:  json.Marshal,
-		JSONDecoder:  json.Unmarshal,
-		ErrorHandler: handling.ErrorHandler,
-	})
+	jobRepo := repository.JobRepository{}
+	shareRepo := repository.ShareRepository{}
+	s3Repo := repository.S3Repository{}
+	uploadRepo := repository.UploadRepository{}
+	apiTokenRepo := repository.ApiTokenRepository{}
+	userSessionRepo := repository.UserSessionRepository{}
+	userRecoveryCodeRepo := repository.UserRecoveryCodeRepository{}
+	rateLimitRepo := repository.RateLimitRepository{Redis: redisClient}
+	userTokenRepo := repository.UserTokenRepository{}
+	userIdentityRepo := repository.UserIdentityRepository{}
+	userInviteRepo := repository.UserInviteRepository{}
+	quotaRepo := repository.QuotaRepository{}
+	quotaWarningBroker := service.NewQuotaWarningBroker()
+	thumbnailService := service.NewThumbnailService()
+	videoCompressionService := service.NewVideoCompressionService()
 
 	err := godotenv.Load(".env")
 
@@ -177,12 +778,75 @@ func main() {
 		panic(errors.WithStack(err))
 	}
 
+	oidcRepo := diOidcRepository()
+
+	app := fiber.New(fiber.Config{
+		JSONEncoder:  json.Marshal,
+		JSONDecoder:  json.Unmarshal,
+		ErrorHandler: handling.ErrorHandler,
+		// WebDAVのメソッド
+		RequestMethods: append(fiber.DefaultMethods, "PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK"),
+		// 大きなファイルのアップロードをメモリに載せずに読み込む
+		StreamRequestBody: true,
+		// multipart/form-dataも一時ファイルに展開せず、本文から直接読み込む
+		DisablePreParseMultipartForm: true,
+		// レート制限のため、リバースプロキシ越しでもクライアントのIPアドレスを使う
+		ProxyHeader:             proxyHeader(),
+		EnableTrustedProxyCheck: len(trustedProxies()) > 0,
+		TrustedProxies:          trustedProxies(),
+		EnableIPValidation:      true,
+	})
+
 	conn, err := database.ConnectToDB()
 	if err != nil {
 		panic(errors.WithStack(err))
 	}
 	defer conn.Close()
 
+	if len(os.Args) > 1 {
+		runCommand(conn, userRepo, os.Args[1:])
+		return
+	}
+
+	// 設定ファイルが無いか保存先が無い場合は、アップロードを受け付けられないため起動しない
+	if storePaths, _ := fileRepo.GetStorageSetting(); len(storePaths) == 0 {
+		log.Fatalf("no mounts are configured in storage_config.yaml")
+	}
+
+	bootstrapAdmin(conn, userRepo)
+
+	// 実体のIDを記録する前に登録されたファイルは、実体を参照する処理より前に解決する
+	resolveLegacyBlobIDsService := service.ResolveLegacyBlobIDsService{
+		Conn:     conn,
+		FileRepo: &fileRepo,
+	}
+	if err := resolveLegacyBlobIDsService.Execute(); err != nil {
+		log.Fatalf("error resolving blob ids: %+v", err)
+	}
+
+	session.Setup(diSessionStorage(conn, redisClient))
+
+	// 容量の導入前に登録されたファイルの大きさを計測する
+	go func() {
+		backfillFileSizesService := service.BackfillFileSizesService{
+			Conn:     conn,
+			FileRepo: &fileRepo,
+		}
+		if err := backfillFileSizesService.Execute(context.Background()); err != nil {
+			log.Printf("Failed to record file sizes: %+v", err)
+		}
+	}()
+
+	// 期限を過ぎたアップロードの実体を定期的に削除し、予約した容量を解放する
+	deleteExpiredUploadsService := service.DeleteExpiredUploadsService{
+		Conn:       conn,
+		UploadRepo: &uploadRepo,
+	}
+	go deleteExpiredUploadsService.Start(context.Background())
+
+	// バックグラウンドジョブのワーカーを起動
+	go diJobRunner(conn, userRepo, fileRepo, jobRepo, quotaRepo, quotaWarningBroker, thumbnailService, videoCompressionService).Start(context.Background())
+
 	file, err := os.OpenFile(fmt.Sprintf("./storage/logs/%s.log", time.Now().Format("2006-01-02")), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
 		log.Fatalf("error opening file: %v", errors.WithStack(err))
@@ -199,19 +863,12 @@ func main() {
 		TimeZone:   "Asia/Tokyo",
 	}))
 
-	// 静的ファイル配信（認証なし）
-	app.Static("/static", "./storage/files", fiber.Static{
-		Compress:  false,
-		Browse:    true,
-		ByteRange: true,
-	})
-
 	route.SetRoutes(
 		app,
-		diController(conn, userRepo, fileRepo, chatGPTRepo),
-		diApi(conn, userRepo, fileRepo, chatGPTRepo),
-		diWs(conn, userRepo, fileRepo, chatGPTRepo),
-		diMiddleware(conn, userRepo, fileRepo, chatGPTRepo),
+		diController(conn, userRepo, fileRepo, chatGPTRepo, jobRepo, shareRepo, s3Repo, uploadRepo, apiTokenRepo, userSessionRepo, userRecoveryCodeRepo, rateLimitRepo, userTokenRepo, userIdentityRepo, userInviteRepo, oidcRepo, quotaRepo, quotaWarningBroker, diMailer(), thumbnailService),
+		diApi(conn, userRepo, fileRepo, chatGPTRepo, jobRepo, uploadRepo, quotaRepo, quotaWarningBroker),
+		diWs(conn, userRepo, fileRepo, chatGPTRepo, jobRepo, uploadRepo, quotaRepo, quotaWarningBroker),
+		diMiddleware(conn, userRepo, fileRepo, chatGPTRepo, s3Repo, apiTokenRepo, userSessionRepo, rateLimitRepo),
 		diSecureFileController(conn, userRepo, fileRepo, chatGPTRepo),
 	)
 
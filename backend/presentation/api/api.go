@@ -3,6 +3,6 @@ package api
 import "github.com/YahiroRyo/yappi_storage/backend/service"
 
 type Api struct {
-	GetUserByTokenService    service.GetUserByTokenService
 	RegistrationFilesService service.RegistrationFilesService
+	UploadFileService        service.UploadFileService
 }
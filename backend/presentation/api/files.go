@@ -1,8 +1,15 @@
 package api
 
 import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+
 	"github.com/YahiroRyo/yappi_storage/backend/helper/validate"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
 	"github.com/YahiroRyo/yappi_storage/backend/presentation/request"
+	"github.com/YahiroRyo/yappi_storage/backend/service"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -17,15 +24,86 @@ func (api *Api) RegistrationFiles(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	user, err := api.GetUserByTokenService.Execute(ctx.Get("Authorization"))
+	principal, err := middleware.PrincipalFromLocals(ctx)
 	if err != nil {
 		return err
 	}
+	loggedInUser := principal.User
 
-	files, err := api.RegistrationFilesService.Execute(*user, req)
+	files, err := api.RegistrationFilesService.Execute(loggedInUser, principal.ApiToken, req)
 	if err != nil {
 		return err
 	}
 
 	return ctx.JSON(files)
 }
+
+func requestBody(ctx *fiber.Ctx) io.Reader {
+	if body := ctx.Request().BodyStream(); body != nil {
+		return body
+	}
+
+	return bytes.NewReader(ctx.Request().Body())
+}
+
+// 本文をそのままファイルとして保存する
+func (api *Api) PutFileContent(ctx *fiber.Ctx) error {
+	req := request.PutFileContentRequest{}
+
+	if err := ctx.QueryParser(&req); err != nil {
+		return err
+	}
+
+	if err := validate.Validate(req); err != nil {
+		return err
+	}
+
+	if req.ParentDirectoryId != nil && *req.ParentDirectoryId == "" {
+		req.ParentDirectoryId = nil
+	}
+
+	principal, err := middleware.PrincipalFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+	loggedInUser := principal.User
+
+	file, err := api.UploadFileService.Execute(
+		loggedInUser,
+		principal.ApiToken,
+		req.ParentDirectoryId,
+		req.Name,
+		requestBody(ctx),
+		int64(ctx.Request().Header.ContentLength()),
+		service.UploadDigests{
+			ContentMD5: ctx.Get("Content-MD5"),
+			Digest:     ctx.Get("Digest"),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(file)
+}
+
+// multipart/form-dataのfileを保存する
+func (api *Api) UploadFile(ctx *fiber.Ctx) error {
+	mediaType, params, err := mime.ParseMediaType(ctx.Get(fiber.HeaderContentType))
+	if err != nil || mediaType != fiber.MIMEMultipartForm || params["boundary"] == "" {
+		return service.InvalidUploadError{Code: 415, Message: "Content-Typeはmultipart/form-dataを指定してください。"}
+	}
+
+	principal, err := middleware.PrincipalFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+	loggedInUser := principal.User
+
+	file, err := api.UploadFileService.ExecuteMultipart(loggedInUser, principal.ApiToken, multipart.NewReader(requestBody(ctx), params["boundary"]))
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(file)
+}
@@ -0,0 +1,244 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper/validate"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/request"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/response"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/session"
+)
+
+func (controller *Controller) GetUsers(ctx *fiber.Ctx) error {
+	req := request.GetUsersRequest{}
+	if err := ctx.QueryParser(&req); err != nil {
+		return err
+	}
+
+	if err := validate.Validate(req); err != nil {
+		return err
+	}
+
+	users, err := controller.GetUsersService.Execute(req.CurrentPageCount, req.PageSize)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(users)
+}
+
+func (controller *Controller) UpdateUserDisabled(ctx *fiber.Ctx) error {
+	params := request.AdminUserRequest{}
+	if err := ctx.ParamsParser(&params); err != nil {
+		return err
+	}
+
+	req := request.UpdateUserDisabledRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	admin, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	updated, err := controller.UpdateUserDisabledService.Execute(*admin, params.Id, req.Disabled, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(updated)
+}
+
+func (controller *Controller) DeleteUser(ctx *fiber.Ctx) error {
+	params := request.AdminUserRequest{}
+	if err := ctx.ParamsParser(&params); err != nil {
+		return err
+	}
+
+	admin, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := controller.DeleteUserService.Execute(*admin, params.Id); err != nil {
+		return err
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (controller *Controller) UpdateUserRole(ctx *fiber.Ctx) error {
+	params := request.AdminUserRequest{}
+	if err := ctx.ParamsParser(&params); err != nil {
+		return err
+	}
+
+	req := request.UpdateUserRoleRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	if err := validate.Validate(req); err != nil {
+		return err
+	}
+
+	role, ok := user.RoleFromString(req.Role)
+	if !ok {
+		return validate.ValidationError{Code: 400, Message: "ロールはuser, adminのいずれかを指定してください。"}
+	}
+
+	admin, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	updated, err := controller.UpdateUserRoleService.Execute(*admin, params.Id, role)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(updated)
+}
+
+func (controller *Controller) UpdateUserQuota(ctx *fiber.Ctx) error {
+	params := request.AdminUserRequest{}
+	if err := ctx.ParamsParser(&params); err != nil {
+		return err
+	}
+
+	req := request.UpdateUserQuotaRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	if req.QuotaBytes != nil && *req.QuotaBytes < 0 {
+		return validate.ValidationError{Code: 400, Message: "容量は0以上で指定してください。"}
+	}
+
+	admin, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	updated, err := controller.UpdateUserQuotaService.Execute(*admin, params.Id, req.QuotaBytes)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(updated)
+}
+
+func (controller *Controller) ResetUserTwoFactor(ctx *fiber.Ctx) error {
+	params := request.AdminUserRequest{}
+	if err := ctx.ParamsParser(&params); err != nil {
+		return err
+	}
+
+	admin, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := controller.ResetUserTwoFactorService.Execute(*admin, params.Id); err != nil {
+		return err
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// 以降のリクエストは指定したユーザーとして扱う。DELETE /users/impersonation で管理者に戻る
+func (controller *Controller) ImpersonateUser(ctx *fiber.Ctx) error {
+	params := request.AdminUserRequest{}
+	if err := ctx.ParamsParser(&params); err != nil {
+		return err
+	}
+
+	admin, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	sess, err := session.GetSession(ctx)
+	if err != nil {
+		return err
+	}
+
+	target, err := controller.ImpersonateUserService.Execute(sess, *admin, params.Id, ctx.Get(fiber.HeaderUserAgent), ctx.IP(), time.Now())
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(target)
+}
+
+func (controller *Controller) StopImpersonation(ctx *fiber.Ctx) error {
+	sess, err := session.GetSession(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := controller.ImpersonateUserService.Stop(sess); err != nil {
+		return err
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (controller *Controller) CreateUserInvite(ctx *fiber.Ctx) error {
+	req := request.CreateUserInviteRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	if req.Email != nil && *req.Email == "" {
+		req.Email = nil
+	}
+	if req.Email != nil {
+		if err := validate.Email(*req.Email, "メールアドレス"); err != nil {
+			return err
+		}
+	}
+
+	admin, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	invite, code, err := controller.CreateUserInviteService.Execute(*admin, req.Email, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(response.CreateUserInviteResponse{
+		Invite: *invite,
+		Code:   code,
+	})
+}
+
+func (controller *Controller) GetUserInvites(ctx *fiber.Ctx) error {
+	invites, err := controller.GetUserInvitesService.Execute()
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(invites)
+}
+
+func (controller *Controller) RevokeUserInvite(ctx *fiber.Ctx) error {
+	req := request.RevokeUserInviteRequest{}
+	if err := ctx.ParamsParser(&req); err != nil {
+		return err
+	}
+
+	if err := controller.RevokeUserInviteService.Execute(req.Id); err != nil {
+		return err
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
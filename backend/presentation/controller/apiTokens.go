@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/apitoken"
+	"github.com/YahiroRyo/yappi_storage/backend/helper/validate"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/request"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/response"
+)
+
+func (controller *Controller) CreateApiToken(ctx *fiber.Ctx) error {
+	req := request.CreateApiTokenRequest{}
+
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	if err := validate.Validate(req); err != nil {
+		return err
+	}
+
+	if len(req.Scopes) == 0 {
+		return validate.ValidationError{Code: 400, Message: "権限を1つ以上指定してください。"}
+	}
+
+	scopes := []apitoken.Scope{}
+	for _, value := range req.Scopes {
+		scope, ok := apitoken.ScopeFromString(value)
+		if !ok {
+			return validate.ValidationError{Code: 400, Message: "権限はread, write, delete, adminのいずれかを指定してください。"}
+		}
+		scopes = append(scopes, scope)
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return validate.ValidationError{Code: 400, Message: "有効期限には未来の日時を指定してください。"}
+	}
+
+	if req.DirectoryId != nil && *req.DirectoryId == "" {
+		req.DirectoryId = nil
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	created, token, err := controller.CreateApiTokenService.Execute(*user, req.Name, scopes, req.DirectoryId, req.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(response.CreateApiTokenResponse{
+		ApiToken: *created,
+		Token:    token,
+	})
+}
+
+func (controller *Controller) GetApiTokens(ctx *fiber.Ctx) error {
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	tokens, err := controller.GetApiTokensService.Execute(*user)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(tokens)
+}
+
+func (controller *Controller) RevokeApiToken(ctx *fiber.Ctx) error {
+	req := request.RevokeApiTokenRequest{}
+	if err := ctx.ParamsParser(&req); err != nil {
+		return err
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := controller.RevokeApiTokenService.Execute(*user, req.Id); err != nil {
+		return err
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
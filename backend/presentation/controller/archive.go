@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"log"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/job"
+	"github.com/YahiroRyo/yappi_storage/backend/helper/validate"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/request"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/response"
+	"github.com/gofiber/fiber/v2"
+)
+
+func (controller *Controller) CreateArchive(ctx *fiber.Ctx) error {
+	req := request.CreateArchiveRequest{}
+
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	if err := validate.Validate(req); err != nil {
+		return err
+	}
+
+	if len(req.FileIds) == 0 {
+		return validate.ValidationError{Code: 400, Message: "ファイルIDは必須項目です。"}
+	}
+
+	format, ok := file.ArchiveFormatFromString(req.Format)
+	if !ok {
+		return validate.ValidationError{Code: 400, Message: "形式はzip, tar.gzのいずれかを指定してください。"}
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 大きなアーカイブはジョブで作成し、完了後に GET /files/archive/:job_id で取得する
+	if req.Async {
+		enqueuedJob, err := controller.EnqueueJobService.Execute(user.ID, job.TypeCreateArchive, job.ArchivePayload{
+			FileIDs: req.FileIds,
+			Format:  string(format),
+		})
+		if err != nil {
+			return err
+		}
+
+		return ctx.Status(fiber.StatusAccepted).JSON(enqueuedJob)
+	}
+
+	archive, err := controller.ArchiveFilesService.Collect(*user, req.FileIds)
+	if err != nil {
+		return err
+	}
+
+	ctx.Set(fiber.HeaderContentType, format.MimeType())
+	ctx.Set(fiber.HeaderContentDisposition, response.ContentDisposition(true, archive.Name+format.Extension()))
+	ctx.Set(fiber.HeaderCacheControl, "private, no-store")
+
+	// 作成しながら送信するため、途中で失敗した場合はステータスを変えられず接続を切る
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := controller.ArchiveFilesService.Write(context.Background(), w, format, *archive); err != nil {
+			log.Printf("Failed to stream archive: %v", err)
+		}
+	})
+
+	return nil
+}
+
+func (controller *Controller) GetArchive(ctx *fiber.Ctx) error {
+	req := request.GetArchiveRequest{}
+
+	if err := ctx.ParamsParser(&req); err != nil {
+		return err
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	served, err := controller.GetArchiveService.Execute(*user, req.JobId)
+	if err != nil {
+		return err
+	}
+
+	return response.SendFile(ctx, response.SendFileOptions{
+		Path:         served.Path,
+		Filename:     served.Filename,
+		MimeType:     served.MimeType,
+		Download:     true,
+		CacheControl: "private, no-store",
+	})
+}
+
+func (controller *Controller) ExtractArchive(ctx *fiber.Ctx) error {
+	req := request.ExtractArchiveRequest{}
+
+	if err := ctx.ParamsParser(&req); err != nil {
+		return err
+	}
+
+	// 展開先を指定しない場合は本文を省略できる
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&req); err != nil {
+			return err
+		}
+	}
+
+	if err := validate.Validate(req); err != nil {
+		return err
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	enqueuedJob, err := controller.ExtractArchiveService.Execute(*user, req.FileId, req.ParentDirectoryId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusAccepted).JSON(enqueuedJob)
+}
@@ -12,10 +12,62 @@ type Controller struct {
 	MoveFilesService             service.MoveFilesService
 	RenameFileService            service.RenameFileService
 	DeleteFilesService           service.DeleteFilesService
+	UpdateCompressionService     service.UpdateCompressionService
+	GetThumbnailService          service.GetThumbnailService
+	GetSpriteService             service.GetSpriteService
+	GetHLSResourceService        service.GetHLSResourceService
+	GetJobsService               service.GetJobsService
+	GetJobService                service.GetJobService
+	CancelJobService             service.CancelJobService
+	CreateShareService           service.CreateShareService
+	GetSharesService             service.GetSharesService
+	RevokeShareService           service.RevokeShareService
+	GetSharedFileService         service.GetSharedFileService
+	GetSharedFilesService        service.GetSharedFilesService
+	DownloadSharedFileService    service.DownloadSharedFileService
+	UnlockShareService           service.UnlockShareService
+	ArchiveFilesService          service.ArchiveFilesService
+	GetArchiveService            service.GetArchiveService
+	ExtractArchiveService        service.ExtractArchiveService
+	DavService                   service.DavService
+	S3Service                    service.S3Service
+	TusService                   service.TusService
+	EnqueueJobService            service.EnqueueJobService
 
-	GetLoggedInUserService  service.GetLoggedInUserService
-	LoginService            service.LoginService
-	RegistrationUserService service.RegistrationUserService
-	LogoutService           service.LogoutService
-	GenerateTokenService    service.GenerateTokenService
+	LoginService                   service.LoginService
+	RegistrationUserService        service.RegistrationUserService
+	LogoutService                  service.LogoutService
+	ForgotPasswordService          service.ForgotPasswordService
+	ResetPasswordService           service.ResetPasswordService
+	ChangePasswordService          service.ChangePasswordService
+	SendEmailVerificationService   service.SendEmailVerificationService
+	VerifyEmailService             service.VerifyEmailService
+	GetAuthMethodsService          service.GetAuthMethodsService
+	StartOidcLoginService          service.StartOidcLoginService
+	OidcLoginService               service.OidcLoginService
+	GetUserSessionsService         service.GetUserSessionsService
+	RevokeUserSessionService       service.RevokeUserSessionService
+	EnrollTotpService              service.EnrollTotpService
+	EnableTotpService              service.EnableTotpService
+	DisableTotpService             service.DisableTotpService
+	RegenerateRecoveryCodesService service.RegenerateRecoveryCodesService
+	CreateApiTokenService          service.CreateApiTokenService
+	GetApiTokensService            service.GetApiTokensService
+	RevokeApiTokenService          service.RevokeApiTokenService
+	UpdateUserSettingService       service.UpdateUserSettingService
+	CreateS3AccessKeyService       service.CreateS3AccessKeyService
+	GetS3AccessKeysService         service.GetS3AccessKeysService
+	DeleteS3AccessKeyService       service.DeleteS3AccessKeyService
+	QuotaService                   service.QuotaService
+
+	GetUsersService           service.GetUsersService
+	UpdateUserDisabledService service.UpdateUserDisabledService
+	DeleteUserService         service.DeleteUserService
+	UpdateUserRoleService     service.UpdateUserRoleService
+	UpdateUserQuotaService    service.UpdateUserQuotaService
+	ResetUserTwoFactorService service.ResetUserTwoFactorService
+	ImpersonateUserService    service.ImpersonateUserService
+	CreateUserInviteService   service.CreateUserInviteService
+	GetUserInvitesService     service.GetUserInvitesService
+	RevokeUserInviteService   service.RevokeUserInviteService
 }
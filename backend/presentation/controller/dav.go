@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/net/webdav"
+
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/response"
+	"github.com/YahiroRyo/yappi_storage/backend/service"
+)
+
+const davPrefix = "/dav"
+
+func (controller *Controller) Dav(ctx *fiber.Ctx) error {
+	principal, err := middleware.PrincipalFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+	loggedInUser := principal.User
+
+	name, err := url.PathUnescape(strings.TrimPrefix(ctx.Path(), davPrefix))
+	if err != nil {
+		return ctx.SendStatus(fiber.StatusBadRequest)
+	}
+
+	// ファイルの取得はRangeやETagに対応した共通の処理で、メモリに載せずに返す
+	if ctx.Method() == fiber.MethodGet || ctx.Method() == fiber.MethodHead {
+		served, err := controller.DavService.GetServedFile(loggedInUser, name)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if served != nil {
+			return response.SendFile(ctx, response.SendFileOptions{
+				Path:         served.Path,
+				Filename:     served.Filename,
+				MimeType:     served.MimeType,
+				ContentHash:  served.ContentHash,
+				CacheControl: "private, no-cache",
+			})
+		}
+	}
+
+	// 容量を超える場合は本文を読む前に断る。長さが分からない場合は保存時に確認する
+	if ctx.Method() == fiber.MethodPut {
+		if contentLength := int64(ctx.Request().Header.ContentLength()); contentLength > 0 {
+			if err := controller.DavService.StoreBlobService.QuotaService.Check(loggedInUser, contentLength); err != nil {
+				return err
+			}
+		}
+	}
+
+	handler := &webdav.Handler{
+		Prefix:     davPrefix,
+		FileSystem: controller.DavService.FileSystem(loggedInUser),
+		LockSystem: controller.DavService.LockSystem(loggedInUser),
+		Logger: func(r *http.Request, err error) {
+			if err != nil {
+				log.Printf("WebDAV %s %s: %v", r.Method, r.URL.Path, err)
+			}
+		},
+	}
+
+	return serveHTTPHandler(ctx, handler)
+}
+
+// net/httpのハンドラを呼び出す。PUTの本文は読み込みながら渡す
+func serveHTTPHandler(ctx *fiber.Ctx, handler http.Handler) error {
+	var body io.Reader = ctx.Request().BodyStream()
+	if body == nil {
+		body = bytes.NewReader(ctx.Request().Body())
+	}
+
+	contentLength := int64(ctx.Request().Header.ContentLength())
+	if contentLength < 0 {
+		contentLength = -1
+	}
+	requestBody := service.NewDavRequestBody(body, contentLength)
+
+	req, err := http.NewRequestWithContext(service.WithDavRequestBody(ctx.UserContext(), requestBody), ctx.Method(), ctx.OriginalURL(), requestBody)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.ContentLength = contentLength
+	req.Host = ctx.Hostname()
+	req.RemoteAddr = ctx.Context().RemoteAddr().String()
+	req.RequestURI = ctx.OriginalURL()
+	ctx.Request().Header.VisitAll(func(key, value []byte) {
+		req.Header.Add(string(key), string(value))
+	})
+
+	w := &fiberResponseWriter{ctx: ctx, header: http.Header{}}
+	handler.ServeHTTP(w, req)
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+type fiberResponseWriter struct {
+	ctx         *fiber.Ctx
+	header      http.Header
+	wroteHeader bool
+}
+
+func (w *fiberResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *fiberResponseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	for key, values := range w.header {
+		// 本文の長さはfasthttpが設定する
+		if http.CanonicalHeaderKey(key) == fiber.HeaderContentLength {
+			continue
+		}
+		for i, value := range values {
+			if i == 0 {
+				w.ctx.Set(key, value)
+			} else {
+				w.ctx.Append(key, value)
+			}
+		}
+	}
+	w.ctx.Status(statusCode)
+}
+
+func (w *fiberResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+
+	return w.ctx.Write(p)
+}
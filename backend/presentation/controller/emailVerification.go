@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/helper/validate"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/request"
+	"github.com/gofiber/fiber/v2"
+)
+
+// メールのリンクを開いた端末でログインしていなくても確認できる
+func (controller *Controller) VerifyEmail(ctx *fiber.Ctx) error {
+	req := request.VerifyEmailRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := validate.Validate(&req); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := controller.VerifyEmailService.Execute(req.Token, time.Now()); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (controller *Controller) ResendEmailVerification(ctx *fiber.Ctx) error {
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := controller.SendEmailVerificationService.Execute(*user, time.Now()); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return ctx.SendStatus(fiber.StatusAccepted)
+}
@@ -1,20 +1,20 @@
 package controller
 
 import (
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
 	"github.com/YahiroRyo/yappi_storage/backend/helper/validate"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
 	"github.com/YahiroRyo/yappi_storage/backend/presentation/request"
 	"github.com/YahiroRyo/yappi_storage/backend/presentation/response"
-	"github.com/YahiroRyo/yappi_storage/backend/presentation/session"
 	"github.com/gofiber/fiber/v2"
 )
 
 func (controller *Controller) DeleteCache(ctx *fiber.Ctx) error {
-	sess, err := session.GetSession(ctx)
+	user, err := middleware.LoggedInUser(ctx)
 	if err != nil {
 		return err
 	}
 
-	user, err := controller.GetLoggedInUserService.Execute(sess)
 	if err := controller.DeleteCacheService.Execute(user.ID); err != nil {
 		return err
 	}
@@ -33,18 +33,18 @@ func (controller *Controller) GetFiles(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	sess, err := session.GetSession(ctx)
-	if err != nil {
-		return err
-	}
-
-	user, err := controller.GetLoggedInUserService.Execute(sess)
+	user, err := middleware.LoggedInUser(ctx)
 	if err != nil {
 		return err
 	}
 
 	if *req.Query == "" {
-		files, err := controller.GetFilesService.Execute(*user, req.ParentDirectoryId, req.CurrentPageCount, req.PageSize)
+		order, ok := file.OrderFromString(req.Order)
+		if !ok {
+			return validate.ValidationError{Code: 400, Message: "並び順はcreated_at, taken_at, nameのいずれかを指定してください。"}
+		}
+
+		files, err := controller.GetFilesService.Execute(*user, req.ParentDirectoryId, order, req.CurrentPageCount, req.PageSize)
 		if err != nil {
 			return err
 		}
@@ -80,12 +80,7 @@ func (controller *Controller) GetFile(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	sess, err := session.GetSession(ctx)
-	if err != nil {
-		return err
-	}
-
-	user, err := controller.GetLoggedInUserService.Execute(sess)
+	user, err := middleware.LoggedInUser(ctx)
 	if err != nil {
 		return err
 	}
@@ -109,12 +104,7 @@ func (controller *Controller) RegistrationDirectory(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	sess, err := session.GetSession(ctx)
-	if err != nil {
-		return err
-	}
-
-	user, err := controller.GetLoggedInUserService.Execute(sess)
+	user, err := middleware.LoggedInUser(ctx)
 	if err != nil {
 		return err
 	}
@@ -138,17 +128,12 @@ func (controller *Controller) RegistrationFiles(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	sess, err := session.GetSession(ctx)
-	if err != nil {
-		return err
-	}
-
-	user, err := controller.GetLoggedInUserService.Execute(sess)
+	user, err := middleware.LoggedInUser(ctx)
 	if err != nil {
 		return err
 	}
 
-	files, err := controller.RegistrationFilesService.Execute(*user, req)
+	files, err := controller.RegistrationFilesService.Execute(*user, nil, req)
 	if err != nil || len(files) == 0 {
 		return err
 	}
@@ -167,12 +152,7 @@ func (controller *Controller) RenameFile(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	sess, err := session.GetSession(ctx)
-	if err != nil {
-		return err
-	}
-
-	user, err := controller.GetLoggedInUserService.Execute(sess)
+	user, err := middleware.LoggedInUser(ctx)
 	if err != nil {
 		return err
 	}
@@ -196,12 +176,7 @@ func (controller *Controller) MoveFiles(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	sess, err := session.GetSession(ctx)
-	if err != nil {
-		return err
-	}
-
-	user, err := controller.GetLoggedInUserService.Execute(sess)
+	user, err := middleware.LoggedInUser(ctx)
 	if err != nil {
 		return err
 	}
@@ -214,8 +189,8 @@ func (controller *Controller) MoveFiles(ctx *fiber.Ctx) error {
 	return ctx.JSON(files)
 }
 
-func (controller *Controller) DeleteFiles(ctx *fiber.Ctx) error {
-	req := request.DeleteFilesRequest{}
+func (controller *Controller) UpdateCompression(ctx *fiber.Ctx) error {
+	req := request.UpdateCompressionRequest{}
 
 	if err := ctx.BodyParser(&req); err != nil {
 		return err
@@ -225,12 +200,30 @@ func (controller *Controller) DeleteFiles(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	sess, err := session.GetSession(ctx)
+	user, err := middleware.LoggedInUser(ctx)
 	if err != nil {
 		return err
 	}
 
-	user, err := controller.GetLoggedInUserService.Execute(sess)
+	if err := controller.UpdateCompressionService.Execute(*user, req.FileIds, req.CompressionDisabled); err != nil {
+		return err
+	}
+
+	return ctx.Status(200).JSON(nil)
+}
+
+func (controller *Controller) DeleteFiles(ctx *fiber.Ctx) error {
+	req := request.DeleteFilesRequest{}
+
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	if err := validate.Validate(req); err != nil {
+		return err
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/request"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	hlsPlaylistContentType = "application/vnd.apple.mpegurl"
+	hlsSignedUrlLifetime   = 6 * time.Hour
+)
+
+func (controller *Controller) GetHLSMasterPlaylist(ctx *fiber.Ctx) error {
+	req := request.GetHLSMasterPlaylistRequest{}
+
+	if err := ctx.ParamsParser(&req); err != nil {
+		return err
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	playlistPath, err := controller.GetHLSResourceService.Execute(*user, req.FileId, "", "")
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(hlsSignedUrlLifetime)
+	basePath := fmt.Sprintf("/hls/%s/%s", user.ID, req.FileId)
+
+	return sendSignedPlaylist(ctx, playlistPath, basePath, expiresAt)
+}
+
+// 署名付きURLで認証されるため、セッションは参照しない
+func (controller *Controller) GetHLSResource(ctx *fiber.Ctx) error {
+	req := request.GetHLSResourceRequest{}
+
+	if err := ctx.ParamsParser(&req); err != nil {
+		return err
+	}
+
+	if err := ctx.QueryParser(&req); err != nil {
+		return err
+	}
+
+	resourcePath, err := controller.GetHLSResourceService.Execute(user.User{ID: req.UserId}, req.FileId, req.Rendition, req.Name)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasSuffix(req.Name, ".m3u8") {
+		// セグメントの有効期限はプレイリストの有効期限を引き継ぐ
+		basePath := fmt.Sprintf("/hls/%s/%s/%s", req.UserId, req.FileId, req.Rendition)
+		return sendSignedPlaylist(ctx, resourcePath, basePath, time.Unix(req.Expires, 0))
+	}
+
+	ctx.Set(fiber.HeaderCacheControl, "private, max-age=86400")
+
+	return ctx.SendFile(resourcePath)
+}
+
+// プレイリスト内の相対URIを署名付きURLに書き換えて返す
+func sendSignedPlaylist(ctx *fiber.Ctx, playlistPath string, basePath string, expiresAt time.Time) error {
+	playlist, err := os.ReadFile(playlistPath)
+	if err != nil {
+		return err
+	}
+
+	var signed strings.Builder
+	scanner := bufio.NewScanner(bytes.NewReader(playlist))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line != "" && !strings.HasPrefix(line, "#") {
+			path := fmt.Sprintf("%s/%s", basePath, line)
+			line = fmt.Sprintf("%s%s?%s", os.Getenv("BASE_URL"), path, helper.SignPathQuery(path, expiresAt))
+		}
+
+		signed.WriteString(line)
+		signed.WriteString("\n")
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// 署名付きURLには有効期限があるため、プレイリストはキャッシュさせない
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	ctx.Set(fiber.HeaderContentType, hlsPlaylistContentType)
+
+	return ctx.SendString(signed.String())
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"github.com/YahiroRyo/yappi_storage/backend/helper/validate"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/request"
+	"github.com/gofiber/fiber/v2"
+)
+
+func (controller *Controller) GetJobs(ctx *fiber.Ctx) error {
+	req := request.GetJobsRequest{}
+
+	if err := ctx.QueryParser(&req); err != nil {
+		return err
+	}
+
+	if err := validate.Validate(&req); err != nil {
+		return err
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	jobs, err := controller.GetJobsService.Execute(*user, req.CurrentPageCount, req.PageSize)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(*jobs)
+}
+
+func (controller *Controller) GetJob(ctx *fiber.Ctx) error {
+	req := request.GetJobRequest{}
+
+	if err := ctx.ParamsParser(&req); err != nil {
+		return err
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	job, err := controller.GetJobService.Execute(*user, req.JobId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(*job)
+}
+
+func (controller *Controller) CancelJob(ctx *fiber.Ctx) error {
+	req := request.CancelJobRequest{}
+
+	if err := ctx.ParamsParser(&req); err != nil {
+		return err
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	job, err := controller.CancelJobService.Execute(*user, req.JobId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(*job)
+}
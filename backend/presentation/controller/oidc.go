@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/request"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/session"
+	"github.com/YahiroRyo/yappi_storage/backend/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+func (controller *Controller) GetAuthMethods(ctx *fiber.Ctx) error {
+	return ctx.JSON(controller.GetAuthMethodsService.Execute())
+}
+
+// IdPの認可エンドポイントにリダイレクトする
+func (controller *Controller) OidcLogin(ctx *fiber.Ctx) error {
+	sess, err := session.GetSession(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	authorizationUrl, err := controller.StartOidcLoginService.Execute(sess, time.Now())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return ctx.Redirect(authorizationUrl, fiber.StatusFound)
+}
+
+// ログインできたらフロントエンドにリダイレクトする
+func (controller *Controller) OidcCallback(ctx *fiber.Ctx) error {
+	req := request.OidcCallbackRequest{}
+	if err := ctx.QueryParser(&req); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if req.Error != "" {
+		return errors.WithStack(service.OidcError{Code: 400, Message: "IdPでのログインがキャンセルされました。"})
+	}
+
+	sess, err := session.GetSession(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, challenge, err := controller.OidcLoginService.Execute(sess, req.State, req.Code, ctx.Get(fiber.HeaderUserAgent), ctx.IP(), time.Now())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	redirectUrl := os.Getenv("FRONTEND_URL")
+	if redirectUrl == "" {
+		redirectUrl = os.Getenv("BASE_URL")
+	}
+
+	// 二段階認証を有効にしている場合は、チャレンジをフラグメントで渡してログイン画面で認証コードを入力させる
+	// フラグメントはサーバーに送られないため、アクセスログなどに残らない
+	if challenge != nil {
+		return ctx.Redirect(redirectUrl+"/login#two_factor_challenge="+url.QueryEscape(challenge.Challenge), fiber.StatusFound)
+	}
+
+	return ctx.Redirect(redirectUrl+"/", fiber.StatusFound)
+}
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/helper/validate"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/request"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/session"
+	"github.com/gofiber/fiber/v2"
+)
+
+// 登録されていないメールアドレスでも同じレスポンスを返す
+func (controller *Controller) ForgotPassword(ctx *fiber.Ctx) error {
+	req := request.ForgotPasswordRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := validate.Validate(&req); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := controller.ForgotPasswordService.Execute(req.Email, ctx.IP(), time.Now()); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return ctx.SendStatus(fiber.StatusAccepted)
+}
+
+func (controller *Controller) ResetPassword(ctx *fiber.Ctx) error {
+	req := request.ResetPasswordRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := validate.Validate(&req); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := controller.ResetPasswordService.Execute(req.Token, req.Password, time.Now()); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (controller *Controller) ChangePassword(ctx *fiber.Ctx) error {
+	req := request.ChangePasswordRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := validate.Validate(&req); err != nil {
+		return errors.WithStack(err)
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	sess, err := session.GetSession(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := controller.ChangePasswordService.Execute(*user, sess, req.CurrentPassword, req.Password); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
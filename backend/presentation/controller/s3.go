@@ -0,0 +1,460 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/request"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/response"
+	"github.com/YahiroRyo/yappi_storage/backend/service"
+)
+
+const (
+	// DeleteObjectsやCompleteMultipartUploadのXMLの上限
+	s3MaxXMLBodySize = 2 * 1024 * 1024
+	s3MaxDeleteKeys  = 1000
+)
+
+func s3MethodNotAllowedError() service.S3Error {
+	return service.S3Error{Status: 405, Code: "MethodNotAllowed", Message: "The specified method is not allowed against this resource."}
+}
+
+func s3NotImplementedError() service.S3Error {
+	return service.S3Error{Status: 501, Code: "NotImplemented", Message: "A header or query you provided implies functionality that is not implemented."}
+}
+
+// パスのバケット名とキーはエスケープされたまま渡される
+// バケット名とキーはエスケープされたままのパスから取り出す
+// (Paramsは末尾の"/"を落とし、リクエストのバッファを参照しているため使わない)
+func s3Params(ctx *fiber.Ctx) (string, string, error) {
+	rawPath := strings.TrimPrefix(string(ctx.Request().URI().PathOriginal()), "/s3/")
+	rawBucket, rawKey, _ := strings.Cut(rawPath, "/")
+
+	bucket, err := url.PathUnescape(rawBucket)
+	if err != nil {
+		return "", "", service.S3Error{Status: 400, Code: "InvalidURI", Message: "Couldn't parse the specified URI."}
+	}
+
+	key, err := url.PathUnescape(rawKey)
+	if err != nil {
+		return "", "", service.S3Error{Status: 400, Code: "InvalidURI", Message: "Couldn't parse the specified URI."}
+	}
+
+	return bucket, key, nil
+}
+
+func s3RequestBody(ctx *fiber.Ctx, limit int64) (*service.S3RequestBody, error) {
+	var body io.Reader = ctx.Request().BodyStream()
+	if body == nil {
+		body = bytes.NewReader(ctx.Request().Body())
+	}
+
+	contentLength := int64(ctx.Request().Header.ContentLength())
+	if contentLength < 0 {
+		contentLength = -1
+	}
+
+	return service.NewS3RequestBody(ctx.Locals("s3_auth").(service.S3Auth), body, func(name string) string {
+		return ctx.Get(name)
+	}, contentLength, limit)
+}
+
+// 署名を検証しながらXMLの本文を読み込む
+func parseS3XMLBody(ctx *fiber.Ctx, value any) error {
+	body, err := s3RequestBody(ctx, s3MaxXMLBodySize)
+	if err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if err := body.Verify(); err != nil {
+		return err
+	}
+
+	if err := xml.Unmarshal(data, value); err != nil {
+		return service.S3Error{Status: 400, Code: "MalformedXML", Message: "The XML you provided was not well-formed or did not validate against our published schema."}
+	}
+
+	return nil
+}
+
+func s3ETag(etag string) string {
+	return `"` + etag + `"`
+}
+
+// encoding-type=urlの場合はキーをURLエンコードして返す
+func s3EncodeKey(key string, encodingType string) string {
+	if encodingType == "url" {
+		return url.QueryEscape(key)
+	}
+
+	return key
+}
+
+func (controller *Controller) S3ListBuckets(ctx *fiber.Ctx) error {
+	principal, err := middleware.PrincipalFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+	loggedInUser := principal.User
+
+	buckets, err := controller.S3Service.ListBuckets(loggedInUser)
+	if err != nil {
+		return err
+	}
+
+	res := response.S3ListBucketsResponse{
+		Owner:   response.S3Owner{ID: loggedInUser.ID, DisplayName: loggedInUser.Email},
+		Buckets: make([]response.S3BucketResponse, 0, len(buckets)),
+	}
+	for _, bucket := range buckets {
+		res.Buckets = append(res.Buckets, response.S3BucketResponse{Name: bucket.Name, CreationDate: response.S3Time(bucket.CreatedAt)})
+	}
+
+	return response.S3XML(ctx, fiber.StatusOK, res)
+}
+
+func (controller *Controller) S3Bucket(ctx *fiber.Ctx) error {
+	principal, err := middleware.PrincipalFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+	loggedInUser := principal.User
+
+	bucket, _, err := s3Params(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := ctx.Request().URI().QueryArgs()
+	switch ctx.Method() {
+	case fiber.MethodGet:
+		if query.Has("location") {
+			if err := controller.S3Service.HeadBucket(loggedInUser, bucket); err != nil {
+				return err
+			}
+			return response.S3XML(ctx, fiber.StatusOK, response.S3LocationResponse{})
+		}
+		return controller.s3ListObjects(ctx, loggedInUser, bucket)
+	case fiber.MethodHead:
+		if err := controller.S3Service.HeadBucket(loggedInUser, bucket); err != nil {
+			return err
+		}
+		ctx.Set("x-amz-bucket-region", "us-east-1")
+		return ctx.Status(fiber.StatusOK).Send(nil)
+	case fiber.MethodPut:
+		if err := controller.S3Service.CreateBucket(loggedInUser, bucket); err != nil {
+			return err
+		}
+		ctx.Set(fiber.HeaderLocation, "/"+bucket)
+		return ctx.Status(fiber.StatusOK).Send(nil)
+	case fiber.MethodDelete:
+		if err := controller.S3Service.DeleteBucket(loggedInUser, bucket); err != nil {
+			return err
+		}
+		return ctx.SendStatus(fiber.StatusNoContent)
+	case fiber.MethodPost:
+		if query.Has("delete") {
+			return controller.s3DeleteObjects(ctx, loggedInUser, bucket)
+		}
+	}
+
+	return s3MethodNotAllowedError()
+}
+
+func (controller *Controller) s3ListObjects(ctx *fiber.Ctx, loggedInUser user.User, bucket string) error {
+	req := request.S3BucketRequest{}
+	if err := ctx.QueryParser(&req); err != nil {
+		return service.S3Error{Status: 400, Code: "InvalidArgument", Message: "Invalid query string."}
+	}
+
+	if req.EncodingType != "" && req.EncodingType != "url" {
+		return service.S3Error{Status: 400, Code: "InvalidArgument", Message: "Invalid Encoding Method specified in Request"}
+	}
+
+	maxKeys := 1000
+	if req.MaxKeys != nil {
+		if *req.MaxKeys < 0 {
+			return service.S3Error{Status: 400, Code: "InvalidArgument", Message: "max-keys must be a non-negative integer."}
+		}
+		maxKeys = min(*req.MaxKeys, 1000)
+	}
+
+	isV2 := req.ListType == "2"
+	input := service.S3ListObjectsInput{
+		Prefix:    req.Prefix,
+		Delimiter: req.Delimiter,
+		MaxKeys:   maxKeys,
+	}
+	if isV2 {
+		input.ContinuationToken = req.ContinuationToken
+		input.StartAfter = req.StartAfter
+	} else {
+		input.Marker = req.Marker
+	}
+
+	result, err := controller.S3Service.ListObjects(loggedInUser, bucket, input)
+	if err != nil {
+		return err
+	}
+
+	res := response.S3ListObjectsResponse{
+		Name:           bucket,
+		Prefix:         s3EncodeKey(req.Prefix, req.EncodingType),
+		Delimiter:      s3EncodeKey(req.Delimiter, req.EncodingType),
+		MaxKeys:        maxKeys,
+		EncodingType:   req.EncodingType,
+		IsTruncated:    result.IsTruncated,
+		Contents:       make([]response.S3ObjectResponse, 0, len(result.Objects)),
+		CommonPrefixes: make([]response.S3CommonPrefixResponse, 0, len(result.CommonPrefixes)),
+	}
+	for _, object := range result.Objects {
+		res.Contents = append(res.Contents, response.S3ObjectResponse{
+			Key:          s3EncodeKey(object.Key, req.EncodingType),
+			LastModified: response.S3Time(object.LastModified),
+			ETag:         s3ETag(object.ETag),
+			Size:         object.Size,
+			StorageClass: "STANDARD",
+		})
+	}
+	for _, prefix := range result.CommonPrefixes {
+		res.CommonPrefixes = append(res.CommonPrefixes, response.S3CommonPrefixResponse{Prefix: s3EncodeKey(prefix, req.EncodingType)})
+	}
+
+	if isV2 {
+		keyCount := len(res.Contents) + len(res.CommonPrefixes)
+		res.KeyCount = &keyCount
+		res.ContinuationToken = req.ContinuationToken
+		res.NextContinuationToken = result.NextContinuationToken
+		res.StartAfter = s3EncodeKey(req.StartAfter, req.EncodingType)
+	} else {
+		marker := s3EncodeKey(req.Marker, req.EncodingType)
+		res.Marker = &marker
+		res.NextMarker = s3EncodeKey(result.NextMarker, req.EncodingType)
+	}
+
+	return response.S3XML(ctx, fiber.StatusOK, res)
+}
+
+func (controller *Controller) s3DeleteObjects(ctx *fiber.Ctx, loggedInUser user.User, bucket string) error {
+	req := request.S3DeleteObjectsRequest{}
+	if err := parseS3XMLBody(ctx, &req); err != nil {
+		return err
+	}
+	if len(req.Objects) == 0 || len(req.Objects) > s3MaxDeleteKeys {
+		return service.S3Error{Status: 400, Code: "MalformedXML", Message: "The number of keys must be between 1 and 1000."}
+	}
+
+	keys := make([]string, 0, len(req.Objects))
+	for _, object := range req.Objects {
+		keys = append(keys, object.Key)
+	}
+
+	results, err := controller.S3Service.DeleteObjects(loggedInUser, bucket, keys)
+	if err != nil {
+		return err
+	}
+
+	res := response.S3DeleteObjectsResponse{}
+	for _, result := range results {
+		if result.Err == nil {
+			if !req.Quiet {
+				res.Deleted = append(res.Deleted, response.S3DeletedResponse{Key: result.Key})
+			}
+			continue
+		}
+
+		s3Err := service.S3Error{Code: "InternalError", Message: "We encountered an internal error. Please try again."}
+		errors.As(result.Err, &s3Err)
+		res.Errors = append(res.Errors, response.S3DeleteErrorResponse{Key: result.Key, Code: s3Err.Code, Message: s3Err.Message})
+	}
+
+	return response.S3XML(ctx, fiber.StatusOK, res)
+}
+
+func (controller *Controller) S3Object(ctx *fiber.Ctx) error {
+	principal, err := middleware.PrincipalFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+	loggedInUser := principal.User
+
+	bucket, key, err := s3Params(ctx)
+	if err != nil {
+		return err
+	}
+	// "/bucket/" はバケットへのリクエストとして扱う
+	if key == "" {
+		return controller.S3Bucket(ctx)
+	}
+
+	req := request.S3ObjectRequest{}
+	if err := ctx.QueryParser(&req); err != nil {
+		return service.S3Error{Status: 400, Code: "InvalidArgument", Message: "Invalid query string."}
+	}
+
+	query := ctx.Request().URI().QueryArgs()
+	switch ctx.Method() {
+	case fiber.MethodGet, fiber.MethodHead:
+		if req.UploadID != "" && ctx.Method() == fiber.MethodGet {
+			return controller.s3ListParts(ctx, loggedInUser, bucket, key, req.UploadID)
+		}
+		return controller.s3GetObject(ctx, loggedInUser, bucket, key)
+	case fiber.MethodPut:
+		if ctx.Get("x-amz-copy-source") != "" {
+			return s3NotImplementedError()
+		}
+		if req.UploadID != "" || req.PartNumber != nil {
+			if req.UploadID == "" || req.PartNumber == nil {
+				return service.S3Error{Status: 400, Code: "InvalidArgument", Message: "Both partNumber and uploadId must be specified."}
+			}
+			return controller.s3UploadPart(ctx, loggedInUser, bucket, key, req.UploadID, *req.PartNumber)
+		}
+		return controller.s3PutObject(ctx, loggedInUser, bucket, key)
+	case fiber.MethodPost:
+		if query.Has("uploads") {
+			upload, err := controller.S3Service.CreateMultipartUpload(loggedInUser, bucket, key)
+			if err != nil {
+				return err
+			}
+			return response.S3XML(ctx, fiber.StatusOK, response.S3InitiateMultipartUploadResponse{Bucket: bucket, Key: key, UploadID: upload.ID})
+		}
+		if req.UploadID != "" {
+			return controller.s3CompleteMultipartUpload(ctx, loggedInUser, bucket, key, req.UploadID)
+		}
+	case fiber.MethodDelete:
+		if req.UploadID != "" {
+			err = controller.S3Service.AbortMultipartUpload(loggedInUser, bucket, key, req.UploadID)
+		} else {
+			err = controller.S3Service.DeleteObject(loggedInUser, bucket, key)
+		}
+		if err != nil {
+			return err
+		}
+		return ctx.SendStatus(fiber.StatusNoContent)
+	}
+
+	return s3MethodNotAllowedError()
+}
+
+func (controller *Controller) s3GetObject(ctx *fiber.Ctx, loggedInUser user.User, bucket string, key string) error {
+	served, object, err := controller.S3Service.GetObject(loggedInUser, bucket, key)
+	if err != nil {
+		return err
+	}
+
+	// フォルダは空のオブジェクトとして返す
+	if served == nil {
+		ctx.Set(fiber.HeaderETag, s3ETag(object.ETag))
+		ctx.Set(fiber.HeaderLastModified, object.LastModified.UTC().Format(http.TimeFormat))
+		ctx.Set(fiber.HeaderContentType, "application/x-directory")
+		return ctx.Status(fiber.StatusOK).Send(nil)
+	}
+
+	return response.SendFile(ctx, response.SendFileOptions{
+		Path:         served.Path,
+		Filename:     served.Filename,
+		MimeType:     served.MimeType,
+		ContentHash:  served.ContentHash,
+		CacheControl: "private, no-cache",
+	})
+}
+
+func (controller *Controller) s3PutObject(ctx *fiber.Ctx, loggedInUser user.User, bucket string, key string) error {
+	body, err := s3RequestBody(ctx, service.S3MaxObjectSize)
+	if err != nil {
+		return err
+	}
+
+	object, err := controller.S3Service.PutObject(loggedInUser, bucket, key, body)
+	if err != nil {
+		return err
+	}
+
+	ctx.Set(fiber.HeaderETag, s3ETag(object.ETag))
+	if body.ChecksumAlgorithm() != "" {
+		ctx.Set("x-amz-checksum-"+body.ChecksumAlgorithm(), body.Checksum())
+	}
+
+	return ctx.Status(fiber.StatusOK).Send(nil)
+}
+
+func (controller *Controller) s3UploadPart(ctx *fiber.Ctx, loggedInUser user.User, bucket string, key string, uploadID string, partNumber int) error {
+	body, err := s3RequestBody(ctx, service.S3MaxObjectSize)
+	if err != nil {
+		return err
+	}
+
+	etag, err := controller.S3Service.UploadPart(loggedInUser, bucket, key, uploadID, partNumber, body)
+	if err != nil {
+		return err
+	}
+
+	ctx.Set(fiber.HeaderETag, s3ETag(etag))
+	if body.ChecksumAlgorithm() != "" {
+		ctx.Set("x-amz-checksum-"+body.ChecksumAlgorithm(), body.Checksum())
+	}
+
+	return ctx.Status(fiber.StatusOK).Send(nil)
+}
+
+func (controller *Controller) s3CompleteMultipartUpload(ctx *fiber.Ctx, loggedInUser user.User, bucket string, key string, uploadID string) error {
+	req := request.S3CompleteMultipartUploadRequest{}
+	if err := parseS3XMLBody(ctx, &req); err != nil {
+		return err
+	}
+
+	parts := make([]service.S3CompletedPart, 0, len(req.Parts))
+	for _, part := range req.Parts {
+		parts = append(parts, service.S3CompletedPart{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+
+	object, err := controller.S3Service.CompleteMultipartUpload(loggedInUser, bucket, key, uploadID, parts)
+	if err != nil {
+		return err
+	}
+
+	return response.S3XML(ctx, fiber.StatusOK, response.S3CompleteMultipartUploadResponse{
+		Location: ctx.BaseURL() + ctx.Path(),
+		Bucket:   bucket,
+		Key:      key,
+		ETag:     s3ETag(object.ETag),
+	})
+}
+
+func (controller *Controller) s3ListParts(ctx *fiber.Ctx, loggedInUser user.User, bucket string, key string, uploadID string) error {
+	parts, err := controller.S3Service.ListParts(loggedInUser, bucket, key, uploadID)
+	if err != nil {
+		return err
+	}
+
+	res := response.S3ListPartsResponse{
+		Bucket:       bucket,
+		Key:          key,
+		UploadID:     uploadID,
+		StorageClass: "STANDARD",
+		Parts:        make([]response.S3PartResponse, 0, len(parts)),
+	}
+	for _, part := range parts {
+		res.Parts = append(res.Parts, response.S3PartResponse{
+			PartNumber:   part.PartNumber,
+			LastModified: response.S3Time(part.LastModified),
+			ETag:         s3ETag(part.ETag),
+			Size:         part.Size,
+		})
+	}
+
+	return response.S3XML(ctx, fiber.StatusOK, res)
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/request"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/response"
+)
+
+func (controller *Controller) CreateS3AccessKey(ctx *fiber.Ctx) error {
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	created, err := controller.CreateS3AccessKeyService.Execute(*user)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(response.CreateS3AccessKeyResponse{
+		AccessKey:       *created,
+		SecretAccessKey: created.SecretAccessKey,
+	})
+}
+
+func (controller *Controller) GetS3AccessKeys(ctx *fiber.Ctx) error {
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys, err := controller.GetS3AccessKeysService.Execute(*user)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(keys)
+}
+
+func (controller *Controller) DeleteS3AccessKey(ctx *fiber.Ctx) error {
+	req := request.DeleteS3AccessKeyRequest{}
+	if err := ctx.ParamsParser(&req); err != nil {
+		return err
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := controller.DeleteS3AccessKeyService.Execute(*user, req.Id); err != nil {
+		return err
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
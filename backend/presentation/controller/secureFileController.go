@@ -1,67 +1,42 @@
 package controller
 
 import (
-	"os"
-	"path/filepath"
-
-	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/response"
 	"github.com/YahiroRyo/yappi_storage/backend/service"
 	"github.com/gofiber/fiber/v2"
 )
 
+// 位置情報の除去設定で内容が変わり得るため、ブラウザには毎回ETagで再検証させる
+const secureFileCacheControl = "private, no-cache"
+
 type SecureFileController struct {
-	GetFileService *service.GetFileService
+	GetSecureFileService *service.GetSecureFileService
 }
 
 func (controller *SecureFileController) GetSecureFile(c *fiber.Ctx) error {
-	// 本番環境でのみ有効
-	if os.Getenv("ENVIRONMENT") != "production" {
-		return c.Status(404).JSON(fiber.Map{
-			"message": "このエンドポイントは本番環境でのみ利用可能です",
-		})
-	}
-
-	fileID := c.Params("id")
-	userContext := c.Locals("user").(user.User)
-
-	// ファイルの所有権確認
-	file, err := controller.GetFileService.Execute(userContext, fileID)
+	// URLのIDはストレージ上のファイル名(拡張子を除く)
+	blobID := c.Params("id")
+	principal, err := middleware.PrincipalFromLocals(c)
 	if err != nil {
-		return c.Status(404).JSON(fiber.Map{
-			"message": "ファイルが見つかりません",
-		})
+		return err
 	}
+	userContext := principal.User
 
-	if file == nil {
-		return c.Status(404).JSON(fiber.Map{
-			"message": "ファイルが見つかりません",
-		})
-	}
-
-	// ファイルの実際のパス構築（ファイルID + 拡張子）
-	ext := filepath.Ext(file.Name)
-	filename := fileID + ext
-
-	// ストレージパスを検索してファイルを見つける
-	storePaths := []string{"1", "2", "3", "4", "5"}
-	var filePath string
-	var found bool
-
-	for _, storePath := range storePaths {
-		possiblePath := filepath.Join("storage/files", storePath, filename)
-		if _, err := os.Stat(possiblePath); err == nil {
-			filePath = possiblePath
-			found = true
-			break
-		}
-	}
-
-	if !found {
+	// ファイルの所有権確認と、設定に応じた位置情報の除去
+	served, err := controller.GetSecureFileService.Execute(userContext, blobID)
+	if err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"message": "ファイルが見つかりません",
 		})
 	}
 
-	// ファイルの送信
-	return c.SendFile(filePath)
+	return response.SendFile(c, response.SendFileOptions{
+		Path:         served.Path,
+		Filename:     served.Filename,
+		MimeType:     served.MimeType,
+		ContentHash:  served.ContentHash,
+		Download:     c.QueryBool("download"),
+		CacheControl: secureFileCacheControl,
+	})
 }
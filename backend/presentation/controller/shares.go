@@ -0,0 +1,231 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/share"
+	"github.com/YahiroRyo/yappi_storage/backend/helper/validate"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/request"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/response"
+	"github.com/YahiroRyo/yappi_storage/backend/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+func (controller *Controller) CreateShare(ctx *fiber.Ctx) error {
+	req := request.CreateShareRequest{}
+
+	if err := ctx.ParamsParser(&req); err != nil {
+		return err
+	}
+
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	if err := validate.Validate(req); err != nil {
+		return err
+	}
+
+	permission, ok := share.PermissionFromString(req.Permission)
+	if !ok {
+		return validate.ValidationError{Code: 400, Message: "権限はview, downloadのいずれかを指定してください。"}
+	}
+
+	if req.ExpiresAt == nil || !req.ExpiresAt.After(time.Now()) {
+		return validate.ValidationError{Code: 400, Message: "有効期限には未来の日時を指定してください。"}
+	}
+
+	if req.Password != nil && (len(*req.Password) < 4 || len(*req.Password) > 128) {
+		return validate.ValidationError{Code: 400, Message: "パスワードは4文字以上128文字以下で入力してください。"}
+	}
+
+	if req.MaxDownloads != nil && *req.MaxDownloads < 1 {
+		return validate.ValidationError{Code: 400, Message: "ダウンロード回数の上限は1以上を指定してください。"}
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	created, err := controller.CreateShareService.Execute(*user, req.FileId, permission, *req.ExpiresAt, req.Password, req.MaxDownloads)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(created)
+}
+
+func (controller *Controller) GetFileShares(ctx *fiber.Ctx) error {
+	req := request.GetFileSharesRequest{}
+
+	if err := ctx.ParamsParser(&req); err != nil {
+		return err
+	}
+
+	if err := ctx.QueryParser(&req); err != nil {
+		return err
+	}
+
+	if err := validate.Validate(&req); err != nil {
+		return err
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	shares, err := controller.GetSharesService.Execute(*user, &req.FileId, req.CurrentPageCount, req.PageSize)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(*shares)
+}
+
+func (controller *Controller) GetShares(ctx *fiber.Ctx) error {
+	req := request.GetSharesRequest{}
+
+	if err := ctx.QueryParser(&req); err != nil {
+		return err
+	}
+
+	if err := validate.Validate(&req); err != nil {
+		return err
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	shares, err := controller.GetSharesService.Execute(*user, nil, req.CurrentPageCount, req.PageSize)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(*shares)
+}
+
+func (controller *Controller) RevokeShare(ctx *fiber.Ctx) error {
+	req := request.RevokeShareRequest{}
+
+	if err := ctx.ParamsParser(&req); err != nil {
+		return err
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := controller.RevokeShareService.Execute(*user, req.ShareId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(revoked)
+}
+
+// 共有リンクからのアクセス。ファイルの場合は中身を、フォルダの場合は一覧を返す
+func (controller *Controller) GetSharedFile(ctx *fiber.Ctx) error {
+	req := request.GetSharedFileRequest{PageSize: 50}
+
+	if err := ctx.ParamsParser(&req); err != nil {
+		return err
+	}
+
+	if err := ctx.QueryParser(&req); err != nil {
+		return err
+	}
+
+	if err := validate.Validate(&req); err != nil {
+		return err
+	}
+
+	// 共有リンクのトークンを外部に漏らさず、取り消し後にキャッシュから配信されないようにする
+	ctx.Set(fiber.HeaderCacheControl, "private, no-store")
+	ctx.Set(fiber.HeaderReferrerPolicy, "no-referrer")
+	ctx.Set("X-Robots-Tag", "noindex")
+
+	shared, err := controller.GetSharedFileService.Execute(
+		req.Token,
+		service.ShareGrant{Expires: req.Expires, Signature: req.Signature},
+		&req.FileId,
+	)
+	if err != nil {
+		return err
+	}
+
+	if file.FileKindFromEnString(shared.File.Kind) == file.Directory {
+		files, err := controller.GetSharedFilesService.Execute(*shared, req.CurrentPageCount, req.PageSize)
+		if err != nil {
+			return err
+		}
+
+		sharedFiles := make([]response.SharedFileResponse, 0, len(files.Files))
+		for _, f := range files.Files {
+			sharedFiles = append(sharedFiles, response.NewSharedFileResponse(f))
+		}
+
+		return ctx.JSON(response.GetSharedDirectoryResponse{
+			Permission:       shared.Share.Permission,
+			ExpiresAt:        shared.Share.ExpiresAt,
+			Directory:        response.NewSharedFileResponse(shared.File),
+			Files:            sharedFiles,
+			PageSize:         files.PageSize,
+			CurrentPageCount: files.CurrentPageCount,
+			Total:            files.Total,
+		})
+	}
+
+	served, err := controller.DownloadSharedFileService.Execute(*shared, req.Download)
+	if err != nil {
+		return err
+	}
+
+	return response.SendFile(ctx, response.SendFileOptions{
+		Path:         served.Path,
+		Filename:     served.Filename,
+		MimeType:     served.MimeType,
+		ContentHash:  served.ContentHash,
+		Download:     req.Download,
+		CacheControl: "private, no-store",
+		// 先頭から返す場合のみ1回と数える。HEADと、再開(If-Range)や動画のシークによる続きの範囲は数えない
+		BeforeBody: func(offset int64) error {
+			if ctx.Method() == fiber.MethodHead || offset > 0 || ctx.Get(fiber.HeaderIfRange) != "" {
+				return nil
+			}
+			return controller.DownloadSharedFileService.CountDownload(*shared)
+		},
+	})
+}
+
+func (controller *Controller) UnlockShare(ctx *fiber.Ctx) error {
+	req := request.UnlockShareRequest{}
+
+	if err := ctx.ParamsParser(&req); err != nil {
+		return err
+	}
+
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	if err := validate.Validate(req); err != nil {
+		return err
+	}
+
+	query, expiresAt, err := controller.UnlockShareService.Execute(req.Token, req.Password, ctx.IP())
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(response.UnlockShareResponse{
+		Query:     query,
+		ExpiresAt: expiresAt,
+	})
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/helper/validate"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/request"
+	"github.com/gofiber/fiber/v2"
+)
+
+// 派生ファイルは元ファイルが変わらない限り不変なのでブラウザにキャッシュさせる
+const derivativeCacheControl = "private, max-age=86400"
+
+func (controller *Controller) GetThumbnail(ctx *fiber.Ctx) error {
+	req := request.GetThumbnailRequest{Size: 256}
+
+	if err := ctx.ParamsParser(&req); err != nil {
+		return err
+	}
+
+	if err := ctx.QueryParser(&req); err != nil {
+		return err
+	}
+
+	if !file.IsThumbnailSize(req.Size) {
+		return validate.ValidationError{
+			Code:    400,
+			Message: fmt.Sprintf("サイズは%vのいずれかを指定してください。", file.ThumbnailSizes),
+		}
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	thumbnailPath, err := controller.GetThumbnailService.Execute(*user, req.FileId, req.Size)
+	if err != nil {
+		return err
+	}
+
+	ctx.Set(fiber.HeaderCacheControl, derivativeCacheControl)
+
+	return ctx.SendFile(thumbnailPath)
+}
+
+func (controller *Controller) GetSprite(ctx *fiber.Ctx) error {
+	req := request.GetSpriteRequest{}
+
+	if err := ctx.ParamsParser(&req); err != nil {
+		return err
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	spritePath, err := controller.GetSpriteService.Execute(*user, req.FileId)
+	if err != nil {
+		return err
+	}
+
+	ctx.Set(fiber.HeaderCacheControl, derivativeCacheControl)
+
+	return ctx.SendFile(spritePath)
+}
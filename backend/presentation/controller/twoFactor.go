@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/YahiroRyo/yappi_storage/backend/helper/validate"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/request"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/response"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/session"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ログインの2段階目。/users/login が返したチャレンジと認証コードを確認する
+func (controller *Controller) VerifyLogin(ctx *fiber.Ctx) error {
+	req := request.VerifyLoginRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	if err := validate.Validate(&req); err != nil {
+		return err
+	}
+
+	sess, err := session.GetSession(ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := controller.LoginService.ExecuteTwoFactor(sess, req.Challenge, req.Code, ctx.Get(fiber.HeaderUserAgent), ctx.IP(), time.Now())
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(user)
+}
+
+func (controller *Controller) EnrollTotp(ctx *fiber.Ctx) error {
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	enrollment, err := controller.EnrollTotpService.Execute(*user)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(enrollment)
+}
+
+func (controller *Controller) EnableTotp(ctx *fiber.Ctx) error {
+	req := request.TwoFactorCodeRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	if err := validate.Validate(&req); err != nil {
+		return err
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	recoveryCodes, err := controller.EnableTotpService.Execute(*user, req.Code, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(response.RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
+
+func (controller *Controller) DisableTotp(ctx *fiber.Ctx) error {
+	req := request.TwoFactorCodeRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	if err := validate.Validate(&req); err != nil {
+		return err
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := controller.DisableTotpService.Execute(*user, req.Code, time.Now()); err != nil {
+		return err
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (controller *Controller) RegenerateRecoveryCodes(ctx *fiber.Ctx) error {
+	req := request.TwoFactorCodeRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+
+	if err := validate.Validate(&req); err != nil {
+		return err
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	recoveryCodes, err := controller.RegenerateRecoveryCodesService.Execute(*user, req.Code, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(response.RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
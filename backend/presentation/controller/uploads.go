@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/upload"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/request"
+	"github.com/YahiroRyo/yappi_storage/backend/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+func setTusUploadHeaders(ctx *fiber.Ctx, u upload.Upload) {
+	ctx.Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	ctx.Set("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+func (controller *Controller) TusOptions(ctx *fiber.Ctx) error {
+	ctx.Set("Tus-Version", service.TusVersion)
+	ctx.Set("Tus-Extension", "creation,termination,checksum,expiration")
+	ctx.Set("Tus-Max-Size", strconv.FormatInt(upload.MaxSize, 10))
+	ctx.Set("Tus-Checksum-Algorithm", strings.Join(service.TusChecksumAlgorithms, ","))
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (controller *Controller) CreateTusUpload(ctx *fiber.Ctx) error {
+	principal, err := middleware.PrincipalFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+	loggedInUser := principal.User
+
+	length, err := strconv.ParseInt(ctx.Get("Upload-Length"), 10, 64)
+	if err != nil {
+		return service.TusError{Code: 400, Message: "Upload-Lengthを指定してください。"}
+	}
+
+	u, err := controller.TusService.Create(loggedInUser, principal.ApiToken, length, ctx.Get("Upload-Metadata"))
+	if err != nil {
+		return err
+	}
+
+	setTusUploadHeaders(ctx, *u)
+	ctx.Set(fiber.HeaderLocation, os.Getenv("BASE_URL")+"/v1/uploads/"+u.ID)
+
+	return ctx.Status(fiber.StatusCreated).Send(nil)
+}
+
+func (controller *Controller) GetTusUpload(ctx *fiber.Ctx) error {
+	req := request.TusUploadRequest{}
+
+	if err := ctx.ParamsParser(&req); err != nil {
+		return err
+	}
+
+	principal, err := middleware.PrincipalFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+	loggedInUser := principal.User
+
+	u, err := controller.TusService.Get(loggedInUser, req.Id)
+	if err != nil {
+		return err
+	}
+
+	setTusUploadHeaders(ctx, *u)
+	ctx.Set("Upload-Length", strconv.FormatInt(u.Length, 10))
+	if u.Metadata != "" {
+		ctx.Set("Upload-Metadata", u.Metadata)
+	}
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+
+	return ctx.Status(fiber.StatusOK).Send(nil)
+}
+
+func (controller *Controller) PatchTusUpload(ctx *fiber.Ctx) error {
+	req := request.TusUploadRequest{}
+
+	if err := ctx.ParamsParser(&req); err != nil {
+		return err
+	}
+
+	if ctx.Get(fiber.HeaderContentType) != "application/offset+octet-stream" {
+		return service.TusError{Code: 415, Message: "Content-Typeはapplication/offset+octet-streamを指定してください。"}
+	}
+
+	offset, err := strconv.ParseInt(ctx.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		return service.TusError{Code: 400, Message: "Upload-Offsetを指定してください。"}
+	}
+
+	principal, err := middleware.PrincipalFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+	loggedInUser := principal.User
+
+	var body io.Reader = ctx.Request().BodyStream()
+	if body == nil {
+		body = bytes.NewReader(ctx.Request().Body())
+	}
+
+	u, err := controller.TusService.Write(loggedInUser, req.Id, offset, body, ctx.Get("Upload-Checksum"))
+	if err != nil {
+		return err
+	}
+
+	setTusUploadHeaders(ctx, *u)
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (controller *Controller) DeleteTusUpload(ctx *fiber.Ctx) error {
+	req := request.TusUploadRequest{}
+
+	if err := ctx.ParamsParser(&req); err != nil {
+		return err
+	}
+
+	principal, err := middleware.PrincipalFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+	loggedInUser := principal.User
+
+	if err := controller.TusService.Terminate(loggedInUser, req.Id); err != nil {
+		return err
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
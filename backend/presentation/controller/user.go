@@ -1,9 +1,13 @@
 package controller
 
 import (
+	"time"
+
 	"github.com/cockroachdb/errors"
 
+	"github.com/YahiroRyo/yappi_storage/backend/domain/apitoken"
 	"github.com/YahiroRyo/yappi_storage/backend/helper/validate"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
 	"github.com/YahiroRyo/yappi_storage/backend/presentation/request"
 	"github.com/YahiroRyo/yappi_storage/backend/presentation/response"
 	"github.com/YahiroRyo/yappi_storage/backend/presentation/session"
@@ -25,11 +29,19 @@ func (controller *Controller) Login(ctx *fiber.Ctx) error {
 		return errors.WithStack(err)
 	}
 
-	user, err := controller.LoginService.Execute(sess, req.Email, req.Password)
+	user, challenge, err := controller.LoginService.Execute(sess, req.Email, req.Password, ctx.Get(fiber.HeaderUserAgent), ctx.IP(), time.Now())
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
+	// 二段階認証を有効にしている場合は、認証コードを /users/login/verify に送る
+	if challenge != nil {
+		return ctx.JSON(response.TwoFactorChallengeResponse{
+			TwoFactorRequired:  true,
+			TwoFactorChallenge: *challenge,
+		})
+	}
+
 	ctx.JSON(user)
 
 	return nil
@@ -50,12 +62,13 @@ func (controller *Controller) Registration(ctx *fiber.Ctx) error {
 		return errors.WithStack(err)
 	}
 
-	err = controller.RegistrationUserService.Execute(sess, req.Email, req.Password, req.Icon)
+	err = controller.RegistrationUserService.Execute(sess, req.Email, req.Password, req.Icon, req.InviteCode, ctx.IP())
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	user, err := controller.LoginService.Execute(sess, req.Email, req.Password)
+	// 登録したばかりのユーザーは二段階認証を有効にしていない
+	user, _, err := controller.LoginService.Execute(sess, req.Email, req.Password, ctx.Get(fiber.HeaderUserAgent), ctx.IP(), time.Now())
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -66,12 +79,7 @@ func (controller *Controller) Registration(ctx *fiber.Ctx) error {
 }
 
 func (controller *Controller) GetLoggedInUser(ctx *fiber.Ctx) error {
-	sess, err := session.GetSession(ctx)
-	if err != nil {
-		return errors.WithStack(err)
-	}
-
-	user, err := controller.GetLoggedInUserService.Execute(sess)
+	user, err := middleware.LoggedInUser(ctx)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -80,12 +88,17 @@ func (controller *Controller) GetLoggedInUser(ctx *fiber.Ctx) error {
 }
 
 func (controller *Controller) Logout(ctx *fiber.Ctx) error {
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
 	sess, err := session.GetSession(ctx)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	err = controller.LogoutService.Execute(sess)
+	err = controller.LogoutService.Execute(*user, sess)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -94,22 +107,51 @@ func (controller *Controller) Logout(ctx *fiber.Ctx) error {
 }
 
 func (controller *Controller) GenerateToken(ctx *fiber.Ctx) error {
-	sess, err := session.GetSession(ctx)
+	user, err := middleware.LoggedInUser(ctx)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	user, err := controller.GetLoggedInUserService.Execute(sess)
+	// 以前の単一のトークンと同じく、管理以外の全ての操作ができる期限の無いトークンを発行する
+	_, token, err := controller.CreateApiTokenService.Execute(*user, "トークン", []apitoken.Scope{apitoken.ScopeRead, apitoken.ScopeWrite, apitoken.ScopeDelete}, nil, nil)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	token, err := controller.GenerateTokenService.Execute(*user)
+	return ctx.JSON(response.GenerateTokenResponse{
+		Token: token,
+	})
+}
+
+func (controller *Controller) UpdateUserSetting(ctx *fiber.Ctx) error {
+	req := request.UpdateUserSettingRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return errors.WithStack(err)
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	return ctx.JSON(response.GenerateTokenResponse{
-		Token: *token,
-	})
+	updatedUser, err := controller.UpdateUserSettingService.Execute(*user, req.StripLocationMetadata)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return ctx.JSON(updatedUser)
+}
+
+func (controller *Controller) GetUsage(ctx *fiber.Ctx) error {
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	usage, err := controller.QuotaService.GetUsage(*user)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return ctx.JSON(usage)
 }
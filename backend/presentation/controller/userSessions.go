@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/request"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/session"
+	"github.com/gofiber/fiber/v2"
+)
+
+func (controller *Controller) GetUserSessions(ctx *fiber.Ctx) error {
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	sess, err := session.GetSession(ctx)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := controller.GetUserSessionsService.Execute(*user, sess)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(sessions)
+}
+
+func (controller *Controller) RevokeUserSession(ctx *fiber.Ctx) error {
+	req := request.RevokeUserSessionRequest{}
+	if err := ctx.ParamsParser(&req); err != nil {
+		return err
+	}
+
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := controller.RevokeUserSessionService.Execute(*user, req.Id); err != nil {
+		return err
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// 今の端末以外を全てログアウトさせる
+func (controller *Controller) RevokeOtherUserSessions(ctx *fiber.Ctx) error {
+	user, err := middleware.LoggedInUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	sess, err := session.GetSession(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := controller.RevokeUserSessionService.ExecuteOthers(*user, sess); err != nil {
+		return err
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
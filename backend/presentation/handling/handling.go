@@ -2,6 +2,8 @@ package handling
 
 import (
 	"log"
+	"math"
+	"strconv"
 
 	"github.com/cockroachdb/errors"
 
@@ -14,18 +16,151 @@ import (
 )
 
 func bignessLogicErrorHandler(ctx *fiber.Ctx, err error) bool {
+	var rateLimitedError service.RateLimitedError
+	if errors.As(err, &rateLimitedError) {
+		ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(max(int(math.Ceil(rateLimitedError.RetryAfter.Seconds())), 1)))
+		ctx.Status(rateLimitedError.Code).JSON(response.ErrorResponse{Message: rateLimitedError.Message})
+		return true
+	}
+
 	var alreadyUsedEmailAddress service.AlreadyUsedEmailAddressError
 	if errors.As(err, &alreadyUsedEmailAddress) {
 		ctx.Status(alreadyUsedEmailAddress.Code).JSON(response.ErrorResponse{Message: alreadyUsedEmailAddress.Message})
 		return true
 	}
 
+	var unsupportedFileKindError service.UnsupportedFileKindError
+	if errors.As(err, &unsupportedFileKindError) {
+		ctx.Status(unsupportedFileKindError.Code).JSON(response.ErrorResponse{Message: unsupportedFileKindError.Message})
+		return true
+	}
+
+	var jobAlreadyFinishedError service.JobAlreadyFinishedError
+	if errors.As(err, &jobAlreadyFinishedError) {
+		ctx.Status(jobAlreadyFinishedError.Code).JSON(response.ErrorResponse{Message: jobAlreadyFinishedError.Message})
+		return true
+	}
+
+	var shareExpiredError service.ShareExpiredError
+	if errors.As(err, &shareExpiredError) {
+		ctx.Status(shareExpiredError.Code).JSON(response.ErrorResponse{Message: shareExpiredError.Message})
+		return true
+	}
+
+	var sharePasswordRequiredError service.SharePasswordRequiredError
+	if errors.As(err, &sharePasswordRequiredError) {
+		ctx.Status(sharePasswordRequiredError.Code).JSON(response.ErrorResponse{Message: sharePasswordRequiredError.Message})
+		return true
+	}
+
+	var sharePermissionDeniedError service.SharePermissionDeniedError
+	if errors.As(err, &sharePermissionDeniedError) {
+		ctx.Status(sharePermissionDeniedError.Code).JSON(response.ErrorResponse{Message: sharePermissionDeniedError.Message})
+		return true
+	}
+
+	var shareDownloadLimitExceededError service.ShareDownloadLimitExceededError
+	if errors.As(err, &shareDownloadLimitExceededError) {
+		ctx.Status(shareDownloadLimitExceededError.Code).JSON(response.ErrorResponse{Message: shareDownloadLimitExceededError.Message})
+		return true
+	}
+
+	var invalidUploadError service.InvalidUploadError
+	if errors.As(err, &invalidUploadError) {
+		ctx.Status(invalidUploadError.Code).JSON(response.ErrorResponse{Message: invalidUploadError.Message})
+		return true
+	}
+
+	var invalidPasswordError service.InvalidPasswordError
+	if errors.As(err, &invalidPasswordError) {
+		ctx.Status(invalidPasswordError.Code).JSON(response.ErrorResponse{Message: invalidPasswordError.Message})
+		return true
+	}
+
+	var emailAlreadyVerifiedError service.EmailAlreadyVerifiedError
+	if errors.As(err, &emailAlreadyVerifiedError) {
+		ctx.Status(emailAlreadyVerifiedError.Code).JSON(response.ErrorResponse{Message: emailAlreadyVerifiedError.Message})
+		return true
+	}
+
+	var oidcError service.OidcError
+	if errors.As(err, &oidcError) {
+		ctx.Status(oidcError.Code).JSON(response.ErrorResponse{Message: oidcError.Message})
+		return true
+	}
+
+	var passwordLoginDisabledError service.PasswordLoginDisabledError
+	if errors.As(err, &passwordLoginDisabledError) {
+		ctx.Status(passwordLoginDisabledError.Code).JSON(response.ErrorResponse{Message: passwordLoginDisabledError.Message})
+		return true
+	}
+
+	var userDisabledError service.UserDisabledError
+	if errors.As(err, &userDisabledError) {
+		ctx.Status(userDisabledError.Code).JSON(response.ErrorResponse{Message: userDisabledError.Message})
+		return true
+	}
+
+	var registrationNotAllowedError service.RegistrationNotAllowedError
+	if errors.As(err, &registrationNotAllowedError) {
+		ctx.Status(registrationNotAllowedError.Code).JSON(response.ErrorResponse{Message: registrationNotAllowedError.Message})
+		return true
+	}
+
+	var adminOperationError service.AdminOperationError
+	if errors.As(err, &adminOperationError) {
+		ctx.Status(adminOperationError.Code).JSON(response.ErrorResponse{Message: adminOperationError.Message})
+		return true
+	}
+
+	var quotaExceededError service.QuotaExceededError
+	if errors.As(err, &quotaExceededError) {
+		ctx.Status(quotaExceededError.Code).JSON(response.ErrorResponse{Message: quotaExceededError.Message})
+		return true
+	}
+
+	var tusError service.TusError
+	if errors.As(err, &tusError) {
+		ctx.Status(tusError.Code).JSON(response.ErrorResponse{Message: tusError.Message})
+		return true
+	}
+
+	var apiTokenPermissionDeniedError service.ApiTokenPermissionDeniedError
+	if errors.As(err, &apiTokenPermissionDeniedError) {
+		ctx.Status(apiTokenPermissionDeniedError.Code).JSON(response.ErrorResponse{Message: apiTokenPermissionDeniedError.Message})
+		return true
+	}
+
+	var twoFactorRequiredError service.TwoFactorRequiredError
+	if errors.As(err, &twoFactorRequiredError) {
+		ctx.Status(twoFactorRequiredError.Code).JSON(response.ErrorResponse{Message: twoFactorRequiredError.Message})
+		return true
+	}
+
+	var invalidTwoFactorCodeError service.InvalidTwoFactorCodeError
+	if errors.As(err, &invalidTwoFactorCodeError) {
+		ctx.Status(invalidTwoFactorCodeError.Code).JSON(response.ErrorResponse{Message: invalidTwoFactorCodeError.Message})
+		return true
+	}
+
+	var twoFactorConflictError service.TwoFactorConflictError
+	if errors.As(err, &twoFactorConflictError) {
+		ctx.Status(twoFactorConflictError.Code).JSON(response.ErrorResponse{Message: twoFactorConflictError.Message})
+		return true
+	}
+
 	var notLoggedInError middleware.NotLoggedInError
 	if errors.As(err, &notLoggedInError) {
 		ctx.Status(notLoggedInError.Code).JSON(response.ErrorResponse{Message: notLoggedInError.Message})
 		return true
 	}
 
+	var invalidSignatureError middleware.InvalidSignatureError
+	if errors.As(err, &invalidSignatureError) {
+		ctx.Status(invalidSignatureError.Code).JSON(response.ErrorResponse{Message: invalidSignatureError.Message})
+		return true
+	}
+
 	return false
 }
 
@@ -55,7 +190,8 @@ func ErrorHandler(ctx *fiber.Ctx, err error) error {
 	code := fiber.StatusInternalServerError
 
 	var notLoggedInError middleware.NotLoggedInError
-	if !errors.As(err, &notLoggedInError) {
+	var rateLimitedError service.RateLimitedError
+	if !errors.As(err, &notLoggedInError) && !errors.As(err, &rateLimitedError) {
 		log.Printf("%+v\n", err)
 	}
 
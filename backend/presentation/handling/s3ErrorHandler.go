@@ -0,0 +1,42 @@
+package handling
+
+import (
+	"log"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/YahiroRyo/yappi_storage/backend/helper/validate"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/response"
+	"github.com/YahiroRyo/yappi_storage/backend/service"
+)
+
+// S3ErrorHandler returns the errors of the following handlers as S3 error documents instead of JSON.
+func S3ErrorHandler(ctx *fiber.Ctx) error {
+	err := ctx.Next()
+	if err == nil {
+		return nil
+	}
+
+	s3Err := service.S3Error{Status: 500, Code: "InternalError", Message: "We encountered an internal error. Please try again."}
+	var validationErr validate.ValidationError
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &s3Err):
+	case errors.As(err, &validationErr):
+		s3Err = service.S3Error{Status: 400, Code: "InvalidArgument", Message: validationErr.Message}
+	case errors.As(err, &fiberErr):
+		s3Err = service.S3Error{Status: fiberErr.Code, Code: "InvalidRequest", Message: fiberErr.Message}
+	default:
+		log.Printf("%+v\n", err)
+	}
+
+	requestID, _ := ctx.Locals("requestid").(string)
+
+	return response.S3XML(ctx, s3Err.Status, response.S3ErrorResponse{
+		Code:      s3Err.Code,
+		Message:   s3Err.Message,
+		Resource:  ctx.Path(),
+		RequestID: requestID,
+	})
+}
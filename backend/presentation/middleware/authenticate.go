@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/auth"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/session"
+	"github.com/gofiber/fiber/v2"
+)
+
+const basicAuthChallenge = `Basic realm="yappi_storage", charset="UTF-8"`
+
+// Authenticate resolves the principal of the request once, using only the given methods, and stores it in Locals.
+// Handlers read it with PrincipalFromLocals or LoggedInUser instead of looking the user up again.
+func (m *Middleware) Authenticate(methods ...auth.Method) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		authorization := ctx.Get(fiber.HeaderAuthorization)
+		scheme, _, _ := strings.Cut(strings.TrimSpace(authorization), " ")
+
+		switch {
+		case authorization != "" && strings.EqualFold(scheme, "Basic") && slices.Contains(methods, auth.MethodBasic):
+			return m.authenticateByBasicAuth(ctx, authorization)
+		case authorization != "" && slices.Contains(methods, auth.MethodBearer):
+			return m.authenticateByToken(ctx, authorization)
+		case slices.Contains(methods, auth.MethodSession):
+			// セッションのみのルートではAuthorizationヘッダを見ない
+			return m.authenticateBySession(ctx)
+		case slices.Contains(methods, auth.MethodBasic):
+			// WebDAVクライアントは401を受け取ってから認証情報を送る
+			ctx.Set(fiber.HeaderWWWAuthenticate, basicAuthChallenge)
+			return NotLoggedInError{Code: 401, Message: "認証が必要です。"}
+		}
+
+		return NotLoggedInError{Code: 401, Message: "使用不可能なトークンです"}
+	}
+}
+
+func (m *Middleware) authenticateBySession(ctx *fiber.Ctx) error {
+	sess, err := session.GetSession(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	user, err := m.GetLoggedInUserService.Execute(sess)
+	if err != nil {
+		return errors.WithStack(NotLoggedInError{Code: 401, Message: "ログインを行ってください。"})
+	}
+
+	setPrincipal(ctx, auth.NewUserPrincipal(*user, auth.MethodSession))
+
+	return ctx.Next()
+}
+
+func (m *Middleware) authenticateByToken(ctx *fiber.Ctx, authorization string) error {
+	if err := m.RateLimitService.CheckTokenAuth(ctx.IP()); err != nil {
+		return errors.WithStack(err)
+	}
+
+	user, apiToken, err := m.AuthenticateApiTokenService.Execute(parseBearerToken(authorization))
+	if err != nil {
+		m.RateLimitService.FailTokenAuth(ctx.IP())
+		return NotLoggedInError{Code: 401, Message: "使用不可能なトークンです"}
+	}
+
+	setPrincipal(ctx, auth.NewApiTokenPrincipal(*user, auth.MethodBearer, *apiToken))
+
+	return ctx.Next()
+}
+
+// WebDAVクライアント向けに、Basic認証のパスワードとしてAPIトークンを受け取る。ユーザー名は使わない
+func (m *Middleware) authenticateByBasicAuth(ctx *fiber.Ctx, authorization string) error {
+	token, ok := parseBasicAuthPassword(authorization)
+	if !ok || token == "" {
+		ctx.Set(fiber.HeaderWWWAuthenticate, basicAuthChallenge)
+		return NotLoggedInError{Code: 401, Message: "認証が必要です。"}
+	}
+
+	if err := m.RateLimitService.CheckTokenAuth(ctx.IP()); err != nil {
+		return errors.WithStack(err)
+	}
+
+	user, apiToken, err := m.AuthenticateApiTokenService.Execute(token)
+	if err != nil {
+		m.RateLimitService.FailTokenAuth(ctx.IP())
+		ctx.Set(fiber.HeaderWWWAuthenticate, basicAuthChallenge)
+		return NotLoggedInError{Code: 401, Message: "使用不可能なトークンです"}
+	}
+
+	setPrincipal(ctx, auth.NewApiTokenPrincipal(*user, auth.MethodBasic, *apiToken))
+
+	return ctx.Next()
+}
+
+// "Bearer " を付けずにトークンのみを送るクライアントにも対応する
+func parseBearerToken(authorization string) string {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(authorization), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+
+	return strings.TrimSpace(authorization)
+}
+
+func parseBasicAuthPassword(authorization string) (string, bool) {
+	scheme, credentials, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Basic") {
+		return "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(credentials))
+	if err != nil {
+		return "", false
+	}
+
+	_, password, ok := strings.Cut(string(decoded), ":")
+
+	return password, ok
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/auth"
+	"github.com/YahiroRyo/yappi_storage/backend/helper/sigv4"
+)
+
+// S3互換APIのSigV4署名を検証する。AuthorizationヘッダとクエリによるURL署名の両方に対応する
+func (m *Middleware) AuthenticateS3Middleware(ctx *fiber.Ctx) error {
+	user, s3Auth, err := m.AuthenticateS3RequestService.Execute(sigv4.Request{
+		Method: ctx.Method(),
+		// 署名はクライアントが送ったエスケープのままのパスで計算されている
+		RawPath:  string(ctx.Request().URI().PathOriginal()),
+		RawQuery: string(ctx.Request().URI().QueryString()),
+		Header: func(name string) string {
+			// 絶対URIで送られた場合はHostヘッダが空になるため、URIのホストを使う
+			if name == "host" {
+				return string(ctx.Request().URI().Host())
+			}
+			return ctx.Get(name)
+		},
+	}, time.Now())
+	if err != nil {
+		return err
+	}
+
+	setPrincipal(ctx, auth.NewUserPrincipal(*user, auth.MethodS3))
+	ctx.Locals("s3_auth", *s3Auth)
+
+	return ctx.Next()
+}
@@ -8,3 +8,12 @@ type NotLoggedInError struct {
 func (e NotLoggedInError) Error() string {
 	return e.Message
 }
+
+type InvalidSignatureError struct {
+	Code    int
+	Message string
+}
+
+func (e InvalidSignatureError) Error() string {
+	return e.Message
+}
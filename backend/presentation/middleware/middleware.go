@@ -3,6 +3,10 @@ package middleware
 import "github.com/YahiroRyo/yappi_storage/backend/service"
 
 type Middleware struct {
-	GetLoggedInUserService service.GetLoggedInUserService
-	GetUserByTokenService  service.GetUserByTokenService
+	GetLoggedInUserService      service.GetLoggedInUserService
+	AuthenticateApiTokenService service.AuthenticateApiTokenService
+	GetAuthSettingService       service.GetAuthSettingService
+	RateLimitService            service.RateLimitService
+
+	AuthenticateS3RequestService service.AuthenticateS3RequestService
 }
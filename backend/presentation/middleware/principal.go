@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"github.com/YahiroRyo/yappi_storage/backend/domain/auth"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/gofiber/fiber/v2"
+)
+
+// 認証ミドルウェアが認証した主体を保存するLocalsのキー
+const PrincipalLocalsKey = "principal"
+
+func setPrincipal(ctx *fiber.Ctx, principal auth.Principal) {
+	ctx.Locals(PrincipalLocalsKey, principal)
+}
+
+// PrincipalFromLocals returns the principal stored by the authentication middleware.
+// It returns 401 when the route is not behind the middleware.
+func PrincipalFromLocals(ctx *fiber.Ctx) (*auth.Principal, error) {
+	principal, ok := ctx.Locals(PrincipalLocalsKey).(auth.Principal)
+	if !ok {
+		return nil, NotLoggedInError{Code: 401, Message: "ログインを行ってください。"}
+	}
+
+	return &principal, nil
+}
+
+// 認証したユーザーのみが必要なハンドラ向け
+func LoggedInUser(ctx *fiber.Ctx) (*user.User, error) {
+	principal, err := PrincipalFromLocals(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &principal.User, nil
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 認証した主体ごとにリクエストの回数を制限する。Authenticateの後に使う
+func (m *Middleware) LimitRequests(ctx *fiber.Ctx) error {
+	principal, err := PrincipalFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	status, err := m.RateLimitService.LimitRequest(*principal)
+	if status != nil {
+		ctx.Set("X-RateLimit-Limit", strconv.FormatInt(status.Limit, 10))
+		ctx.Set("X-RateLimit-Remaining", strconv.FormatInt(status.Remaining, 10))
+		ctx.Set("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(status.Reset.Seconds()))))
+	}
+	if err != nil {
+		return err
+	}
+
+	return ctx.Next()
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/YahiroRyo/yappi_storage/backend/service"
+)
+
+// 管理者のみが使えるルート。なりすましているセッションは管理者ではないため使えない
+func (m *Middleware) RequireAdmin(ctx *fiber.Ctx) error {
+	principal, err := PrincipalFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !principal.User.IsAdmin() {
+		return service.AdminOperationError{Code: 403, Message: "管理者のみ操作できます。"}
+	}
+
+	return ctx.Next()
+}
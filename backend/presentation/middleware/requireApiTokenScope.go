@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/apitoken"
+	"github.com/YahiroRyo/yappi_storage/backend/service"
+)
+
+// 認証した主体がscopeを持つことを確認する。セッションで認証した場合は管理以外の全ての権限を持つ
+func (m *Middleware) RequireApiTokenScope(scope apitoken.Scope) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		principal, err := PrincipalFromLocals(ctx)
+		if err != nil {
+			return err
+		}
+		if !principal.HasScope(scope) {
+			return service.ApiTokenPermissionDeniedError{Code: 403, Message: "トークンに" + string(scope) + "の権限がありません。"}
+		}
+
+		return ctx.Next()
+	}
+}
+
+// WebDAVのように、メソッドによって必要な権限が決まるルートで使う
+func (m *Middleware) RequireApiTokenScopeByMethod(ctx *fiber.Ctx) error {
+	scope := apitoken.ScopeWrite
+	switch ctx.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions, "PROPFIND":
+		scope = apitoken.ScopeRead
+	case fiber.MethodDelete:
+		scope = apitoken.ScopeDelete
+	}
+
+	return m.RequireApiTokenScope(scope)(ctx)
+}
+
+// ディレクトリを制限したトークンでは、制限を確認できないルートを使えないようにする
+func (m *Middleware) RejectDirectoryRestrictedApiToken(ctx *fiber.Ctx) error {
+	principal, err := PrincipalFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+	if principal.ApiToken != nil && principal.ApiToken.IsDirectoryRestricted() {
+		return service.ApiTokenPermissionDeniedError{Code: 403, Message: "ディレクトリを制限したトークンでは使用できません。"}
+	}
+
+	return ctx.Next()
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/YahiroRyo/yappi_storage/backend/service"
+)
+
+// 設定でパスワードでのログインを無効にしている場合、パスワードを扱うルートを使わせない
+func (m *Middleware) RequirePasswordLogin(ctx *fiber.Ctx) error {
+	if m.GetAuthSettingService.Execute().DisablePasswordLogin {
+		return service.PasswordLoginDisabledError{Code: 403, Message: "パスワードでのログインは無効です。シングルサインオンでログインしてください。"}
+	}
+
+	return ctx.Next()
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/auth"
+	"github.com/YahiroRyo/yappi_storage/backend/service"
+)
+
+// 設定で二段階認証を必須にしている場合、設定していないユーザーのセッションでは登録以外の操作をさせない
+func (m *Middleware) RequireTwoFactorEnrollment(ctx *fiber.Ctx) error {
+	principal, err := PrincipalFromLocals(ctx)
+	if err != nil {
+		return err
+	}
+
+	if principal.Method == auth.MethodSession && !principal.User.TwoFactorEnabled && m.GetAuthSettingService.Execute().RequireTwoFactor {
+		return service.TwoFactorRequiredError{Code: 403, Message: "二段階認証を設定してください。"}
+	}
+
+	return ctx.Next()
+}
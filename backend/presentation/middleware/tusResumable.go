@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"github.com/YahiroRyo/yappi_storage/backend/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+// tusのバージョンを確認し、全ての応答にTus-Resumableを付ける
+func (m *Middleware) TusResumableMiddleware(ctx *fiber.Ctx) error {
+	ctx.Set("Tus-Resumable", service.TusVersion)
+
+	if ctx.Method() != fiber.MethodOptions && ctx.Get("Tus-Resumable") != service.TusVersion {
+		ctx.Set("Tus-Version", service.TusVersion)
+		return service.TusError{Code: 412, Message: "対応していないtusのバージョンです。"}
+	}
+
+	return ctx.Next()
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/gofiber/fiber/v2"
+)
+
+func (m *Middleware) VerifySignedUrlMiddleware(ctx *fiber.Ctx) error {
+	expires := int64(ctx.QueryInt("expires"))
+	signature := ctx.Query("signature")
+
+	if !helper.VerifySignedPath(ctx.Path(), expires, signature) {
+		return InvalidSignatureError{Code: 403, Message: "URLの有効期限が切れているか、署名が正しくありません。"}
+	}
+
+	return ctx.Next()
+}
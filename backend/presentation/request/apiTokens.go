@@ -0,0 +1,14 @@
+package request
+
+import "time"
+
+type CreateApiTokenRequest struct {
+	Name        string     `json:"name" validate:"required,max_len=128" validate_name:"トークン名"`
+	Scopes      []string   `json:"scopes"`
+	DirectoryId *string    `json:"directory_id"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+type RevokeApiTokenRequest struct {
+	Id string `params:"id"`
+}
@@ -3,6 +3,7 @@ package request
 type GetFilesRequest struct {
 	Query             *string `query:"query" validate:"min_len=1,max_len=512" validate_name:"検索内容"`
 	ParentDirectoryId *string `query:"parent_directory_id"`
+	Order             string  `query:"order"`
 	PageSize          int     `query:"page_size" validate:"required,min=1,max=50" validate_name:"ページサイズ"`
 	CurrentPageCount  int     `query:"current_page_count" validate:"required,max=512" validate_name:"ページあたりの個数"`
 }
@@ -11,6 +12,27 @@ type GetFileRequest struct {
 	FileId string `params:"file_id"`
 }
 
+type GetThumbnailRequest struct {
+	FileId string `params:"file_id"`
+	Size   int    `query:"size"`
+}
+
+type GetSpriteRequest struct {
+	FileId string `params:"file_id"`
+}
+
+type GetHLSMasterPlaylistRequest struct {
+	FileId string `params:"file_id"`
+}
+
+type GetHLSResourceRequest struct {
+	UserId    string `params:"user_id"`
+	FileId    string `params:"file_id"`
+	Rendition string `params:"rendition"`
+	Name      string `params:"name"`
+	Expires   int64  `query:"expires"`
+}
+
 type RegistrationDirectoryRequest struct {
 	Name              string  `json:"name" validate:"required,max_len=128" validate_name:"ディレクトリ名"`
 	ParentDirectoryId *string `json:"parent_directory_id"`
@@ -21,7 +43,13 @@ type RegistrationFilesRequest struct {
 		ParentDirectoryId *string `json:"parent_directory_id"`
 		Name              string  `json:"name" validate:"required,max_len=128" validate_name:"ファイル名"`
 		Kind              string  `json:"kind" validate:"required" validate_name:"ファイル種類"`
-		Url               string  `json:"url" validate:"required,url" validate_name:"URL"`
+		// 外部のURL。アップロードした実体は upload_id で指定する
+		Url      string  `json:"url" validate:"url" validate_name:"URL"`
+		UploadId *string `json:"upload_id" validate:"id" validate_name:"アップロードID"`
+		// 動画の圧縮で元ファイルを置き換えない
+		CompressionDisabled bool `json:"compression_disabled"`
+		// URLの内容をダウンロードして自ストレージに取り込む
+		Import bool `json:"import"`
 	} `json:"registration_files" validate:"required" validate_name:"ファイル登録リスト"`
 }
 
@@ -35,6 +63,31 @@ type MoveFilesRequest struct {
 	AfterParentDirectoryId string   `json:"after_parent_directory_id"`
 }
 
+type UpdateCompressionRequest struct {
+	FileIds             []string `json:"file_ids" validate:"required" validate_name:"ファイルID"`
+	CompressionDisabled bool     `json:"compression_disabled"`
+}
+
 type DeleteFilesRequest struct {
 	FileIds []string `json:"file_ids" validate:"required" validate_name:"ファイルID"`
 }
+
+type CreateArchiveRequest struct {
+	FileIds []string `json:"file_ids" validate:"required" validate_name:"ファイルID"`
+	Format  string   `json:"format"`
+	Async   bool     `json:"async"`
+}
+
+type GetArchiveRequest struct {
+	JobId string `params:"job_id"`
+}
+
+type ExtractArchiveRequest struct {
+	FileId            string  `params:"file_id"`
+	ParentDirectoryId *string `json:"parent_directory_id"`
+}
+
+type PutFileContentRequest struct {
+	ParentDirectoryId *string `query:"parent_directory_id"`
+	Name              string  `query:"name" validate:"required" validate_name:"ファイル名"`
+}
@@ -0,0 +1,14 @@
+package request
+
+type GetJobsRequest struct {
+	PageSize         int `query:"page_size" validate:"required,min=1,max=50" validate_name:"ページサイズ"`
+	CurrentPageCount int `query:"current_page_count" validate:"required,min=1,max=512" validate_name:"ページ番号"`
+}
+
+type GetJobRequest struct {
+	JobId string `params:"job_id"`
+}
+
+type CancelJobRequest struct {
+	JobId string `params:"job_id"`
+}
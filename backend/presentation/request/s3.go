@@ -0,0 +1,39 @@
+package request
+
+import "encoding/xml"
+
+type S3BucketRequest struct {
+	ListType          string `query:"list-type"`
+	Prefix            string `query:"prefix"`
+	Delimiter         string `query:"delimiter"`
+	MaxKeys           *int   `query:"max-keys"`
+	EncodingType      string `query:"encoding-type"`
+	ContinuationToken string `query:"continuation-token"`
+	StartAfter        string `query:"start-after"`
+	Marker            string `query:"marker"`
+}
+
+type S3ObjectRequest struct {
+	UploadID   string `query:"uploadId"`
+	PartNumber *int   `query:"partNumber"`
+}
+
+type S3DeleteObjectsRequest struct {
+	XMLName xml.Name `xml:"Delete"`
+	Quiet   bool     `xml:"Quiet"`
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+type S3CompleteMultipartUploadRequest struct {
+	XMLName xml.Name `xml:"CompleteMultipartUpload"`
+	Parts   []struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	} `xml:"Part"`
+}
+
+type DeleteS3AccessKeyRequest struct {
+	Id string `params:"id"`
+}
@@ -0,0 +1,41 @@
+package request
+
+import "time"
+
+type CreateShareRequest struct {
+	FileId       string     `params:"file_id"`
+	Permission   string     `json:"permission" validate:"required" validate_name:"権限"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	Password     *string    `json:"password"`
+	MaxDownloads *int       `json:"max_downloads"`
+}
+
+type GetFileSharesRequest struct {
+	FileId           string `params:"file_id"`
+	PageSize         int    `query:"page_size" validate:"required,min=1,max=50" validate_name:"ページサイズ"`
+	CurrentPageCount int    `query:"current_page_count" validate:"required,min=1,max=512" validate_name:"ページ番号"`
+}
+
+type GetSharesRequest struct {
+	PageSize         int `query:"page_size" validate:"required,min=1,max=50" validate_name:"ページサイズ"`
+	CurrentPageCount int `query:"current_page_count" validate:"required,min=1,max=512" validate_name:"ページ番号"`
+}
+
+type RevokeShareRequest struct {
+	ShareId string `params:"share_id"`
+}
+
+type GetSharedFileRequest struct {
+	Token            string `params:"token"`
+	FileId           string `params:"file_id"`
+	Download         bool   `query:"download"`
+	Expires          int64  `query:"expires"`
+	Signature        string `query:"signature"`
+	PageSize         int    `query:"page_size" validate:"required,min=1,max=50" validate_name:"ページサイズ"`
+	CurrentPageCount int    `query:"current_page_count" validate:"required,max=512" validate_name:"ページ番号"`
+}
+
+type UnlockShareRequest struct {
+	Token    string `params:"token"`
+	Password string `json:"password" validate:"required,max_len=128" validate_name:"パスワード"`
+}
@@ -0,0 +1,5 @@
+package request
+
+type TusUploadRequest struct {
+	Id string `params:"id"`
+}
@@ -5,8 +5,82 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required,password" validate_name:"パスワード"`
 }
 
+type VerifyLoginRequest struct {
+	Challenge string `json:"challenge" validate:"required" validate_name:"チャレンジ"`
+	Code      string `json:"code" validate:"required" validate_name:"認証コード"`
+}
+
 type RegistrationRequest struct {
 	Email    string `json:"email" validate:"required,email" validate_name:"メールアドレス"`
 	Password string `json:"password" validate:"required,password" validate_name:"パスワード"`
 	Icon     string `json:"icon" validate:"required,url" validate_name:"アイコン"`
+	// 招待制の場合のみ必要
+	InviteCode string `json:"invite_code"`
+}
+
+type UpdateUserSettingRequest struct {
+	StripLocationMetadata bool `json:"strip_location_metadata"`
+}
+
+type RevokeUserSessionRequest struct {
+	Id string `params:"id"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required" validate_name:"認証コード"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email" validate_name:"メールアドレス"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required" validate_name:"トークン"`
+	Password string `json:"password" validate:"required,password" validate_name:"パスワード"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required" validate_name:"現在のパスワード"`
+	Password        string `json:"password" validate:"required,password" validate_name:"パスワード"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required" validate_name:"トークン"`
+}
+
+type OidcCallbackRequest struct {
+	State string `query:"state"`
+	Code  string `query:"code"`
+	Error string `query:"error"`
+}
+
+type GetUsersRequest struct {
+	PageSize         int `query:"page_size" validate:"required,min=1,max=50" validate_name:"ページサイズ"`
+	CurrentPageCount int `query:"current_page_count" validate:"required,min=1" validate_name:"ページ番号"`
+}
+
+type AdminUserRequest struct {
+	Id string `params:"id"`
+}
+
+type UpdateUserDisabledRequest struct {
+	Disabled bool `json:"disabled"`
+}
+
+type UpdateUserRoleRequest struct {
+	Role string `json:"role" validate:"required" validate_name:"ロール"`
+}
+
+type UpdateUserQuotaRequest struct {
+	// nullの場合は設定の既定値に戻す
+	QuotaBytes *int64 `json:"quota_bytes"`
+}
+
+type CreateUserInviteRequest struct {
+	// 指定した場合は、このメールアドレスでのみ登録できる
+	Email *string `json:"email"`
+}
+
+type RevokeUserInviteRequest struct {
+	Id string `params:"id"`
 }
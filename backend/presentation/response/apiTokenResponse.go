@@ -0,0 +1,9 @@
+package response
+
+import "github.com/YahiroRyo/yappi_storage/backend/domain/apitoken"
+
+// トークンの平文は作成時のレスポンスでのみ返す
+type CreateApiTokenResponse struct {
+	apitoken.ApiToken
+	Token string `json:"token"`
+}
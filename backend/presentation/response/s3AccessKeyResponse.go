@@ -0,0 +1,9 @@
+package response
+
+import "github.com/YahiroRyo/yappi_storage/backend/domain/s3"
+
+// 秘密鍵は作成時のレスポンスでのみ返す
+type CreateS3AccessKeyResponse struct {
+	s3.AccessKey
+	SecretAccessKey string `json:"secret_access_key"`
+}
@@ -0,0 +1,139 @@
+package response
+
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// S3のレスポンスの日時の形式
+const S3TimeFormat = "2006-01-02T15:04:05.000Z"
+
+func S3Time(t time.Time) string {
+	return t.UTC().Format(S3TimeFormat)
+}
+
+// S3XML sends the value as an XML document.
+func S3XML(ctx *fiber.Ctx, status int, value any) error {
+	body, err := xml.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	ctx.Set(fiber.HeaderContentType, fiber.MIMEApplicationXMLCharsetUTF8)
+
+	return ctx.Status(status).Send(append([]byte(xml.Header), body...))
+}
+
+type S3ErrorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource,omitempty"`
+	RequestID string   `xml:"RequestId,omitempty"`
+}
+
+type S3Owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type S3BucketResponse struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type S3ListBucketsResponse struct {
+	XMLName xml.Name           `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListAllMyBucketsResult"`
+	Owner   S3Owner            `xml:"Owner"`
+	Buckets []S3BucketResponse `xml:"Buckets>Bucket"`
+}
+
+type S3LocationResponse struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LocationConstraint"`
+	// us-east-1は空で返す
+	Location string `xml:",chardata"`
+}
+
+type S3ObjectResponse struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type S3CommonPrefixResponse struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type S3ListObjectsResponse struct {
+	XMLName        xml.Name                 `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name           string                   `xml:"Name"`
+	Prefix         string                   `xml:"Prefix"`
+	Delimiter      string                   `xml:"Delimiter,omitempty"`
+	MaxKeys        int                      `xml:"MaxKeys"`
+	EncodingType   string                   `xml:"EncodingType,omitempty"`
+	IsTruncated    bool                     `xml:"IsTruncated"`
+	Contents       []S3ObjectResponse       `xml:"Contents"`
+	CommonPrefixes []S3CommonPrefixResponse `xml:"CommonPrefixes"`
+
+	// ListObjectsV2
+	KeyCount              *int   `xml:"KeyCount,omitempty"`
+	ContinuationToken     string `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string `xml:"NextContinuationToken,omitempty"`
+	StartAfter            string `xml:"StartAfter,omitempty"`
+
+	// ListObjects
+	Marker     *string `xml:"Marker,omitempty"`
+	NextMarker string  `xml:"NextMarker,omitempty"`
+}
+
+type S3DeletedResponse struct {
+	Key string `xml:"Key"`
+}
+
+type S3DeleteErrorResponse struct {
+	Key     string `xml:"Key"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+type S3DeleteObjectsResponse struct {
+	XMLName xml.Name                `xml:"http://s3.amazonaws.com/doc/2006-03-01/ DeleteResult"`
+	Deleted []S3DeletedResponse     `xml:"Deleted"`
+	Errors  []S3DeleteErrorResponse `xml:"Error"`
+}
+
+type S3InitiateMultipartUploadResponse struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+type S3CompleteMultipartUploadResponse struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CompleteMultipartUploadResult"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+type S3PartResponse struct {
+	PartNumber   int    `xml:"PartNumber"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+}
+
+type S3ListPartsResponse struct {
+	XMLName      xml.Name         `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListPartsResult"`
+	Bucket       string           `xml:"Bucket"`
+	Key          string           `xml:"Key"`
+	UploadID     string           `xml:"UploadId"`
+	StorageClass string           `xml:"StorageClass"`
+	IsTruncated  bool             `xml:"IsTruncated"`
+	Parts        []S3PartResponse `xml:"Part"`
+}
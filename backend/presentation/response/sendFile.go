@@ -0,0 +1,382 @@
+package response
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gofiber/fiber/v2"
+)
+
+type SendFileOptions struct {
+	Path     string
+	Filename string
+	MimeType string
+	// 内容のハッシュから作った強いETag(引用符を含まない)
+	ContentHash  string
+	Download     bool
+	CacheControl string
+	// 本文を返す直前に、返す最初のバイトの位置を渡して呼ぶ。エラーを返すと本文を返さない
+	// 条件付きリクエストで本文を返さない場合(304・412・416)は呼ばない
+	BeforeBody func(offset int64) error
+}
+
+// 1リクエストで受け付ける範囲の数。細かい範囲を大量に指定された場合は全体を返す
+const maxRanges = 32
+
+// 同一オリジンでスクリプトとして解釈され得る形式
+var scriptableMimeTypes = []string{"text/html", "application/xhtml+xml", "image/svg+xml", "text/xml", "application/xml"}
+
+type byteRange struct {
+	start  int64
+	length int64
+}
+
+var (
+	errInvalidRange       = errors.New("invalid range")
+	errRangeUnsatisfiable = errors.New("range not satisfiable")
+)
+
+// SendFile sends the file with validators, conditional request handling and single or multipart byte ranges.
+// The body is streamed from disk and never loaded into memory.
+func SendFile(ctx *fiber.Ctx, options SendFileOptions) error {
+	f, err := os.Open(options.Path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+
+	size := stat.Size()
+	modTime := stat.ModTime().UTC().Truncate(time.Second)
+	etag := ""
+	if options.ContentHash != "" {
+		etag = `"` + options.ContentHash + `"`
+		ctx.Set(fiber.HeaderETag, etag)
+	}
+
+	contentType := options.MimeType
+	if contentType == "" {
+		contentType = fiber.MIMEOctetStream
+	}
+
+	ctx.Set(fiber.HeaderAcceptRanges, "bytes")
+	ctx.Set(fiber.HeaderLastModified, modTime.Format(http.TimeFormat))
+	ctx.Set(fiber.HeaderContentDisposition, ContentDisposition(options.Download, options.Filename))
+	ctx.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	if options.CacheControl != "" {
+		ctx.Set(fiber.HeaderCacheControl, options.CacheControl)
+	}
+	if isScriptable(contentType) {
+		ctx.Set(fiber.HeaderContentSecurityPolicy, "sandbox")
+	}
+
+	if status := checkPreconditions(ctx, etag, modTime); status != 0 {
+		f.Close()
+		ctx.Status(status)
+		return nil
+	}
+
+	rangeHeader := ctx.Get(fiber.HeaderRange)
+	if rangeHeader == "" || !ifRangeMatches(ctx.Get(fiber.HeaderIfRange), etag, modTime) {
+		return sendWhole(ctx, f, size, contentType, options)
+	}
+
+	ranges, err := parseRange(rangeHeader, size)
+	if errors.Is(err, errRangeUnsatisfiable) {
+		f.Close()
+		ctx.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", size))
+		ctx.Status(fiber.StatusRequestedRangeNotSatisfiable)
+		return nil
+	}
+
+	// 不正なRangeヘッダは無視して全体を返す
+	if err != nil || len(ranges) == 0 || len(ranges) > maxRanges || sumRanges(ranges) > size {
+		return sendWhole(ctx, f, size, contentType, options)
+	}
+
+	if options.BeforeBody != nil {
+		offset := ranges[0].start
+		for _, r := range ranges[1:] {
+			offset = min(offset, r.start)
+		}
+		if err := options.BeforeBody(offset); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	ctx.Status(fiber.StatusPartialContent)
+
+	if len(ranges) == 1 {
+		r := ranges[0]
+		ctx.Set(fiber.HeaderContentType, contentType)
+		ctx.Set(fiber.HeaderContentRange, contentRange(r, size))
+		ctx.Context().SetBodyStream(readCloser{io.NewSectionReader(f, r.start, r.length), f}, int(r.length))
+		return nil
+	}
+
+	// multipart/byteranges は各部分の見出しと範囲を順に読み出して組み立てる
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	readers := make([]io.Reader, 0, len(ranges)*2+1)
+	var length int64
+	for i, r := range ranges {
+		header := fmt.Sprintf("--%s\r\n%s: %s\r\n%s: %s\r\n\r\n", boundary, fiber.HeaderContentType, contentType, fiber.HeaderContentRange, contentRange(r, size))
+		if i > 0 {
+			header = "\r\n" + header
+		}
+
+		readers = append(readers, strings.NewReader(header), io.NewSectionReader(f, r.start, r.length))
+		length += int64(len(header)) + r.length
+	}
+	closing := fmt.Sprintf("\r\n--%s--\r\n", boundary)
+	readers = append(readers, strings.NewReader(closing))
+	length += int64(len(closing))
+
+	ctx.Set(fiber.HeaderContentType, "multipart/byteranges; boundary="+boundary)
+	ctx.Context().SetBodyStream(readCloser{io.MultiReader(readers...), f}, int(length))
+
+	return nil
+}
+
+func sendWhole(ctx *fiber.Ctx, f *os.File, size int64, contentType string, options SendFileOptions) error {
+	if options.BeforeBody != nil {
+		if err := options.BeforeBody(0); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	ctx.Set(fiber.HeaderContentType, contentType)
+	ctx.Context().SetBodyStream(f, int(size))
+
+	return nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// ContentDisposition returns the header value with an ASCII fallback and the RFC 5987 encoded UTF-8 filename
+func ContentDisposition(download bool, filename string) string {
+	dispositionType := "inline"
+	if download {
+		dispositionType = "attachment"
+	}
+
+	if filename == "" {
+		return dispositionType
+	}
+
+	fallback := strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7E || r == '"' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, filename)
+
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, dispositionType, fallback, encodeRFC5987(filename))
+}
+
+func encodeRFC5987(value string) string {
+	var builder strings.Builder
+	for _, b := range []byte(value) {
+		if isAttrChar(b) {
+			builder.WriteByte(b)
+			continue
+		}
+		fmt.Fprintf(&builder, "%%%02X", b)
+	}
+
+	return builder.String()
+}
+
+func isAttrChar(b byte) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		return true
+	}
+
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
+
+func isScriptable(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for _, scriptable := range scriptableMimeTypes {
+		if mediaType == scriptable {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkPreconditions evaluates the conditional headers in the order of RFC 9110 13.2.2.
+// It returns 0 when the request should be processed.
+func checkPreconditions(ctx *fiber.Ctx, etag string, modTime time.Time) int {
+	if ifMatch := ctx.Get(fiber.HeaderIfMatch); ifMatch != "" {
+		if !etagListMatches(ifMatch, etag, false) {
+			return fiber.StatusPreconditionFailed
+		}
+	} else if ifUnmodifiedSince, err := http.ParseTime(ctx.Get(fiber.HeaderIfUnmodifiedSince)); err == nil {
+		if modTime.After(ifUnmodifiedSince) {
+			return fiber.StatusPreconditionFailed
+		}
+	}
+
+	isGetOrHead := ctx.Method() == fiber.MethodGet || ctx.Method() == fiber.MethodHead
+
+	if ifNoneMatch := ctx.Get(fiber.HeaderIfNoneMatch); ifNoneMatch != "" {
+		if etagListMatches(ifNoneMatch, etag, true) {
+			if isGetOrHead {
+				return fiber.StatusNotModified
+			}
+			return fiber.StatusPreconditionFailed
+		}
+	} else if ifModifiedSince, err := http.ParseTime(ctx.Get(fiber.HeaderIfModifiedSince)); err == nil && isGetOrHead {
+		if !modTime.After(ifModifiedSince) {
+			return fiber.StatusNotModified
+		}
+	}
+
+	return 0
+}
+
+// etagListMatches reports whether the comma separated list contains the ETag.
+// The weak comparison ignores the W/ prefix, while the strong comparison never matches weak tags.
+func etagListMatches(list string, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+
+		if candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// If-Rangeが無いか、ETagまたは更新日時が一致する場合のみ範囲を返す
+func ifRangeMatches(ifRange string, etag string, modTime time.Time) bool {
+	if ifRange == "" {
+		return true
+	}
+
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		return etag != "" && ifRange == etag
+	}
+
+	t, err := http.ParseTime(ifRange)
+	if err != nil {
+		return false
+	}
+
+	return t.Equal(modTime)
+}
+
+func parseRange(header string, size int64) ([]byteRange, error) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(header, prefix) {
+		return nil, errInvalidRange
+	}
+
+	ranges := []byteRange{}
+	unsatisfiable := false
+
+	for _, spec := range strings.Split(header[len(prefix):], ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		startValue, endValue, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, errInvalidRange
+		}
+		startValue = strings.TrimSpace(startValue)
+		endValue = strings.TrimSpace(endValue)
+
+		var r byteRange
+		if startValue == "" {
+			// 末尾からのバイト数の指定
+			suffixLength, err := strconv.ParseInt(endValue, 10, 64)
+			if err != nil || suffixLength < 0 {
+				return nil, errInvalidRange
+			}
+			if suffixLength == 0 || size == 0 {
+				unsatisfiable = true
+				continue
+			}
+
+			suffixLength = min(suffixLength, size)
+			r = byteRange{start: size - suffixLength, length: suffixLength}
+		} else {
+			start, err := strconv.ParseInt(startValue, 10, 64)
+			if err != nil || start < 0 {
+				return nil, errInvalidRange
+			}
+			if start >= size {
+				unsatisfiable = true
+				continue
+			}
+
+			end := size - 1
+			if endValue != "" {
+				end, err = strconv.ParseInt(endValue, 10, 64)
+				if err != nil || start > end {
+					return nil, errInvalidRange
+				}
+				end = min(end, size-1)
+			}
+
+			r = byteRange{start: start, length: end - start + 1}
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	if len(ranges) == 0 && unsatisfiable {
+		return nil, errRangeUnsatisfiable
+	}
+
+	return ranges, nil
+}
+
+func sumRanges(ranges []byteRange) int64 {
+	var sum int64
+	for _, r := range ranges {
+		sum += r.length
+	}
+
+	return sum
+}
+
+func contentRange(r byteRange, size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
@@ -0,0 +1,280 @@
+package response
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+const testEtag = `"abc"`
+
+func newTestCtx(t *testing.T, method string, headers map[string]string) *fiber.Ctx {
+	t.Helper()
+
+	app := fiber.New()
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	t.Cleanup(func() { app.ReleaseCtx(ctx) })
+
+	ctx.Method(method)
+	for name, value := range headers {
+		ctx.Request().Header.Set(name, value)
+	}
+
+	return ctx
+}
+
+func TestCheckPreconditions(t *testing.T) {
+	modTime := time.Unix(1760000000, 0).UTC()
+	before := modTime.Add(-time.Hour).Format(http.TimeFormat)
+	same := modTime.Format(http.TimeFormat)
+	after := modTime.Add(time.Hour).Format(http.TimeFormat)
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    int
+	}{
+		{name: "条件なし", method: fiber.MethodGet, want: 0},
+		{name: "If-Matchが一致", method: fiber.MethodGet, headers: map[string]string{"If-Match": testEtag}, want: 0},
+		{name: "If-Matchは弱いETagと一致しない", method: fiber.MethodGet, headers: map[string]string{"If-Match": `W/"abc"`}, want: fiber.StatusPreconditionFailed},
+		{name: "If-Matchの*", method: fiber.MethodPut, headers: map[string]string{"If-Match": "*"}, want: 0},
+		{name: "If-Matchの一覧に含まれる", method: fiber.MethodGet, headers: map[string]string{"If-Match": `"other", "abc"`}, want: 0},
+		{name: "If-Matchが一致しない", method: fiber.MethodGet, headers: map[string]string{"If-Match": `"other"`}, want: fiber.StatusPreconditionFailed},
+		{name: "If-MatchはIf-Unmodified-Sinceより優先する", method: fiber.MethodGet, headers: map[string]string{"If-Match": testEtag, "If-Unmodified-Since": before}, want: 0},
+		{name: "一致しないIf-MatchはIf-Unmodified-Sinceより優先する", method: fiber.MethodGet, headers: map[string]string{"If-Match": `"other"`, "If-Unmodified-Since": after}, want: fiber.StatusPreconditionFailed},
+		{name: "If-Unmodified-Since以降に更新", method: fiber.MethodGet, headers: map[string]string{"If-Unmodified-Since": before}, want: fiber.StatusPreconditionFailed},
+		{name: "If-Unmodified-Sinceと同じ日時", method: fiber.MethodGet, headers: map[string]string{"If-Unmodified-Since": same}, want: 0},
+		{name: "If-None-Matchが一致", method: fiber.MethodGet, headers: map[string]string{"If-None-Match": testEtag}, want: fiber.StatusNotModified},
+		{name: "If-None-Matchは弱いETagとも一致する", method: fiber.MethodGet, headers: map[string]string{"If-None-Match": `W/"abc"`}, want: fiber.StatusNotModified},
+		{name: "HEADのIf-None-Match", method: fiber.MethodHead, headers: map[string]string{"If-None-Match": testEtag}, want: fiber.StatusNotModified},
+		{name: "GET以外のIf-None-Match", method: fiber.MethodPut, headers: map[string]string{"If-None-Match": testEtag}, want: fiber.StatusPreconditionFailed},
+		{name: "If-None-Matchの*", method: fiber.MethodGet, headers: map[string]string{"If-None-Match": "*"}, want: fiber.StatusNotModified},
+		{name: "GET以外のIf-None-Matchの*", method: fiber.MethodPost, headers: map[string]string{"If-None-Match": "*"}, want: fiber.StatusPreconditionFailed},
+		{name: "If-None-Matchが一致しない", method: fiber.MethodGet, headers: map[string]string{"If-None-Match": `"other"`}, want: 0},
+		{name: "If-None-MatchはIf-Modified-Sinceより優先する", method: fiber.MethodGet, headers: map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": after}, want: 0},
+		{name: "If-MatchはIf-None-Matchより先に評価する", method: fiber.MethodGet, headers: map[string]string{"If-Match": `"other"`, "If-None-Match": testEtag}, want: fiber.StatusPreconditionFailed},
+		{name: "If-Modified-Sinceと同じ日時", method: fiber.MethodGet, headers: map[string]string{"If-Modified-Since": same}, want: fiber.StatusNotModified},
+		{name: "If-Modified-Since以降に更新", method: fiber.MethodGet, headers: map[string]string{"If-Modified-Since": before}, want: 0},
+		{name: "GET以外のIf-Modified-Sinceは無視する", method: fiber.MethodPut, headers: map[string]string{"If-Modified-Since": after}, want: 0},
+		{name: "不正な日時は無視する", method: fiber.MethodGet, headers: map[string]string{"If-Modified-Since": "yesterday"}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestCtx(t, tt.method, tt.headers)
+
+			if got := checkPreconditions(ctx, testEtag, modTime); got != tt.want {
+				t.Errorf("checkPreconditions = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIfRangeMatches(t *testing.T) {
+	modTime := time.Unix(1760000000, 0).UTC()
+
+	tests := []struct {
+		name    string
+		ifRange string
+		etag    string
+		want    bool
+	}{
+		{name: "If-Rangeなし", ifRange: "", etag: testEtag, want: true},
+		{name: "ETagが一致", ifRange: testEtag, etag: testEtag, want: true},
+		{name: "ETagが一致しない", ifRange: `"other"`, etag: testEtag, want: false},
+		{name: "弱いETagは一致しない", ifRange: `W/"abc"`, etag: testEtag, want: false},
+		{name: "ETagが無いファイル", ifRange: testEtag, etag: "", want: false},
+		{name: "更新日時が一致", ifRange: modTime.Format(http.TimeFormat), etag: testEtag, want: true},
+		{name: "更新日時が一致しない", ifRange: modTime.Add(time.Second).Format(http.TimeFormat), etag: testEtag, want: false},
+		{name: "不正な値", ifRange: "yesterday", etag: testEtag, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ifRangeMatches(tt.ifRange, tt.etag, modTime); got != tt.want {
+				t.Errorf("ifRangeMatches(%q) = %v, want %v", tt.ifRange, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		size    int64
+		want    []byteRange
+		wantErr error
+	}{
+		{name: "範囲", header: "bytes=0-4", size: 10, want: []byteRange{{start: 0, length: 5}}},
+		{name: "終わりの省略", header: "bytes=5-", size: 10, want: []byteRange{{start: 5, length: 5}}},
+		{name: "末尾から", header: "bytes=-3", size: 10, want: []byteRange{{start: 7, length: 3}}},
+		{name: "大きさを超える末尾から", header: "bytes=-20", size: 10, want: []byteRange{{start: 0, length: 10}}},
+		{name: "終わりが大きさを超える", header: "bytes=8-20", size: 10, want: []byteRange{{start: 8, length: 2}}},
+		{name: "複数の範囲", header: "bytes=0-1, 4-5", size: 10, want: []byteRange{{start: 0, length: 2}, {start: 4, length: 2}}},
+		{name: "重なる範囲", header: "bytes=0-5,3-8", size: 10, want: []byteRange{{start: 0, length: 6}, {start: 3, length: 6}}},
+		{name: "満たせない範囲は除く", header: "bytes=10-,0-0", size: 10, want: []byteRange{{start: 0, length: 1}}},
+		{name: "末尾から0バイト", header: "bytes=-0", size: 10, wantErr: errRangeUnsatisfiable},
+		{name: "大きさ以降から", header: "bytes=10-", size: 10, wantErr: errRangeUnsatisfiable},
+		{name: "空のファイル", header: "bytes=0-", size: 0, wantErr: errRangeUnsatisfiable},
+		{name: "始まりが終わりより後", header: "bytes=5-2", size: 10, wantErr: errInvalidRange},
+		{name: "負の末尾から", header: "bytes=--1", size: 10, wantErr: errInvalidRange},
+		{name: "数値ではない", header: "bytes=a-b", size: 10, wantErr: errInvalidRange},
+		{name: "ハイフンが無い", header: "bytes=0", size: 10, wantErr: errInvalidRange},
+		{name: "bytes以外の単位", header: "items=0-1", size: 10, wantErr: errInvalidRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRange(tt.header, tt.size)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("parseRange(%q) error = %v, want %v", tt.header, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRange(%q) returned an error: %v", tt.header, err)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseRange(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendFile(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat file: %v", err)
+	}
+	modTime := stat.ModTime().UTC().Truncate(time.Second)
+
+	// i番目のバイトを1つずつ指定する範囲
+	singleBytes := func(n int) string {
+		specs := make([]string, n)
+		for i := range specs {
+			specs[i] = fmt.Sprintf("%d-%d", i, i)
+		}
+		return "bytes=" + strings.Join(specs, ",")
+	}
+
+	tests := []struct {
+		name             string
+		method           string
+		headers          map[string]string
+		wantStatus       int
+		wantBody         string
+		wantContentRange string
+		// multipart/byteranges の場合に含まれる部分
+		wantPart string
+		// 本文を返さない場合は-1
+		wantOffset int64
+	}{
+		{name: "全体", wantStatus: fiber.StatusOK, wantBody: content},
+		{name: "単一の範囲", headers: map[string]string{"Range": "bytes=2-4"}, wantStatus: fiber.StatusPartialContent, wantBody: "234", wantContentRange: "bytes 2-4/100", wantOffset: 2},
+		{name: "末尾から", headers: map[string]string{"Range": "bytes=-3"}, wantStatus: fiber.StatusPartialContent, wantBody: "789", wantContentRange: "bytes 97-99/100", wantOffset: 97},
+		{name: "大きさを超える末尾から", headers: map[string]string{"Range": "bytes=-200"}, wantStatus: fiber.StatusPartialContent, wantBody: content, wantContentRange: "bytes 0-99/100"},
+		{name: "複数の範囲", headers: map[string]string{"Range": "bytes=12-13,0-1"}, wantStatus: fiber.StatusPartialContent, wantPart: "Content-Range: bytes 12-13/100\r\n\r\n23\r\n"},
+		{name: "上限の数の範囲", headers: map[string]string{"Range": singleBytes(maxRanges)}, wantStatus: fiber.StatusPartialContent, wantPart: "Content-Range: bytes 31-31/100\r\n\r\n1\r\n"},
+		{name: "上限を超える数の範囲は全体を返す", headers: map[string]string{"Range": singleBytes(maxRanges + 1)}, wantStatus: fiber.StatusOK, wantBody: content},
+		{name: "大きさを超えて重なる範囲は全体を返す", headers: map[string]string{"Range": "bytes=0-79,20-99,10-50"}, wantStatus: fiber.StatusOK, wantBody: content},
+		{name: "不正な範囲は全体を返す", headers: map[string]string{"Range": "bytes=5-2"}, wantStatus: fiber.StatusOK, wantBody: content},
+		{name: "末尾から0バイト", headers: map[string]string{"Range": "bytes=-0"}, wantStatus: fiber.StatusRequestedRangeNotSatisfiable, wantContentRange: "bytes */100", wantOffset: -1},
+		{name: "大きさ以降から", headers: map[string]string{"Range": "bytes=100-"}, wantStatus: fiber.StatusRequestedRangeNotSatisfiable, wantContentRange: "bytes */100", wantOffset: -1},
+		{name: "If-RangeのETagが一致", headers: map[string]string{"Range": "bytes=50-51", "If-Range": testEtag}, wantStatus: fiber.StatusPartialContent, wantBody: "01", wantContentRange: "bytes 50-51/100", wantOffset: 50},
+		{name: "If-RangeのETagが一致しない", headers: map[string]string{"Range": "bytes=50-51", "If-Range": `"other"`}, wantStatus: fiber.StatusOK, wantBody: content},
+		{name: "If-Rangeの日時が一致", headers: map[string]string{"Range": "bytes=50-51", "If-Range": modTime.Format(http.TimeFormat)}, wantStatus: fiber.StatusPartialContent, wantBody: "01", wantContentRange: "bytes 50-51/100", wantOffset: 50},
+		{name: "If-Rangeの日時が一致しない", headers: map[string]string{"Range": "bytes=50-51", "If-Range": modTime.Add(-time.Hour).Format(http.TimeFormat)}, wantStatus: fiber.StatusOK, wantBody: content},
+		{name: "If-None-Matchが一致", headers: map[string]string{"If-None-Match": testEtag, "Range": "bytes=0-1"}, wantStatus: fiber.StatusNotModified, wantOffset: -1},
+		{name: "If-Matchが一致しない", headers: map[string]string{"If-Match": `"other"`}, wantStatus: fiber.StatusPreconditionFailed, wantOffset: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = fiber.MethodGet
+			}
+			ctx := newTestCtx(t, method, tt.headers)
+
+			offset := int64(-1)
+			err := SendFile(ctx, SendFileOptions{
+				Path:        path,
+				Filename:    "file.txt",
+				MimeType:    "text/plain",
+				ContentHash: "abc",
+				BeforeBody: func(o int64) error {
+					offset = o
+					return nil
+				},
+			})
+			if err != nil {
+				t.Fatalf("SendFile returned an error: %v", err)
+			}
+
+			res := ctx.Response()
+			if res.StatusCode() != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", res.StatusCode(), tt.wantStatus)
+			}
+			if offset != tt.wantOffset {
+				t.Errorf("BeforeBody offset = %d, want %d", offset, tt.wantOffset)
+			}
+			if got := string(res.Header.Peek(fiber.HeaderContentRange)); got != tt.wantContentRange {
+				t.Errorf("Content-Range = %q, want %q", got, tt.wantContentRange)
+			}
+
+			body := string(res.Body())
+			if tt.wantPart != "" {
+				if contentType := string(res.Header.ContentType()); !strings.HasPrefix(contentType, "multipart/byteranges; boundary=") {
+					t.Errorf("Content-Type = %q, want multipart/byteranges", contentType)
+				}
+				if !strings.Contains(body, tt.wantPart) {
+					t.Errorf("body does not contain %q: %q", tt.wantPart, body)
+				}
+				return
+			}
+			if body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSendFileBeforeBodyError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte("0123456789"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	limitErr := errors.New("limit exceeded")
+	ctx := newTestCtx(t, fiber.MethodGet, nil)
+
+	err := SendFile(ctx, SendFileOptions{
+		Path:       path,
+		BeforeBody: func(int64) error { return limitErr },
+	})
+	if !errors.Is(err, limitErr) {
+		t.Fatalf("SendFile error = %v, want %v", err, limitErr)
+	}
+	if len(ctx.Response().Body()) != 0 {
+		t.Errorf("sent the body after BeforeBody failed")
+	}
+}
@@ -0,0 +1,48 @@
+package response
+
+import (
+	"time"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/share"
+)
+
+// 共有リンクの閲覧者に返すファイルの情報。所有者のURLやEXIFなどは含めない
+type SharedFileResponse struct {
+	ID        string     `json:"id"`
+	Kind      string     `json:"kind"`
+	Name      string     `json:"name"`
+	TakenAt   *time.Time `json:"taken_at"`
+	Width     *int       `json:"width"`
+	Height    *int       `json:"height"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func NewSharedFileResponse(f file.File) SharedFileResponse {
+	return SharedFileResponse{
+		ID:        f.ID,
+		Kind:      f.Kind,
+		Name:      f.Name,
+		TakenAt:   f.TakenAt,
+		Width:     f.Width,
+		Height:    f.Height,
+		CreatedAt: f.CreatedAt,
+		UpdatedAt: f.UpdatedAt,
+	}
+}
+
+type GetSharedDirectoryResponse struct {
+	Permission       share.Permission     `json:"permission"`
+	ExpiresAt        time.Time            `json:"expires_at"`
+	Directory        SharedFileResponse   `json:"directory"`
+	Files            []SharedFileResponse `json:"files"`
+	PageSize         int                  `json:"page_size"`
+	CurrentPageCount int                  `json:"current_page_count"`
+	Total            int                  `json:"total"`
+}
+
+type UnlockShareResponse struct {
+	Query     string    `json:"query"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,13 @@
+package response
+
+import "github.com/YahiroRyo/yappi_storage/backend/domain/user"
+
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool `json:"two_factor_required"`
+	user.TwoFactorChallenge
+}
+
+// リカバリーコードの平文は発行時のレスポンスでのみ返す
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
@@ -0,0 +1,9 @@
+package response
+
+import "github.com/YahiroRyo/yappi_storage/backend/domain/user"
+
+// 招待コードの平文は作成時のレスポンスでのみ返す
+type CreateUserInviteResponse struct {
+	user.Invite
+	Code string `json:"code"`
+}
@@ -1,35 +1,57 @@
 package session
 
 import (
-	"time"
+	"os"
+	"strconv"
+	"strings"
 
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/session"
 	"github.com/gofiber/fiber/v2/utils"
-	"github.com/gofiber/storage/sqlite3"
 )
 
-var storage *sqlite3.Storage
 var store *session.Store
 
-func init() {
-	storage = sqlite3.New(sqlite3.ConfigDefault)
-	if storage == nil {
-		panic("failed create sqlite3 instance")
-	}
-
+// Setup creates the session store on the given storage. Cookie attributes are read from the environment:
+// SESSION_COOKIE_DOMAIN, SESSION_COOKIE_SECURE (defaults to true when BASE_URL is https) and SESSION_COOKIE_SAMESITE (defaults to Lax).
+func Setup(storage fiber.Storage) {
 	store = session.New(session.Config{
-		Storage:      storage,
-		Expiration:   24 * time.Hour,
-		KeyLookup:    "cookie:session_id",
-		KeyGenerator: utils.UUIDv4,
-		CookieName:   "session_id",
+		Storage: storage,
+		// 端末ごとの有効期限はuser_sessionsで管理するため、Cookieはログインからの上限まで保持する
+		Expiration:     user.SessionAbsoluteTimeout,
+		KeyLookup:      "cookie:session_id",
+		KeyGenerator:   utils.UUIDv4,
+		CookieDomain:   os.Getenv("SESSION_COOKIE_DOMAIN"),
+		CookiePath:     "/",
+		CookieSecure:   cookieSecure(),
+		CookieHTTPOnly: true,
+		CookieSameSite: cookieSameSite(),
 	})
 	if store == nil {
 		panic("failed create store instance")
 	}
 }
 
+func cookieSecure() bool {
+	if secure, err := strconv.ParseBool(os.Getenv("SESSION_COOKIE_SECURE")); err == nil {
+		return secure
+	}
+
+	return strings.HasPrefix(os.Getenv("BASE_URL"), "https://")
+}
+
+func cookieSameSite() string {
+	switch strings.ToLower(os.Getenv("SESSION_COOKIE_SAMESITE")) {
+	case "strict":
+		return fiber.CookieSameSiteStrictMode
+	case "none":
+		return fiber.CookieSameSiteNoneMode
+	default:
+		return fiber.CookieSameSiteLaxMode
+	}
+}
+
 func GetSession(ctx *fiber.Ctx) (*session.Session, error) {
 	sess, err := store.Get(ctx)
 
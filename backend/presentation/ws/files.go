@@ -3,10 +3,14 @@ package ws
 import (
 	"fmt"
 	"log"
-	"os"
+	"sync"
 	"time"
 
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
 	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/service"
 )
 
 type UploadFileChunkData struct {
@@ -21,12 +25,37 @@ type UploadSession struct {
 	TotalSize int64
 	IsActive  bool
 	StartTime time.Time
+
+	UserID string
+	// initialize_file_nameで申告された大きさ。これを超えるチャンクは受け付けない
+	DeclaredSize int64
 }
 
-var uploadSessions = make(map[string]*UploadSession)
+// 全ての接続で共有するため、セッションとその内容はuploadSessionsMuを取得して読み書きする
+var (
+	uploadSessions   = make(map[string]*UploadSession)
+	uploadSessionsMu sync.Mutex
+)
 
-func (wsc *WsController) initializeFileName(filename string) EventEnvelopeResponse {
-	log.Printf("Initializing file upload for: %s", filename)
+func (wsc *WsController) initializeFileName(loggedInUser user.User, filename string, size int64) EventEnvelopeResponse {
+	log.Printf("Initializing file upload for: %s (%d bytes)", filename, size)
+
+	// 容量を超える場合は書き込む前に断る
+	if err := wsc.QuotaService.Check(loggedInUser, size); err != nil {
+		var quotaExceededError service.QuotaExceededError
+		if errors.As(err, &quotaExceededError) {
+			return EventEnvelopeResponse{
+				Event: EventEnvelopeEventInitializeFileName,
+				Data:  map[string]string{"status": "error", "message": "quota_exceeded"},
+			}
+		}
+
+		log.Printf("Error checking quota: %+v", err)
+		return EventEnvelopeResponse{
+			Event: EventEnvelopeEventInitializeFileName,
+			Data:  map[string]string{"status": "error", "message": "failed_to_check_quota"},
+		}
+	}
 
 	// ファイルIDを生成
 	fileID, err := helper.GenerateSnowflake()
@@ -40,13 +69,17 @@ func (wsc *WsController) initializeFileName(filename string) EventEnvelopeRespon
 
 	// 一意のセッションIDを生成
 	sessionID := fmt.Sprintf("%s_%d", filename, time.Now().UnixNano())
+	uploadSessionsMu.Lock()
+	defer uploadSessionsMu.Unlock()
 	uploadSessions[sessionID] = &UploadSession{
-		FileName:  filename,
-		FileID:    *fileID,
-		Chunks:    make([][]byte, 0),
-		TotalSize: 0,
-		IsActive:  true,
-		StartTime: time.Now(),
+		UserID:       loggedInUser.ID,
+		DeclaredSize: size,
+		FileName:     filename,
+		FileID:       *fileID,
+		Chunks:       make([][]byte, 0),
+		TotalSize:    0,
+		IsActive:     true,
+		StartTime:    time.Now(),
 	}
 
 	return EventEnvelopeResponse{
@@ -55,7 +88,7 @@ func (wsc *WsController) initializeFileName(filename string) EventEnvelopeRespon
 	}
 }
 
-func (wsc *WsController) uploadFileChunk(data UploadFileChunkData) EventEnvelopeResponse {
+func (wsc *WsController) uploadFileChunk(loggedInUser user.User, data UploadFileChunkData) EventEnvelopeResponse {
 	log.Printf("Received file chunk with checksum: %d, size: %d bytes", data.Checksum, len(data.Chunk))
 
 	// CheckSumの検証
@@ -74,11 +107,14 @@ func (wsc *WsController) uploadFileChunk(data UploadFileChunkData) EventEnvelope
 
 	log.Printf("Checksum verification successful for chunk")
 
+	uploadSessionsMu.Lock()
+	defer uploadSessionsMu.Unlock()
+
 	// 現在のセッションを見つける（簡単な実装：最後にアクティブなセッション）
 	var currentSession *UploadSession
 	var currentSessionID string
 	for sessionID, session := range uploadSessions {
-		if session.IsActive {
+		if session.IsActive && session.UserID == loggedInUser.ID {
 			currentSession = session
 			currentSessionID = sessionID
 			break
@@ -93,6 +129,14 @@ func (wsc *WsController) uploadFileChunk(data UploadFileChunkData) EventEnvelope
 		}
 	}
 
+	if currentSession.TotalSize+int64(len(data.Chunk)) > currentSession.DeclaredSize {
+		log.Printf("Chunk exceeds declared size of session %s", currentSessionID)
+		return EventEnvelopeResponse{
+			Event: EventEnvelopeEventUploadFileChunk,
+			Data:  map[string]string{"status": "error", "message": "exceeds_declared_size"},
+		}
+	}
+
 	// チャンクをセッションに追加
 	currentSession.Chunks = append(currentSession.Chunks, data.Chunk)
 	currentSession.TotalSize += int64(len(data.Chunk))
@@ -110,17 +154,23 @@ func (wsc *WsController) uploadFileChunk(data UploadFileChunkData) EventEnvelope
 	}
 }
 
-func (wsc *WsController) finishedUpload(sessionID string) EventEnvelopeResponse {
+func (wsc *WsController) finishedUpload(loggedInUser user.User, sessionID string) EventEnvelopeResponse {
 	log.Printf("Finishing upload for session: %s", sessionID)
 
+	// 他のユーザーのセッションは存在しないものとして扱う。同時に完了させないよう、保存する間はセッションを取り除いておく
+	uploadSessionsMu.Lock()
 	session, exists := uploadSessions[sessionID]
-	if !exists {
+	if !exists || session.UserID != loggedInUser.ID {
+		uploadSessionsMu.Unlock()
 		log.Printf("Session not found: %s", sessionID)
 		return EventEnvelopeResponse{
 			Event: EventEnvelopeEventFinishedUpload,
 			Data:  map[string]string{"status": "error", "message": "session_not_found"},
 		}
 	}
+	session.IsActive = false
+	delete(uploadSessions, sessionID)
+	uploadSessionsMu.Unlock()
 
 	// 全チャンクを結合
 	var completeFile []byte
@@ -132,65 +182,41 @@ func (wsc *WsController) finishedUpload(sessionID string) EventEnvelopeResponse
 		session.FileName, len(completeFile), len(session.Chunks))
 
 	// ファイルを保存（ファイルID + 拡張子のファイル名で保存）
-	uploadResult, err := wsc.UploadFileChunkService.Execute(completeFile, session.FileID, session.FileName)
+	uploadResult, err := wsc.UploadFileChunkService.Execute(loggedInUser, completeFile, session.FileID, session.FileName)
 	if err != nil {
+		// 他のアップロードで容量が埋まった場合は、再試行しても保存できない
+		var quotaExceededError service.QuotaExceededError
+		if errors.As(err, &quotaExceededError) {
+			return EventEnvelopeResponse{
+				Event: EventEnvelopeEventFinishedUpload,
+				Data:  map[string]string{"status": "error", "message": "quota_exceeded"},
+			}
+		}
+
 		log.Printf("Error saving complete file: %v", err)
+
+		// 完了を再試行できるよう戻す
+		uploadSessionsMu.Lock()
+		session.IsActive = true
+		uploadSessions[sessionID] = session
+		uploadSessionsMu.Unlock()
+
 		return EventEnvelopeResponse{
 			Event: EventEnvelopeEventFinishedUpload,
 			Data:  map[string]string{"status": "error", "message": "save_failed"},
 		}
 	}
 
-	// セッションをクリーンアップ
-	session.IsActive = false
-	delete(uploadSessions, sessionID)
-
 	log.Printf("Upload completed successfully for file: %s, saved at: %s (local: %s)",
 		session.FileName, uploadResult.URL, uploadResult.LocalPath)
 
-	// 動画ファイルの場合は非同期で圧縮処理を開始
-	if wsc.VideoCompressionService.IsVideoFile(session.FileName) {
-		log.Printf("Video file detected: %s, starting compression", session.FileName)
-
-		// 圧縮後のファイル名を生成（ファイルID + _compressed + 拡張子）
-		compressedFilename := session.FileID + "_compressed.mp4"
-
-		// アップロード結果から実際のローカルパスを使用
-		inputPath := uploadResult.LocalPath
-
-		// 圧縮後のファイル用のストレージパスを取得
-		storagePath, err := wsc.GetStoreStoragePathService.Execute()
-		if err != nil {
-			log.Printf("Error getting storage path: %v", err)
-		} else {
-			outputPath := fmt.Sprintf("storage/files/%s/%s", storagePath, compressedFilename)
-
-			// 非同期で圧縮処理を実行
-			go func() {
-				log.Printf("Starting background video compression: %s -> %s", inputPath, outputPath)
-
-				err := wsc.VideoCompressionService.CompressVideo(inputPath, outputPath)
-				if err != nil {
-					log.Printf("Video compression failed: %v", err)
-				} else {
-					log.Printf("Video compression completed successfully: %s", outputPath)
-
-					// 圧縮に成功した場合、元ファイルを削除
-					if err := os.Remove(inputPath); err != nil {
-						log.Printf("Warning: Failed to delete original file %s: %v", inputPath, err)
-					} else {
-						log.Printf("Original video file deleted: %s", inputPath)
-					}
-				}
-			}()
-		}
-	}
-
+	// POST /files で upload_id を指定して登録する。派生ファイルの生成と動画の変換のジョブは登録時に作成する
 	return EventEnvelopeResponse{
 		Event: EventEnvelopeEventFinishedUpload,
 		Data: map[string]interface{}{
 			"status":     "completed",
 			"filename":   session.FileName,
+			"upload_id":  session.FileID,
 			"file_path":  uploadResult.URL,
 			"total_size": session.TotalSize,
 		},
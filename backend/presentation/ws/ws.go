@@ -5,16 +5,17 @@ import (
 	"encoding/json"
 	"log"
 
+	"github.com/YahiroRyo/yappi_storage/backend/domain/auth"
+	"github.com/YahiroRyo/yappi_storage/backend/presentation/middleware"
 	"github.com/YahiroRyo/yappi_storage/backend/service"
 	"github.com/gofiber/contrib/websocket"
 )
 
 type WsController struct {
 	UploadFileChunkService     service.UploadFileChunkService
-	GetLoggedInUserService     service.GetLoggedInUserService
 	GetStorageSettingService   service.GetStorageSettingService
 	GetStoreStoragePathService service.GetStoreStoragePathService
-	VideoCompressionService    service.VideoCompressionService
+	QuotaService               service.QuotaService
 }
 
 type EventEnvelopeEvent string
@@ -32,15 +33,23 @@ const (
 	EventEnvelopeEventInitializeFileName EventEnvelopeEvent = "initialize_file_name"
 	EventEnvelopeEventUploadFileChunk    EventEnvelopeEvent = "upload_file_chunk"
 	EventEnvelopeEventFinishedUpload     EventEnvelopeEvent = "finished_upload"
+	EventEnvelopeEventQuotaWarning       EventEnvelopeEvent = "quota_warning"
 )
 
 func (wsc *WsController) Ws(c *websocket.Conn) {
 	log.Printf("WebSocket connection established from %s", c.RemoteAddr())
 
+	// 認証ミドルウェアで設定されたユーザー
+	loggedInUser := c.Locals(middleware.PrincipalLocalsKey).(auth.Principal).User
+
 	// チャネルをバッファ付きにして、ブロッキングを防ぐ
 	broadcast := make(chan EventEnvelopeResponse, 100)
 	done := make(chan bool, 2) // 2つのgoroutineの終了を待つ
 
+	// 使用量が警告の割合を超えたときに知らせる
+	warnings, unsubscribe := wsc.QuotaService.QuotaWarningBroker.Subscribe(loggedInUser.ID)
+	defer unsubscribe()
+
 	// メッセージ読み取りgoroutine
 	go func() {
 		defer func() {
@@ -86,7 +95,15 @@ func (wsc *WsController) Ws(c *websocket.Conn) {
 					if dataMap, ok := eventEnvelope.Data.(map[string]interface{}); ok {
 						if filename, exists := dataMap["filename"]; exists {
 							if filenameStr, isString := filename.(string); isString {
-								response = wsc.initializeFileName(filenameStr)
+								// 容量を確認するため、アップロードする大きさを申告する
+								if size, isNumber := dataMap["size"].(float64); isNumber && size >= 0 {
+									response = wsc.initializeFileName(loggedInUser, filenameStr, int64(size))
+								} else {
+									response = EventEnvelopeResponse{
+										Event: EventEnvelopeEventInitializeFileName,
+										Data:  map[string]string{"status": "error", "message": "invalid_size"},
+									}
+								}
 							} else {
 								response = EventEnvelopeResponse{
 									Event: EventEnvelopeEventInitializeFileName,
@@ -107,7 +124,7 @@ func (wsc *WsController) Ws(c *websocket.Conn) {
 					}
 				case EventEnvelopeEventFinishedUpload:
 					if sessionID, ok := eventEnvelope.Data.(string); ok {
-						response = wsc.finishedUpload(sessionID)
+						response = wsc.finishedUpload(loggedInUser, sessionID)
 					} else {
 						response = EventEnvelopeResponse{
 							Event: EventEnvelopeEventFinishedUpload,
@@ -155,7 +172,7 @@ func (wsc *WsController) Ws(c *websocket.Conn) {
 					Chunk:    chunk,
 				}
 
-				response := wsc.uploadFileChunk(uploadData)
+				response := wsc.uploadFileChunk(loggedInUser, uploadData)
 				select {
 				case broadcast <- response:
 				default:
@@ -189,6 +206,13 @@ func (wsc *WsController) Ws(c *websocket.Conn) {
 					return
 				}
 
+			case warning := <-warnings:
+				select {
+				case broadcast <- EventEnvelopeResponse{Event: EventEnvelopeEventQuotaWarning, Data: warning}:
+				default:
+					log.Printf("Broadcast channel full, skipping quota warning")
+				}
+
 			case <-done:
 				// 読み取りgoroutineが終了した場合、こちらも終了
 				return
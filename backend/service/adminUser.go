@@ -0,0 +1,23 @@
+package service
+
+import (
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+// 管理者が操作するユーザーを取得する。自分自身を無効・削除などして、管理者がいなくなることを防ぐ
+func adminTargetUser(conn *sqlx.DB, userRepo repository.UserRepositoryInterface, admin user.User, id string) (*user.User, error) {
+	if admin.ID == id {
+		return nil, errors.WithStack(AdminOperationError{Code: 400, Message: "自分自身は操作できません。"})
+	}
+
+	target, err := userRepo.GetUserByID(conn, id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return target, nil
+}
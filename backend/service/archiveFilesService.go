@@ -0,0 +1,454 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/job"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+const (
+	// ジョブで作成したアーカイブの保存先
+	archiveDir = "storage/archives"
+	// ジョブで作成したアーカイブを保持する期間
+	archiveRetention = 24 * time.Hour
+)
+
+// アーカイブに含める1件。ディレクトリ・自ストレージに実体が無いファイルはLocalPathが空
+type ArchiveEntry struct {
+	Path      string
+	File      file.File
+	LocalPath string
+}
+
+type Archive struct {
+	Name    string
+	Entries []ArchiveEntry
+}
+
+func (archive Archive) FileCount() int {
+	count := 0
+	for _, entry := range archive.Entries {
+		if entry.LocalPath != "" {
+			count++
+		}
+	}
+
+	return count
+}
+
+type ArchiveFilesService struct {
+	Conn                     *sqlx.DB
+	UserRepo                 repository.UserRepositoryInterface
+	FileRepo                 repository.FileRepositoryInterface
+	GetServedOriginalService GetServedOriginalService
+}
+
+// Collect resolves the selected files and directories into archive entries with unique relative paths.
+// Files selected together with one of their ancestors are only included once.
+func (service *ArchiveFilesService) Collect(user user.User, fileIDs []string) (*Archive, error) {
+	names := archiveNames{}
+	included := map[string]bool{}
+	nested := map[string]bool{}
+	entries := []ArchiveEntry{}
+	roots := []file.File{}
+
+	for _, id := range fileIDs {
+		root, err := service.FileRepo.GetFileByID(service.Conn, user, id)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if root.ID == "" {
+			return nil, errors.WithStack(repository.NotFoundError{Code: 404, Message: "ファイルが見つかりません。"})
+		}
+		roots = append(roots, *root)
+	}
+
+	// 祖先のディレクトリも選択されているファイルは、ディレクトリ側で含める
+	for _, root := range roots {
+		if file.FileKindFromEnString(root.Kind) != file.Directory {
+			continue
+		}
+
+		descendants, err := service.FileRepo.GetDescendantFiles(service.Conn, user, root.ID)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, descendant := range descendants {
+			if descendant.ID != root.ID && slices.ContainsFunc(roots, func(f file.File) bool { return f.ID == descendant.ID }) {
+				nested[descendant.ID] = true
+			}
+		}
+	}
+
+	for _, root := range roots {
+		if nested[root.ID] || included[root.ID] {
+			continue
+		}
+
+		collected, err := service.collect(user, root, "", names, included)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		entries = append(entries, collected...)
+	}
+
+	name := fmt.Sprintf("archive_%s", time.Now().Format("20060102_150405"))
+	if len(roots) == 1 {
+		name = sanitizeArchiveName(roots[0].Name)
+	}
+
+	return &Archive{Name: name, Entries: entries}, nil
+}
+
+func (service *ArchiveFilesService) collect(user user.User, root file.File, dir string, names archiveNames, included map[string]bool) ([]ArchiveEntry, error) {
+	included[root.ID] = true
+	entryPath := names.allocate(dir, root.Name)
+
+	if file.FileKindFromEnString(root.Kind) != file.Directory {
+		entry := ArchiveEntry{Path: entryPath, File: root}
+
+		// 外部URLのみを登録したファイルは含めない
+		localPath, err := service.FileRepo.GetLocalPath(root)
+		if err != nil {
+			return nil, nil
+		}
+
+		servedPath, err := service.GetServedOriginalService.Execute(user, localPath)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		entry.LocalPath = servedPath
+
+		return []ArchiveEntry{entry}, nil
+	}
+
+	entries := []ArchiveEntry{{Path: entryPath, File: root}}
+
+	descendants, err := service.FileRepo.GetDescendantFiles(service.Conn, user, root.ID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	children := map[string][]file.File{}
+	for _, descendant := range descendants {
+		if descendant.ParentDirectoryID != nil {
+			children[*descendant.ParentDirectoryID] = append(children[*descendant.ParentDirectoryID], descendant)
+		}
+	}
+
+	var walk func(parent file.File, parentPath string) error
+	walk = func(parent file.File, parentPath string) error {
+		siblings := children[parent.ID]
+		slices.SortFunc(siblings, func(a, b file.File) int { return strings.Compare(a.Name, b.Name) })
+
+		for _, child := range siblings {
+			// 移動の競合などで循環している場合に備える
+			if included[child.ID] {
+				continue
+			}
+
+			if file.FileKindFromEnString(child.Kind) == file.Directory {
+				included[child.ID] = true
+				childPath := names.allocate(parentPath, child.Name)
+				entries = append(entries, ArchiveEntry{Path: childPath, File: child})
+				if err := walk(child, childPath); err != nil {
+					return err
+				}
+				continue
+			}
+
+			collected, err := service.collect(user, child, parentPath, names, included)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, collected...)
+		}
+
+		return nil
+	}
+
+	if err := walk(root, entryPath); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return entries, nil
+}
+
+// Write streams the archive to w. Nothing but the copy buffer is held in memory.
+func (service *ArchiveFilesService) Write(ctx context.Context, w io.Writer, format file.ArchiveFormat, archive Archive) error {
+	if format == file.ArchiveTarGz {
+		return writeTarGz(ctx, w, archive)
+	}
+
+	return writeZip(ctx, w, archive)
+}
+
+func writeZip(ctx context.Context, w io.Writer, archive Archive) error {
+	zw := zip.NewWriter(w)
+
+	for _, entry := range archive.Entries {
+		header := &zip.FileHeader{
+			Name:     entry.Path,
+			Method:   zip.Deflate,
+			Modified: entry.File.UpdatedAt,
+		}
+
+		if entry.LocalPath == "" {
+			if file.FileKindFromEnString(entry.File.Kind) != file.Directory {
+				continue
+			}
+			header.Name += "/"
+			header.Method = zip.Store
+			if _, err := zw.CreateHeader(header); err != nil {
+				return errors.WithStack(err)
+			}
+			continue
+		}
+
+		// 圧縮済みの形式は再圧縮しない
+		switch file.FileKindFromFilename(entry.LocalPath) {
+		case file.Image, file.Video, file.Zip:
+			header.Method = zip.Store
+		}
+
+		entryWriter, err := zw.CreateHeader(header)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if err := copyArchiveEntry(ctx, entryWriter, entry.LocalPath); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return errors.WithStack(zw.Close())
+}
+
+func writeTarGz(ctx context.Context, w io.Writer, archive Archive) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	for _, entry := range archive.Entries {
+		if entry.LocalPath == "" {
+			if file.FileKindFromEnString(entry.File.Kind) != file.Directory {
+				continue
+			}
+			if err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     entry.Path + "/",
+				Mode:     0755,
+				ModTime:  entry.File.UpdatedAt,
+			}); err != nil {
+				return errors.WithStack(err)
+			}
+			continue
+		}
+
+		stat, err := os.Stat(entry.LocalPath)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		// 日本語などのファイル名はPAX形式のヘッダに自動で切り替わる
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     entry.Path,
+			Mode:     0644,
+			Size:     stat.Size(),
+			ModTime:  entry.File.UpdatedAt,
+		}); err != nil {
+			return errors.WithStack(err)
+		}
+
+		if err := copyArchiveEntry(ctx, tw, entry.LocalPath); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(gw.Close())
+}
+
+func copyArchiveEntry(ctx context.Context, w io.Writer, localPath string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := make([]byte, 256*1024)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n, err := f.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Handle creates the archive of a create_archive job under storage/archives
+func (service *ArchiveFilesService) Handle(ctx context.Context, j job.Job) (any, error) {
+	var payload job.ArchivePayload
+	if err := json.Unmarshal(j.Payload, &payload); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	format, ok := file.ArchiveFormatFromString(payload.Format)
+	if !ok {
+		return nil, errors.WithStack(fmt.Errorf("unsupported archive format: %s", payload.Format))
+	}
+
+	owner, err := service.UserRepo.GetUserByID(service.Conn, j.UserID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	removeExpiredArchives()
+
+	archive, err := service.Collect(*owner, payload.FileIDs)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := os.MkdirAll(archiveDir, 0755); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	archivePath := ArchivePath(j.ID, format)
+	tmpPath := archivePath + ".tmp"
+	defer os.Remove(tmpPath)
+
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	bw := bufio.NewWriterSize(out, 1024*1024)
+	if err := service.Write(ctx, bw, format, *archive); err != nil {
+		out.Close()
+		return nil, errors.WithStack(err)
+	}
+	if err := bw.Flush(); err != nil {
+		out.Close()
+		return nil, errors.WithStack(err)
+	}
+	if err := out.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := os.Rename(tmpPath, archivePath); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	stat, err := os.Stat(archivePath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return job.ArchiveResult{
+		Name:      archive.Name + format.Extension(),
+		Format:    string(format),
+		Size:      stat.Size(),
+		FileCount: archive.FileCount(),
+		ExpiresAt: time.Now().Add(archiveRetention),
+	}, nil
+}
+
+func ArchivePath(jobID string, format file.ArchiveFormat) string {
+	return filepath.Join(archiveDir, jobID+format.Extension())
+}
+
+// 保持期間を過ぎたアーカイブを削除する
+func removeExpiredArchives() {
+	dirEntries, err := os.ReadDir(archiveDir)
+	if err != nil {
+		return
+	}
+
+	for _, dirEntry := range dirEntries {
+		info, err := dirEntry.Info()
+		if err != nil || time.Since(info.ModTime()) < archiveRetention {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(archiveDir, dirEntry.Name())); err != nil {
+			log.Printf("Warning: Failed to delete expired archive %s: %v", dirEntry.Name(), err)
+		}
+	}
+}
+
+// アーカイブ内のパスとして使えない文字を置き換える
+func sanitizeArchiveName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == '/' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+
+	return name
+}
+
+// ディレクトリごとに使用済みの名前を管理する。大文字小文字を区別しないファイルシステムでも衝突しないよう小文字で比較する
+type archiveNames map[string]map[string]bool
+
+func (names archiveNames) allocate(dir string, name string) string {
+	used, ok := names[dir]
+	if !ok {
+		used = map[string]bool{}
+		names[dir] = used
+	}
+
+	name = sanitizeArchiveName(name)
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	if stem == "" {
+		stem, ext = name, ""
+	}
+
+	candidate := name
+	for i := 1; used[strings.ToLower(candidate)]; i++ {
+		candidate = fmt.Sprintf("%s (%d)%s", stem, i, ext)
+	}
+	used[strings.ToLower(candidate)] = true
+
+	return path.Join(dir, candidate)
+}
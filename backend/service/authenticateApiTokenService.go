@@ -0,0 +1,57 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/apitoken"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+// 最終使用日時を記録する間隔。リクエストごとに更新しないようにする
+const apiTokenLastUsedInterval = time.Minute
+
+type AuthenticateApiTokenService struct {
+	Conn         *sqlx.DB
+	UserRepo     repository.UserRepositoryInterface
+	ApiTokenRepo repository.ApiTokenRepositoryInterface
+}
+
+// Execute returns the owner of the token. Unknown and expired tokens are reported as not found.
+func (service *AuthenticateApiTokenService) Execute(plainToken string) (*user.User, *apitoken.ApiToken, error) {
+	if plainToken == "" {
+		return nil, nil, errors.WithStack(repository.NotFoundError{Code: 404, Message: "トークンが見つかりません。"})
+	}
+
+	t, err := service.ApiTokenRepo.GetApiTokenByHash(service.Conn, apitoken.Hash(plainToken))
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	now := time.Now()
+	if t.IsExpired(now) {
+		return nil, nil, errors.WithStack(repository.NotFoundError{Code: 404, Message: "トークンが見つかりません。"})
+	}
+
+	owner, err := service.UserRepo.GetUserByID(service.Conn, t.UserID)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	// 無効にしたユーザーのトークンは、有効に戻すと再び使える
+	if owner.IsDisabled() {
+		return nil, nil, errors.WithStack(repository.NotFoundError{Code: 404, Message: "トークンが見つかりません。"})
+	}
+
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) >= apiTokenLastUsedInterval {
+		if err := service.ApiTokenRepo.UpdateApiTokenLastUsedAt(service.Conn, t.ID, now); err != nil {
+			log.Printf("Warning: Failed to update last used time of api token %s: %v", t.ID, err)
+		}
+		t.LastUsedAt = &now
+	}
+
+	return owner, t, nil
+}
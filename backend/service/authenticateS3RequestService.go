@@ -0,0 +1,102 @@
+package service
+
+import (
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper/sigv4"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+// 検証済みの署名。チャンクごとの署名の検証に使う
+type S3Auth struct {
+	Signature  sigv4.Signature
+	SigningKey []byte
+	// x-amz-content-sha256 の値。署名付きURLの場合はUNSIGNED-PAYLOAD
+	PayloadHash string
+}
+
+type AuthenticateS3RequestService struct {
+	Conn     *sqlx.DB
+	UserRepo repository.UserRepositoryInterface
+	S3Repo   repository.S3RepositoryInterface
+}
+
+// Execute verifies the SigV4 signature given in the Authorization header or the query of a presigned URL.
+func (service *AuthenticateS3RequestService) Execute(req sigv4.Request, now time.Time) (*user.User, *S3Auth, error) {
+	query, err := url.ParseQuery(req.RawQuery)
+	if err != nil {
+		return nil, nil, S3Error{Status: 400, Code: "InvalidArgument", Message: "Invalid query string."}
+	}
+
+	var signature *sigv4.Signature
+	authorization := req.Header("authorization")
+	switch {
+	case authorization != "":
+		signature, err = sigv4.ParseAuthorization(authorization, req.Header("x-amz-date"))
+		if err != nil {
+			return nil, nil, S3Error{Status: 400, Code: "AuthorizationHeaderMalformed", Message: err.Error()}
+		}
+
+		req.PayloadHash = req.Header("x-amz-content-sha256")
+		if req.PayloadHash == "" {
+			return nil, nil, S3Error{Status: 400, Code: "InvalidRequest", Message: "Missing required header for this request: x-amz-content-sha256"}
+		}
+	case query.Has("X-Amz-Signature"):
+		signature, err = sigv4.ParsePresigned(query)
+		if err != nil {
+			return nil, nil, S3Error{Status: 400, Code: "AuthorizationQueryParametersError", Message: err.Error()}
+		}
+
+		req.PayloadHash = sigv4.UnsignedPayload
+	default:
+		return nil, nil, S3Error{Status: 403, Code: "AccessDenied", Message: "Access Denied"}
+	}
+
+	if signature.Credential.Service != "s3" || signature.Credential.Date != signature.Time.UTC().Format(sigv4.DateFormat) {
+		return nil, nil, S3Error{Status: 400, Code: "AuthorizationHeaderMalformed", Message: "The credential scope is invalid."}
+	}
+	if !slices.Contains(signature.SignedHeaders, "host") {
+		return nil, nil, S3Error{Status: 400, Code: "AuthorizationHeaderMalformed", Message: "The host header must be signed."}
+	}
+
+	if signature.Presigned {
+		if now.Before(signature.Time.Add(-sigv4.MaxClockSkew)) {
+			return nil, nil, S3Error{Status: 403, Code: "AccessDenied", Message: "Request is not valid yet"}
+		}
+		if !now.Before(signature.Time.Add(signature.Expires)) {
+			return nil, nil, S3Error{Status: 403, Code: "AccessDenied", Message: "Request has expired"}
+		}
+	} else if now.Sub(signature.Time).Abs() > sigv4.MaxClockSkew {
+		return nil, nil, S3Error{Status: 403, Code: "RequestTimeTooSkewed", Message: "The difference between the request time and the current time is too large."}
+	}
+
+	accessKey, err := service.S3Repo.GetAccessKeyByAccessKeyID(service.Conn, signature.Credential.AccessKeyID)
+	if err != nil {
+		var notFoundErr repository.NotFoundError
+		if errors.As(err, &notFoundErr) {
+			return nil, nil, S3Error{Status: 403, Code: "InvalidAccessKeyId", Message: "The AWS Access Key Id you provided does not exist in our records."}
+		}
+		return nil, nil, errors.WithStack(err)
+	}
+
+	signingKey := sigv4.SigningKey(accessKey.SecretAccessKey, signature.Credential)
+	if !sigv4.Equal(sigv4.Sign(signingKey, *signature, req), signature.Signature) {
+		return nil, nil, S3Error{Status: 403, Code: "SignatureDoesNotMatch", Message: "The request signature we calculated does not match the signature you provided."}
+	}
+
+	u, err := service.UserRepo.GetUserByID(service.Conn, accessKey.UserID)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	if u.IsDisabled() {
+		return nil, nil, S3Error{Status: 403, Code: "AccessDenied", Message: "Access Denied"}
+	}
+
+	return u, &S3Auth{Signature: *signature, SigningKey: signingKey, PayloadHash: req.PayloadHash}, nil
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/apitoken"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+// ディレクトリの制限を確認する際にたどる親の数の上限
+const apiTokenMaxDirectoryDepth = 1024
+
+// AuthorizeApiTokenDirectoryService confines the writes made with a directory restricted token to that directory.
+type AuthorizeApiTokenDirectoryService struct {
+	Conn     *sqlx.DB
+	FileRepo repository.FileRepositoryInterface
+}
+
+// Execute returns the directory to write into with the token.
+// For a token restricted to a directory, nil means that directory and any other directory must be inside it.
+func (service *AuthorizeApiTokenDirectoryService) Execute(user user.User, t *apitoken.ApiToken, parentDirectoryID *string) (*string, error) {
+	if t == nil || !t.IsDirectoryRestricted() {
+		return parentDirectoryID, nil
+	}
+	if parentDirectoryID == nil {
+		return t.DirectoryID, nil
+	}
+
+	directoryID := parentDirectoryID
+	for range apiTokenMaxDirectoryDepth {
+		if directoryID == nil {
+			break
+		}
+		if *directoryID == *t.DirectoryID {
+			return parentDirectoryID, nil
+		}
+
+		f, err := service.FileRepo.GetFileByID(service.Conn, user, *directoryID)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if f.ID == "" || file.FileKindFromEnString(f.Kind) != file.Directory {
+			return nil, errors.WithStack(repository.NotFoundError{Code: 404, Message: "ディレクトリが見つかりません。"})
+		}
+		directoryID = f.ParentDirectoryID
+	}
+
+	return nil, ApiTokenPermissionDeniedError{Code: 403, Message: "このトークンで操作できるディレクトリではありません。"}
+}
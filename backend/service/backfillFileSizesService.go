@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+// 1回のトランザクションで大きさを記録するファイルの数
+const backfillFileSizesBatchSize = 100
+
+// BackfillFileSizesService measures the files registered before sizes were recorded
+// and adds them to the usage of their owners.
+type BackfillFileSizesService struct {
+	Conn     *sqlx.DB
+	FileRepo repository.FileRepositoryInterface
+}
+
+// 起動時に呼ぶ。自ストレージ上にないファイルは0バイトとして記録する
+func (service *BackfillFileSizesService) Execute(ctx context.Context) error {
+	var total int
+	for ctx.Err() == nil {
+		files, err := service.FileRepo.GetFilesWithoutSize(service.Conn, backfillFileSizesBatchSize)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if len(files) == 0 {
+			break
+		}
+
+		tx, err := service.Conn.Beginx()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		for _, f := range files {
+			var size int64
+			if localPath, err := service.FileRepo.GetLocalPath(f); err == nil {
+				if info, err := os.Stat(localPath); err == nil {
+					size = info.Size()
+				}
+			}
+
+			if err := service.FileRepo.UpdateFileSize(tx, f, size); err != nil {
+				tx.Rollback()
+				return errors.WithStack(err)
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return errors.WithStack(err)
+		}
+		total += len(files)
+	}
+
+	if total > 0 {
+		log.Printf("Recorded sizes of %d files", total)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/helper/validate"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+type BootstrapAdminService struct {
+	Conn     *sqlx.DB
+	UserRepo repository.UserRepositoryInterface
+}
+
+// 管理者がいない場合のみ、最初の管理者を作成する。起動のたびに呼ぶ
+func (service *BootstrapAdminService) ExecuteIfNoAdmin(email string, password string) error {
+	exists, err := service.UserRepo.ExistsAdmin(service.Conn)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if exists {
+		return nil
+	}
+
+	return service.Execute(email, password, time.Now())
+}
+
+// 登録済みのメールアドレスの場合は、パスワードを変えずに管理者にする
+func (service *BootstrapAdminService) Execute(email string, password string, now time.Time) error {
+	if err := validate.Email(email, "メールアドレス"); err != nil {
+		return errors.WithStack(err)
+	}
+
+	owner, err := service.UserRepo.GetUserByEmail(service.Conn, email)
+	var notFoundError repository.NotFoundError
+	if err != nil && !errors.As(err, &notFoundError) {
+		return errors.WithStack(err)
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if owner != nil {
+		if err := service.UserRepo.UpdateRole(tx, *owner, user.RoleAdmin); err != nil {
+			tx.Rollback()
+			return errors.WithStack(err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return errors.WithStack(err)
+		}
+
+		log.Printf("Promoted user %s to admin", owner.ID)
+		return nil
+	}
+
+	if err := validate.Password(password, "パスワード"); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	hashedPassword, err := helper.EncryptPassword(password)
+	if err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	// 管理者が指定したメールアドレスのため、確認済みとする
+	userID, err := service.UserRepo.RegistrationWithoutPassword(tx, email, "", user.RoleAdmin, &now)
+	if err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := service.UserRepo.UpdatePassword(tx, user.User{ID: userID}, hashedPassword); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	log.Printf("Created admin user %s", userID)
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/job"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+type CancelJobService struct {
+	Conn    *sqlx.DB
+	JobRepo repository.JobRepositoryInterface
+}
+
+func (service *CancelJobService) Execute(user user.User, id string) (*job.Job, error) {
+	current, err := service.JobRepo.GetJobByID(service.Conn, user, id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if current.Status.IsFinished() {
+		return nil, errors.WithStack(JobAlreadyFinishedError{Code: 400, Message: "ジョブはすでに終了しています。"})
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	cancelled, err := service.JobRepo.CancelJob(tx, user, id)
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return cancelled, nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"github.com/jmoiron/sqlx"
+)
+
+type ChangePasswordService struct {
+	Conn            *sqlx.DB
+	UserRepo        repository.UserRepositoryInterface
+	UserTokenRepo   repository.UserTokenRepositoryInterface
+	UserSessionRepo repository.UserSessionRepositoryInterface
+}
+
+// 現在のパスワードを確認してから変更し、リクエストした端末以外をログアウトさせる
+func (service *ChangePasswordService) Execute(owner user.User, sess *session.Session, currentPassword string, password string) error {
+	if err := helper.CompareHashPassword(owner.Password, currentPassword); err != nil {
+		return errors.WithStack(errors.Join(InvalidPasswordError{Code: 400, Message: "現在のパスワードが違います。"}, err))
+	}
+
+	hashedPassword, err := helper.EncryptPassword(password)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := service.UserRepo.UpdatePassword(tx, owner, hashedPassword); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	// 変更前に発行した再設定のリンクでは変更できないようにする
+	if err := service.UserTokenRepo.DeleteUserTokens(tx, owner, user.TokenPurposePasswordReset); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := service.UserSessionRepo.DeleteOtherUserSessions(tx, owner, loggedInSessionID(sess)); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
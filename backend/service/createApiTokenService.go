@@ -0,0 +1,75 @@
+package service
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/apitoken"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+const (
+	// URLセーフなbase64で43文字になる
+	apiTokenBytes = 32
+	// 一覧で見分けるために残すプレフィックスを除いた先頭の文字数
+	apiTokenHintLength = 4
+)
+
+type CreateApiTokenService struct {
+	Conn         *sqlx.DB
+	FileRepo     repository.FileRepositoryInterface
+	ApiTokenRepo repository.ApiTokenRepositoryInterface
+}
+
+// トークンと、その平文を返す。平文を返すのはこの時だけ
+func (service *CreateApiTokenService) Execute(user user.User, name string, scopes []apitoken.Scope, directoryID *string, expiresAt *time.Time) (*apitoken.ApiToken, string, error) {
+	if err := checkParentDirectory(service.Conn, service.FileRepo, user, directoryID); err != nil {
+		return nil, "", err
+	}
+
+	generatedID, err := helper.GenerateSnowflake()
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+
+	secret, err := helper.GenerateRandomToken(apiTokenBytes)
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+	plainToken := apitoken.Prefix + secret
+
+	now := time.Now()
+	t := apitoken.ApiToken{
+		ID:          *generatedID,
+		UserID:      user.ID,
+		Name:        name,
+		Hint:        plainToken[:len(apitoken.Prefix)+apiTokenHintLength],
+		TokenHash:   apitoken.Hash(plainToken),
+		Scopes:      scopes,
+		DirectoryID: directoryID,
+		ExpiresAt:   expiresAt,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+
+	created, err := service.ApiTokenRepo.RegistrationApiToken(tx, t)
+	if err != nil {
+		tx.Rollback()
+		return nil, "", errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+
+	return created, plainToken, nil
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/s3"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+const (
+	// AWSのアクセスキーIDと同じく英大文字と数字のみにする
+	s3AccessKeyIDPrefix = "YS"
+	s3AccessKeyIDBytes  = 10
+	// URLセーフなbase64で40文字になる
+	s3SecretAccessKeyBytes = 30
+)
+
+type CreateS3AccessKeyService struct {
+	Conn   *sqlx.DB
+	S3Repo repository.S3RepositoryInterface
+}
+
+// 秘密鍵を含むアクセスキーを返す。秘密鍵を返すのはこの時だけ
+func (service *CreateS3AccessKeyService) Execute(user user.User) (*s3.AccessKey, error) {
+	generatedID, err := helper.GenerateSnowflake()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	buf := make([]byte, s3AccessKeyIDBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	accessKeyID := s3AccessKeyIDPrefix + base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf)
+
+	secretAccessKey, err := helper.GenerateRandomToken(s3SecretAccessKeyBytes)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	now := time.Now()
+	key := s3.AccessKey{
+		ID:              *generatedID,
+		UserID:          user.ID,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	created, err := service.S3Repo.RegistrationAccessKey(tx, key)
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return created, nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/share"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+// 共有リンクのトークンのバイト数
+const shareTokenBytes = 32
+
+type CreateShareService struct {
+	Conn      *sqlx.DB
+	FileRepo  repository.FileRepositoryInterface
+	ShareRepo repository.ShareRepositoryInterface
+}
+
+func (service *CreateShareService) Execute(user user.User, fileID string, permission share.Permission, expiresAt time.Time, password *string, maxDownloads *int) (*share.Share, error) {
+	f, err := service.FileRepo.GetFileByID(service.Conn, user, fileID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if f.ID == "" {
+		return nil, errors.WithStack(repository.NotFoundError{Code: 404, Message: "ファイルが見つかりません。"})
+	}
+
+	generatedID, err := helper.GenerateSnowflake()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	token, err := helper.GenerateRandomToken(shareTokenBytes)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var passwordHash *string
+	if password != nil {
+		hash, err := helper.EncryptPassword(*password)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		passwordHash = &hash
+	}
+
+	now := time.Now()
+	s := share.Share{
+		ID:           *generatedID,
+		UserID:       user.ID,
+		FileID:       f.ID,
+		Token:        token,
+		Permission:   permission,
+		PasswordHash: passwordHash,
+		ExpiresAt:    expiresAt,
+		MaxDownloads: maxDownloads,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	created, err := service.ShareRepo.RegistrationShare(tx, s)
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return created, nil
+}
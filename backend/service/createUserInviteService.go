@@ -0,0 +1,45 @@
+package service
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+// 招待コードのバイト数
+const userInviteCodeBytes = 24
+
+type CreateUserInviteService struct {
+	Conn           *sqlx.DB
+	UserInviteRepo repository.UserInviteRepositoryInterface
+}
+
+// 招待を作成し、招待コードを返す。招待コードはハッシュのみを保存するため、ここでしか返せない
+func (service *CreateUserInviteService) Execute(admin user.User, email *string, now time.Time) (*user.Invite, string, error) {
+	code, err := helper.GenerateRandomToken(userInviteCodeBytes)
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+
+	invite, err := service.UserInviteRepo.RegistrationUserInvite(tx, admin, user.HashToken(code), email, now.Add(user.InviteTimeout))
+	if err != nil {
+		tx.Rollback()
+		return nil, "", errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+
+	return invite, code, nil
+}
@@ -0,0 +1,511 @@
+package service
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/net/webdav"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+// WebDAVのロックはユーザーごとにメモリ上で管理する
+var davLockSystems sync.Map
+
+// DavService maps the files hierarchy of a user to a WebDAV file system.
+// Directories are collections and the name of each row is the path segment.
+type DavService struct {
+	Conn                         *sqlx.DB
+	FileRepo                     repository.FileRepositoryInterface
+	RegistrationDirectoryService RegistrationDirectoryService
+	StoreBlobService             StoreBlobService
+}
+
+func (service *DavService) FileSystem(user user.User) webdav.FileSystem {
+	return &davFileSystem{service: service, user: user}
+}
+
+func (service *DavService) LockSystem(user user.User) webdav.LockSystem {
+	lockSystem, _ := davLockSystems.LoadOrStore(user.ID, webdav.NewMemLS())
+
+	return lockSystem.(webdav.LockSystem)
+}
+
+// GetServedFile returns the blob of the file at name. It returns nil for collections.
+// WebDAV clients compare the size with PROPFIND, so the original is served as is.
+func (service *DavService) GetServedFile(user user.User, name string) (*ServedFile, error) {
+	davFS := &davFileSystem{service: service, user: user}
+
+	f, err := davFS.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil || file.FileKindFromEnString(f.Kind) == file.Directory {
+		return nil, nil
+	}
+
+	localPath, err := service.FileRepo.GetLocalPath(*f)
+	if err != nil {
+		return nil, os.ErrNotExist
+	}
+
+	return newServedFile(service.FileRepo, *f, localPath)
+}
+
+// PUTの本文。途中で切断された場合に、書きかけのファイルを登録しないために読み込みの失敗を記録する
+type DavRequestBody struct {
+	r         io.Reader
+	remaining int64
+	err       error
+}
+
+// contentLengthが負の場合は長さを確認しない
+func NewDavRequestBody(r io.Reader, contentLength int64) *DavRequestBody {
+	return &DavRequestBody{r: r, remaining: contentLength}
+}
+
+func (body *DavRequestBody) Read(p []byte) (int, error) {
+	n, err := body.r.Read(p)
+
+	if body.remaining >= 0 {
+		body.remaining -= int64(n)
+		if err == io.EOF && body.remaining > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	if err != nil && err != io.EOF {
+		body.err = err
+	}
+
+	return n, err
+}
+
+func (body *DavRequestBody) Close() error {
+	return nil
+}
+
+type davRequestBodyKey struct{}
+
+func WithDavRequestBody(ctx context.Context, body *DavRequestBody) context.Context {
+	return context.WithValue(ctx, davRequestBodyKey{}, body)
+}
+
+type davFileSystem struct {
+	service *DavService
+	user    user.User
+}
+
+// パスに対応する行を返す。ルートの場合はnilを返す
+func (davFS *davFileSystem) resolve(name string) (*file.File, error) {
+	return resolveFilePath(davFS.service.Conn, davFS.service.FileRepo, davFS.user, name)
+}
+
+// 親ディレクトリのIDと、その中での名前を返す
+func (davFS *davFileSystem) resolveParent(name string) (*string, string, error) {
+	return resolveParentFilePath(davFS.service.Conn, davFS.service.FileRepo, davFS.user, name)
+}
+
+func (davFS *davFileSystem) stat(f *file.File) *davFileInfo {
+	info := &davFileInfo{file: f}
+	if f == nil || file.FileKindFromEnString(f.Kind) == file.Directory {
+		return info
+	}
+
+	// 外部URLのみを登録したファイルは大きさ0として扱う
+	if localPath, err := davFS.service.FileRepo.GetLocalPath(*f); err == nil {
+		if stat, err := os.Stat(localPath); err == nil {
+			info.size = stat.Size()
+		}
+	}
+
+	return info
+}
+
+func (davFS *davFileSystem) deleteCache() {
+	if err := davFS.service.FileRepo.DeleteCache(davFS.user.ID); err != nil {
+		log.Printf("Warning: Failed to delete cache of user %s: %v", davFS.user.ID, err)
+	}
+}
+
+func (davFS *davFileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	parentDirectoryID, base, err := davFS.resolveParent(name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := davFS.resolve(name); err == nil {
+		return os.ErrExist
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if _, err := davFS.service.RegistrationDirectoryService.Execute(davFS.user, base, parentDirectoryID); err != nil {
+		return err
+	}
+	davFS.deleteCache()
+
+	return nil
+}
+
+func (davFS *davFileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		return davFS.create(ctx, name, flag)
+	}
+
+	f, err := davFS.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info := davFS.stat(f)
+	if info.IsDir() {
+		return &davDir{davFS: davFS, info: info}, nil
+	}
+
+	localPath, err := davFS.service.FileRepo.GetLocalPath(*f)
+	if err != nil {
+		return nil, os.ErrNotExist
+	}
+
+	blob, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &davReadFile{File: blob, info: info}, nil
+}
+
+// 新しい実体に書き込み、閉じた時点で行を登録する。既存のファイルの場合は実体を差し替える
+func (davFS *davFileSystem) create(ctx context.Context, name string, flag int) (webdav.File, error) {
+	parentDirectoryID, base, err := davFS.resolveParent(name)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := davFS.resolve(name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if existing != nil {
+		if file.FileKindFromEnString(existing.Kind) == file.Directory {
+			return nil, os.ErrExist
+		}
+		if flag&os.O_EXCL != 0 {
+			return nil, os.ErrExist
+		}
+	} else if flag&os.O_CREATE == 0 {
+		return nil, os.ErrNotExist
+	}
+
+	blob, err := davFS.service.StoreBlobService.Create(base)
+	if err != nil {
+		return nil, err
+	}
+
+	return &davWriteFile{
+		blob:              blob,
+		ctx:               ctx,
+		davFS:             davFS,
+		parentDirectoryID: parentDirectoryID,
+		name:              base,
+		existing:          existing,
+	}, nil
+}
+
+func (davFS *davFileSystem) RemoveAll(ctx context.Context, name string) error {
+	f, err := davFS.resolve(name)
+	if err != nil {
+		return err
+	}
+	if f == nil {
+		return os.ErrPermission
+	}
+
+	ids := []string{f.ID}
+	if file.FileKindFromEnString(f.Kind) == file.Directory {
+		descendants, err := davFS.service.FileRepo.GetDescendantFiles(davFS.service.Conn, davFS.user, f.ID)
+		if err != nil {
+			return err
+		}
+		for _, descendant := range descendants {
+			ids = append(ids, descendant.ID)
+		}
+	}
+
+	tx, err := davFS.service.Conn.Beginx()
+	if err != nil {
+		return err
+	}
+
+	blobIDs := []string{}
+	for _, id := range ids {
+		deletedBlobIDs, err := davFS.service.FileRepo.DeleteFile(tx, davFS.user, id)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		blobIDs = append(blobIDs, deletedBlobIDs...)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	deleteUnreferencedBlobs(davFS.service.Conn, davFS.service.FileRepo, blobIDs)
+	davFS.deleteCache()
+
+	return nil
+}
+
+func (davFS *davFileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	f, err := davFS.resolve(oldName)
+	if err != nil {
+		return err
+	}
+	if f == nil {
+		return os.ErrPermission
+	}
+
+	parentDirectoryID, base, err := davFS.resolveParent(newName)
+	if err != nil {
+		return err
+	}
+
+	if _, err := davFS.resolve(newName); err == nil {
+		return os.ErrExist
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	// ディレクトリを自身の配下には移動できない
+	if parentDirectoryID != nil && file.FileKindFromEnString(f.Kind) == file.Directory {
+		isDescendant, err := davFS.service.FileRepo.IsDescendantFile(davFS.service.Conn, davFS.user, f.ID, *parentDirectoryID)
+		if err != nil {
+			return err
+		}
+		if isDescendant {
+			return os.ErrPermission
+		}
+	}
+
+	tx, err := davFS.service.Conn.Beginx()
+	if err != nil {
+		return err
+	}
+
+	renamed := *f
+	renamed.ParentDirectoryID = parentDirectoryID
+	renamed.Name = base
+	renamed.UpdatedAt = time.Now()
+	if _, err := davFS.service.FileRepo.UpdateFile(tx, davFS.user, renamed); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	davFS.deleteCache()
+
+	return nil
+}
+
+func (davFS *davFileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	f, err := davFS.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return davFS.stat(f), nil
+}
+
+// ルートはfileがnil
+type davFileInfo struct {
+	file *file.File
+	size int64
+}
+
+func (info *davFileInfo) Name() string {
+	if info.file == nil {
+		return "/"
+	}
+
+	return info.file.Name
+}
+
+func (info *davFileInfo) Size() int64 {
+	return info.size
+}
+
+func (info *davFileInfo) Mode() fs.FileMode {
+	if info.IsDir() {
+		return fs.ModeDir | 0755
+	}
+
+	return 0644
+}
+
+func (info *davFileInfo) ModTime() time.Time {
+	// ルートは行が無いため、常に更新されたものとして扱う
+	if info.file == nil {
+		return time.Now()
+	}
+
+	return info.file.UpdatedAt
+}
+
+func (info *davFileInfo) IsDir() bool {
+	return info.file == nil || file.FileKindFromEnString(info.file.Kind) == file.Directory
+}
+
+func (info *davFileInfo) Sys() any {
+	return nil
+}
+
+// 登録済みのMIMEタイプを返し、PROPFINDのたびに実体を読まないようにする
+func (info *davFileInfo) ContentType(ctx context.Context) (string, error) {
+	if info.file == nil || info.file.MimeType == nil || *info.file.MimeType == "" {
+		return "", webdav.ErrNotImplemented
+	}
+
+	return *info.file.MimeType, nil
+}
+
+type davDir struct {
+	davFS *davFileSystem
+	info  *davFileInfo
+}
+
+func (dir *davDir) Close() error {
+	return nil
+}
+
+func (dir *davDir) Read(p []byte) (int, error) {
+	return 0, os.ErrInvalid
+}
+
+func (dir *davDir) Seek(offset int64, whence int) (int64, error) {
+	return 0, os.ErrInvalid
+}
+
+func (dir *davDir) Write(p []byte) (int, error) {
+	return 0, os.ErrInvalid
+}
+
+func (dir *davDir) Stat() (os.FileInfo, error) {
+	return dir.info, nil
+}
+
+// 同じ名前のファイルが複数ある場合は、パスで参照できる最も古いものだけを返す
+func (dir *davDir) Readdir(count int) ([]os.FileInfo, error) {
+	var parentDirectoryID *string
+	if dir.info.file != nil {
+		parentDirectoryID = &dir.info.file.ID
+	}
+
+	children, err := dir.davFS.service.FileRepo.GetChildFiles(dir.davFS.service.Conn, dir.davFS.user, parentDirectoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	infos := []os.FileInfo{}
+	for _, child := range children {
+		if seen[child.Name] || child.Name == "" || strings.Contains(child.Name, "/") {
+			continue
+		}
+		seen[child.Name] = true
+
+		infos = append(infos, dir.davFS.stat(&child))
+	}
+
+	return infos, nil
+}
+
+type davReadFile struct {
+	*os.File
+	info *davFileInfo
+}
+
+func (f *davReadFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *davReadFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, os.ErrInvalid
+}
+
+func (f *davReadFile) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+type davWriteFile struct {
+	blob              *Blob
+	ctx               context.Context
+	davFS             *davFileSystem
+	parentDirectoryID *string
+	name              string
+	existing          *file.File
+	err               error
+}
+
+func (f *davWriteFile) Read(p []byte) (int, error) {
+	return 0, os.ErrInvalid
+}
+
+func (f *davWriteFile) Seek(offset int64, whence int) (int64, error) {
+	return f.blob.Seek(offset, whence)
+}
+
+func (f *davWriteFile) Write(p []byte) (int, error) {
+	n, err := f.blob.Write(p)
+	if err != nil {
+		f.err = err
+	}
+
+	return n, err
+}
+
+func (f *davWriteFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, os.ErrInvalid
+}
+
+func (f *davWriteFile) Stat() (os.FileInfo, error) {
+	stat, err := f.blob.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return &davFileInfo{
+		file: &file.File{Name: f.name, Kind: file.FileKindFromFilename(f.name).ToEnString(), UpdatedAt: stat.ModTime()},
+		size: stat.Size(),
+	}, nil
+}
+
+// 書き込みが全て成功した場合のみ、ファイルとして登録する
+func (f *davWriteFile) Close() error {
+	failed := errors.Join(f.blob.Close(), f.err, f.ctx.Err())
+	if body, ok := f.ctx.Value(davRequestBodyKey{}).(*DavRequestBody); ok && body.err != nil {
+		failed = errors.Join(failed, body.err)
+	}
+	if failed != nil {
+		f.blob.Remove()
+		return failed
+	}
+
+	if _, err := f.davFS.service.StoreBlobService.Register(f.davFS.user, f.parentDirectoryID, f.name, f.existing, *f.blob); err != nil {
+		f.blob.Remove()
+		return err
+	}
+
+	return nil
+}
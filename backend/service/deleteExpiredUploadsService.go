@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+const (
+	expiredUploadSweepInterval = 10 * time.Minute
+	// 1回のクエリで削除するアップロードの数
+	expiredUploadSweepBatchSize = 100
+)
+
+// DeleteExpiredUploadsService periodically removes the uploads past their expiry together with the blobs that were never registered.
+type DeleteExpiredUploadsService struct {
+	Conn       *sqlx.DB
+	UploadRepo repository.UploadRepositoryInterface
+}
+
+// Start sweeps the expired uploads until ctx is cancelled
+func (service *DeleteExpiredUploadsService) Start(ctx context.Context) {
+	ticker := time.NewTicker(expiredUploadSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := service.Execute(time.Now())
+		if err != nil {
+			log.Printf("Failed to delete expired uploads: %+v", err)
+		} else if deleted > 0 {
+			log.Printf("Deleted %d expired upload(s)", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Execute deletes the uploads expired at now and returns how many were deleted
+func (service *DeleteExpiredUploadsService) Execute(now time.Time) (int, error) {
+	deleted := 0
+
+	for {
+		uploads, err := service.UploadRepo.GetExpiredUploads(service.Conn, now, expiredUploadSweepBatchSize)
+		if err != nil {
+			return deleted, errors.WithStack(err)
+		}
+
+		skipped := 0
+		for _, u := range uploads {
+			// 書き込み中のアップロードは次の機会に削除する
+			lock, _ := tusUploadLocks.LoadOrStore(u.ID, &sync.Mutex{})
+			if !lock.(*sync.Mutex).TryLock() {
+				skipped++
+				continue
+			}
+
+			err := deleteUpload(service.Conn, service.UploadRepo, user.User{ID: u.UserID}, u)
+			lock.(*sync.Mutex).Unlock()
+			if err != nil {
+				return deleted, errors.WithStack(err)
+			}
+			deleted++
+		}
+
+		// 削除できない分しか残っていない場合は、同じアップロードを取得し続けないよう終える
+		if len(uploads) < expiredUploadSweepBatchSize || skipped == len(uploads) {
+			return deleted, nil
+		}
+	}
+}
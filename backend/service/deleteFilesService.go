@@ -1,10 +1,11 @@
 package service
 
 import (
-	"sync"
+	"log"
 
 	"github.com/cockroachdb/errors"
 
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
 	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
 	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
 	"github.com/jmoiron/sqlx"
@@ -15,32 +16,68 @@ type DeleteFilesService struct {
 	FileRepo repository.FileRepositoryInterface
 }
 
+// ディレクトリは配下のファイルも削除し、使用量から除く
 func (service *DeleteFilesService) Execute(user user.User, fileIds []string) error {
+	ids := []string{}
+	for _, fileId := range fileIds {
+		ids = append(ids, fileId)
+
+		f, err := service.FileRepo.GetFileByID(service.Conn, user, fileId)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if f.ID == "" || file.FileKindFromEnString(f.Kind) != file.Directory {
+			continue
+		}
+
+		descendants, err := service.FileRepo.GetDescendantFiles(service.Conn, user, f.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		for _, descendant := range descendants {
+			ids = append(ids, descendant.ID)
+		}
+	}
+
 	tx, err := service.Conn.Beginx()
 	if err != nil {
 		return err
 	}
 
-	var result error
-	var wg sync.WaitGroup
-	for _, fileId := range fileIds {
-		wg.Add(1)
-		go func(fileId string) {
-			defer wg.Done()
-			err := service.FileRepo.DeleteFile(tx, user, fileId)
-			if err != nil {
-				result = errors.Join(result, err)
-			}
-		}(fileId)
+	// 1つのトランザクションを複数のゴルーチンから使えないため、順に削除する
+	blobIDs := []string{}
+	for _, id := range ids {
+		deletedBlobIDs, err := service.FileRepo.DeleteFile(tx, user, id)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		blobIDs = append(blobIDs, deletedBlobIDs...)
 	}
 
-	wg.Wait()
-
-	if result != nil {
-		tx.Rollback()
-		return result
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
 	}
 
-	tx.Commit()
+	deleteUnreferencedBlobs(service.Conn, service.FileRepo, blobIDs)
+
 	return nil
 }
+
+// コミットした後に、参照するファイルが無くなった実体を削除する。失敗しても削除したファイルは戻さない
+func deleteUnreferencedBlobs(conn *sqlx.DB, fileRepo repository.FileRepositoryInterface, blobIDs []string) {
+	for _, blobID := range blobIDs {
+		referenced, err := fileRepo.IsBlobReferenced(conn, blobID)
+		if err != nil {
+			log.Printf("Warning: Failed to check references of blob %s: %v", blobID, err)
+			continue
+		}
+		if referenced {
+			continue
+		}
+
+		if err := fileRepo.DeleteBlob(blobID); err != nil {
+			log.Printf("Warning: Failed to delete blob %s: %v", blobID, err)
+		}
+	}
+}
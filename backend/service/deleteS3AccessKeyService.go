@@ -0,0 +1,32 @@
+package service
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+type DeleteS3AccessKeyService struct {
+	Conn   *sqlx.DB
+	S3Repo repository.S3RepositoryInterface
+}
+
+func (service *DeleteS3AccessKeyService) Execute(user user.User, id string) error {
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := service.S3Repo.DeleteAccessKey(tx, user, id); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"log"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+type DeleteUserService struct {
+	Conn     *sqlx.DB
+	UserRepo repository.UserRepositoryInterface
+	FileRepo repository.FileRepositoryInterface
+}
+
+func (service *DeleteUserService) Execute(admin user.User, id string) error {
+	target, err := adminTargetUser(service.Conn, service.UserRepo, admin, id)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	blobIDs, err := service.UserRepo.DeleteUser(tx, *target)
+	if err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	deleteUnreferencedBlobs(service.Conn, service.FileRepo, blobIDs)
+
+	log.Printf("Admin %s deleted user %s", admin.ID, target.ID)
+
+	return nil
+}
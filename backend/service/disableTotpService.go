@@ -0,0 +1,55 @@
+package service
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+type DisableTotpService struct {
+	Conn                   *sqlx.DB
+	UserRepo               repository.UserRepositoryInterface
+	UserRecoveryCodeRepo   repository.UserRecoveryCodeRepositoryInterface
+	VerifyTwoFactorService VerifyTwoFactorService
+}
+
+// コードを確認してから二段階認証を無効にする。設定で必須にしている場合は無効にできない
+func (service *DisableTotpService) Execute(owner user.User, code string, now time.Time) error {
+	if service.UserRepo.GetAuthSetting().RequireTwoFactor {
+		return errors.WithStack(TwoFactorRequiredError{Code: 403, Message: "二段階認証は無効にできません。"})
+	}
+
+	if err := service.VerifyTwoFactorService.Execute(owner, code, now); err != nil {
+		return errors.WithStack(err)
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := service.UserRepo.UpdateTwoFactorEnabled(tx, owner, false); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := service.UserRepo.UpdateTotpSecret(tx, owner, nil); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := service.UserRecoveryCodeRepo.DeleteRecoveryCodes(tx, owner); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
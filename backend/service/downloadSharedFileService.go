@@ -0,0 +1,60 @@
+package service
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/share"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+type DownloadSharedFileService struct {
+	Conn                     *sqlx.DB
+	FileRepo                 repository.FileRepositoryInterface
+	ShareRepo                repository.ShareRepositoryInterface
+	GetServedOriginalService GetServedOriginalService
+}
+
+// 共有されたファイルの配信するファイルを返す。ダウンロード回数は本文を返す時にCountDownloadで数える
+func (service *DownloadSharedFileService) Execute(shared SharedFile, download bool) (*ServedFile, error) {
+	if file.FileKindFromEnString(shared.File.Kind) == file.Directory {
+		return nil, errors.WithStack(UnsupportedFileKindError{Code: 400, Message: "フォルダは配信できません。"})
+	}
+
+	// 閲覧のみのリンクはブラウザでの表示のみ許可する
+	if download && shared.Share.Permission != share.PermissionDownload {
+		return nil, errors.WithStack(SharePermissionDeniedError{Code: 403, Message: "この共有リンクではダウンロードできません。"})
+	}
+
+	localPath, err := service.FileRepo.GetLocalPath(shared.File)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// 所有者の設定に従い位置情報を取り除いたファイルを配信する
+	servedPath, err := service.GetServedOriginalService.Execute(shared.Owner, localPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	served, err := newServedFile(service.FileRepo, shared.File, servedPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return served, nil
+}
+
+// 先頭から返すダウンロード1回を数える。上限に達している場合はShareDownloadLimitExceededError
+func (service *DownloadSharedFileService) CountDownload(shared SharedFile) error {
+	counted, err := service.ShareRepo.IncrementDownloadCount(service.Conn, shared.Share.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if !counted {
+		return errors.WithStack(ShareDownloadLimitExceededError{Code: 410, Message: "ダウンロード回数の上限に達しました。"})
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+type EnableTotpService struct {
+	Conn                 *sqlx.DB
+	UserRepo             repository.UserRepositoryInterface
+	UserRecoveryCodeRepo repository.UserRecoveryCodeRepositoryInterface
+}
+
+// 登録中の秘密鍵のコードを確認して二段階認証を有効にし、リカバリーコードを返す。リカバリーコードの平文を返すのはこの時だけ
+func (service *EnableTotpService) Execute(owner user.User, code string, now time.Time) ([]string, error) {
+	if owner.TwoFactorEnabled {
+		return nil, errors.WithStack(TwoFactorConflictError{Code: 409, Message: "二段階認証は既に有効です。"})
+	}
+	if owner.TotpSecret == "" {
+		return nil, errors.WithStack(TwoFactorConflictError{Code: 409, Message: "先に二段階認証の登録を開始してください。"})
+	}
+
+	counter, ok := user.VerifyTotp(owner.TotpSecret, code, now, 0)
+	if !ok {
+		return nil, errors.WithStack(InvalidTwoFactorCodeError{Code: 400, Message: "認証コードが違います。"})
+	}
+
+	recoveryCodes, err := user.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if _, err := service.UserRepo.UpdateTotpLastCounter(tx, owner, counter); err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := service.UserRepo.UpdateTwoFactorEnabled(tx, owner, true); err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := service.UserRecoveryCodeRepo.ReplaceRecoveryCodes(tx, owner, hashRecoveryCodes(recoveryCodes)); err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return recoveryCodes, nil
+}
+
+func hashRecoveryCodes(codes []string) []string {
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, user.HashRecoveryCode(code))
+	}
+
+	return hashes
+}
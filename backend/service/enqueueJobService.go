@@ -0,0 +1,95 @@
+package service
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/job"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+type EnqueueJobService struct {
+	Conn    *sqlx.DB
+	JobRepo repository.JobRepositoryInterface
+}
+
+func (service *EnqueueJobService) Execute(userID string, jobType job.Type, payload any) (*job.Job, error) {
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	enqueuedJob, err := service.ExecuteTx(tx, userID, jobType, payload)
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return enqueuedJob, nil
+}
+
+// 他の更新と同じトランザクションでジョブを登録する
+func (service *EnqueueJobService) ExecuteTx(tx *sqlx.Tx, userID string, jobType job.Type, payload any) (*job.Job, error) {
+	generatedID, err := helper.GenerateSnowflake()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	encodedPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	now := time.Now()
+	j := job.Job{
+		ID:          *generatedID,
+		UserID:      userID,
+		Type:        jobType,
+		Status:      job.StatusQueued,
+		Payload:     encodedPayload,
+		MaxAttempts: service.JobRepo.GetJobSetting().Get(jobType).MaxAttempts,
+		RunAt:       now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	return service.JobRepo.RegistrationJob(tx, j)
+}
+
+// 登録した実体に対して、派生ファイルの生成と動画の変換のジョブを登録する。blobIDはストレージ上のファイル名から拡張子を除いたもの
+func (service *EnqueueJobService) ExecuteBlobJobsTx(tx *sqlx.Tx, userID string, blobID string, f file.File) ([]job.Job, error) {
+	kind := file.FileKindFromEnString(f.Kind)
+	payload := job.BlobPayload{
+		BlobID:   blobID,
+		Filename: f.Name,
+		Kind:     kind.ToEnString(),
+	}
+
+	jobTypes := []job.Type{}
+	if kind.HasDerivatives() {
+		jobTypes = append(jobTypes, job.TypeGenerateDerivatives)
+	}
+	if kind == file.Video {
+		jobTypes = append(jobTypes, job.TypeTranscodeVideo)
+	}
+
+	enqueuedJobs := []job.Job{}
+	for _, jobType := range jobTypes {
+		enqueuedJob, err := service.ExecuteTx(tx, userID, jobType, payload)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		enqueuedJobs = append(enqueuedJobs, *enqueuedJob)
+	}
+
+	return enqueuedJobs, nil
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+type EnrollTotpService struct {
+	Conn     *sqlx.DB
+	UserRepo repository.UserRepositoryInterface
+}
+
+// 新しい秘密鍵を発行する。EnableTotpServiceでコードを確認するまで二段階認証は有効にならない
+func (service *EnrollTotpService) Execute(owner user.User) (*user.TotpEnrollment, error) {
+	if owner.TwoFactorEnabled {
+		return nil, errors.WithStack(TwoFactorConflictError{Code: 409, Message: "二段階認証は既に有効です。"})
+	}
+
+	secret, err := user.GenerateTotpSecret()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := service.UserRepo.UpdateTotpSecret(tx, owner, &secret); err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &user.TotpEnrollment{
+		Secret:          secret,
+		ProvisioningUri: user.TotpProvisioningUri(secret, owner.Email),
+	}, nil
+}
@@ -1,5 +1,7 @@
 package service
 
+import "time"
+
 type AlreadyUsedEmailAddressError struct {
 	Code    int
 	Message string
@@ -8,3 +10,206 @@ type AlreadyUsedEmailAddressError struct {
 func (e AlreadyUsedEmailAddressError) Error() string {
 	return e.Message
 }
+
+type UnsupportedFileKindError struct {
+	Code    int
+	Message string
+}
+
+func (e UnsupportedFileKindError) Error() string {
+	return e.Message
+}
+
+type JobAlreadyFinishedError struct {
+	Code    int
+	Message string
+}
+
+func (e JobAlreadyFinishedError) Error() string {
+	return e.Message
+}
+
+type ShareExpiredError struct {
+	Code    int
+	Message string
+}
+
+func (e ShareExpiredError) Error() string {
+	return e.Message
+}
+
+type SharePasswordRequiredError struct {
+	Code    int
+	Message string
+}
+
+func (e SharePasswordRequiredError) Error() string {
+	return e.Message
+}
+
+type SharePermissionDeniedError struct {
+	Code    int
+	Message string
+}
+
+func (e SharePermissionDeniedError) Error() string {
+	return e.Message
+}
+
+type ShareDownloadLimitExceededError struct {
+	Code    int
+	Message string
+}
+
+func (e ShareDownloadLimitExceededError) Error() string {
+	return e.Message
+}
+
+// S3互換APIのエラー。CodeはS3のエラーコード(NoSuchKeyなど)
+type S3Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e S3Error) Error() string {
+	return e.Message
+}
+
+// tusのプロトコルで決められたステータスを返すエラー
+type TusError struct {
+	Code    int
+	Message string
+}
+
+func (e TusError) Error() string {
+	return e.Message
+}
+
+// アップロードされた本文が大きすぎる・ダイジェストが一致しないなどのエラー
+type InvalidUploadError struct {
+	Code    int
+	Message string
+}
+
+func (e InvalidUploadError) Error() string {
+	return e.Message
+}
+
+type ApiTokenPermissionDeniedError struct {
+	Code    int
+	Message string
+}
+
+func (e ApiTokenPermissionDeniedError) Error() string {
+	return e.Message
+}
+
+type TwoFactorRequiredError struct {
+	Code    int
+	Message string
+}
+
+func (e TwoFactorRequiredError) Error() string {
+	return e.Message
+}
+
+type InvalidTwoFactorCodeError struct {
+	Code    int
+	Message string
+}
+
+func (e InvalidTwoFactorCodeError) Error() string {
+	return e.Message
+}
+
+type TwoFactorConflictError struct {
+	Code    int
+	Message string
+}
+
+func (e TwoFactorConflictError) Error() string {
+	return e.Message
+}
+
+type RateLimitedError struct {
+	Code       int
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e RateLimitedError) Error() string {
+	return e.Message
+}
+
+type InvalidPasswordError struct {
+	Code    int
+	Message string
+}
+
+func (e InvalidPasswordError) Error() string {
+	return e.Message
+}
+
+type EmailAlreadyVerifiedError struct {
+	Code    int
+	Message string
+}
+
+func (e EmailAlreadyVerifiedError) Error() string {
+	return e.Message
+}
+
+type OidcError struct {
+	Code    int
+	Message string
+}
+
+func (e OidcError) Error() string {
+	return e.Message
+}
+
+type PasswordLoginDisabledError struct {
+	Code    int
+	Message string
+}
+
+func (e PasswordLoginDisabledError) Error() string {
+	return e.Message
+}
+
+type UserDisabledError struct {
+	Code    int
+	Message string
+}
+
+func (e UserDisabledError) Error() string {
+	return e.Message
+}
+
+type RegistrationNotAllowedError struct {
+	Code    int
+	Message string
+}
+
+func (e RegistrationNotAllowedError) Error() string {
+	return e.Message
+}
+
+type AdminOperationError struct {
+	Code    int
+	Message string
+}
+
+func (e AdminOperationError) Error() string {
+	return e.Message
+}
+
+type QuotaExceededError struct {
+	Code    int
+	Message string
+}
+
+func (e QuotaExceededError) Error() string {
+	return e.Message
+}
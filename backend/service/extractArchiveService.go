@@ -0,0 +1,588 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/job"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+// 進捗を記録する間隔
+const extractProgressInterval = time.Second
+
+type ExtractArchiveService struct {
+	Conn              *sqlx.DB
+	UserRepo          repository.UserRepositoryInterface
+	FileRepo          repository.FileRepositoryInterface
+	JobRepo           repository.JobRepositoryInterface
+	EnqueueJobService EnqueueJobService
+	QuotaService      QuotaService
+}
+
+// Execute checks that the file can be extracted and enqueues an extract_archive job.
+// When parentDirectoryID is nil, the archive is extracted next to the archive itself.
+func (service *ExtractArchiveService) Execute(user user.User, fileID string, parentDirectoryID *string) (*job.Job, error) {
+	archiveFile, err := service.FileRepo.GetFileByID(service.Conn, user, fileID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if archiveFile.ID == "" {
+		return nil, errors.WithStack(repository.NotFoundError{Code: 404, Message: "ファイルが見つかりません。"})
+	}
+
+	if _, ok := file.ArchiveFormatFromFilename(archiveFile.Name); !ok || file.FileKindFromEnString(archiveFile.Kind) != file.Zip {
+		return nil, errors.WithStack(UnsupportedFileKindError{Code: 400, Message: "展開できるのはZIP・tar・tar.gz形式のファイルのみです。"})
+	}
+
+	// 外部URLのみを登録したファイルは展開できない
+	if _, err := service.FileRepo.GetLocalPath(*archiveFile); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if parentDirectoryID != nil {
+		parent, err := service.FileRepo.GetFileByID(service.Conn, user, *parentDirectoryID)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if parent.ID == "" || file.FileKindFromEnString(parent.Kind) != file.Directory {
+			return nil, errors.WithStack(repository.NotFoundError{Code: 404, Message: "ディレクトリが見つかりません。"})
+		}
+	}
+
+	return service.EnqueueJobService.Execute(user.ID, job.TypeExtractArchive, job.ExtractPayload{
+		FileID:            archiveFile.ID,
+		ParentDirectoryID: parentDirectoryID,
+	})
+}
+
+// Handle extracts the archive of an extract_archive job into a new directory named after the archive.
+// All rows are registered in a single transaction at the end, so a failed job leaves nothing behind.
+func (service *ExtractArchiveService) Handle(ctx context.Context, j job.Job) (any, error) {
+	var payload job.ExtractPayload
+	if err := json.Unmarshal(j.Payload, &payload); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	owner, err := service.UserRepo.GetUserByID(service.Conn, j.UserID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	archiveFile, err := service.FileRepo.GetFileByID(service.Conn, *owner, payload.FileID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if archiveFile.ID == "" {
+		return nil, errors.WithStack(repository.NotFoundError{Code: 404, Message: "ファイルが見つかりません。"})
+	}
+
+	format, ok := file.ArchiveFormatFromFilename(archiveFile.Name)
+	if !ok {
+		return nil, errors.WithStack(fmt.Errorf("unsupported archive: %s", archiveFile.Name))
+	}
+
+	localPath, err := service.FileRepo.GetLocalPath(*archiveFile)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	stat, err := os.Stat(localPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// 展開したファイルは全て同じマウントに保存する
+	mount, err := service.FileRepo.GetStoreStoragePath()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	parentDirectoryID := payload.ParentDirectoryID
+	if parentDirectoryID == nil {
+		parentDirectoryID = archiveFile.ParentDirectoryID
+	}
+
+	quotaRemaining, quotaLimited, err := service.QuotaService.Remaining(*owner)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	extraction := &archiveExtraction{
+		service:     service,
+		ctx:         ctx,
+		jobID:       j.ID,
+		owner:       *owner,
+		setting:     service.FileRepo.GetExtractionSetting(),
+		archiveSize: stat.Size(),
+		mount:       mount,
+		dirs:        map[string]file.File{},
+		names:       archiveNames{},
+		skipped:     []string{},
+
+		quotaRemaining: quotaRemaining,
+		quotaLimited:   quotaLimited,
+	}
+
+	root, err := extraction.newFile(parentDirectoryID, file.TrimArchiveExtension(archiveFile.Name), file.Directory)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	extraction.dirs["."] = root
+
+	if err := extraction.extract(format, localPath); err != nil {
+		extraction.removeBlobs()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := extraction.register(); err != nil {
+		extraction.removeBlobs()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := service.FileRepo.DeleteCache(owner.ID); err != nil {
+		log.Printf("Warning: Failed to delete cache of user %s: %v", owner.ID, err)
+	}
+
+	service.QuotaService.Warn(*owner, extraction.totalSize)
+
+	return job.ExtractResult{
+		DirectoryID:    root.ID,
+		FileCount:      len(extraction.blobs),
+		DirectoryCount: len(extraction.dirs),
+		TotalSize:      extraction.totalSize,
+		Skipped:        extraction.skipped,
+	}, nil
+}
+
+type extractedBlob struct {
+	File      file.File
+	LocalPath string
+}
+
+// 1件のジョブで展開中の状態
+type archiveExtraction struct {
+	service     *ExtractArchiveService
+	ctx         context.Context
+	jobID       string
+	owner       user.User
+	setting     file.ExtractionSetting
+	archiveSize int64
+	mount       string
+	// 展開を始めたときのユーザーの残りの容量
+	quotaRemaining int64
+	quotaLimited   bool
+
+	// アーカイブ内のディレクトリのパスごとの行
+	dirs  map[string]file.File
+	names archiveNames
+	// 登録する行。親ディレクトリが先に並ぶ
+	rows  []file.File
+	blobs []extractedBlob
+
+	entries   int
+	totalSize int64
+	skipped   []string
+
+	progress       job.Progress
+	progressSaveAt time.Time
+}
+
+func (extraction *archiveExtraction) extract(format file.ArchiveFormat, localPath string) error {
+	if format == file.ArchiveZip {
+		return extraction.extractZip(localPath)
+	}
+
+	return extraction.extractTar(format, localPath)
+}
+
+func (extraction *archiveExtraction) extractZip(localPath string) error {
+	zr, err := zip.OpenReader(localPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer zr.Close()
+
+	// 中央ディレクトリに記録されたサイズで、展開前に上限を確認する
+	if len(zr.File) > extraction.setting.MaxEntries {
+		return errors.WithStack(fmt.Errorf("archive has too many entries: %d > %d", len(zr.File), extraction.setting.MaxEntries))
+	}
+	var declaredSize uint64
+	for _, f := range zr.File {
+		declaredSize += f.UncompressedSize64
+	}
+	if err := extraction.checkSize(int64(min(declaredSize, uint64(1)<<62))); err != nil {
+		return errors.WithStack(err)
+	}
+
+	extraction.progress = job.Progress{Total: int64(len(zr.File)), Unit: "entries"}
+
+	for _, f := range zr.File {
+		extraction.progress.Current++
+
+		name := helper.DecodeArchiveFilename(f.Name, !f.NonUTF8)
+		mode := f.Mode()
+
+		switch {
+		case mode.IsDir():
+			if err := extraction.addDir(name); err != nil {
+				return errors.WithStack(err)
+			}
+		case mode.IsRegular():
+			if err := extraction.addZipFile(name, f); err != nil {
+				return errors.WithStack(err)
+			}
+		default:
+			// シンボリックリンク等は展開しない
+			extraction.skip(name)
+		}
+
+		extraction.saveProgress(false)
+	}
+
+	extraction.saveProgress(true)
+
+	return nil
+}
+
+func (extraction *archiveExtraction) addZipFile(name string, f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer rc.Close()
+
+	return extraction.addFile(name, rc)
+}
+
+func (extraction *archiveExtraction) extractTar(format file.ArchiveFormat, localPath string) error {
+	archive, err := os.Open(localPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer archive.Close()
+
+	// tarはエントリ数が事前に分からないため、読み込んだアーカイブのバイト数を進捗とする
+	extraction.progress = job.Progress{Total: extraction.archiveSize, Unit: "bytes"}
+	counted := &countingReader{r: archive, n: &extraction.progress.Current}
+
+	var r io.Reader = counted
+	if format == file.ArchiveTarGz {
+		gr, err := gzip.NewReader(counted)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		// PAX形式のヘッダはUTF-8だが、古いGNU形式ではShift_JISの場合がある
+		_, hasPAXPath := header.PAXRecords["path"]
+		name := helper.DecodeArchiveFilename(header.Name, hasPAXPath)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := extraction.addDir(name); err != nil {
+				return errors.WithStack(err)
+			}
+		case tar.TypeReg:
+			if err := extraction.addFile(name, tr); err != nil {
+				return errors.WithStack(err)
+			}
+		default:
+			// シンボリックリンク・ハードリンク・デバイスファイル等は展開しない
+			extraction.skip(name)
+		}
+
+		extraction.saveProgress(false)
+	}
+
+	extraction.saveProgress(true)
+
+	return nil
+}
+
+// アーカイブ内のパスを正規化する。親ディレクトリや絶対パスを指すもの(zip slip)はokがfalseになる。
+// 実体はIDをファイル名として保存するため、アーカイブ内のパスがストレージ上のパスに使われることは無い
+func normalizeArchiveEntryPath(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+
+	if strings.HasPrefix(name, "/") || (len(name) >= 2 && name[1] == ':') {
+		return "", false
+	}
+
+	for _, segment := range strings.Split(name, "/") {
+		if segment == ".." {
+			return "", false
+		}
+	}
+
+	return path.Clean(name), true
+}
+
+// macOSで作成されたZIPに含まれるリソースフォーク等
+func isArchiveMetadataPath(entryPath string) bool {
+	return entryPath == "__MACOSX" || strings.HasPrefix(entryPath, "__MACOSX/") || path.Base(entryPath) == ".DS_Store"
+}
+
+func (extraction *archiveExtraction) addDir(name string) error {
+	entryPath, ok := normalizeArchiveEntryPath(name)
+	if !ok {
+		extraction.skip(name)
+		return nil
+	}
+	if isArchiveMetadataPath(entryPath) {
+		return nil
+	}
+
+	_, err := extraction.ensureDir(entryPath)
+
+	return err
+}
+
+func (extraction *archiveExtraction) addFile(name string, r io.Reader) error {
+	entryPath, ok := normalizeArchiveEntryPath(name)
+	if !ok || entryPath == "." {
+		extraction.skip(name)
+		return nil
+	}
+	if isArchiveMetadataPath(entryPath) {
+		return nil
+	}
+
+	dir := path.Dir(entryPath)
+	parent, err := extraction.ensureDir(dir)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	f, err := extraction.newFile(&parent.ID, path.Base(extraction.names.allocate(dir, path.Base(entryPath))), file.FileKindFromFilename(entryPath))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	localPath := fmt.Sprintf("storage/files/%s/%s%s", extraction.mount, f.ID, filepath.Ext(f.Name))
+	// 途中で失敗した場合も削除できるよう、書き込む前に記録する
+	extraction.blobs = append(extraction.blobs, extractedBlob{File: f, LocalPath: localPath})
+
+	writtenBefore := extraction.totalSize
+	if err := extraction.writeBlob(localPath, r); err != nil {
+		return errors.WithStack(err)
+	}
+	f.Size = extraction.totalSize - writtenBefore
+
+	url := extraction.service.FileRepo.GetUrl(localPath)
+	mimeType := helper.DetectMimeType(localPath)
+	f.Url = &url
+	f.BlobID = &f.ID
+	f.MimeType = &mimeType
+	if file.FileKindFromEnString(f.Kind) == file.Image {
+		applyImageMetadata(&f, localPath)
+	}
+
+	extraction.blobs[len(extraction.blobs)-1].File = f
+	extraction.rows = append(extraction.rows, f)
+
+	return nil
+}
+
+// アーカイブ内のディレクトリに対応する行を、親から順に作成する
+func (extraction *archiveExtraction) ensureDir(entryPath string) (file.File, error) {
+	if dir, ok := extraction.dirs[entryPath]; ok {
+		return dir, nil
+	}
+
+	parentPath := path.Dir(entryPath)
+	parent, err := extraction.ensureDir(parentPath)
+	if err != nil {
+		return file.File{}, err
+	}
+
+	dir, err := extraction.newFile(&parent.ID, path.Base(extraction.names.allocate(parentPath, path.Base(entryPath))), file.Directory)
+	if err != nil {
+		return file.File{}, err
+	}
+	extraction.dirs[entryPath] = dir
+
+	return dir, nil
+}
+
+// 行を作成する。ディレクトリの行はここで登録対象に加え、ファイルの行は実体の書き込み後に加える
+func (extraction *archiveExtraction) newFile(parentDirectoryID *string, name string, kind file.FileKind) (file.File, error) {
+	extraction.entries++
+	if extraction.entries > extraction.setting.MaxEntries {
+		return file.File{}, errors.WithStack(fmt.Errorf("archive has too many entries: more than %d", extraction.setting.MaxEntries))
+	}
+
+	generatedID, err := helper.GenerateSnowflake()
+	if err != nil {
+		return file.File{}, errors.WithStack(err)
+	}
+
+	now := time.Now()
+	f := file.File{
+		ID:                *generatedID,
+		UserID:            extraction.owner.ID,
+		ParentDirectoryID: parentDirectoryID,
+		Kind:              kind.ToEnString(),
+		Name:              name,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+
+	if kind == file.Directory {
+		extraction.rows = append(extraction.rows, f)
+	}
+
+	return f, nil
+}
+
+// 実際に展開したバイト数で上限を確認しながら書き込む。ヘッダのサイズを偽装したアーカイブにも対応する
+func (extraction *archiveExtraction) writeBlob(localPath string, r io.Reader) error {
+	out, err := os.OpenFile(localPath, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0666)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer out.Close()
+
+	buf := make([]byte, 256*1024)
+	for {
+		if err := extraction.ctx.Err(); err != nil {
+			return err
+		}
+
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			extraction.totalSize += int64(n)
+			if err := extraction.checkSize(extraction.totalSize); err != nil {
+				return err
+			}
+
+			if _, err := out.Write(buf[:n]); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return errors.WithStack(readErr)
+		}
+
+		extraction.saveProgress(false)
+	}
+
+	return errors.WithStack(out.Close())
+}
+
+// 展開後の合計サイズと圧縮率の上限を確認する
+func (extraction *archiveExtraction) checkSize(size int64) error {
+	if size > extraction.setting.MaxTotalSize {
+		return fmt.Errorf("extracted size exceeds the limit of %d bytes", extraction.setting.MaxTotalSize)
+	}
+
+	if extraction.archiveSize > 0 && float64(size)/float64(extraction.archiveSize) > extraction.setting.MaxCompressionRatio {
+		return fmt.Errorf("compression ratio exceeds the limit of %g", extraction.setting.MaxCompressionRatio)
+	}
+
+	if extraction.quotaLimited && size > extraction.quotaRemaining {
+		return quotaExceededError()
+	}
+
+	return nil
+}
+
+func (extraction *archiveExtraction) skip(name string) {
+	log.Printf("Skipped archive entry of job %s: %q", extraction.jobID, name)
+	extraction.skipped = append(extraction.skipped, name)
+}
+
+func (extraction *archiveExtraction) saveProgress(force bool) {
+	if !force && time.Since(extraction.progressSaveAt) < extractProgressInterval {
+		return
+	}
+	extraction.progressSaveAt = time.Now()
+
+	if err := extraction.service.JobRepo.UpdateJobProgress(extraction.service.Conn, extraction.jobID, extraction.progress); err != nil {
+		log.Printf("Failed to update progress of job %s: %+v", extraction.jobID, err)
+	}
+}
+
+// 展開した行をまとめて登録し、派生ファイルの生成と動画の変換のジョブを登録する
+func (extraction *archiveExtraction) register() error {
+	service := extraction.service
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// 展開中に他の保存で使用量が増えている場合もあるため、登録する前に改めて確認する
+	if err := service.QuotaService.CheckTx(tx, extraction.owner, extraction.totalSize, ""); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	for _, row := range extraction.rows {
+		if _, err := service.FileRepo.RegistrationFile(tx, extraction.owner, row); err != nil {
+			tx.Rollback()
+			return errors.WithStack(err)
+		}
+	}
+
+	for _, blob := range extraction.blobs {
+		if _, err := service.EnqueueJobService.ExecuteBlobJobsTx(tx, extraction.owner.ID, blob.File.ID, blob.File); err != nil {
+			tx.Rollback()
+			return errors.WithStack(err)
+		}
+	}
+
+	return errors.WithStack(tx.Commit())
+}
+
+func (extraction *archiveExtraction) removeBlobs() {
+	for _, blob := range extraction.blobs {
+		if err := os.Remove(blob.LocalPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("Warning: Failed to delete extracted file %s: %v", blob.LocalPath, err)
+		}
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (reader *countingReader) Read(p []byte) (int, error) {
+	n, err := reader.r.Read(p)
+	*reader.n += int64(n)
+
+	return n, err
+}
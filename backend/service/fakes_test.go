@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"github.com/jmoiron/sqlx"
+	"github.com/valyala/fasthttp"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/oidc"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/ratelimit"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+// トランザクションの開始と終了のみに対応するDB。クエリは偽のリポジトリが扱う
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.Newf("fake database does not run queries: %s", query)
+}
+func (fakeConn) Close() error              { return nil }
+func (fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+func newFakeDB() *sqlx.DB {
+	return sqlx.NewDb(sql.OpenDB(fakeConnector{}), "postgres")
+}
+
+// 実際のクエリと同じく、保存しているステップより後のステップのみ記録する
+type fakeUserRepo struct {
+	repository.UserRepositoryInterface
+	users map[string]user.User
+	auth  user.AuthSetting
+}
+
+func newFakeUserRepo(users ...user.User) *fakeUserRepo {
+	repo := &fakeUserRepo{users: map[string]user.User{}, auth: user.DefaultAuthSetting()}
+	for _, u := range users {
+		repo.users[u.ID] = u
+	}
+
+	return repo
+}
+
+func (repo *fakeUserRepo) Login(tx *sqlx.Tx, email string, password string) (*user.User, error) {
+	for _, u := range repo.users {
+		if u.Email == email && u.Password == password {
+			return &u, nil
+		}
+	}
+
+	return nil, repository.NotFoundError{Code: 404, Message: "メールアドレスまたはパスワードが違います。"}
+}
+
+func (repo *fakeUserRepo) GetUserByID(conn *sqlx.DB, id string) (*user.User, error) {
+	u, ok := repo.users[id]
+	if !ok {
+		return nil, repository.NotFoundError{Code: 404, Message: "ユーザーが見つかりません。"}
+	}
+
+	return &u, nil
+}
+
+func (repo *fakeUserRepo) GetUserByEmail(conn *sqlx.DB, email string) (*user.User, error) {
+	for _, u := range repo.users {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+
+	return nil, repository.NotFoundError{Code: 404, Message: "ユーザーが見つかりません。"}
+}
+
+func (repo *fakeUserRepo) RegistrationWithoutPassword(tx *sqlx.Tx, email string, icon string, role user.Role, emailVerifiedAt *time.Time) (string, error) {
+	id := strconv.Itoa(len(repo.users) + 1)
+	repo.users[id] = user.User{ID: id, Email: email, Icon: icon, Role: role, EmailVerifiedAt: emailVerifiedAt}
+
+	return id, nil
+}
+
+func (repo *fakeUserRepo) GetAuthSetting() user.AuthSetting {
+	return repo.auth
+}
+
+func (repo *fakeUserRepo) UpdateTotpLastCounter(tx *sqlx.Tx, owner user.User, counter int64) (bool, error) {
+	stored := repo.users[owner.ID]
+	if stored.TotpLastCounter >= counter {
+		return false, nil
+	}
+
+	stored.TotpLastCounter = counter
+	repo.users[owner.ID] = stored
+
+	return true, nil
+}
+
+// 実際のクエリと同じく、使われていないコードのみ使用済みにする
+type fakeUserRecoveryCodeRepo struct {
+	repository.UserRecoveryCodeRepositoryInterface
+	usedAt map[string]*time.Time
+}
+
+func newFakeUserRecoveryCodeRepo(codes ...string) *fakeUserRecoveryCodeRepo {
+	repo := &fakeUserRecoveryCodeRepo{usedAt: map[string]*time.Time{}}
+	for _, code := range codes {
+		repo.usedAt[user.HashRecoveryCode(code)] = nil
+	}
+
+	return repo
+}
+
+func (repo *fakeUserRecoveryCodeRepo) UseRecoveryCode(tx *sqlx.Tx, owner user.User, codeHash string, usedAt time.Time) (bool, error) {
+	used, ok := repo.usedAt[codeHash]
+	if !ok || used != nil {
+		return false, nil
+	}
+
+	repo.usedAt[codeHash] = &usedAt
+
+	return true, nil
+}
+
+type fakeUserSessionRepo struct {
+	repository.UserSessionRepositoryInterface
+	sessions []user.Session
+}
+
+func (repo *fakeUserSessionRepo) RegistrationUserSession(tx *sqlx.Tx, s user.Session) (*user.Session, error) {
+	repo.sessions = append(repo.sessions, s)
+
+	return &s, nil
+}
+
+func (repo *fakeUserSessionRepo) DeleteExpiredUserSessions(tx *sqlx.Tx, owner user.User, now time.Time) error {
+	return nil
+}
+
+// 回数のみ数える。ロックは実際の時刻で解除されるため、このテストでは掛けない
+type fakeRateLimitRepo struct {
+	repository.RateLimitRepositoryInterface
+	counts map[string]int64
+}
+
+func newFakeRateLimitRepo() *fakeRateLimitRepo {
+	return &fakeRateLimitRepo{counts: map[string]int64{}}
+}
+
+func (repo *fakeRateLimitRepo) Hit(key string, window time.Duration) (int64, time.Duration, error) {
+	repo.counts[key]++
+
+	return repo.counts[key], window, nil
+}
+
+func (repo *fakeRateLimitRepo) GetCount(key string) (int64, time.Duration, error) {
+	return repo.counts[key], 0, nil
+}
+
+func (repo *fakeRateLimitRepo) Lock(key string, duration time.Duration) error {
+	return nil
+}
+
+func (repo *fakeRateLimitRepo) GetLockedFor(key string) (time.Duration, error) {
+	return 0, nil
+}
+
+func (repo *fakeRateLimitRepo) Reset(keys ...string) error {
+	for _, key := range keys {
+		delete(repo.counts, key)
+	}
+
+	return nil
+}
+
+func (repo *fakeRateLimitRepo) GetRateLimitSetting() ratelimit.Setting {
+	return ratelimit.DefaultSetting()
+}
+
+// Cookieのセッションをリクエスト間で引き継ぐ。fiberのセッションは保存すると再利用できないため、リクエストごとに取得し直す
+type testSessions struct {
+	app    *fiber.App
+	store  *session.Store
+	cookie string
+}
+
+func newTestSessions() *testSessions {
+	return &testSessions{app: fiber.New(), store: session.New()}
+}
+
+func (s *testSessions) request(t *testing.T, handle func(sess *session.Session)) {
+	t.Helper()
+
+	ctx := s.app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer s.app.ReleaseCtx(ctx)
+
+	if s.cookie != "" {
+		ctx.Request().Header.SetCookie("session_id", s.cookie)
+	}
+
+	sess, err := s.store.Get(ctx)
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+
+	handle(sess)
+
+	if raw := ctx.Response().Header.PeekCookie("session_id"); len(raw) > 0 {
+		cookie := fasthttp.AcquireCookie()
+		defer fasthttp.ReleaseCookie(cookie)
+		if err := cookie.ParseBytes(raw); err != nil {
+			t.Fatalf("failed to parse session cookie: %v", err)
+		}
+		s.cookie = string(cookie.Value())
+	}
+}
+
+// IdPとの通信は行わず、認可コードに対応するクレームを返す
+type fakeOidcRepo struct {
+	repository.OidcRepositoryInterface
+	setting oidc.Setting
+	claims  map[string]oidc.Claims
+}
+
+func (repo *fakeOidcRepo) GetIssuer() string {
+	return "https://idp.example.com"
+}
+
+func (repo *fakeOidcRepo) ExchangeCode(code string, codeVerifier string) (string, error) {
+	return code, nil
+}
+
+func (repo *fakeOidcRepo) VerifyIdToken(rawIdToken string, nonce string, now time.Time) (*oidc.Claims, error) {
+	claims, ok := repo.claims[rawIdToken]
+	if !ok || claims.Nonce != nonce {
+		return nil, errors.New("invalid id token")
+	}
+
+	return &claims, nil
+}
+
+func (repo *fakeOidcRepo) GetOidcSetting() oidc.Setting {
+	return repo.setting
+}
+
+type fakeUserIdentityRepo struct {
+	repository.UserIdentityRepositoryInterface
+	// 発行者と対象者の組からユーザーIDへの対応
+	userIDs map[[2]string]string
+}
+
+func (repo *fakeUserIdentityRepo) GetUserIDByIdentity(conn *sqlx.DB, issuer string, subject string) (string, error) {
+	userID, ok := repo.userIDs[[2]string{issuer, subject}]
+	if !ok {
+		return "", repository.NotFoundError{Code: 404, Message: "ユーザーが見つかりません。"}
+	}
+
+	return userID, nil
+}
+
+func (repo *fakeUserIdentityRepo) RegistrationUserIdentity(tx *sqlx.Tx, owner user.User, issuer string, subject string) error {
+	repo.userIDs[[2]string{issuer, subject}] = owner.ID
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runCommand executes an external media tool and kills it when the timeout is exceeded or ctx is cancelled
+func runCommand(ctx context.Context, timeout time.Duration, name string, args ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+
+	log.Printf("Executing %s command: %s", name, cmd.String())
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Printf("%s error: %v", name, err)
+		log.Printf("%s output: %s", name, string(output))
+		return fmt.Errorf("%s failed: %v", name, err)
+	}
+
+	return nil
+}
+
+// probe runs ffprobe and returns its trimmed output
+func probe(ctx context.Context, inputPath string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	args = append([]string{"-v", "error"}, args...)
+	args = append(args, inputPath)
+
+	output, err := exec.CommandContext(ctx, "ffprobe", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("ffprobe failed: %v", err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// probeDuration returns the duration of the media in seconds
+func probeDuration(ctx context.Context, inputPath string) (float64, error) {
+	output, err := probe(ctx, inputPath, "-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1")
+	if err != nil {
+		return 0, err
+	}
+
+	duration, err := strconv.ParseFloat(output, 64)
+	if err != nil {
+		// ストリーム形式によっては長さが取得できないため、その場合は0として扱う
+		return 0, nil
+	}
+
+	return duration, nil
+}
+
+// probeResolution returns the width and height of the first video stream
+func probeResolution(ctx context.Context, inputPath string) (int, int, error) {
+	output, err := probe(ctx, inputPath, "-select_streams", "v:0", "-show_entries", "stream=width,height", "-of", "csv=s=x:p=0")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var width, height int
+	if _, err := fmt.Sscanf(output, "%dx%d", &width, &height); err != nil || width == 0 || height == 0 {
+		return 0, 0, fmt.Errorf("unexpected ffprobe output: %s", output)
+	}
+
+	return width, height, nil
+}
+
+// probeHasAudio reports whether the media has at least one audio stream
+func probeHasAudio(ctx context.Context, inputPath string) (bool, error) {
+	output, err := probe(ctx, inputPath, "-select_streams", "a", "-show_entries", "stream=index", "-of", "csv=p=0")
+	if err != nil {
+		return false, err
+	}
+
+	return output != "", nil
+}
+
+type videoStream struct {
+	Codec     string
+	Width     int
+	Height    int
+	BitRate   int
+	FrameRate float64
+}
+
+// probeVideoStream returns the codec, resolution, bitrate and frame rate of the first video stream
+func probeVideoStream(ctx context.Context, inputPath string) (*videoStream, error) {
+	output, err := probe(ctx, inputPath, "-select_streams", "v:0", "-show_entries", "stream=codec_name,width,height,bit_rate,avg_frame_rate", "-of", "default=noprint_wrappers=1")
+	if err != nil {
+		return nil, err
+	}
+
+	stream := videoStream{}
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "codec_name":
+			stream.Codec = value
+		case "width":
+			stream.Width, _ = strconv.Atoi(value)
+		case "height":
+			stream.Height, _ = strconv.Atoi(value)
+		case "bit_rate":
+			stream.BitRate, _ = strconv.Atoi(value)
+		case "avg_frame_rate":
+			stream.FrameRate = parseFrameRate(value)
+		}
+	}
+
+	if stream.Codec == "" || stream.Width == 0 || stream.Height == 0 {
+		return nil, fmt.Errorf("unexpected ffprobe output: %s", output)
+	}
+
+	// MKVやWebMはストリーム単位のビットレートを持たないため、コンテナ全体の値で代用する
+	if stream.BitRate == 0 {
+		output, err := probe(ctx, inputPath, "-show_entries", "format=bit_rate", "-of", "default=noprint_wrappers=1:nokey=1")
+		if err == nil {
+			stream.BitRate, _ = strconv.Atoi(output)
+		}
+	}
+
+	return &stream, nil
+}
+
+// parseFrameRate converts ffprobe's "30000/1001" notation into frames per second
+func parseFrameRate(value string) float64 {
+	numerator, denominator, ok := strings.Cut(value, "/")
+	if !ok {
+		rate, _ := strconv.ParseFloat(value, 64)
+		return rate
+	}
+
+	n, err := strconv.ParseFloat(numerator, 64)
+	if err != nil {
+		return 0
+	}
+	d, err := strconv.ParseFloat(denominator, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+
+	return n / d
+}
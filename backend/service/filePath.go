@@ -0,0 +1,93 @@
+package service
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper/validate"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+// "a/b/c.txt" のようなパスを、ルートから名前をたどって行に解決する。ルートの場合はnilを返す
+// 同じ名前の行が複数ある場合は最も古いものを使う
+func resolveFilePath(conn *sqlx.DB, fileRepo repository.FileRepositoryInterface, user user.User, name string) (*file.File, error) {
+	name = strings.Trim(path.Clean("/"+name), "/")
+	if name == "" {
+		return nil, nil
+	}
+
+	var parentDirectoryID *string
+	var f *file.File
+	for _, segment := range strings.Split(name, "/") {
+		if f != nil && file.FileKindFromEnString(f.Kind) != file.Directory {
+			return nil, os.ErrNotExist
+		}
+
+		child, err := fileRepo.GetChildFileByName(conn, user, parentDirectoryID, segment)
+		if err != nil {
+			var notFoundErr repository.NotFoundError
+			if errors.As(err, &notFoundErr) {
+				return nil, os.ErrNotExist
+			}
+			return nil, err
+		}
+
+		f = child
+		parentDirectoryID = &child.ID
+	}
+
+	return f, nil
+}
+
+// 親ディレクトリのIDと、その中での名前を返す
+func resolveParentFilePath(conn *sqlx.DB, fileRepo repository.FileRepositoryInterface, user user.User, name string) (*string, string, error) {
+	name = path.Clean("/" + name)
+	if name == "/" {
+		return nil, "", os.ErrPermission
+	}
+
+	parent, err := resolveFilePath(conn, fileRepo, user, path.Dir(name))
+	if err != nil {
+		return nil, "", err
+	}
+	if parent == nil {
+		return nil, path.Base(name), nil
+	}
+	if file.FileKindFromEnString(parent.Kind) != file.Directory {
+		return nil, "", os.ErrNotExist
+	}
+
+	return &parent.ID, path.Base(name), nil
+}
+
+// アップロードで受け取ったファイル名を検証する
+func validateFileName(name string) error {
+	if name == "" || name == "." || name == ".." || len(name) > 255 || strings.ContainsAny(name, "/\x00") {
+		return validate.ValidationError{Code: 400, Message: "ファイル名が正しくありません。"}
+	}
+
+	return nil
+}
+
+// 保存先のディレクトリが存在することを確認する。nilの場合はルート
+func checkParentDirectory(conn *sqlx.DB, fileRepo repository.FileRepositoryInterface, user user.User, parentDirectoryID *string) error {
+	if parentDirectoryID == nil {
+		return nil
+	}
+
+	parent, err := fileRepo.GetFileByID(conn, user, *parentDirectoryID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if parent.ID == "" || file.FileKindFromEnString(parent.Kind) != file.Directory {
+		return errors.WithStack(repository.NotFoundError{Code: 404, Message: "ディレクトリが見つかりません。"})
+	}
+
+	return nil
+}
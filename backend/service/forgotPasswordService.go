@@ -0,0 +1,55 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/mailer"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+type ForgotPasswordService struct {
+	Conn             *sqlx.DB
+	UserRepo         repository.UserRepositoryInterface
+	UserTokenRepo    repository.UserTokenRepositoryInterface
+	Mailer           mailer.Mailer
+	RateLimitService RateLimitService
+}
+
+// パスワードの再設定用のリンクをメールで送る
+// 登録されているメールアドレスかを推測されないよう、存在しない場合も成功として扱う
+func (service *ForgotPasswordService) Execute(email string, ipAddress string, now time.Time) error {
+	if err := service.RateLimitService.CheckPasswordReset(ipAddress); err != nil {
+		return errors.WithStack(err)
+	}
+
+	owner, err := service.UserRepo.GetUserByEmail(service.Conn, email)
+	if err != nil {
+		var notFoundErr repository.NotFoundError
+		if errors.As(err, &notFoundErr) {
+			return nil
+		}
+		return errors.WithStack(err)
+	}
+
+	token, err := issueUserToken(service.Conn, service.UserTokenRepo, *owner, user.TokenPurposePasswordReset, now)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	sendMailInBackground(service.Mailer, mailer.Message{
+		To:      owner.Email,
+		Subject: "パスワードの再設定",
+		Body: fmt.Sprintf(
+			"以下のリンクからパスワードを再設定してください。リンクの有効期限は%d分です。\n\n%s\n\nこのメールに心当たりが無い場合は破棄してください。\n",
+			int(user.PasswordResetTokenTimeout.Minutes()),
+			frontendUrl("/password/reset", token),
+		),
+	})
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/job"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+type GenerateDerivativesService struct {
+	FileRepo         repository.FileRepositoryInterface
+	ThumbnailService ThumbnailService
+}
+
+func (service *GenerateDerivativesService) Execute(ctx context.Context, kind file.FileKind, localPath string) error {
+	if !kind.HasDerivatives() {
+		return nil
+	}
+
+	derivativeDir, err := service.FileRepo.GetDerivativeDir(localPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return service.ThumbnailService.GenerateDerivatives(ctx, kind, localPath, derivativeDir)
+}
+
+func (service *GenerateDerivativesService) Handle(ctx context.Context, j job.Job) (any, error) {
+	var payload job.BlobPayload
+	if err := json.Unmarshal(j.Payload, &payload); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// 動画の圧縮で拡張子が変わることがあるため、実行時に実体のパスを解決する
+	localPath, err := service.FileRepo.FindBlobPath(payload.BlobID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return nil, service.Execute(ctx, file.FileKindFromEnString(payload.Kind), localPath)
+}
@@ -0,0 +1,18 @@
+package service
+
+import (
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/apitoken"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+type GetApiTokensService struct {
+	Conn         *sqlx.DB
+	ApiTokenRepo repository.ApiTokenRepositoryInterface
+}
+
+func (service *GetApiTokensService) Execute(user user.User) ([]apitoken.ApiToken, error) {
+	return service.ApiTokenRepo.GetApiTokens(service.Conn, user)
+}
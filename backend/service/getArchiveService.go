@@ -0,0 +1,54 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/job"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+type GetArchiveService struct {
+	Conn    *sqlx.DB
+	JobRepo repository.JobRepositoryInterface
+}
+
+// ジョブで作成したアーカイブを返す
+func (service *GetArchiveService) Execute(user user.User, jobID string) (*ServedFile, error) {
+	notFoundErr := errors.WithStack(repository.NotFoundError{Code: 404, Message: "アーカイブが見つかりません。"})
+
+	j, err := service.JobRepo.GetJobByID(service.Conn, user, jobID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if j.Type != job.TypeCreateArchive || j.Status != job.StatusSucceeded {
+		return nil, notFoundErr
+	}
+
+	var result job.ArchiveResult
+	if err := json.Unmarshal(j.Result, &result); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	format, ok := file.ArchiveFormatFromString(result.Format)
+	if !ok {
+		return nil, notFoundErr
+	}
+
+	// 保持期間を過ぎて削除された場合
+	archivePath := ArchivePath(j.ID, format)
+	if _, err := os.Stat(archivePath); err != nil {
+		return nil, notFoundErr
+	}
+
+	return &ServedFile{
+		Path:     archivePath,
+		Filename: result.Name,
+		MimeType: format.MimeType(),
+	}, nil
+}
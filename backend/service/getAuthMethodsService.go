@@ -0,0 +1,23 @@
+package service
+
+import (
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+// ログイン画面に表示するログイン方法
+type AuthMethods struct {
+	Password bool `json:"password"`
+	Oidc     bool `json:"oidc"`
+}
+
+type GetAuthMethodsService struct {
+	UserRepo repository.UserRepositoryInterface
+	OidcRepo repository.OidcRepositoryInterface
+}
+
+func (service *GetAuthMethodsService) Execute() AuthMethods {
+	return AuthMethods{
+		Password: !service.UserRepo.GetAuthSetting().DisablePasswordLogin,
+		Oidc:     service.OidcRepo.IsEnabled(),
+	}
+}
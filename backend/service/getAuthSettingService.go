@@ -0,0 +1,14 @@
+package service
+
+import (
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+type GetAuthSettingService struct {
+	UserRepo repository.UserRepositoryInterface
+}
+
+func (service *GetAuthSettingService) Execute() user.AuthSetting {
+	return service.UserRepo.GetAuthSetting()
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+const HLSMasterPlaylist = "master.m3u8"
+
+var hlsResourceNamePattern = regexp.MustCompile(`^(index\.m3u8|segment_\d+\.ts)$`)
+
+type GetHLSResourceService struct {
+	Conn     *sqlx.DB
+	FileRepo repository.FileRepositoryInterface
+}
+
+// rendition が空の場合はマスタープレイリストを返す
+func (service *GetHLSResourceService) Execute(user user.User, fileID string, rendition string, name string) (string, error) {
+	notFoundErr := errors.WithStack(repository.NotFoundError{Code: 404, Message: "ファイルが見つかりません。"})
+
+	resource := HLSMasterPlaylist
+	if rendition != "" {
+		if _, ok := service.FileRepo.GetVideoSetting().FindRendition(rendition); !ok || !hlsResourceNamePattern.MatchString(name) {
+			return "", notFoundErr
+		}
+		resource = filepath.Join(rendition, name)
+	}
+
+	f, err := service.FileRepo.GetFileByID(service.Conn, user, fileID)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if f.ID == "" {
+		return "", notFoundErr
+	}
+
+	if file.FileKindFromEnString(f.Kind) != file.Video {
+		return "", errors.WithStack(UnsupportedFileKindError{Code: 400, Message: "ストリーミング再生は動画のみ対応しています。"})
+	}
+
+	localPath, err := service.FileRepo.GetLocalPath(*f)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	derivativeDir, err := service.FileRepo.GetDerivativeDir(localPath)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	resourcePath := filepath.Join(derivativeDir, "hls", resource)
+	if _, err := os.Stat(resourcePath); err != nil {
+		// 変換が完了するまではプレイリストが存在しない
+		return "", errors.WithStack(repository.NotFoundError{Code: 404, Message: "ストリーミング用の動画を準備中です。"})
+	}
+
+	return resourcePath, nil
+}
@@ -0,0 +1,18 @@
+package service
+
+import (
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/job"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+type GetJobService struct {
+	Conn    *sqlx.DB
+	JobRepo repository.JobRepositoryInterface
+}
+
+func (service *GetJobService) Execute(user user.User, id string) (*job.Job, error) {
+	return service.JobRepo.GetJobByID(service.Conn, user, id)
+}
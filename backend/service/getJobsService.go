@@ -0,0 +1,18 @@
+package service
+
+import (
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/job"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+type GetJobsService struct {
+	Conn    *sqlx.DB
+	JobRepo repository.JobRepositoryInterface
+}
+
+func (service *GetJobsService) Execute(user user.User, currentPageCount int, pageSize int) (*job.PaginationJobs, error) {
+	return service.JobRepo.GetJobs(service.Conn, user, currentPageCount, pageSize)
+}
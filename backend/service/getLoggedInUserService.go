@@ -1,6 +1,9 @@
 package service
 
 import (
+	"log"
+	"time"
+
 	"github.com/cockroachdb/errors"
 
 	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
@@ -9,15 +12,58 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+// 最終使用日時を記録する間隔。リクエストごとに更新しないようにする
+const userSessionLastSeenInterval = time.Minute
+
 type GetLoggedInUserService struct {
-	Conn     *sqlx.DB
-	UserRepo repository.UserRepositoryInterface
+	Conn            *sqlx.DB
+	UserRepo        repository.UserRepositoryInterface
+	UserSessionRepo repository.UserSessionRepositoryInterface
 }
 
 func (service *GetLoggedInUserService) Execute(sess *session.Session) (*user.User, error) {
-	user, err := service.UserRepo.GetLoggedInUser(service.Conn, sess)
+	id := loggedInSessionID(sess)
+	if id == "" {
+		return nil, errors.WithStack(repository.NotFoundError{Code: 404, Message: "IDが存在しません。"})
+	}
+
+	s, err := service.UserSessionRepo.GetUserSession(service.Conn, id)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+
+	now := time.Now()
+	if s.IsExpired(now) {
+		return nil, errors.WithStack(repository.NotFoundError{Code: 404, Message: "セッションの有効期限が切れています。"})
+	}
+
+	if now.Sub(s.LastSeenAt) >= userSessionLastSeenInterval {
+		if err := service.UserSessionRepo.UpdateUserSessionLastSeenAt(service.Conn, s.ID, now); err != nil {
+			log.Printf("Warning: Failed to update last seen time of session %s: %v", s.ID, err)
+		}
+	}
+
+	user, err := service.UserRepo.GetUserByID(service.Conn, s.UserID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if user.IsDisabled() {
+		return nil, errors.WithStack(UserDisabledError{Code: 403, Message: "このアカウントは無効になっています。"})
+	}
 	return user, nil
 }
+
+// Cookieのセッションに保存した、user_sessionsの行のID
+func loggedInSessionID(sess *session.Session) string {
+	switch id := sess.Get("id").(type) {
+	case string:
+		return id
+	case *string:
+		// 以前はポインタのまま保存していた
+		if id != nil {
+			return *id
+		}
+	}
+
+	return ""
+}
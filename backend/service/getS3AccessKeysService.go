@@ -0,0 +1,18 @@
+package service
+
+import (
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/s3"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+type GetS3AccessKeysService struct {
+	Conn   *sqlx.DB
+	S3Repo repository.S3RepositoryInterface
+}
+
+func (service *GetS3AccessKeysService) Execute(user user.User) ([]s3.AccessKey, error) {
+	return service.S3Repo.GetAccessKeys(service.Conn, user)
+}
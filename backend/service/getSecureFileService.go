@@ -0,0 +1,36 @@
+package service
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+type GetSecureFileService struct {
+	Conn                     *sqlx.DB
+	FileRepo                 repository.FileRepositoryInterface
+	GetServedOriginalService GetServedOriginalService
+}
+
+// ログインユーザーが参照しているblobの、配信するファイルを返す
+func (service *GetSecureFileService) Execute(user user.User, blobID string) (*ServedFile, error) {
+	// 所有は利用者が指定できるURLではなく、アップロードの処理が記録した実体のIDで確認する
+	f, err := service.FileRepo.GetFileByBlobID(service.Conn, user, blobID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	localPath, err := service.FileRepo.FindBlobPath(blobID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	servedPath, err := service.GetServedOriginalService.Execute(user, localPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return newServedFile(service.FileRepo, *f, servedPath)
+}
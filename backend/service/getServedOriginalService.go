@@ -0,0 +1,73 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper/exif"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+var stripLocationLocks sync.Map
+
+type GetServedOriginalService struct {
+	FileRepo repository.FileRepositoryInterface
+}
+
+// 所有者の設定に応じて、配信する元ファイルのパスを返す。
+// 位置情報を取り除く設定の場合は、位置情報を消したコピーを派生ファイルとして作成して返す
+func (service *GetServedOriginalService) Execute(owner user.User, localPath string) (string, error) {
+	if !owner.StripLocationMetadata || file.FileKindFromFilename(localPath) != file.Image {
+		return localPath, nil
+	}
+
+	derivativeDir, err := service.FileRepo.GetDerivativeDir(localPath)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	ext := filepath.Ext(localPath)
+	strippedPath := filepath.Join(derivativeDir, "original_without_location"+ext)
+	// 位置情報が無いことを確認済みであることを示す印
+	noLocationPath := filepath.Join(derivativeDir, "original_without_location.none")
+
+	lock, _ := stripLocationLocks.LoadOrStore(strippedPath, &sync.Mutex{})
+	mutex := lock.(*sync.Mutex)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, err := os.Stat(strippedPath); err == nil {
+		return strippedPath, nil
+	}
+	if _, err := os.Stat(noLocationPath); err == nil {
+		return localPath, nil
+	}
+
+	tmpPath := strings.TrimSuffix(strippedPath, ext) + ".tmp" + ext
+	defer os.Remove(tmpPath)
+
+	stripped, err := exif.StripLocation(localPath, tmpPath)
+	if err != nil {
+		return "", errors.WithStack(fmt.Errorf("failed to strip location metadata: %v", err))
+	}
+
+	if !stripped {
+		if err := os.WriteFile(noLocationPath, nil, 0644); err != nil {
+			return "", errors.WithStack(err)
+		}
+		return localPath, nil
+	}
+
+	if err := os.Rename(tmpPath, strippedPath); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return strippedPath, nil
+}
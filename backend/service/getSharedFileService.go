@@ -0,0 +1,109 @@
+package service
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/share"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+// パスワード付きの共有リンクで、パスワードの入力後に発行する署名の対象パス
+func SharePath(token string) string {
+	return "/s/" + token
+}
+
+// パスワード入力後に発行された署名
+type ShareGrant struct {
+	Expires   int64
+	Signature string
+}
+
+// 共有リンクから参照されたファイルと、その所有者
+type SharedFile struct {
+	Share share.Share
+	Owner user.User
+	File  file.File
+}
+
+type GetSharedFileService struct {
+	Conn      *sqlx.DB
+	UserRepo  repository.UserRepositoryInterface
+	FileRepo  repository.FileRepositoryInterface
+	ShareRepo repository.ShareRepositoryInterface
+}
+
+// 共有リンクを検証し、共有されたファイルを返す。
+// fileIDを指定した場合は、共有されたフォルダ配下のファイルを返す
+func (service *GetSharedFileService) Execute(token string, grant ShareGrant, fileID *string) (*SharedFile, error) {
+	s, err := service.ShareRepo.GetShareByToken(service.Conn, token)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := checkShareAvailable(*s); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if s.HasPassword && !helper.VerifySignedPath(SharePath(token), grant.Expires, grant.Signature) {
+		return nil, errors.WithStack(SharePasswordRequiredError{Code: 401, Message: "パスワードを入力してください。"})
+	}
+
+	owner, err := service.UserRepo.GetUserByID(service.Conn, s.UserID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	root, err := service.FileRepo.GetFileByID(service.Conn, *owner, s.FileID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// 共有後に削除された場合
+	if root.ID == "" {
+		return nil, errors.WithStack(repository.NotFoundError{Code: 404, Message: "共有リンクが見つかりません。"})
+	}
+
+	if fileID == nil || *fileID == "" || *fileID == root.ID {
+		return &SharedFile{Share: *s, Owner: *owner, File: *root}, nil
+	}
+
+	if file.FileKindFromEnString(root.Kind) != file.Directory {
+		return nil, errors.WithStack(repository.NotFoundError{Code: 404, Message: "ファイルが見つかりません。"})
+	}
+
+	isDescendant, err := service.FileRepo.IsDescendantFile(service.Conn, *owner, root.ID, *fileID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if !isDescendant {
+		return nil, errors.WithStack(repository.NotFoundError{Code: 404, Message: "ファイルが見つかりません。"})
+	}
+
+	target, err := service.FileRepo.GetFileByID(service.Conn, *owner, *fileID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if target.ID == "" {
+		return nil, errors.WithStack(repository.NotFoundError{Code: 404, Message: "ファイルが見つかりません。"})
+	}
+
+	return &SharedFile{Share: *s, Owner: *owner, File: *target}, nil
+}
+
+func checkShareAvailable(s share.Share) error {
+	// 取り消し済みのリンクは存在しないものとして扱う
+	if s.IsRevoked() {
+		return repository.NotFoundError{Code: 404, Message: "共有リンクが見つかりません。"}
+	}
+
+	if s.IsExpired(time.Now()) {
+		return ShareExpiredError{Code: 410, Message: "共有リンクの有効期限が切れています。"}
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+type GetSharedFilesService struct {
+	Conn     *sqlx.DB
+	FileRepo repository.FileRepositoryInterface
+}
+
+// 共有されたフォルダ(または配下のフォルダ)の中身を返す
+func (service *GetSharedFilesService) Execute(shared SharedFile, currentPageCount int, pageSize int) (*file.PaginationFiles, error) {
+	if file.FileKindFromEnString(shared.File.Kind) != file.Directory {
+		return nil, errors.WithStack(UnsupportedFileKindError{Code: 400, Message: "フォルダではありません。"})
+	}
+
+	return service.FileRepo.GetFiles(service.Conn, shared.Owner, &shared.File.ID, file.OrderName, currentPageCount, pageSize)
+}
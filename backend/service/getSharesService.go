@@ -0,0 +1,18 @@
+package service
+
+import (
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/share"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+type GetSharesService struct {
+	Conn      *sqlx.DB
+	ShareRepo repository.ShareRepositoryInterface
+}
+
+func (service *GetSharesService) Execute(user user.User, fileID *string, currentPageCount int, pageSize int) (*share.PaginationShares, error) {
+	return service.ShareRepo.GetShares(service.Conn, user, fileID, currentPageCount, pageSize)
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+type GetSpriteService struct {
+	Conn             *sqlx.DB
+	FileRepo         repository.FileRepositoryInterface
+	ThumbnailService ThumbnailService
+}
+
+func (service *GetSpriteService) Execute(user user.User, fileID string) (string, error) {
+	f, err := service.FileRepo.GetFileByID(service.Conn, user, fileID)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if f.ID == "" {
+		return "", errors.WithStack(repository.NotFoundError{Code: 404, Message: "ファイルが見つかりません。"})
+	}
+
+	if file.FileKindFromEnString(f.Kind) != file.Video {
+		return "", errors.WithStack(UnsupportedFileKindError{Code: 400, Message: "スプライト画像は動画のみ対応しています。"})
+	}
+
+	localPath, err := service.FileRepo.GetLocalPath(*f)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	derivativeDir, err := service.FileRepo.GetDerivativeDir(localPath)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	spritePath, err := service.ThumbnailService.GetSprite(context.Background(), localPath, derivativeDir)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return spritePath, nil
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+type GetThumbnailService struct {
+	Conn             *sqlx.DB
+	FileRepo         repository.FileRepositoryInterface
+	ThumbnailService ThumbnailService
+}
+
+func (service *GetThumbnailService) Execute(user user.User, fileID string, size int) (string, error) {
+	f, err := service.FileRepo.GetFileByID(service.Conn, user, fileID)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if f.ID == "" {
+		return "", errors.WithStack(repository.NotFoundError{Code: 404, Message: "ファイルが見つかりません。"})
+	}
+
+	kind := file.FileKindFromEnString(f.Kind)
+	if !kind.HasDerivatives() {
+		return "", errors.WithStack(UnsupportedFileKindError{Code: 400, Message: "サムネイルに対応していないファイルです。"})
+	}
+
+	localPath, err := service.FileRepo.GetLocalPath(*f)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	derivativeDir, err := service.FileRepo.GetDerivativeDir(localPath)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	// 存在しない場合はここで再生成される
+	thumbnailPath, err := service.ThumbnailService.GetThumbnail(context.Background(), kind, localPath, derivativeDir, size)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return thumbnailPath, nil
+}
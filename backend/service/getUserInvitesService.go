@@ -0,0 +1,23 @@
+package service
+
+import (
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+type GetUserInvitesService struct {
+	Conn           *sqlx.DB
+	UserInviteRepo repository.UserInviteRepositoryInterface
+}
+
+func (service *GetUserInvitesService) Execute() ([]user.Invite, error) {
+	invites, err := service.UserInviteRepo.GetUserInvites(service.Conn)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return invites, nil
+}
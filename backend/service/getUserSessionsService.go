@@ -0,0 +1,40 @@
+package service
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"github.com/jmoiron/sqlx"
+)
+
+type GetUserSessionsService struct {
+	Conn            *sqlx.DB
+	UserSessionRepo repository.UserSessionRepositoryInterface
+}
+
+// 有効なセッションの一覧。リクエストしたセッションにはCurrentを付ける
+func (service *GetUserSessionsService) Execute(owner user.User, sess *session.Session) ([]user.Session, error) {
+	sessions, err := service.UserSessionRepo.GetUserSessions(service.Conn, owner)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	currentID := loggedInSessionID(sess)
+	now := time.Now()
+
+	activeSessions := []user.Session{}
+	for _, s := range sessions {
+		if s.IsExpired(now) {
+			continue
+		}
+
+		s.Current = s.ID == currentID
+		activeSessions = append(activeSessions, s)
+	}
+
+	return activeSessions, nil
+}
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+type GetUsersService struct {
+	Conn     *sqlx.DB
+	UserRepo repository.UserRepositoryInterface
+}
+
+func (service *GetUsersService) Execute(currentPageCount int, pageSize int) (*user.PaginationUsers, error) {
+	users, err := service.UserRepo.GetUsers(service.Conn, currentPageCount, pageSize)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return users, nil
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"log"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/helper/exif"
+)
+
+// applyImageMetadata sets the capture time, dimensions and EXIF metadata of the image to f
+func applyImageMetadata(f *file.File, localPath string) {
+	e, err := exif.Read(localPath)
+	if err != nil {
+		log.Printf("Failed to read exif of %s: %v", localPath, err)
+		return
+	}
+
+	// 一覧では表示される向きでの大きさを使う
+	width, height := e.DisplaySize()
+	if width > 0 && height > 0 {
+		f.Width = &width
+		f.Height = &height
+	}
+	f.TakenAt = e.TakenAt
+
+	metadata := file.Metadata{
+		Make:        e.Make,
+		Model:       e.Model,
+		LensModel:   e.LensModel,
+		Orientation: e.Orientation,
+	}
+	if e.GPS != nil {
+		metadata.GPS = &file.GPS{
+			Latitude:  e.GPS.Latitude,
+			Longitude: e.GPS.Longitude,
+			Altitude:  e.GPS.Altitude,
+		}
+	}
+	f.Metadata = &metadata
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"github.com/jmoiron/sqlx"
+)
+
+// なりすます前の管理者のセッションのIDをCookieのセッションに保存するキー
+const impersonatorSessionIDKey = "impersonator_session_id"
+
+type ImpersonateUserService struct {
+	Conn            *sqlx.DB
+	UserRepo        repository.UserRepositoryInterface
+	UserSessionRepo repository.UserSessionRepositoryInterface
+}
+
+// 管理者以外のユーザーとしてログインする。作成したセッションには管理者のIDを残し、本人のセッション一覧にも表示する
+func (service *ImpersonateUserService) Execute(sess *session.Session, admin user.User, id string, userAgent string, ipAddress string, now time.Time) (*user.User, error) {
+	target, err := adminTargetUser(service.Conn, service.UserRepo, admin, id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if target.IsAdmin() {
+		return nil, errors.WithStack(AdminOperationError{Code: 403, Message: "管理者にはなりすませません。"})
+	}
+	if target.IsDisabled() {
+		return nil, errors.WithStack(UserDisabledError{Code: 403, Message: "このアカウントは無効になっています。"})
+	}
+
+	// なりすましている間に、さらになりすますことはできない
+	if impersonatorSessionID(sess) != "" {
+		return nil, errors.WithStack(AdminOperationError{Code: 400, Message: "なりすましを終了してから操作してください。"})
+	}
+
+	sessionId, err := helper.GenerateSnowflake()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if len(userAgent) > userSessionMaxUserAgentLength {
+		userAgent = userAgent[:userSessionMaxUserAgentLength]
+	}
+
+	s := userSession(*sessionId, target.ID, userAgent, ipAddress, now)
+	s.ExpiresAt = now.Add(user.ImpersonationTimeout)
+	s.ImpersonatorID = &admin.ID
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if _, err := service.UserSessionRepo.RegistrationUserSession(tx, s); err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// 管理者のセッションは削除せず、なりすましを終了したときに戻す
+	sess.Set(impersonatorSessionIDKey, loggedInSessionID(sess))
+	sess.Set("id", *sessionId)
+	if err := sess.Save(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	log.Printf("Admin %s started impersonating user %s", admin.ID, target.ID)
+
+	return target, nil
+}
+
+// なりすましのセッションを削除し、管理者のセッションに戻す
+// なりすましのセッションの期限が切れていても戻せるよう、ログインしているかは確認しない
+func (service *ImpersonateUserService) Stop(sess *session.Session) error {
+	adminSessionID := impersonatorSessionID(sess)
+	if adminSessionID == "" {
+		return errors.WithStack(AdminOperationError{Code: 400, Message: "なりすましていません。"})
+	}
+
+	s, err := service.UserSessionRepo.GetUserSession(service.Conn, loggedInSessionID(sess))
+	if err != nil {
+		var notFoundError repository.NotFoundError
+		if !errors.As(err, &notFoundError) {
+			return errors.WithStack(err)
+		}
+	}
+
+	if s != nil {
+		tx, err := service.Conn.Beginx()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if err := service.UserSessionRepo.DeleteUserSession(tx, user.User{ID: s.UserID}, s.ID); err != nil {
+			tx.Rollback()
+			return errors.WithStack(err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return errors.WithStack(err)
+		}
+
+		log.Printf("Stopped impersonating user %s", s.UserID)
+	}
+
+	sess.Set("id", adminSessionID)
+	sess.Delete(impersonatorSessionIDKey)
+	if err := sess.Save(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+func impersonatorSessionID(sess *session.Session) string {
+	id, _ := sess.Get(impersonatorSessionIDKey).(string)
+	return id
+}
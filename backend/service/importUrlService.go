@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/job"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+// リダイレクトをたどる回数の上限
+const importMaxRedirects = 10
+
+// ImportUrlService downloads the URL of a registered file into the blob store and points the row at the stored copy.
+type ImportUrlService struct {
+	Conn             *sqlx.DB
+	UserRepo         repository.UserRepositoryInterface
+	FileRepo         repository.FileRepositoryInterface
+	JobRepo          repository.JobRepositoryInterface
+	StoreBlobService StoreBlobService
+}
+
+// Handle downloads the URL of an import_url job within the size and time limits of the import setting.
+// The kind and MIME type are sniffed from the downloaded content, and the blob jobs are enqueued like an upload.
+func (service *ImportUrlService) Handle(ctx context.Context, j job.Job) (any, error) {
+	var payload job.ImportPayload
+	if err := json.Unmarshal(j.Payload, &payload); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	owner, err := service.UserRepo.GetUserByID(service.Conn, j.UserID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	importFile, err := service.FileRepo.GetFileByID(service.Conn, *owner, payload.FileID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if importFile.ID == "" {
+		return nil, errors.WithStack(repository.NotFoundError{Code: 404, Message: "ファイルが見つかりません。"})
+	}
+
+	setting := service.FileRepo.GetImportSetting()
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(setting.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, payload.Url, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	res, err := newImportHttpClient(setting).Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, errors.Newf("unexpected status: %s", res.Status)
+	}
+	if res.ContentLength > setting.MaxSize {
+		return nil, errors.Newf("file too large: %d bytes", res.ContentLength)
+	}
+
+	// 容量を超える分は書き込まない
+	maxSize := setting.MaxSize
+	remaining, limited, err := service.StoreBlobService.QuotaService.Remaining(*owner)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if limited {
+		if res.ContentLength > remaining {
+			return nil, errors.WithStack(quotaExceededError())
+		}
+		maxSize = min(maxSize, remaining)
+	}
+
+	blob, err := service.StoreBlobService.Create(importFile.Name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	progress := &importProgress{service: service, jobID: j.ID, progress: job.Progress{Total: max(res.ContentLength, 0), Unit: "bytes"}}
+
+	// 上限を1バイト超えて読めた場合は大きすぎる
+	written, err := io.Copy(io.MultiWriter(blob, progress), io.LimitReader(res.Body, maxSize+1))
+	if err != nil {
+		blob.Remove()
+		return nil, errors.WithStack(err)
+	}
+	if written > maxSize {
+		blob.Remove()
+		if maxSize < setting.MaxSize {
+			return nil, errors.WithStack(quotaExceededError())
+		}
+		return nil, errors.Newf("file too large: more than %d bytes", setting.MaxSize)
+	}
+	progress.save(true)
+
+	if err := blob.Close(); err != nil {
+		blob.Remove()
+		return nil, errors.WithStack(err)
+	}
+
+	// 取り込み中に削除・移動されていないか確認してから差し替える
+	importFile, err = service.FileRepo.GetFileByID(service.Conn, *owner, payload.FileID)
+	if err != nil || importFile.ID == "" {
+		blob.Remove()
+		return nil, errors.WithStack(repository.NotFoundError{Code: 404, Message: "ファイルが見つかりません。"})
+	}
+
+	registered, err := service.StoreBlobService.Register(*owner, importFile.ParentDirectoryID, importFile.Name, importFile, *blob)
+	if err != nil {
+		blob.Remove()
+		return nil, errors.WithStack(err)
+	}
+
+	return job.ImportResult{
+		FileID:   registered.ID,
+		Url:      *registered.Url,
+		MimeType: *registered.MimeType,
+		Kind:     registered.Kind,
+		Size:     written,
+	}, nil
+}
+
+// プライベートなアドレスへ接続しないクライアント。名前解決した後のアドレスを接続の直前に確認するため、リダイレクト先やDNSの再バインドも防げる
+func newImportHttpClient(setting file.ImportSetting) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || !setting.IsAllowedIP(ip) {
+				return errors.Newf("connection to %s is not allowed", host)
+			}
+
+			return nil
+		},
+	}
+	allowedDialer := &net.Dialer{Timeout: 30 * time.Second}
+
+	transport := &http.Transport{
+		// 環境変数のプロキシを経由すると接続先を確認できない
+		Proxy: nil,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if setting.IsAllowedHost(host) {
+				return allowedDialer.DialContext(ctx, network, address)
+			}
+
+			return dialer.DialContext(ctx, network, address)
+		},
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= importMaxRedirects {
+				return errors.Newf("stopped after %d redirects", importMaxRedirects)
+			}
+			if !isImportableUrl(req.URL) {
+				return errors.Newf("redirect to %s is not allowed", req.URL.Redacted())
+			}
+
+			return nil
+		},
+	}
+}
+
+func isImportableUrl(u *url.URL) bool {
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// ダウンロードしたバイト数を一定の間隔で記録する
+type importProgress struct {
+	service  *ImportUrlService
+	jobID    string
+	progress job.Progress
+	savedAt  time.Time
+}
+
+func (progress *importProgress) Write(p []byte) (int, error) {
+	progress.progress.Current += int64(len(p))
+	progress.save(false)
+
+	return len(p), nil
+}
+
+func (progress *importProgress) save(force bool) {
+	if !force && time.Since(progress.savedAt) < extractProgressInterval {
+		return
+	}
+	progress.savedAt = time.Now()
+
+	if err := progress.service.JobRepo.UpdateJobProgress(progress.service.Conn, progress.jobID, progress.progress); err != nil {
+		log.Printf("Failed to update progress of job %s: %+v", progress.jobID, err)
+	}
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/job"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+const (
+	jobPollInterval      = 2 * time.Second
+	jobHeartbeatInterval = 5 * time.Second
+	jobReapInterval      = time.Minute
+	// この時間ハートビートが無いジョブはワーカーが停止したとみなす
+	jobStaleTimeout = 2 * time.Minute
+)
+
+// JobHandler processes a claimed job. The returned value is stored as the job result.
+type JobHandler func(ctx context.Context, j job.Job) (any, error)
+
+// JobRunner polls the jobs table and runs the registered handler of each type
+// with at most the configured number of concurrent workers.
+type JobRunner struct {
+	Conn     *sqlx.DB
+	JobRepo  repository.JobRepositoryInterface
+	handlers map[job.Type]JobHandler
+}
+
+func (runner *JobRunner) Register(jobType job.Type, handler JobHandler) {
+	if runner.handlers == nil {
+		runner.handlers = map[job.Type]JobHandler{}
+	}
+	runner.handlers[jobType] = handler
+}
+
+// Start runs the workers until ctx is cancelled
+func (runner *JobRunner) Start(ctx context.Context) {
+	setting := runner.JobRepo.GetJobSetting()
+
+	var wg sync.WaitGroup
+	for jobType, handler := range runner.handlers {
+		concurrency := setting.Get(jobType).Concurrency
+		log.Printf("Starting %d job worker(s) for %s", concurrency, jobType)
+
+		for range concurrency {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				runner.work(ctx, jobType, handler)
+			}()
+		}
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runner.reap(ctx)
+	}()
+
+	wg.Wait()
+}
+
+func (runner *JobRunner) work(ctx context.Context, jobType job.Type, handler JobHandler) {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		// 取得できる限り続けて実行し、無くなったら次のポーリングまで待つ
+		for ctx.Err() == nil {
+			claimed, err := runner.JobRepo.ClaimJob(runner.Conn, jobType)
+			if err != nil {
+				log.Printf("Failed to claim %s job: %+v", jobType, err)
+				break
+			}
+			if claimed == nil {
+				break
+			}
+
+			runner.run(ctx, *claimed, handler)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (runner *JobRunner) run(ctx context.Context, claimed job.Job, handler JobHandler) {
+	log.Printf("Running job %s (%s), attempt %d/%d", claimed.ID, claimed.Type, claimed.Attempts, claimed.MaxAttempts)
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cancelRequested := make(chan struct{})
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		runner.heartbeat(jobCtx, claimed.ID, cancelRequested, cancel)
+	}()
+
+	result, err := runner.handle(jobCtx, claimed, handler)
+	cancel()
+	<-heartbeatDone
+
+	select {
+	case <-cancelRequested:
+		log.Printf("Job %s cancelled", claimed.ID)
+		if err := runner.JobRepo.FinishCancelledJob(runner.Conn, claimed.ID); err != nil {
+			log.Printf("Failed to finish cancelled job %s: %+v", claimed.ID, err)
+		}
+		return
+	default:
+	}
+
+	if err != nil {
+		log.Printf("Job %s failed: %+v", claimed.ID, err)
+		if err := runner.JobRepo.FailJob(runner.Conn, claimed, err.Error()); err != nil {
+			log.Printf("Failed to record failure of job %s: %+v", claimed.ID, err)
+		}
+		return
+	}
+
+	encodedResult, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("Failed to encode result of job %s: %+v", claimed.ID, err)
+		encodedResult = []byte("null")
+	}
+
+	if err := runner.JobRepo.CompleteJob(runner.Conn, claimed.ID, encodedResult); err != nil {
+		log.Printf("Failed to complete job %s: %+v", claimed.ID, err)
+		return
+	}
+
+	log.Printf("Job %s succeeded", claimed.ID)
+}
+
+// handle runs the handler and converts a panic into an error so that a single job cannot stop the worker
+func (runner *JobRunner) handle(ctx context.Context, claimed job.Job, handler JobHandler) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Newf("panic: %v", r)
+		}
+	}()
+
+	return handler(ctx, claimed)
+}
+
+// heartbeat keeps the job locked while it runs and cancels it when a user requests cancellation
+func (runner *JobRunner) heartbeat(ctx context.Context, id string, cancelRequested chan struct{}, cancel context.CancelFunc) {
+	ticker := time.NewTicker(jobHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		requested, err := runner.JobRepo.HeartbeatJob(runner.Conn, id)
+		if err != nil {
+			log.Printf("Failed to heartbeat job %s: %+v", id, err)
+			continue
+		}
+
+		if requested {
+			close(cancelRequested)
+			cancel()
+			return
+		}
+	}
+}
+
+// reap requeues jobs whose worker stopped (e.g. the process was restarted) while running them
+func (runner *JobRunner) reap(ctx context.Context) {
+	ticker := time.NewTicker(jobReapInterval)
+	defer ticker.Stop()
+
+	for {
+		requeued, err := runner.JobRepo.RequeueStaleJobs(runner.Conn, time.Now().Add(-jobStaleTimeout))
+		if err != nil {
+			log.Printf("Failed to requeue stale jobs: %+v", err)
+		} else if requeued > 0 {
+			log.Printf("Requeued %d stale job(s)", requeued)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,6 +1,12 @@
 package service
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"log"
+	"time"
+
 	"github.com/cockroachdb/errors"
 
 	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
@@ -10,37 +16,238 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+// 保存するUser-Agentの長さの上限
+const userSessionMaxUserAgentLength = 512
+
+// 二段階認証を待っているログインをCookieのセッションに保存するキー
+const (
+	twoFactorUserIDKey    = "two_factor_user_id"
+	twoFactorChallengeKey = "two_factor_challenge"
+	twoFactorExpiresAtKey = "two_factor_expires_at"
+	twoFactorAttemptsKey  = "two_factor_attempts"
+)
+
 type LoginService struct {
-	Conn     *sqlx.DB
-	UserRepo repository.UserRepositoryInterface
+	Conn                   *sqlx.DB
+	UserRepo               repository.UserRepositoryInterface
+	UserSessionRepo        repository.UserSessionRepositoryInterface
+	VerifyTwoFactorService VerifyTwoFactorService
+	RateLimitService       RateLimitService
 }
 
-func (service *LoginService) Execute(sess *session.Session, email string, password string) (*user.User, error) {
-	sessionId, err := helper.GenerateSnowflake()
+// ログインした端末のセッションを作成する。他の端末のセッションはそのまま残す
+// 二段階認証を有効にしている場合はセッションを作成せず、ExecuteTwoFactorに渡すチャレンジを返す
+func (service *LoginService) Execute(sess *session.Session, email string, password string, userAgent string, ipAddress string, now time.Time) (*user.User, *user.TwoFactorChallenge, error) {
+	if err := service.RateLimitService.CheckLogin(email, ipAddress); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	user, err := service.UserRepo.Login(tx, email, password)
+	if err != nil {
+		tx.Rollback()
+
+		var notFoundErr repository.NotFoundError
+		if errors.As(err, &notFoundErr) {
+			service.RateLimitService.FailLogin(email)
+		}
+
+		return nil, nil, errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	// パスワードが正しい場合のみ伝えるため、存在するかを推測されることはない
+	if user.IsDisabled() {
+		return nil, nil, errors.WithStack(UserDisabledError{Code: 403, Message: "このアカウントは無効になっています。"})
+	}
+
+	if user.TwoFactorEnabled {
+		challenge, err := service.startTwoFactorChallenge(sess, *user, now)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+
+		return nil, challenge, nil
+	}
+
+	if err := service.createSession(sess, *user, userAgent, ipAddress, now); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	service.RateLimitService.SucceedLogin(email)
+
+	return user, nil, nil
+}
+
+// Executeが返したチャレンジと認証コード(またはリカバリーコード)を確認し、セッションを作成する
+func (service *LoginService) ExecuteTwoFactor(sess *session.Session, challenge string, code string, userAgent string, ipAddress string, now time.Time) (*user.User, error) {
+	userID, _ := sess.Get(twoFactorUserIDKey).(string)
+	expected, _ := sess.Get(twoFactorChallengeKey).(string)
+	expiresAt, _ := sess.Get(twoFactorExpiresAtKey).(int64)
+	attempts, _ := sess.Get(twoFactorAttemptsKey).(int)
+
+	if userID == "" || expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) != 1 || now.Unix() >= expiresAt {
+		return nil, errors.WithStack(InvalidTwoFactorCodeError{Code: 401, Message: "ログインをやり直してください。"})
+	}
+
+	owner, err := service.UserRepo.GetUserByID(service.Conn, userID)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	if owner.IsDisabled() {
+		clearTwoFactorChallenge(sess)
+		if err := sess.Save(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return nil, errors.WithStack(UserDisabledError{Code: 403, Message: "このアカウントは無効になっています。"})
+	}
 
-	sess.Set("id", sessionId)
+	if err := service.RateLimitService.CheckAccountLock(owner.Email); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := service.VerifyTwoFactorService.Execute(*owner, code, now); err != nil {
+		// 認証コードの失敗もパスワードの失敗と同じく数える
+		service.RateLimitService.FailLogin(owner.Email)
+
+		// 失敗が続いた場合はパスワードの入力からやり直させる
+		if attempts+1 >= user.TwoFactorChallengeMaxAttempts {
+			clearTwoFactorChallenge(sess)
+		} else {
+			sess.Set(twoFactorAttemptsKey, attempts+1)
+		}
+		if err := sess.Save(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		return nil, errors.WithStack(err)
+	}
+
+	clearTwoFactorChallenge(sess)
+	if err := service.createSession(sess, *owner, userAgent, ipAddress, now); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	service.RateLimitService.SucceedLogin(owner.Email)
+
+	return owner, nil
+}
+
+// パスワード以外(OpenID Connectなど)で本人を確認したユーザーのセッションを作成する
+func (service *LoginService) StartSession(sess *session.Session, owner user.User, userAgent string, ipAddress string, now time.Time) error {
+	if err := service.createSession(sess, owner, userAgent, ipAddress, now); err != nil {
+		return errors.WithStack(err)
+	}
+	service.RateLimitService.SucceedLogin(owner.Email)
+
+	return nil
+}
+
+func (service *LoginService) startTwoFactorChallenge(sess *session.Session, owner user.User, now time.Time) (*user.TwoFactorChallenge, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	challenge := user.TwoFactorChallenge{
+		Challenge: base64.RawURLEncoding.EncodeToString(b),
+		ExpiresAt: now.Add(user.TwoFactorChallengeTimeout),
+	}
+
+	// ログイン前のIDを使い続けないよう、チャレンジを保存する前にも変更する
+	if err := sess.Regenerate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sess.Set(twoFactorUserIDKey, owner.ID)
+	sess.Set(twoFactorChallengeKey, challenge.Challenge)
+	sess.Set(twoFactorExpiresAtKey, challenge.ExpiresAt.Unix())
+	sess.Set(twoFactorAttemptsKey, 0)
 
 	if err := sess.Save(); err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	tx, err := service.Conn.Beginx()
+	return &challenge, nil
+}
+
+func clearTwoFactorChallenge(sess *session.Session) {
+	sess.Delete(twoFactorUserIDKey)
+	sess.Delete(twoFactorChallengeKey)
+	sess.Delete(twoFactorExpiresAtKey)
+	sess.Delete(twoFactorAttemptsKey)
+}
+
+func (service *LoginService) createSession(sess *session.Session, owner user.User, userAgent string, ipAddress string, now time.Time) error {
+	sessionId, err := helper.GenerateSnowflake()
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return errors.WithStack(err)
 	}
 
-	user, err := service.UserRepo.Login(tx, email, password, *sessionId)
+	if len(userAgent) > userSessionMaxUserAgentLength {
+		userAgent = userAgent[:userSessionMaxUserAgentLength]
+	}
+
+	tx, err := service.Conn.Beginx()
 	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if _, err := service.UserSessionRepo.RegistrationUserSession(tx, userSession(*sessionId, owner.ID, userAgent, ipAddress, now)); err != nil {
 		tx.Rollback()
-		return nil, errors.WithStack(err)
+		return errors.WithStack(err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		return nil, errors.WithStack(err)
+		return errors.WithStack(err)
+	}
+
+	service.deleteExpiredUserSessions(owner, now)
+
+	// ログイン前のCookieのIDを使い続けないよう、IDを変更してから保存する
+	if err := sess.Regenerate(); err != nil {
+		return errors.WithStack(err)
+	}
+	sess.Set("id", *sessionId)
+
+	if err := sess.Save(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+func userSession(id string, userID string, userAgent string, ipAddress string, now time.Time) user.Session {
+	return user.Session{
+		ID:         id,
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IpAddress:  ipAddress,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(user.SessionAbsoluteTimeout),
+	}
+}
+
+// 期限を過ぎたセッションを削除する
+func (service *LoginService) deleteExpiredUserSessions(owner user.User, now time.Time) {
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		log.Printf("Warning: Failed to delete expired sessions of user %s: %v", owner.ID, err)
+		return
 	}
 
-	return user, nil
+	if err := service.UserSessionRepo.DeleteExpiredUserSessions(tx, owner, now); err != nil {
+		tx.Rollback()
+		log.Printf("Warning: Failed to delete expired sessions of user %s: %v", owner.ID, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Warning: Failed to delete expired sessions of user %s: %v", owner.ID, err)
+	}
 }
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gofiber/fiber/v2/middleware/session"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+)
+
+func newTestLoginService(owner user.User) LoginService {
+	userRepo := newFakeUserRepo(owner)
+
+	return LoginService{
+		Conn:            newFakeDB(),
+		UserRepo:        userRepo,
+		UserSessionRepo: &fakeUserSessionRepo{},
+		VerifyTwoFactorService: VerifyTwoFactorService{
+			Conn:                 newFakeDB(),
+			UserRepo:             userRepo,
+			UserRecoveryCodeRepo: newFakeUserRecoveryCodeRepo(),
+		},
+		RateLimitService: RateLimitService{
+			RateLimitRepo: newFakeRateLimitRepo(),
+		},
+	}
+}
+
+// パスワードを確認し、二段階認証のチャレンジを返す
+func startTestTwoFactorChallenge(t *testing.T, service *LoginService, sessions *testSessions, now time.Time) string {
+	t.Helper()
+
+	var challenge *user.TwoFactorChallenge
+	sessions.request(t, func(sess *session.Session) {
+		loggedInUser, c, err := service.Execute(sess, "user@example.com", "password", "test", "127.0.0.1", now)
+		if err != nil {
+			t.Fatalf("Execute returned an error: %v", err)
+		}
+		if loggedInUser != nil || c == nil {
+			t.Fatalf("Execute created a session before two-factor authentication")
+		}
+		challenge = c
+	})
+
+	if want := now.Add(user.TwoFactorChallengeTimeout); !challenge.ExpiresAt.Equal(want) {
+		t.Fatalf("challenge expires at %v, want %v", challenge.ExpiresAt, want)
+	}
+
+	return challenge.Challenge
+}
+
+func TestLoginServiceTwoFactorChallenge(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	owner := user.User{
+		ID:               "1",
+		Email:            "user@example.com",
+		Password:         "password",
+		TwoFactorEnabled: true,
+		TotpSecret:       testTotpSecret,
+	}
+
+	type attempt struct {
+		// チャレンジを開始してからの経過時間
+		elapsed time.Duration
+		// 空の場合は経過後の時刻の正しいコード
+		code string
+		// 空の場合は開始したチャレンジ
+		challenge string
+		// 空の場合は成功
+		wantMessage string
+	}
+
+	const (
+		invalidCode  = "認証コードが違います。"
+		loginExpired = "ログインをやり直してください。"
+	)
+
+	tests := []struct {
+		name     string
+		attempts []attempt
+	}{
+		{
+			name:     "期限の直前までは認証できる",
+			attempts: []attempt{{elapsed: user.TwoFactorChallengeTimeout - time.Second}},
+		},
+		{
+			name:     "期限を過ぎると認証できない",
+			attempts: []attempt{{elapsed: user.TwoFactorChallengeTimeout, wantMessage: loginExpired}},
+		},
+		{
+			name:     "チャレンジが違う",
+			attempts: []attempt{{challenge: "other", wantMessage: loginExpired}},
+		},
+		{
+			name: "上限未満の失敗の後は認証できる",
+			attempts: []attempt{
+				{code: "000000", wantMessage: invalidCode},
+				{code: "000000", wantMessage: invalidCode},
+				{code: "000000", wantMessage: invalidCode},
+				{code: "000000", wantMessage: invalidCode},
+				{},
+			},
+		},
+		{
+			name: "上限まで失敗するとパスワードの入力からやり直す",
+			attempts: []attempt{
+				{code: "000000", wantMessage: invalidCode},
+				{code: "000000", wantMessage: invalidCode},
+				{code: "000000", wantMessage: invalidCode},
+				{code: "000000", wantMessage: invalidCode},
+				{code: "000000", wantMessage: invalidCode},
+				{wantMessage: loginExpired},
+			},
+		},
+		{
+			name: "認証した後はチャレンジを使えない",
+			attempts: []attempt{
+				{},
+				{wantMessage: loginExpired},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestLoginService(owner)
+			sessions := newTestSessions()
+			challenge := startTestTwoFactorChallenge(t, &service, sessions, now)
+
+			for i, a := range tt.attempts {
+				attemptedAt := now.Add(a.elapsed)
+				code := a.code
+				if code == "" {
+					code = testTotpCode(t, attemptedAt, 0)
+				}
+				attemptedChallenge := a.challenge
+				if attemptedChallenge == "" {
+					attemptedChallenge = challenge
+				}
+
+				sessions.request(t, func(sess *session.Session) {
+					loggedInUser, err := service.ExecuteTwoFactor(sess, attemptedChallenge, code, "test", "127.0.0.1", attemptedAt)
+
+					if a.wantMessage == "" {
+						if err != nil {
+							t.Fatalf("attempt %d: unexpected error: %v", i, err)
+						}
+						if loggedInUser == nil || loggedInUser.ID != owner.ID {
+							t.Fatalf("attempt %d: logged in as %v, want user %s", i, loggedInUser, owner.ID)
+						}
+						return
+					}
+
+					var invalidTwoFactorCodeError InvalidTwoFactorCodeError
+					if !errors.As(err, &invalidTwoFactorCodeError) || invalidTwoFactorCodeError.Message != a.wantMessage {
+						t.Fatalf("attempt %d: error = %v, want %q", i, err, a.wantMessage)
+					}
+				})
+			}
+		})
+	}
+}
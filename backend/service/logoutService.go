@@ -3,35 +3,35 @@ package service
 import (
 	"github.com/cockroachdb/errors"
 
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
 	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
 	"github.com/gofiber/fiber/v2/middleware/session"
 	"github.com/jmoiron/sqlx"
 )
 
 type LogoutService struct {
-	Conn     *sqlx.DB
-	UserRepo repository.UserRepositoryInterface
+	Conn            *sqlx.DB
+	UserSessionRepo repository.UserSessionRepositoryInterface
 }
 
-func (service *LogoutService) Execute(sess *session.Session) error {
+// リクエストした端末のセッションだけを削除する
+func (service *LogoutService) Execute(user user.User, sess *session.Session) error {
 	tx, err := service.Conn.Beginx()
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	user, err := service.UserRepo.GetLoggedInUser(service.Conn, sess)
+	err = service.UserSessionRepo.DeleteUserSession(tx, user, loggedInSessionID(sess))
 	if err != nil {
 		tx.Rollback()
 		return errors.WithStack(err)
 	}
 
-	err = service.UserRepo.Logout(tx, *user)
-	if err != nil {
-		tx.Rollback()
+	if err := tx.Commit(); err != nil {
 		return errors.WithStack(err)
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := sess.Destroy(); err != nil {
 		return errors.WithStack(err)
 	}
 
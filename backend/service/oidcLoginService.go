@@ -0,0 +1,219 @@
+package service
+
+import (
+	"crypto/subtle"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/oidc"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"github.com/jmoiron/sqlx"
+)
+
+type OidcLoginService struct {
+	Conn             *sqlx.DB
+	UserRepo         repository.UserRepositoryInterface
+	UserIdentityRepo repository.UserIdentityRepositoryInterface
+	OidcRepo         repository.OidcRepositoryInterface
+	LoginService     LoginService
+}
+
+// IdPからのコールバックの認可コードをIDトークンと交換し、対応するユーザーでログインする
+// 紐付けられたユーザーが無い場合は、確認済みのメールアドレスで既存のユーザーに紐付けるか、新しく作成する
+// 二段階認証を有効にしている場合はパスワードでのログインと同じく、セッションを作成せずにチャレンジを返す
+func (service *OidcLoginService) Execute(sess *session.Session, state string, code string, userAgent string, ipAddress string, now time.Time) (*user.User, *user.TwoFactorChallenge, error) {
+	expectedState, _ := sess.Get(oidcStateKey).(string)
+	nonce, _ := sess.Get(oidcNonceKey).(string)
+	codeVerifier, _ := sess.Get(oidcCodeVerifierKey).(string)
+	expiresAt, _ := sess.Get(oidcExpiresAtKey).(int64)
+
+	// 認可リクエストは1回のみ使用できる
+	sess.Delete(oidcStateKey)
+	sess.Delete(oidcNonceKey)
+	sess.Delete(oidcCodeVerifierKey)
+	sess.Delete(oidcExpiresAtKey)
+
+	if expectedState == "" || subtle.ConstantTimeCompare([]byte(expectedState), []byte(state)) != 1 || now.Unix() >= expiresAt {
+		if err := sess.Save(); err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		return nil, nil, errors.WithStack(OidcError{Code: 400, Message: "ログインをやり直してください。"})
+	}
+
+	owner, err := service.authenticate(code, codeVerifier, nonce, now)
+	if err != nil {
+		if saveErr := sess.Save(); saveErr != nil {
+			return nil, nil, errors.WithStack(saveErr)
+		}
+		return nil, nil, errors.WithStack(err)
+	}
+
+	// IdPでの認証はパスワードの代わりにしかならないため、二段階認証を省略させない
+	if owner.TwoFactorEnabled {
+		challenge, err := service.LoginService.startTwoFactorChallenge(sess, *owner, now)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+
+		return nil, challenge, nil
+	}
+
+	if err := service.LoginService.StartSession(sess, *owner, userAgent, ipAddress, now); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	return owner, nil, nil
+}
+
+func (service *OidcLoginService) authenticate(code string, codeVerifier string, nonce string, now time.Time) (*user.User, error) {
+	idToken, err := service.OidcRepo.ExchangeCode(code, codeVerifier)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(OidcError{Code: 502, Message: "IdPでの認証に失敗しました。"}, err))
+	}
+
+	claims, err := service.OidcRepo.VerifyIdToken(idToken, nonce, now)
+	if err != nil {
+		return nil, errors.WithStack(errors.Join(OidcError{Code: 401, Message: "IdPでの認証に失敗しました。"}, err))
+	}
+
+	owner, err := service.findOrCreateUser(*claims, now)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if owner.IsDisabled() {
+		return nil, errors.WithStack(UserDisabledError{Code: 403, Message: "このアカウントは無効になっています。"})
+	}
+
+	return service.syncRole(*owner, *claims)
+}
+
+func (service *OidcLoginService) findOrCreateUser(claims oidc.Claims, now time.Time) (*user.User, error) {
+	issuer := service.OidcRepo.GetIssuer()
+
+	userID, err := service.UserIdentityRepo.GetUserIDByIdentity(service.Conn, issuer, claims.Subject)
+	if err == nil {
+		return service.UserRepo.GetUserByID(service.Conn, userID)
+	}
+	var notFoundErr repository.NotFoundError
+	if !errors.As(err, &notFoundErr) {
+		return nil, errors.WithStack(err)
+	}
+
+	if claims.Email == "" {
+		return nil, errors.WithStack(OidcError{Code: 400, Message: "IdPからメールアドレスを取得できませんでした。"})
+	}
+
+	existing, err := service.UserRepo.GetUserByEmail(service.Conn, claims.Email)
+	if err != nil && !errors.As(err, &notFoundErr) {
+		return nil, errors.WithStack(err)
+	}
+
+	if existing != nil {
+		// 確認されていないメールアドレスでは、他人のアカウントを乗っ取れてしまうため紐付けない
+		if !claims.EmailVerified {
+			return nil, errors.WithStack(OidcError{Code: 409, Message: "同じメールアドレスのユーザーが存在します。IdPでメールアドレスを確認してからログインしてください。"})
+		}
+		return service.linkUser(*existing, issuer, claims, now)
+	}
+
+	if !service.OidcRepo.GetOidcSetting().AutoProvision {
+		return nil, errors.WithStack(OidcError{Code: 403, Message: "このアカウントではログインできません。"})
+	}
+	// IdPからは招待コードを受け取れないため、誰でも登録できる場合のみ作成する
+	if service.UserRepo.GetAuthSetting().Registration != user.RegistrationModeOpen {
+		return nil, errors.WithStack(RegistrationNotAllowedError{Code: 403, Message: "新規登録は受け付けていません。"})
+	}
+
+	return service.createUser(issuer, claims, now)
+}
+
+func (service *OidcLoginService) linkUser(owner user.User, issuer string, claims oidc.Claims, now time.Time) (*user.User, error) {
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := service.UserIdentityRepo.RegistrationUserIdentity(tx, owner, issuer, claims.Subject); err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if owner.EmailVerifiedAt == nil {
+		if err := service.UserRepo.UpdateEmailVerifiedAt(tx, owner, now); err != nil {
+			tx.Rollback()
+			return nil, errors.WithStack(err)
+		}
+		owner.EmailVerifiedAt = &now
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &owner, nil
+}
+
+func (service *OidcLoginService) createUser(issuer string, claims oidc.Claims, now time.Time) (*user.User, error) {
+	setting := service.OidcRepo.GetOidcSetting()
+
+	role, ok := setting.MapRole(claims.Groups)
+	if !ok {
+		role = setting.DefaultRole
+	}
+
+	var emailVerifiedAt *time.Time
+	if claims.EmailVerified {
+		emailVerifiedAt = &now
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	userID, err := service.UserRepo.RegistrationWithoutPassword(tx, claims.Email, claims.Picture, role, emailVerifiedAt)
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := service.UserIdentityRepo.RegistrationUserIdentity(tx, user.User{ID: userID}, issuer, claims.Subject); err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return service.UserRepo.GetUserByID(service.Conn, userID)
+}
+
+// ログインするたびに、IdPのグループに合わせてロールを更新する
+func (service *OidcLoginService) syncRole(owner user.User, claims oidc.Claims) (*user.User, error) {
+	role, ok := service.OidcRepo.GetOidcSetting().MapRole(claims.Groups)
+	if !ok || role == owner.Role {
+		return &owner, nil
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := service.UserRepo.UpdateRole(tx, owner, role); err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	owner.Role = role
+
+	return &owner, nil
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gofiber/fiber/v2/middleware/session"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/oidc"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+)
+
+const testOidcCode = "code"
+
+func newTestOidcLoginService(owner user.User, claims oidc.Claims) (OidcLoginService, *fakeUserSessionRepo) {
+	oidcRepo := &fakeOidcRepo{
+		setting: oidc.DefaultSetting(),
+		claims:  map[string]oidc.Claims{testOidcCode: claims},
+	}
+	identityRepo := &fakeUserIdentityRepo{userIDs: map[[2]string]string{
+		{oidcRepo.GetIssuer(), claims.Subject}: owner.ID,
+	}}
+	loginService := newTestLoginService(owner)
+	userSessionRepo := loginService.UserSessionRepo.(*fakeUserSessionRepo)
+
+	return OidcLoginService{
+		Conn:             newFakeDB(),
+		UserRepo:         loginService.UserRepo,
+		UserIdentityRepo: identityRepo,
+		OidcRepo:         oidcRepo,
+		LoginService:     loginService,
+	}, userSessionRepo
+}
+
+// OidcLoginが保存する認可リクエストをセッションに入れる
+func setTestOidcRequest(sess *session.Session, nonce string, now time.Time) {
+	sess.Set(oidcStateKey, "state")
+	sess.Set(oidcNonceKey, nonce)
+	sess.Set(oidcCodeVerifierKey, "verifier")
+	sess.Set(oidcExpiresAtKey, now.Add(oidc.AuthorizationTimeout).Unix())
+}
+
+func TestOidcLoginServiceTwoFactor(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	claims := oidc.Claims{Subject: "subject", Email: "user@example.com", EmailVerified: true, Nonce: "nonce"}
+
+	tests := []struct {
+		name             string
+		twoFactorEnabled bool
+	}{
+		{name: "二段階認証が無効な場合はセッションを作成する"},
+		{name: "二段階認証が有効な場合はチャレンジを返す", twoFactorEnabled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner := user.User{ID: "1", Email: "user@example.com", TwoFactorEnabled: tt.twoFactorEnabled, TotpSecret: testTotpSecret}
+			service, userSessionRepo := newTestOidcLoginService(owner, claims)
+			sessions := newTestSessions()
+
+			sessions.request(t, func(sess *session.Session) {
+				setTestOidcRequest(sess, claims.Nonce, now)
+				if err := sess.Save(); err != nil {
+					t.Fatalf("failed to save session: %v", err)
+				}
+			})
+
+			var challenge *user.TwoFactorChallenge
+			sessions.request(t, func(sess *session.Session) {
+				loggedInUser, c, err := service.Execute(sess, "state", testOidcCode, "test", "127.0.0.1", now)
+				if err != nil {
+					t.Fatalf("Execute returned an error: %v", err)
+				}
+				if tt.twoFactorEnabled != (c != nil) || tt.twoFactorEnabled != (loggedInUser == nil) {
+					t.Fatalf("Execute returned user %v and challenge %v", loggedInUser, c)
+				}
+				challenge = c
+			})
+
+			if !tt.twoFactorEnabled {
+				if len(userSessionRepo.sessions) != 1 {
+					t.Fatalf("created %d sessions, want 1", len(userSessionRepo.sessions))
+				}
+				return
+			}
+
+			if len(userSessionRepo.sessions) != 0 {
+				t.Fatalf("created a session before two-factor authentication")
+			}
+
+			sessions.request(t, func(sess *session.Session) {
+				loggedInUser, err := service.LoginService.ExecuteTwoFactor(sess, challenge.Challenge, testTotpCode(t, now, 0), "test", "127.0.0.1", now)
+				if err != nil {
+					t.Fatalf("ExecuteTwoFactor returned an error: %v", err)
+				}
+				if loggedInUser == nil || loggedInUser.ID != owner.ID {
+					t.Fatalf("logged in as %v, want user %s", loggedInUser, owner.ID)
+				}
+			})
+
+			if len(userSessionRepo.sessions) != 1 {
+				t.Fatalf("created %d sessions, want 1", len(userSessionRepo.sessions))
+			}
+		})
+	}
+}
+
+func TestOidcLoginServiceProvisioning(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	existing := user.User{ID: "1", Email: "existing@example.com", EmailVerifiedAt: &now}
+
+	tests := []struct {
+		name          string
+		registration  user.RegistrationMode
+		autoProvision bool
+		email         string
+		wantErr       error
+	}{
+		{name: "誰でも登録できる場合は作成する", registration: user.RegistrationModeOpen, autoProvision: true, email: "new@example.com"},
+		{name: "招待制の場合は作成しない", registration: user.RegistrationModeInvite, autoProvision: true, email: "new@example.com", wantErr: RegistrationNotAllowedError{}},
+		{name: "登録できない場合は作成しない", registration: user.RegistrationModeDisabled, autoProvision: true, email: "new@example.com", wantErr: RegistrationNotAllowedError{}},
+		{name: "自動作成が無効な場合は作成しない", registration: user.RegistrationModeOpen, autoProvision: false, email: "new@example.com", wantErr: OidcError{}},
+		{name: "登録できない場合も既存のユーザーには紐付ける", registration: user.RegistrationModeDisabled, autoProvision: true, email: existing.Email},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := oidc.Claims{Subject: "subject", Email: tt.email, EmailVerified: true, Nonce: "nonce"}
+			service, userSessionRepo := newTestOidcLoginService(existing, claims)
+			// IdPのアカウントはまだ紐付けられていない
+			service.UserIdentityRepo = &fakeUserIdentityRepo{userIDs: map[[2]string]string{}}
+			service.OidcRepo.(*fakeOidcRepo).setting.AutoProvision = tt.autoProvision
+			userRepo := service.UserRepo.(*fakeUserRepo)
+			userRepo.auth.Registration = tt.registration
+			sessions := newTestSessions()
+
+			sessions.request(t, func(sess *session.Session) {
+				setTestOidcRequest(sess, claims.Nonce, now)
+				if err := sess.Save(); err != nil {
+					t.Fatalf("failed to save session: %v", err)
+				}
+			})
+
+			sessions.request(t, func(sess *session.Session) {
+				loggedInUser, _, err := service.Execute(sess, "state", testOidcCode, "test", "127.0.0.1", now)
+
+				if tt.wantErr != nil {
+					if !errors.HasType(err, tt.wantErr) {
+						t.Fatalf("error = %v, want %T", err, tt.wantErr)
+					}
+					if len(userRepo.users) != 1 || len(userSessionRepo.sessions) != 0 {
+						t.Fatalf("created %d users and %d sessions", len(userRepo.users)-1, len(userSessionRepo.sessions))
+					}
+					return
+				}
+
+				if err != nil {
+					t.Fatalf("Execute returned an error: %v", err)
+				}
+				if loggedInUser == nil || loggedInUser.Email != tt.email {
+					t.Fatalf("logged in as %v, want %s", loggedInUser, tt.email)
+				}
+			})
+		})
+	}
+}
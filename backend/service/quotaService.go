@@ -0,0 +1,123 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/quota"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+type QuotaService struct {
+	Conn               *sqlx.DB
+	QuotaRepo          repository.QuotaRepositoryInterface
+	QuotaWarningBroker *QuotaWarningBroker
+}
+
+func (service *QuotaService) GetUsage(owner user.User) (*quota.Usage, error) {
+	kinds, err := service.QuotaRepo.GetUsage(service.Conn, owner)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	usage := quota.NewUsage(kinds, service.QuotaRepo.GetQuotaSetting().QuotaBytes(owner))
+
+	return &usage, nil
+}
+
+// 書き込む前に、申告された大きさを加えても容量を超えないかを確認する。アップロード中の分も使用済みとして数える
+func (service *QuotaService) Check(owner user.User, size int64) error {
+	remaining, limited, err := service.Remaining(owner)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if limited && size > remaining {
+		return errors.WithStack(quotaExceededError())
+	}
+
+	return nil
+}
+
+// 残りの容量。書き込み中や登録を待つアップロードの分は予約済みとして除く。無制限の場合はlimitedがfalse
+func (service *QuotaService) Remaining(owner user.User) (remaining int64, limited bool, err error) {
+	quotaBytes := service.QuotaRepo.GetQuotaSetting().QuotaBytes(owner)
+	if quotaBytes <= 0 {
+		return 0, false, nil
+	}
+
+	usage, err := service.GetUsage(owner)
+	if err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+
+	reservedBytes, err := service.QuotaRepo.GetReservedBytes(service.Conn, owner, time.Now())
+	if err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+
+	return max(quotaBytes-usage.UsedBytes-reservedBytes, 0), true, nil
+}
+
+// 登録のトランザクション内で、増える大きさを加えても容量を超えないかを確認する
+// 同時に登録して容量を超えないよう、コミットするまでユーザーの使用量をロックする
+// アップロードを登録する場合は、そのアップロードの予約が使用量に変わるためuploadIDを渡す
+func (service *QuotaService) CheckTx(tx *sqlx.Tx, owner user.User, addedBytes int64, uploadID string) error {
+	quotaBytes := service.QuotaRepo.GetQuotaSetting().QuotaBytes(owner)
+	if quotaBytes <= 0 || addedBytes <= 0 {
+		return nil
+	}
+
+	usedBytes, err := service.QuotaRepo.LockUsedBytes(tx, owner, time.Now(), uploadID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if usedBytes+addedBytes > quotaBytes {
+		return errors.WithStack(quotaExceededError())
+	}
+
+	return nil
+}
+
+// 使用量がaddedBytes増えて警告の割合を超えた場合に、WebSocketで接続しているユーザーに知らせる
+func (service *QuotaService) Warn(owner user.User, addedBytes int64) {
+	if addedBytes <= 0 {
+		return
+	}
+
+	setting := service.QuotaRepo.GetQuotaSetting()
+	quotaBytes := setting.QuotaBytes(owner)
+	if quotaBytes <= 0 {
+		return
+	}
+
+	usage, err := service.GetUsage(owner)
+	if err != nil {
+		log.Printf("Warning: Failed to get usage of user %s: %v", owner.ID, err)
+		return
+	}
+
+	percent, crossed := setting.CrossedWarningPercent(usage.UsedBytes-addedBytes, usage.UsedBytes, quotaBytes)
+	if !crossed {
+		return
+	}
+
+	log.Printf("User %s used %d%% of quota (%d / %d bytes)", owner.ID, percent, usage.UsedBytes, quotaBytes)
+
+	if service.QuotaWarningBroker != nil {
+		service.QuotaWarningBroker.publish(owner.ID, quota.Warning{
+			Percent:    percent,
+			UsedBytes:  usage.UsedBytes,
+			QuotaBytes: quotaBytes,
+		})
+	}
+}
+
+func quotaExceededError() QuotaExceededError {
+	return QuotaExceededError{Code: 507, Message: "容量の上限を超えるため保存できません。"}
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"log"
+	"sync"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/quota"
+)
+
+// QuotaWarningBroker delivers quota warnings to the WebSocket connections of the user in this process.
+type QuotaWarningBroker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan quota.Warning]struct{}
+}
+
+func NewQuotaWarningBroker() *QuotaWarningBroker {
+	return &QuotaWarningBroker{subscribers: map[string]map[chan quota.Warning]struct{}{}}
+}
+
+// 返した関数で購読をやめる
+func (broker *QuotaWarningBroker) Subscribe(userID string) (<-chan quota.Warning, func()) {
+	ch := make(chan quota.Warning, 10)
+
+	broker.mu.Lock()
+	if broker.subscribers[userID] == nil {
+		broker.subscribers[userID] = map[chan quota.Warning]struct{}{}
+	}
+	broker.subscribers[userID][ch] = struct{}{}
+	broker.mu.Unlock()
+
+	return ch, func() {
+		broker.mu.Lock()
+		defer broker.mu.Unlock()
+
+		delete(broker.subscribers[userID], ch)
+		if len(broker.subscribers[userID]) == 0 {
+			delete(broker.subscribers, userID)
+		}
+	}
+}
+
+// 受け取れない接続には送らず、登録などの処理を待たせない
+func (broker *QuotaWarningBroker) publish(userID string, warning quota.Warning) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	for ch := range broker.subscribers[userID] {
+		select {
+		case ch <- warning:
+		default:
+			log.Printf("Warning: Quota warning channel of user %s is full", userID)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/auth"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/ratelimit"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+// Redisに障害があってもログインやAPIを止めないよう、数えられない場合は制限しない
+type RateLimitService struct {
+	RateLimitRepo repository.RateLimitRepositoryInterface
+}
+
+// APIの回数の制限の状態。レスポンスヘッダに使う
+type RateLimitStatus struct {
+	Limit     int64
+	Remaining int64
+	Reset     time.Duration
+}
+
+// ログインを試みる前に、IPアドレスごとの上限とアカウントのロックを確認する
+func (service *RateLimitService) CheckLogin(email string, ipAddress string) error {
+	setting := service.RateLimitRepo.GetRateLimitSetting().Login
+
+	count, ttl, err := service.RateLimitRepo.Hit("login:ip:"+ipAddress, setting.PerIp.Window())
+	if err != nil {
+		log.Printf("Warning: Failed to count login attempts: %+v", err)
+		return nil
+	}
+	if count > setting.PerIp.Limit {
+		return errors.WithStack(loginRateLimitedError(ttl))
+	}
+
+	return service.CheckAccountLock(email)
+}
+
+// 失敗が続いたアカウントは、待ち時間が過ぎるまでパスワードを確認しない
+func (service *RateLimitService) CheckAccountLock(email string) error {
+	lockedFor, err := service.RateLimitRepo.GetLockedFor(loginLockKey(email))
+	if err != nil {
+		log.Printf("Warning: Failed to get login lock: %+v", err)
+		return nil
+	}
+	if lockedFor > 0 {
+		return errors.WithStack(loginRateLimitedError(lockedFor))
+	}
+
+	return nil
+}
+
+// 失敗した回数に応じて、次の試行まで待たせるか一時的にロックする
+func (service *RateLimitService) FailLogin(email string) {
+	setting := service.RateLimitRepo.GetRateLimitSetting().Login
+
+	failures, _, err := service.RateLimitRepo.Hit(loginFailuresKey(email), setting.PerAccount.Window())
+	if err != nil {
+		log.Printf("Warning: Failed to count login failures: %+v", err)
+		return
+	}
+
+	if delay := setting.Delay(failures); delay > 0 {
+		if err := service.RateLimitRepo.Lock(loginLockKey(email), delay); err != nil {
+			log.Printf("Warning: Failed to lock login: %+v", err)
+		}
+	}
+}
+
+func (service *RateLimitService) SucceedLogin(email string) {
+	if err := service.RateLimitRepo.Reset(loginFailuresKey(email), loginLockKey(email)); err != nil {
+		log.Printf("Warning: Failed to reset login failures: %+v", err)
+	}
+}
+
+func (service *RateLimitService) CheckRegistration(ipAddress string) error {
+	return service.checkIpAddress("registration:ip:"+ipAddress, service.RateLimitRepo.GetRateLimitSetting().Registration)
+}
+
+func (service *RateLimitService) CheckPasswordReset(ipAddress string) error {
+	return service.checkIpAddress("password_reset:ip:"+ipAddress, service.RateLimitRepo.GetRateLimitSetting().PasswordReset)
+}
+
+func (service *RateLimitService) checkIpAddress(key string, rule ratelimit.Rule) error {
+	count, ttl, err := service.RateLimitRepo.Hit(key, rule.Window())
+	if err != nil {
+		log.Printf("Warning: Failed to count %s: %+v", key, err)
+		return nil
+	}
+	if count > rule.Limit {
+		return errors.WithStack(RateLimitedError{Code: 429, Message: "しばらくしてから再度お試しください。", RetryAfter: ttl})
+	}
+
+	return nil
+}
+
+// APIトークンの総当たりを防ぐため、失敗が続いたIPアドレスからの認証を止める
+func (service *RateLimitService) CheckTokenAuth(ipAddress string) error {
+	rule := service.RateLimitRepo.GetRateLimitSetting().TokenAuth
+
+	failures, ttl, err := service.RateLimitRepo.GetCount(tokenAuthFailuresKey(ipAddress))
+	if err != nil {
+		log.Printf("Warning: Failed to get token auth failures: %+v", err)
+		return nil
+	}
+	if failures >= rule.Limit {
+		return errors.WithStack(RateLimitedError{Code: 429, Message: "認証の失敗が続いたため、しばらくしてから再度お試しください。", RetryAfter: ttl})
+	}
+
+	return nil
+}
+
+func (service *RateLimitService) FailTokenAuth(ipAddress string) {
+	rule := service.RateLimitRepo.GetRateLimitSetting().TokenAuth
+
+	if _, _, err := service.RateLimitRepo.Hit(tokenAuthFailuresKey(ipAddress), rule.Window()); err != nil {
+		log.Printf("Warning: Failed to count token auth failures: %+v", err)
+	}
+}
+
+// 共有リンクのパスワードの総当たりを防ぐため、IPアドレスごとの入力回数とリンクごとの失敗回数を確認する
+func (service *RateLimitService) CheckSharePassword(token string, ipAddress string) error {
+	setting := service.RateLimitRepo.GetRateLimitSetting().SharePassword
+
+	if err := service.checkIpAddress("share_password:ip:"+ipAddress, setting.PerIp); err != nil {
+		return err
+	}
+
+	failures, ttl, err := service.RateLimitRepo.GetCount(sharePasswordFailuresKey(token))
+	if err != nil {
+		log.Printf("Warning: Failed to get share password failures: %+v", err)
+		return nil
+	}
+	if failures >= setting.PerShare.Limit {
+		return errors.WithStack(RateLimitedError{Code: 429, Message: "しばらくしてから再度お試しください。", RetryAfter: ttl})
+	}
+
+	return nil
+}
+
+func (service *RateLimitService) FailSharePassword(token string) {
+	rule := service.RateLimitRepo.GetRateLimitSetting().SharePassword.PerShare
+
+	if _, _, err := service.RateLimitRepo.Hit(sharePasswordFailuresKey(token), rule.Window()); err != nil {
+		log.Printf("Warning: Failed to count share password failures: %+v", err)
+	}
+}
+
+// APIトークンで認証した場合はトークンごと、それ以外はユーザーごとに数える
+func (service *RateLimitService) LimitRequest(principal auth.Principal) (*RateLimitStatus, error) {
+	setting := service.RateLimitRepo.GetRateLimitSetting().Api
+
+	key := "api:user:" + principal.User.ID
+	rule := setting.PerUser
+	if principal.ApiToken != nil {
+		key = "api:token:" + principal.ApiToken.ID
+		rule = setting.PerToken
+	}
+
+	count, ttl, err := service.RateLimitRepo.Hit(key, rule.Window())
+	if err != nil {
+		log.Printf("Warning: Failed to count requests: %+v", err)
+		return nil, nil
+	}
+
+	status := RateLimitStatus{Limit: rule.Limit, Remaining: max(rule.Limit-count, 0), Reset: ttl}
+	if count > rule.Limit {
+		return &status, errors.WithStack(RateLimitedError{Code: 429, Message: "リクエストが多すぎます。しばらくしてから再度お試しください。", RetryAfter: ttl})
+	}
+
+	return &status, nil
+}
+
+// どの理由で待たされているかを区別できないよう、同じメッセージを返す
+func loginRateLimitedError(retryAfter time.Duration) RateLimitedError {
+	return RateLimitedError{Code: 429, Message: "ログインの試行が多すぎます。しばらくしてから再度お試しください。", RetryAfter: retryAfter}
+}
+
+// メールアドレスをそのままRedisに保存しない
+func loginAccountKey(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:])
+}
+
+func loginFailuresKey(email string) string {
+	return "login:failures:" + loginAccountKey(email)
+}
+
+func loginLockKey(email string) string {
+	return "login:lock:" + loginAccountKey(email)
+}
+
+func tokenAuthFailuresKey(ipAddress string) string {
+	return "token_auth:failures:" + ipAddress
+}
+
+// 共有リンクのトークンをそのままRedisに保存しない
+func sharePasswordFailuresKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "share_password:failures:" + hex.EncodeToString(sum[:])
+}
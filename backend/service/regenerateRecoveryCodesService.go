@@ -0,0 +1,45 @@
+package service
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+type RegenerateRecoveryCodesService struct {
+	Conn                   *sqlx.DB
+	UserRecoveryCodeRepo   repository.UserRecoveryCodeRepositoryInterface
+	VerifyTwoFactorService VerifyTwoFactorService
+}
+
+// 以前のリカバリーコードを全て無効にし、新しいコードを返す
+func (service *RegenerateRecoveryCodesService) Execute(owner user.User, code string, now time.Time) ([]string, error) {
+	if err := service.VerifyTwoFactorService.Execute(owner, code, now); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	recoveryCodes, err := user.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := service.UserRecoveryCodeRepo.ReplaceRecoveryCodes(tx, owner, hashRecoveryCodes(recoveryCodes)); err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return recoveryCodes, nil
+}
@@ -1,11 +1,17 @@
 package service
 
 import (
+	"net/url"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/YahiroRyo/yappi_storage/backend/domain/apitoken"
 	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/job"
 	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
 	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/helper/validate"
 	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
 	"github.com/YahiroRyo/yappi_storage/backend/presentation/request"
 	"github.com/jmoiron/sqlx"
@@ -16,15 +22,30 @@ type RegistrationFilesService struct {
 	UserRepo    repository.UserRepositoryInterface
 	FileRepo    repository.FileRepositoryInterface
 	ChatGPTRepo repository.ChatGPTRepositoryInterface
+	UploadRepo  repository.UploadRepositoryInterface
+
+	EnqueueJobService                 EnqueueJobService
+	AuthorizeApiTokenDirectoryService AuthorizeApiTokenDirectoryService
+	QuotaService                      QuotaService
 }
 
-func (service *RegistrationFilesService) Execute(user user.User, registrationFiles request.RegistrationFilesRequest) ([]file.File, error) {
+// apiTokenはAPIトークンで認証した場合のみ指定する
+func (service *RegistrationFilesService) Execute(user user.User, apiToken *apitoken.ApiToken, registrationFiles request.RegistrationFilesRequest) ([]file.File, error) {
+	for i, registrationFile := range registrationFiles.RegistrationFiles {
+		parentDirectoryID, err := service.AuthorizeApiTokenDirectoryService.Execute(user, apiToken, registrationFile.ParentDirectoryId)
+		if err != nil {
+			return nil, err
+		}
+		registrationFiles.RegistrationFiles[i].ParentDirectoryId = parentDirectoryID
+	}
+
 	tx, err := service.Conn.Beginx()
 	if err != nil {
 		return nil, err
 	}
 
 	uploadedFiles := []file.File{}
+	var addedBytes int64
 
 	for _, registrationFile := range registrationFiles.RegistrationFiles {
 		generatedID, err := helper.GenerateSnowflake()
@@ -33,16 +54,81 @@ func (service *RegistrationFilesService) Execute(user user.User, registrationFil
 			return nil, err
 		}
 
+		kind := file.FileKindFromEnString(registrationFile.Kind)
+		isImage := kind == file.Image
+
 		file := file.File{
 			ID:                *generatedID,
 			UserID:            user.ID,
 			ParentDirectoryID: registrationFile.ParentDirectoryId,
-			Url:               &registrationFile.Url,
 			// Embedding:         nil,
-			Kind:      file.FileKindFromEnString(registrationFile.Kind).ToEnString(),
-			Name:      registrationFile.Name,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+			Kind:                kind.ToEnString(),
+			Name:                registrationFile.Name,
+			CompressionDisabled: registrationFile.CompressionDisabled,
+			CreatedAt:           time.Now(),
+			UpdatedAt:           time.Now(),
+		}
+
+		importing := false
+		if registrationFile.UploadId != nil && registrationFile.Url != "" {
+			tx.Rollback()
+			return nil, validate.ValidationError{Code: 400, Message: "upload_idとURLはどちらか一方を指定してください。"}
+		}
+
+		if registrationFile.UploadId != nil {
+			// WebSocketでアップロードした実体は、アップロードした本人が1度だけ登録できる
+			u, err := service.UploadRepo.GetUpload(service.Conn, user, *registrationFile.UploadId)
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+
+			url := service.FileRepo.GetUrl(u.LocalPath)
+			mimeType := helper.DetectMimeType(u.LocalPath)
+			file.ID = u.ID
+			file.Url = &url
+			file.BlobID = &u.ID
+			file.MimeType = &mimeType
+			file.Size = u.Length
+			addedBytes += file.Size
+
+			// 自ストレージ上の画像であればEXIFから撮影日時や大きさを取り込む
+			if isImage {
+				applyImageMetadata(&file, u.LocalPath)
+			}
+
+			completed, err := service.UploadRepo.CompleteUpload(tx, user, u.ID, file.ID, time.Now())
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			if !completed {
+				tx.Rollback()
+				return nil, repository.NotFoundError{Code: 404, Message: "アップロードが見つかりません。"}
+			}
+		} else {
+			// 自ストレージのURLを指定しても実体は参照できないため、upload_id で登録させる
+			if registrationFile.Url == "" || isStorageUrl(registrationFile.Url) {
+				tx.Rollback()
+				return nil, validate.ValidationError{Code: 400, Message: "アップロードしたファイルはupload_idを指定して登録してください。"}
+			}
+			file.Url = &registrationFile.Url
+
+			// 自ストレージ上にないURLは取り込みのジョブが終わるまで元のURLを指す
+			if registrationFile.Import {
+				if parsed, err := url.Parse(registrationFile.Url); err != nil || !isImportableUrl(parsed) {
+					tx.Rollback()
+					return nil, validate.ValidationError{Code: 400, Message: "取り込めるのはhttp・httpsのURLのみです。"}
+				}
+				importing = true
+			}
+		}
+
+		// 自ストレージ上のファイルの分だけ使用量が増える。使用量には同じトランザクションで登録した分も含まれる
+		// 登録するアップロードは完了として記録したため、予約には含まれない
+		if err := service.QuotaService.CheckTx(tx, user, file.Size, ""); err != nil {
+			tx.Rollback()
+			return nil, err
 		}
 
 		uploadedFile, err := service.FileRepo.RegistrationFile(tx, user, file)
@@ -51,10 +137,37 @@ func (service *RegistrationFilesService) Execute(user user.User, registrationFil
 			return nil, err
 		}
 
+		// 行と同じトランザクションで登録し、ジョブの実行時に行が存在するようにする
+		if uploadedFile.BlobID != nil {
+			if _, err := service.EnqueueJobService.ExecuteBlobJobsTx(tx, user.ID, *uploadedFile.BlobID, *uploadedFile); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+
+		if importing {
+			if _, err := service.EnqueueJobService.ExecuteTx(tx, user.ID, job.TypeImportUrl, job.ImportPayload{
+				FileID: uploadedFile.ID,
+				Url:    registrationFile.Url,
+			}); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+
 		uploadedFiles = append(uploadedFiles, *uploadedFile)
 	}
 
 	tx.Commit()
 
+	service.QuotaService.Warn(user, addedBytes)
+
 	return uploadedFiles, nil
 }
+
+// BASE_URL 以下のURLか
+func isStorageUrl(u string) bool {
+	baseUrl := os.Getenv("BASE_URL")
+
+	return baseUrl != "" && strings.HasPrefix(u, baseUrl)
+}
@@ -1,8 +1,12 @@
 package service
 
 import (
+	"log"
+	"time"
+
 	"github.com/cockroachdb/errors"
 
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
 	"github.com/YahiroRyo/yappi_storage/backend/helper"
 	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
 	"github.com/gofiber/fiber/v2/middleware/session"
@@ -10,11 +14,27 @@ import (
 )
 
 type RegistrationUserService struct {
-	Conn     *sqlx.DB
-	UserRepo repository.UserRepositoryInterface
+	Conn             *sqlx.DB
+	UserRepo         repository.UserRepositoryInterface
+	UserInviteRepo   repository.UserInviteRepositoryInterface
+	RateLimitService RateLimitService
+	// 登録したメールアドレスに確認用のリンクを送る
+	SendEmailVerificationService SendEmailVerificationService
 }
 
-func (service *RegistrationUserService) Execute(sess *session.Session, email string, password string, icon string) error {
+func (service *RegistrationUserService) Execute(sess *session.Session, email string, password string, icon string, inviteCode string, ipAddress string) error {
+	mode := service.UserRepo.GetAuthSetting().Registration
+	if mode == user.RegistrationModeDisabled {
+		return errors.WithStack(RegistrationNotAllowedError{Code: 403, Message: "新規登録は受け付けていません。"})
+	}
+	if mode == user.RegistrationModeInvite && inviteCode == "" {
+		return errors.WithStack(RegistrationNotAllowedError{Code: 403, Message: "登録には招待コードが必要です。"})
+	}
+
+	if err := service.RateLimitService.CheckRegistration(ipAddress); err != nil {
+		return errors.WithStack(err)
+	}
+
 	tx, err := service.Conn.Beginx()
 	if err != nil {
 		return err
@@ -26,6 +46,14 @@ func (service *RegistrationUserService) Execute(sess *session.Session, email str
 		return err
 	}
 
+	// 登録に失敗した場合は招待コードも未使用に戻す
+	if mode == user.RegistrationModeInvite {
+		if err := service.UserInviteRepo.UseUserInvite(tx, user.HashToken(inviteCode), email, time.Now()); err != nil {
+			tx.Rollback()
+			return errors.WithStack(err)
+		}
+	}
+
 	err = service.UserRepo.Registration(tx, email, encriptedPassword, icon)
 	if err != nil {
 		tx.Rollback()
@@ -34,5 +62,20 @@ func (service *RegistrationUserService) Execute(sess *session.Session, email str
 
 	tx.Commit()
 
+	service.sendEmailVerification(email)
+
 	return nil
 }
+
+// 確認メールを送れなくても登録は成功とし、あとから再送できるようにする
+func (service *RegistrationUserService) sendEmailVerification(email string) {
+	owner, err := service.UserRepo.GetUserByEmail(service.Conn, email)
+	if err != nil {
+		log.Printf("Warning: Failed to send email verification to %s: %+v", email, err)
+		return
+	}
+
+	if err := service.SendEmailVerificationService.Execute(*owner, time.Now()); err != nil {
+		log.Printf("Warning: Failed to send email verification to %s: %+v", email, err)
+	}
+}
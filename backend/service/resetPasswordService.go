@@ -0,0 +1,77 @@
+package service
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+type ResetPasswordService struct {
+	Conn             *sqlx.DB
+	UserRepo         repository.UserRepositoryInterface
+	UserTokenRepo    repository.UserTokenRepositoryInterface
+	UserSessionRepo  repository.UserSessionRepositoryInterface
+	RateLimitService RateLimitService
+}
+
+// メールのリンクのトークンでパスワードを再設定し、全ての端末をログアウトさせる
+func (service *ResetPasswordService) Execute(token string, password string, now time.Time) error {
+	hashedPassword, err := helper.EncryptPassword(password)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	usedToken, err := service.UserTokenRepo.UseUserToken(tx, user.TokenPurposePasswordReset, user.HashToken(token), now)
+	if err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	owner, err := service.UserRepo.GetUserByID(service.Conn, usedToken.UserID)
+	if err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := service.UserRepo.UpdatePassword(tx, *owner, hashedPassword); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	// メールを受け取れたので、メールアドレスも確認できている
+	if owner.EmailVerifiedAt == nil {
+		if err := service.UserRepo.UpdateEmailVerifiedAt(tx, *owner, now); err != nil {
+			tx.Rollback()
+			return errors.WithStack(err)
+		}
+	}
+
+	if err := service.UserTokenRepo.DeleteUserTokens(tx, *owner, user.TokenPurposePasswordReset); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := service.UserSessionRepo.DeleteUserSessions(tx, *owner); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// 以前のパスワードでの失敗によるロックを解除する
+	service.RateLimitService.SucceedLogin(owner.Email)
+
+	return nil
+}
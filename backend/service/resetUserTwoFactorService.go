@@ -0,0 +1,53 @@
+package service
+
+import (
+	"log"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+type ResetUserTwoFactorService struct {
+	Conn                 *sqlx.DB
+	UserRepo             repository.UserRepositoryInterface
+	UserRecoveryCodeRepo repository.UserRecoveryCodeRepositoryInterface
+}
+
+// 認証アプリとリカバリーコードを失ったユーザーの二段階認証を、コードを確認せずに無効にする
+func (service *ResetUserTwoFactorService) Execute(admin user.User, id string) error {
+	target, err := adminTargetUser(service.Conn, service.UserRepo, admin, id)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := service.UserRepo.UpdateTwoFactorEnabled(tx, *target, false); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := service.UserRepo.UpdateTotpSecret(tx, *target, nil); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := service.UserRecoveryCodeRepo.DeleteRecoveryCodes(tx, *target); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	log.Printf("Admin %s reset two-factor authentication of user %s", admin.ID, target.ID)
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"log"
+	"os"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+// ResolveLegacyBlobIDsService records the blob ids of the files registered before
+// blob ids were stored, so that files are never resolved from their URLs.
+type ResolveLegacyBlobIDsService struct {
+	Conn     *sqlx.DB
+	FileRepo repository.FileRepositoryInterface
+}
+
+// 起動時、実体を参照する処理より前に呼ぶ。BASE_URL が必要なためマイグレーションではなくここで行う
+func (service *ResolveLegacyBlobIDsService) Execute() error {
+	// 未設定のまま解決すると自ストレージのファイルを外部のファイルとして扱ってしまうため、未解決のファイルがあれば起動しない
+	if os.Getenv("BASE_URL") == "" {
+		exists, err := service.FileRepo.HasLegacyBlobIDs(service.Conn)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if exists {
+			return errors.New("BASE_URL must be set to resolve the blob ids of files registered before blob ids were stored")
+		}
+		return nil
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	rewritten, err := service.FileRepo.RewriteLegacyStaticUrls(tx)
+	if err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	resolved, err := service.FileRepo.ResolveLegacyBlobIDs(tx)
+	if err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if rewritten > 0 {
+		log.Printf("Rewrote %d /static/ urls to /files/secure/", rewritten)
+	}
+	if resolved > 0 {
+		log.Printf("Resolved blob ids of %d files", resolved)
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+type RevokeApiTokenService struct {
+	Conn         *sqlx.DB
+	ApiTokenRepo repository.ApiTokenRepositoryInterface
+}
+
+func (service *RevokeApiTokenService) Execute(user user.User, id string) error {
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := service.ApiTokenRepo.DeleteApiToken(tx, user, id); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/share"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+type RevokeShareService struct {
+	Conn      *sqlx.DB
+	ShareRepo repository.ShareRepositoryInterface
+}
+
+func (service *RevokeShareService) Execute(user user.User, id string) (*share.Share, error) {
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	revoked, err := service.ShareRepo.RevokeShare(tx, user, id)
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return revoked, nil
+}
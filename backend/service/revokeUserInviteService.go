@@ -0,0 +1,31 @@
+package service
+
+import (
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+type RevokeUserInviteService struct {
+	Conn           *sqlx.DB
+	UserInviteRepo repository.UserInviteRepositoryInterface
+}
+
+func (service *RevokeUserInviteService) Execute(id string) error {
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := service.UserInviteRepo.DeleteUserInvite(tx, id); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
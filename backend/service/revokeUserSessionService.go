@@ -0,0 +1,53 @@
+package service
+
+import (
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"github.com/jmoiron/sqlx"
+)
+
+type RevokeUserSessionService struct {
+	Conn            *sqlx.DB
+	UserSessionRepo repository.UserSessionRepositoryInterface
+}
+
+// 指定したセッションを削除し、その端末をログアウトさせる
+func (service *RevokeUserSessionService) Execute(user user.User, id string) error {
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := service.UserSessionRepo.DeleteUserSession(tx, user, id); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// リクエストしたセッション以外を全て削除する
+func (service *RevokeUserSessionService) ExecuteOthers(user user.User, sess *session.Session) error {
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := service.UserSessionRepo.DeleteOtherUserSessions(tx, user, loggedInSessionID(sess)); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
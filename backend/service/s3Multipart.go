@@ -0,0 +1,342 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/s3"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+const (
+	// 完了するまでのパートの置き場所
+	s3UploadsDir = "storage/s3_uploads"
+
+	S3MaxPartNumber = 10000
+	// 最後以外のパートの最小の大きさ
+	s3MinPartSize = 5 * 1024 * 1024
+	// マルチパートで作成できる大きさ(S3と同じ5TiB)
+	s3MaxMultipartObjectSize = 5 * 1024 * 1024 * 1024 * 1024
+)
+
+type S3Part struct {
+	PartNumber   int
+	LastModified time.Time
+	// 引用符を含まないMD5
+	ETag string
+	Size int64
+}
+
+type S3CompletedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+func noSuchUploadError() S3Error {
+	return S3Error{Status: 404, Code: "NoSuchUpload", Message: "The specified multipart upload does not exist."}
+}
+
+func s3UploadDir(uploadID string) string {
+	return filepath.Join(s3UploadsDir, uploadID)
+}
+
+func s3PartPath(uploadID string, partNumber int) string {
+	return filepath.Join(s3UploadDir(uploadID), fmt.Sprintf("%d.part", partNumber))
+}
+
+// パートのETagは実体と並べて保存する
+func s3PartETagPath(uploadID string, partNumber int) string {
+	return filepath.Join(s3UploadDir(uploadID), fmt.Sprintf("%d.etag", partNumber))
+}
+
+func (service *S3Service) getUpload(user user.User, bucket string, key string, uploadID string) (*s3.MultipartUpload, error) {
+	if _, err := strconv.ParseUint(uploadID, 10, 64); err != nil {
+		return nil, noSuchUploadError()
+	}
+
+	upload, err := service.S3Repo.GetMultipartUpload(service.Conn, user, uploadID)
+	if errors.As(err, new(repository.NotFoundError)) {
+		return nil, noSuchUploadError()
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if upload.Bucket != bucket || upload.Key != key || upload.IsExpired(time.Now()) {
+		return nil, noSuchUploadError()
+	}
+
+	return upload, nil
+}
+
+func (service *S3Service) deleteUpload(user user.User, uploadID string) error {
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := service.S3Repo.DeleteMultipartUpload(tx, user, uploadID); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := os.RemoveAll(s3UploadDir(uploadID)); err != nil {
+		log.Printf("Warning: Failed to delete parts of upload %s: %v", uploadID, err)
+	}
+
+	return nil
+}
+
+// 期限を過ぎたアップロードのパートを削除する
+func (service *S3Service) deleteExpiredUploads(user user.User) {
+	uploads, err := service.S3Repo.GetExpiredMultipartUploads(service.Conn, user, time.Now())
+	if err != nil {
+		log.Printf("Warning: Failed to get expired uploads of user %s: %v", user.ID, err)
+		return
+	}
+
+	for _, upload := range uploads {
+		if err := service.deleteUpload(user, upload.ID); err != nil {
+			log.Printf("Warning: Failed to delete expired upload %s: %v", upload.ID, err)
+		}
+	}
+}
+
+func (service *S3Service) CreateMultipartUpload(user user.User, bucket string, key string) (*s3.MultipartUpload, error) {
+	if _, err := service.getBucket(user, bucket); err != nil {
+		return nil, err
+	}
+	if err := validateS3Key(key); err != nil {
+		return nil, err
+	}
+	if isS3DirectoryKey(key) {
+		return nil, S3Error{Status: 400, Code: "InvalidArgument", Message: "A folder cannot be uploaded in parts."}
+	}
+
+	service.deleteExpiredUploads(user)
+
+	generatedID, err := helper.GenerateSnowflake()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := os.MkdirAll(s3UploadDir(*generatedID), 0755); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	now := time.Now()
+	upload := s3.MultipartUpload{
+		ID:        *generatedID,
+		UserID:    user.ID,
+		Bucket:    bucket,
+		Key:       key,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	created, err := service.S3Repo.RegistrationMultipartUpload(tx, upload)
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return created, nil
+}
+
+// UploadPart stores the part and returns its MD5 as the ETag. Uploading the same part number again replaces it.
+func (service *S3Service) UploadPart(user user.User, bucket string, key string, uploadID string, partNumber int, body *S3RequestBody) (string, error) {
+	if partNumber < 1 || partNumber > S3MaxPartNumber {
+		return "", S3Error{Status: 400, Code: "InvalidArgument", Message: "Part number must be an integer between 1 and 10000, inclusive."}
+	}
+
+	if _, err := service.getUpload(user, bucket, key, uploadID); err != nil {
+		return "", err
+	}
+	if err := service.checkQuota(user, body.length); err != nil {
+		return "", err
+	}
+
+	// 書き込みが終わるまでは別の名前にしておき、途中のパートを完了に使わないようにする
+	tmp, err := os.CreateTemp(s3UploadDir(uploadID), fmt.Sprintf("%d.*.tmp", partNumber))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, body)
+	if closeErr := tmp.Close(); err == nil && closeErr != nil {
+		err = errors.WithStack(closeErr)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := body.Verify(); err != nil {
+		return "", err
+	}
+
+	etag := body.MD5Hex()
+	if err := os.WriteFile(s3PartETagPath(uploadID, partNumber), []byte(etag), 0644); err != nil {
+		return "", errors.WithStack(err)
+	}
+	if err := os.Rename(tmp.Name(), s3PartPath(uploadID, partNumber)); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return etag, nil
+}
+
+func (service *S3Service) ListParts(user user.User, bucket string, key string, uploadID string) ([]S3Part, error) {
+	if _, err := service.getUpload(user, bucket, key, uploadID); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(s3UploadDir(uploadID))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	parts := []S3Part{}
+	for _, entry := range entries {
+		partNumber, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ".part"))
+		if err != nil || !strings.HasSuffix(entry.Name(), ".part") {
+			continue
+		}
+
+		part, err := readS3Part(uploadID, partNumber)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, *part)
+	}
+
+	slices.SortFunc(parts, func(a, b S3Part) int {
+		return a.PartNumber - b.PartNumber
+	})
+
+	return parts, nil
+}
+
+func readS3Part(uploadID string, partNumber int) (*S3Part, error) {
+	stat, err := os.Stat(s3PartPath(uploadID, partNumber))
+	if err != nil {
+		return nil, err
+	}
+
+	etag, err := os.ReadFile(s3PartETagPath(uploadID, partNumber))
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Part{
+		PartNumber:   partNumber,
+		LastModified: stat.ModTime(),
+		ETag:         string(etag),
+		Size:         stat.Size(),
+	}, nil
+}
+
+// CompleteMultipartUpload concatenates the listed parts into a new blob and registers it at the key.
+func (service *S3Service) CompleteMultipartUpload(user user.User, bucket string, key string, uploadID string, completed []S3CompletedPart) (*S3Object, error) {
+	bucketDir, err := service.getBucket(user, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := service.getUpload(user, bucket, key, uploadID); err != nil {
+		return nil, err
+	}
+	if len(completed) == 0 {
+		return nil, S3Error{Status: 400, Code: "MalformedXML", Message: "You must specify at least one part."}
+	}
+
+	paths := make([]string, 0, len(completed))
+	var total int64
+	for i, c := range completed {
+		if i > 0 && c.PartNumber <= completed[i-1].PartNumber {
+			return nil, S3Error{Status: 400, Code: "InvalidPartOrder", Message: "The list of parts was not in ascending order."}
+		}
+
+		part, err := readS3Part(uploadID, c.PartNumber)
+		if err != nil || part.ETag != strings.Trim(c.ETag, `"`) {
+			return nil, S3Error{Status: 400, Code: "InvalidPart", Message: "One or more of the specified parts could not be found."}
+		}
+		if i < len(completed)-1 && part.Size < s3MinPartSize {
+			return nil, S3Error{Status: 400, Code: "EntityTooSmall", Message: "Your proposed upload is smaller than the minimum allowed object size."}
+		}
+
+		total += part.Size
+		paths = append(paths, s3PartPath(uploadID, c.PartNumber))
+	}
+	if total > s3MaxMultipartObjectSize {
+		return nil, S3Error{Status: 400, Code: "EntityTooLarge", Message: "Your proposed upload exceeds the maximum allowed object size."}
+	}
+	if err := service.checkQuota(user, total); err != nil {
+		return nil, err
+	}
+
+	blob, err := service.StoreBlobService.Create(path.Base(key))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	for _, partPath := range paths {
+		if err := appendFile(blob, partPath); err != nil {
+			blob.Remove()
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	object, err := service.storeObject(user, *bucketDir, key, blob)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := service.deleteUpload(user, uploadID); err != nil {
+		log.Printf("Warning: Failed to delete completed upload %s: %v", uploadID, err)
+	}
+
+	return object, nil
+}
+
+func appendFile(w io.Writer, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+
+	return err
+}
+
+func (service *S3Service) AbortMultipartUpload(user user.User, bucket string, key string, uploadID string) error {
+	if _, err := service.getUpload(user, bucket, key, uploadID); err != nil {
+		return err
+	}
+
+	return service.deleteUpload(user, uploadID)
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/helper/sigv4"
+)
+
+// CRC-64/NVMEの多項式(ビット反転したもの)
+var crc64NVMETable = crc64.MakeTable(0x9a6c9329ac4bc9b5)
+
+// x-amz-checksum-* で指定できるアルゴリズム
+var s3ChecksumAlgorithms = map[string]func() hash.Hash{
+	"crc32":     func() hash.Hash { return crc32.NewIEEE() },
+	"crc32c":    func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) },
+	"crc64nvme": func() hash.Hash { return crc64.New(crc64NVMETable) },
+	"sha1":      sha1.New,
+	"sha256":    sha256.New,
+}
+
+// S3RequestBody decodes the body of a PutObject or UploadPart request and verifies it once fully read.
+// aws-chunked bodies are decoded and the signature of every chunk is checked against the seed signature.
+type S3RequestBody struct {
+	r       io.Reader
+	chunked *sigv4.ChunkedReader
+	limit   int64
+	n       int64
+	// 本文の長さが分からない場合は-1
+	length int64
+
+	payloadHash string
+	md5         hash.Hash
+	sha256      hash.Hash
+	contentMD5  string
+
+	checksumAlgorithm string
+	checksum          hash.Hash
+	// トレーラーで送られる場合は空
+	expectedChecksum string
+}
+
+// contentLengthが負の場合は長さを確認しない。limitを超える本文はEntityTooLargeにする
+func NewS3RequestBody(auth S3Auth, body io.Reader, header func(name string) string, contentLength int64, limit int64) (*S3RequestBody, error) {
+	requestBody := &S3RequestBody{
+		r:           body,
+		limit:       limit,
+		length:      contentLength,
+		payloadHash: auth.PayloadHash,
+		md5:         md5.New(),
+		sha256:      sha256.New(),
+		contentMD5:  header("content-md5"),
+	}
+
+	switch auth.PayloadHash {
+	case sigv4.StreamingPayload, sigv4.StreamingPayloadTrailer:
+		requestBody.chunked = sigv4.NewChunkedReader(body, auth.SigningKey, auth.Signature)
+	case sigv4.StreamingUnsignedPayloadTrailer:
+		requestBody.chunked = sigv4.NewChunkedReader(body, nil, auth.Signature)
+	}
+	if requestBody.chunked != nil {
+		requestBody.r = requestBody.chunked
+		requestBody.length = -1
+		if decoded := header("x-amz-decoded-content-length"); decoded != "" {
+			length, err := strconv.ParseInt(decoded, 10, 64)
+			if err != nil || length < 0 {
+				return nil, S3Error{Status: 400, Code: "InvalidArgument", Message: "Invalid x-amz-decoded-content-length."}
+			}
+			requestBody.length = length
+		}
+	}
+
+	if requestBody.length > limit {
+		return nil, S3Error{Status: 400, Code: "EntityTooLarge", Message: "Your proposed upload exceeds the maximum allowed object size."}
+	}
+
+	// ヘッダで値を送るか、x-amz-trailerでトレーラーに付けることを予告する
+	trailer := strings.ToLower(strings.TrimSpace(header("x-amz-trailer")))
+	for name, newHash := range s3ChecksumAlgorithms {
+		value := header("x-amz-checksum-" + name)
+		if value == "" && trailer != "x-amz-checksum-"+name {
+			continue
+		}
+		if requestBody.checksum != nil {
+			return nil, S3Error{Status: 400, Code: "InvalidRequest", Message: "Expecting a single x-amz-checksum- header."}
+		}
+
+		requestBody.checksumAlgorithm = name
+		requestBody.checksum = newHash()
+		requestBody.expectedChecksum = value
+	}
+	if strings.HasPrefix(trailer, "x-amz-checksum-") && requestBody.checksum == nil {
+		return nil, S3Error{Status: 400, Code: "InvalidRequest", Message: "Unsupported checksum algorithm."}
+	}
+
+	return requestBody, nil
+}
+
+func (body *S3RequestBody) Read(p []byte) (int, error) {
+	n, err := body.r.Read(p)
+	body.n += int64(n)
+	body.md5.Write(p[:n])
+	body.sha256.Write(p[:n])
+	if body.checksum != nil {
+		body.checksum.Write(p[:n])
+	}
+
+	if body.n > body.limit {
+		return n, S3Error{Status: 400, Code: "EntityTooLarge", Message: "Your proposed upload exceeds the maximum allowed object size."}
+	}
+	if errors.Is(err, sigv4.ErrChunkSignatureInvalid) {
+		return n, S3Error{Status: 403, Code: "SignatureDoesNotMatch", Message: "The chunk signature does not match."}
+	}
+	if errors.Is(err, sigv4.ErrInvalidChunk) {
+		return n, S3Error{Status: 400, Code: "IncompleteBody", Message: "The request body is not a valid aws-chunked encoding."}
+	}
+	if err == io.EOF && body.length >= 0 && body.n != body.length {
+		return n, S3Error{Status: 400, Code: "IncompleteBody", Message: "You did not provide the number of bytes specified by the Content-Length HTTP header."}
+	}
+
+	return n, err
+}
+
+// Verify checks the length and the digests of the body. It must be called after the body is read to EOF.
+func (body *S3RequestBody) Verify() error {
+	if body.payloadHash != sigv4.UnsignedPayload && !strings.HasPrefix(body.payloadHash, "STREAMING-") {
+		if hex.EncodeToString(body.sha256.Sum(nil)) != strings.ToLower(body.payloadHash) {
+			return S3Error{Status: 400, Code: "XAmzContentSHA256Mismatch", Message: "The provided 'x-amz-content-sha256' header does not match what was computed."}
+		}
+	}
+
+	if body.contentMD5 != "" {
+		expected, err := base64.StdEncoding.DecodeString(body.contentMD5)
+		if err != nil || len(expected) != md5.Size {
+			return S3Error{Status: 400, Code: "InvalidDigest", Message: "The Content-MD5 you specified is not valid."}
+		}
+		if string(expected) != string(body.md5.Sum(nil)) {
+			return S3Error{Status: 400, Code: "BadDigest", Message: "The Content-MD5 you specified did not match what we received."}
+		}
+	}
+
+	if body.checksum != nil {
+		expected := body.expectedChecksum
+		if expected == "" && body.chunked != nil {
+			expected = body.chunked.Trailers()["x-amz-checksum-"+body.checksumAlgorithm]
+		}
+		if expected == "" {
+			return S3Error{Status: 400, Code: "InvalidRequest", Message: "The x-amz-checksum trailer is missing."}
+		}
+		if expected != body.Checksum() {
+			return S3Error{Status: 400, Code: "BadDigest", Message: "The " + body.checksumAlgorithm + " you specified did not match the calculated checksum."}
+		}
+	}
+
+	return nil
+}
+
+// 本文のMD5(16進数)。パートのETagに使う
+func (body *S3RequestBody) MD5Hex() string {
+	return hex.EncodeToString(body.md5.Sum(nil))
+}
+
+// 指定されたアルゴリズムのチェックサム(base64)。指定が無い場合は空
+func (body *S3RequestBody) ChecksumAlgorithm() string {
+	return body.checksumAlgorithm
+}
+
+func (body *S3RequestBody) Checksum() string {
+	if body.checksum == nil {
+		return ""
+	}
+
+	return base64.StdEncoding.EncodeToString(body.checksum.Sum(nil))
+}
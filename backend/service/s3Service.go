@@ -0,0 +1,653 @@
+package service
+
+import (
+	"encoding/base64"
+	"io"
+	"log"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+const (
+	// PutObject 1回で受け付ける大きさ(S3と同じ5GiB)
+	S3MaxObjectSize = 5 * 1024 * 1024 * 1024
+	s3MaxKeyLength  = 1024
+	s3MaxListKeys   = 1000
+	// フォルダを表す空のオブジェクトのETag(空のMD5)
+	S3DirectoryETag = "d41d8cd98f00b204e9800998ecf8427e"
+)
+
+// S3Service exposes the files hierarchy of a user as S3 buckets and objects.
+// Buckets are the top-level directories and keys are the slash separated paths below them.
+// A directory is also listed as an empty object whose key ends with a slash, like the folders of the S3 console.
+type S3Service struct {
+	Conn                         *sqlx.DB
+	FileRepo                     repository.FileRepositoryInterface
+	S3Repo                       repository.S3RepositoryInterface
+	RegistrationDirectoryService RegistrationDirectoryService
+	StoreBlobService             StoreBlobService
+}
+
+type S3Bucket struct {
+	Name      string
+	CreatedAt time.Time
+}
+
+type S3Object struct {
+	Key          string
+	LastModified time.Time
+	// 引用符を含まない
+	ETag string
+	Size int64
+}
+
+type S3ListObjectsInput struct {
+	Prefix    string
+	Delimiter string
+	// ListObjectsV2のcontinuation-tokenとstart-after、またはListObjectsのmarker
+	ContinuationToken string
+	StartAfter        string
+	Marker            string
+	MaxKeys           int
+}
+
+type S3ListObjectsResult struct {
+	Objects        []S3Object
+	CommonPrefixes []string
+	IsTruncated    bool
+	// V2で使う次のページのトークンと、V1で使う最後に返したキー
+	NextContinuationToken string
+	NextMarker            string
+}
+
+func noSuchBucketError() S3Error {
+	return S3Error{Status: 404, Code: "NoSuchBucket", Message: "The specified bucket does not exist."}
+}
+
+func noSuchKeyError() S3Error {
+	return S3Error{Status: 404, Code: "NoSuchKey", Message: "The specified key does not exist."}
+}
+
+// パスとして扱えるバケット名のみを受け付ける
+func validateS3BucketName(bucket string) error {
+	if bucket == "" || bucket == "." || bucket == ".." || strings.Contains(bucket, "/") {
+		return S3Error{Status: 400, Code: "InvalidBucketName", Message: "The specified bucket is not valid."}
+	}
+
+	return nil
+}
+
+// キーは空のセグメントや . .. を含まないパスに限る。末尾の / はフォルダを表す
+func validateS3Key(key string) error {
+	if len(key) > s3MaxKeyLength {
+		return S3Error{Status: 400, Code: "KeyTooLongError", Message: "Your key is too long."}
+	}
+
+	for _, segment := range strings.Split(strings.TrimSuffix(key, "/"), "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return S3Error{Status: 400, Code: "InvalidArgument", Message: "The key must be a path without empty, '.' or '..' segments."}
+		}
+	}
+
+	return nil
+}
+
+func isS3ErrorCode(err error, code string) bool {
+	var s3Err S3Error
+
+	return errors.As(err, &s3Err) && s3Err.Code == code
+}
+
+func isS3DirectoryKey(key string) bool {
+	return strings.HasSuffix(key, "/")
+}
+
+func (service *S3Service) deleteCache(user user.User) {
+	if err := service.FileRepo.DeleteCache(user.ID); err != nil {
+		log.Printf("Warning: Failed to delete cache of user %s: %v", user.ID, err)
+	}
+}
+
+func (service *S3Service) getBucket(user user.User, bucket string) (*file.File, error) {
+	if err := validateS3BucketName(bucket); err != nil {
+		return nil, err
+	}
+
+	f, err := resolveFilePath(service.Conn, service.FileRepo, user, bucket)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, noSuchBucketError()
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if file.FileKindFromEnString(f.Kind) != file.Directory {
+		return nil, noSuchBucketError()
+	}
+
+	return f, nil
+}
+
+// キーに対応する行を返す。フォルダのキーはディレクトリに、それ以外はファイルに対応する
+func (service *S3Service) resolveObject(user user.User, bucket string, key string) (*file.File, error) {
+	if _, err := service.getBucket(user, bucket); err != nil {
+		return nil, err
+	}
+	if err := validateS3Key(key); err != nil {
+		return nil, err
+	}
+
+	f, err := resolveFilePath(service.Conn, service.FileRepo, user, bucket+"/"+key)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, noSuchKeyError()
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if (file.FileKindFromEnString(f.Kind) == file.Directory) != isS3DirectoryKey(key) {
+		return nil, noSuchKeyError()
+	}
+
+	return f, nil
+}
+
+// ディレクトリを順にたどり、無いものは作成して最後のディレクトリのIDを返す
+func (service *S3Service) mkdirAll(user user.User, parent file.File, dir string) (*string, error) {
+	parentDirectoryID := &parent.ID
+	if dir == "" || dir == "." {
+		return parentDirectoryID, nil
+	}
+
+	for _, segment := range strings.Split(dir, "/") {
+		child, err := service.FileRepo.GetChildFileByName(service.Conn, user, parentDirectoryID, segment)
+		var notFoundErr repository.NotFoundError
+		if errors.As(err, &notFoundErr) {
+			child, err = service.RegistrationDirectoryService.Execute(user, segment, parentDirectoryID)
+		}
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if file.FileKindFromEnString(child.Kind) != file.Directory {
+			return nil, S3Error{Status: 409, Code: "InvalidRequest", Message: "An object already exists where a folder is required."}
+		}
+
+		parentDirectoryID = &child.ID
+	}
+
+	return parentDirectoryID, nil
+}
+
+// ListBuckets returns the top-level directories. Only the oldest of the same name is reachable by path.
+func (service *S3Service) ListBuckets(user user.User) ([]S3Bucket, error) {
+	children, err := service.FileRepo.GetChildFiles(service.Conn, user, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	seen := map[string]bool{}
+	buckets := []S3Bucket{}
+	for _, child := range children {
+		if seen[child.Name] {
+			continue
+		}
+		seen[child.Name] = true
+
+		if file.FileKindFromEnString(child.Kind) != file.Directory || validateS3BucketName(child.Name) != nil {
+			continue
+		}
+		buckets = append(buckets, S3Bucket{Name: child.Name, CreatedAt: child.CreatedAt})
+	}
+
+	return buckets, nil
+}
+
+func (service *S3Service) HeadBucket(user user.User, bucket string) error {
+	_, err := service.getBucket(user, bucket)
+
+	return err
+}
+
+func (service *S3Service) CreateBucket(user user.User, bucket string) error {
+	if err := validateS3BucketName(bucket); err != nil {
+		return err
+	}
+
+	f, err := resolveFilePath(service.Conn, service.FileRepo, user, bucket)
+	if err == nil && file.FileKindFromEnString(f.Kind) == file.Directory {
+		return S3Error{Status: 409, Code: "BucketAlreadyOwnedByYou", Message: "Your previous request to create the named bucket succeeded and you already own it."}
+	}
+	if err == nil {
+		return S3Error{Status: 409, Code: "BucketAlreadyExists", Message: "A file with the same name already exists."}
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return errors.WithStack(err)
+	}
+
+	if _, err := service.RegistrationDirectoryService.Execute(user, bucket, nil); err != nil {
+		return errors.WithStack(err)
+	}
+	service.deleteCache(user)
+
+	return nil
+}
+
+func (service *S3Service) DeleteBucket(user user.User, bucket string) error {
+	f, err := service.getBucket(user, bucket)
+	if err != nil {
+		return err
+	}
+
+	children, err := service.FileRepo.GetChildFiles(service.Conn, user, &f.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(children) > 0 {
+		return S3Error{Status: 409, Code: "BucketNotEmpty", Message: "The bucket you tried to delete is not empty."}
+	}
+
+	return service.deleteFiles(user, []string{f.ID})
+}
+
+func (service *S3Service) deleteFiles(user user.User, ids []string) error {
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	blobIDs := []string{}
+	for _, id := range ids {
+		deletedBlobIDs, err := service.FileRepo.DeleteFile(tx, user, id)
+		if err != nil {
+			tx.Rollback()
+			return errors.WithStack(err)
+		}
+		blobIDs = append(blobIDs, deletedBlobIDs...)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+	deleteUnreferencedBlobs(service.Conn, service.FileRepo, blobIDs)
+	service.deleteCache(user)
+
+	return nil
+}
+
+// ListObjects lists the keys below the bucket in UTF-8 binary order.
+// The size and the ETag are computed only for the keys of the returned page.
+func (service *S3Service) ListObjects(user user.User, bucket string, input S3ListObjectsInput) (*S3ListObjectsResult, error) {
+	bucketDir, err := service.getBucket(user, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	maxKeys := input.MaxKeys
+	if maxKeys < 0 || maxKeys > s3MaxListKeys {
+		maxKeys = s3MaxListKeys
+	}
+
+	// 前のページの最後がフォルダ(CommonPrefix)の場合は、その配下を全て飛ばす
+	marker, skipPrefix := input.Marker, ""
+	if input.ContinuationToken != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(input.ContinuationToken)
+		if err != nil || len(decoded) < 2 {
+			return nil, S3Error{Status: 400, Code: "InvalidArgument", Message: "The continuation token provided is incorrect."}
+		}
+		marker = string(decoded[2:])
+		if strings.HasPrefix(string(decoded), "p:") {
+			skipPrefix = marker
+		}
+	} else if input.StartAfter > marker {
+		marker = input.StartAfter
+	}
+	if input.Marker != "" && input.Delimiter != "" && strings.HasSuffix(input.Marker, input.Delimiter) {
+		skipPrefix = input.Marker
+	}
+
+	entries, err := service.listKeys(user, *bucketDir, input.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &S3ListObjectsResult{Objects: []S3Object{}, CommonPrefixes: []string{}}
+	page := []file.File{}
+	last, lastIsPrefix := "", false
+	for _, entry := range entries {
+		if entry.key <= marker || !strings.HasPrefix(entry.key, input.Prefix) {
+			continue
+		}
+		if skipPrefix != "" && strings.HasPrefix(entry.key, skipPrefix) {
+			continue
+		}
+
+		commonPrefix := ""
+		if input.Delimiter != "" {
+			if i := strings.Index(entry.key[len(input.Prefix):], input.Delimiter); i >= 0 {
+				commonPrefix = entry.key[:len(input.Prefix)+i+len(input.Delimiter)]
+			}
+		}
+		if commonPrefix != "" && lastIsPrefix && commonPrefix == last {
+			continue
+		}
+
+		if len(page)+len(result.CommonPrefixes) >= maxKeys {
+			result.IsTruncated = maxKeys > 0
+			break
+		}
+
+		if commonPrefix != "" {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix)
+			last, lastIsPrefix = commonPrefix, true
+			continue
+		}
+
+		result.Objects = append(result.Objects, S3Object{Key: entry.key})
+		page = append(page, entry.file)
+		last, lastIsPrefix = entry.key, false
+	}
+
+	for i, f := range page {
+		object, err := service.describeObject(result.Objects[i].Key, f)
+		if err != nil {
+			return nil, err
+		}
+		result.Objects[i] = *object
+	}
+
+	if result.IsTruncated {
+		kind := "k:"
+		if lastIsPrefix {
+			kind = "p:"
+		}
+		result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(kind + last))
+		result.NextMarker = last
+	}
+
+	return result, nil
+}
+
+type s3KeyEntry struct {
+	key  string
+	file file.File
+}
+
+// バケット配下の全てのキーを作る。prefixと関係の無いディレクトリはたどらない
+func (service *S3Service) listKeys(user user.User, bucketDir file.File, prefix string) ([]s3KeyEntry, error) {
+	descendants, err := service.FileRepo.GetDescendantFiles(service.Conn, user, bucketDir.ID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// パスで参照できるのは同じ名前の中で最も古いもの
+	slices.SortStableFunc(descendants, func(a, b file.File) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	children := map[string][]file.File{}
+	for _, descendant := range descendants {
+		if descendant.ParentDirectoryID != nil {
+			children[*descendant.ParentDirectoryID] = append(children[*descendant.ParentDirectoryID], descendant)
+		}
+	}
+
+	entries := []s3KeyEntry{}
+	var walk func(parentID string, keyPrefix string)
+	walk = func(parentID string, keyPrefix string) {
+		seen := map[string]bool{}
+		for _, child := range children[parentID] {
+			if seen[child.Name] || validateS3Key(child.Name) != nil || strings.Contains(child.Name, "/") {
+				continue
+			}
+			seen[child.Name] = true
+
+			key := keyPrefix + child.Name
+			if file.FileKindFromEnString(child.Kind) != file.Directory {
+				entries = append(entries, s3KeyEntry{key: key, file: child})
+				continue
+			}
+
+			key += "/"
+			entries = append(entries, s3KeyEntry{key: key, file: child})
+			if strings.HasPrefix(key, prefix) || strings.HasPrefix(prefix, key) {
+				walk(child.ID, key)
+			}
+		}
+	}
+	walk(bucketDir.ID, "")
+
+	slices.SortFunc(entries, func(a, b s3KeyEntry) int {
+		return strings.Compare(a.key, b.key)
+	})
+
+	return entries, nil
+}
+
+func (service *S3Service) describeObject(key string, f file.File) (*S3Object, error) {
+	object := &S3Object{Key: key, LastModified: f.UpdatedAt, ETag: S3DirectoryETag}
+	if file.FileKindFromEnString(f.Kind) == file.Directory {
+		return object, nil
+	}
+
+	// 外部URLのみを登録したファイルは大きさ0として扱う
+	localPath, err := service.FileRepo.GetLocalPath(f)
+	if err != nil {
+		return object, nil
+	}
+
+	stat, err := os.Stat(localPath)
+	if err != nil {
+		return object, nil
+	}
+	object.Size = stat.Size()
+
+	object.ETag, err = service.FileRepo.GetContentHash(localPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return object, nil
+}
+
+// GetObject returns the blob to serve. It returns nil with the object of a folder.
+func (service *S3Service) GetObject(user user.User, bucket string, key string) (*ServedFile, *S3Object, error) {
+	f, err := service.resolveObject(user, bucket, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	object, err := service.describeObject(key, *f)
+	if err != nil {
+		return nil, nil, err
+	}
+	if file.FileKindFromEnString(f.Kind) == file.Directory {
+		return nil, object, nil
+	}
+
+	localPath, err := service.FileRepo.GetLocalPath(*f)
+	if err != nil {
+		return nil, nil, noSuchKeyError()
+	}
+
+	served, err := newServedFile(service.FileRepo, *f, localPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return served, object, nil
+}
+
+// PutObject writes the body into a new blob and registers it at the key, creating the parent folders.
+// An existing object at the key keeps its ID and only its content is replaced.
+func (service *S3Service) PutObject(user user.User, bucket string, key string, body *S3RequestBody) (*S3Object, error) {
+	bucketDir, err := service.getBucket(user, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateS3Key(key); err != nil {
+		return nil, err
+	}
+
+	// フォルダを表すキーはディレクトリを作成する
+	if isS3DirectoryKey(key) {
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			return nil, err
+		}
+		if err := body.Verify(); err != nil {
+			return nil, err
+		}
+		if _, err := service.mkdirAll(user, *bucketDir, strings.TrimSuffix(key, "/")); err != nil {
+			return nil, err
+		}
+		service.deleteCache(user)
+
+		return &S3Object{Key: key, LastModified: time.Now(), ETag: S3DirectoryETag}, nil
+	}
+
+	if err := service.checkQuota(user, body.length); err != nil {
+		return nil, err
+	}
+
+	blob, err := service.StoreBlobService.Create(path.Base(key))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if _, err := io.Copy(blob, body); err != nil {
+		blob.Remove()
+		return nil, err
+	}
+	if err := body.Verify(); err != nil {
+		blob.Remove()
+		return nil, err
+	}
+
+	return service.storeObject(user, *bucketDir, key, blob)
+}
+
+// 書き込み済みの実体をキーの位置に登録する。失敗した場合は実体を削除する
+func (service *S3Service) storeObject(user user.User, bucketDir file.File, key string, blob *Blob) (*S3Object, error) {
+	if err := blob.Close(); err != nil {
+		blob.Remove()
+		return nil, errors.WithStack(err)
+	}
+
+	parentDirectoryID, err := service.mkdirAll(user, bucketDir, path.Dir(key))
+	if err != nil {
+		blob.Remove()
+		return nil, err
+	}
+
+	var existing *file.File
+	child, err := service.FileRepo.GetChildFileByName(service.Conn, user, parentDirectoryID, path.Base(key))
+	if err == nil {
+		if file.FileKindFromEnString(child.Kind) == file.Directory {
+			blob.Remove()
+			return nil, S3Error{Status: 409, Code: "InvalidRequest", Message: "A folder already exists at the key."}
+		}
+		existing = child
+	} else if !errors.As(err, new(repository.NotFoundError)) {
+		blob.Remove()
+		return nil, errors.WithStack(err)
+	}
+
+	registered, err := service.StoreBlobService.Register(user, parentDirectoryID, path.Base(key), existing, *blob)
+	if err != nil {
+		blob.Remove()
+		if errors.As(err, new(QuotaExceededError)) {
+			return nil, s3QuotaExceededError()
+		}
+		return nil, errors.WithStack(err)
+	}
+
+	return service.describeObject(key, *registered)
+}
+
+// DeleteObject deletes the file at the key. Folders are deleted only when empty, and missing keys are not an error.
+func (service *S3Service) DeleteObject(user user.User, bucket string, key string) error {
+	f, err := service.resolveObject(user, bucket, key)
+	if isS3ErrorCode(err, "NoSuchKey") {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if file.FileKindFromEnString(f.Kind) == file.Directory {
+		children, err := service.FileRepo.GetChildFiles(service.Conn, user, &f.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if len(children) > 0 {
+			return nil
+		}
+	}
+
+	return service.deleteFiles(user, []string{f.ID})
+}
+
+type S3DeleteResult struct {
+	Key string
+	Err error
+}
+
+// DeleteObjects deletes the keys one by one. Folders are deleted after the files, deepest first, so that they are empty by then.
+func (service *S3Service) DeleteObjects(user user.User, bucket string, keys []string) ([]S3DeleteResult, error) {
+	if _, err := service.getBucket(user, bucket); err != nil {
+		return nil, err
+	}
+
+	ordered := slices.Clone(keys)
+	slices.SortStableFunc(ordered, func(a, b string) int {
+		if isS3DirectoryKey(a) != isS3DirectoryKey(b) {
+			if isS3DirectoryKey(a) {
+				return 1
+			}
+			return -1
+		}
+		return strings.Count(b, "/") - strings.Count(a, "/")
+	})
+
+	results := make([]S3DeleteResult, 0, len(ordered))
+	for _, key := range ordered {
+		err := service.DeleteObject(user, bucket, key)
+		if err != nil && !errors.As(err, new(S3Error)) {
+			log.Printf("Warning: Failed to delete object %s/%s: %+v", bucket, key, err)
+			err = S3Error{Status: 500, Code: "InternalError", Message: "We encountered an internal error. Please try again."}
+		}
+		results = append(results, S3DeleteResult{Key: key, Err: err})
+	}
+
+	return results, nil
+}
+
+// 書き込む前に、申告された大きさの分の容量が残っているかを確認する。大きさが分からない場合は登録時に確認する
+func (service *S3Service) checkQuota(user user.User, size int64) error {
+	if size < 0 {
+		return nil
+	}
+
+	if err := service.StoreBlobService.QuotaService.Check(user, size); err != nil {
+		if errors.As(err, new(QuotaExceededError)) {
+			return s3QuotaExceededError()
+		}
+		return err
+	}
+
+	return nil
+}
+
+func s3QuotaExceededError() S3Error {
+	return S3Error{Status: 403, Code: "QuotaExceeded", Message: "The upload exceeds the storage quota of the user."}
+}
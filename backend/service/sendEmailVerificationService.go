@@ -0,0 +1,43 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/mailer"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+type SendEmailVerificationService struct {
+	Conn          *sqlx.DB
+	UserTokenRepo repository.UserTokenRepositoryInterface
+	Mailer        mailer.Mailer
+}
+
+// メールアドレスの確認用のリンクを送る。以前に送ったリンクは使えなくなる
+func (service *SendEmailVerificationService) Execute(owner user.User, now time.Time) error {
+	if owner.EmailVerifiedAt != nil {
+		return errors.WithStack(EmailAlreadyVerifiedError{Code: 409, Message: "メールアドレスは確認済みです。"})
+	}
+
+	token, err := issueUserToken(service.Conn, service.UserTokenRepo, owner, user.TokenPurposeEmailVerification, now)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	sendMailInBackground(service.Mailer, mailer.Message{
+		To:      owner.Email,
+		Subject: "メールアドレスの確認",
+		Body: fmt.Sprintf(
+			"以下のリンクを開いて、メールアドレスを確認してください。リンクの有効期限は%d時間です。\n\n%s\n\nこのメールに心当たりが無い場合は破棄してください。\n",
+			int(user.EmailVerificationTokenTimeout.Hours()),
+			frontendUrl("/verify", token),
+		),
+	})
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"path/filepath"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+// 配信するファイルと、レスポンスヘッダに必要な情報
+type ServedFile struct {
+	Path        string
+	Filename    string
+	MimeType    string
+	ContentHash string
+}
+
+func newServedFile(fileRepo repository.FileRepositoryInterface, f file.File, servedPath string) (*ServedFile, error) {
+	contentHash, err := fileRepo.GetContentHash(servedPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// MIMEタイプを保存する前に登録されたファイルは配信時に判定する
+	mimeType := helper.DetectMimeType(servedPath)
+	if f.MimeType != nil && *f.MimeType != "" {
+		mimeType = *f.MimeType
+	}
+
+	filename := f.Name
+	// 名前に拡張子が無い場合は実体の拡張子を補う
+	if filepath.Ext(filename) == "" {
+		filename += filepath.Ext(servedPath)
+	}
+
+	return &ServedFile{
+		Path:        servedPath,
+		Filename:    filename,
+		MimeType:    mimeType,
+		ContentHash: contentHash,
+	}, nil
+}
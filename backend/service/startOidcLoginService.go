@@ -0,0 +1,51 @@
+package service
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/oidc"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/gofiber/fiber/v2/middleware/session"
+)
+
+// コールバックまで認可リクエストをCookieのセッションに保存するキー
+const (
+	oidcStateKey        = "oidc_state"
+	oidcNonceKey        = "oidc_nonce"
+	oidcCodeVerifierKey = "oidc_code_verifier"
+	oidcExpiresAtKey    = "oidc_expires_at"
+)
+
+type StartOidcLoginService struct {
+	OidcRepo repository.OidcRepositoryInterface
+}
+
+// 認可リクエストを作成し、IdPの認可エンドポイントのURLを返す
+func (service *StartOidcLoginService) Execute(sess *session.Session, now time.Time) (string, error) {
+	if !service.OidcRepo.IsEnabled() {
+		return "", errors.WithStack(OidcError{Code: 404, Message: "シングルサインオンは設定されていません。"})
+	}
+
+	request, err := oidc.NewAuthorizationRequest(now)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	authorizationUrl, err := service.OidcRepo.AuthorizationUrl(*request)
+	if err != nil {
+		return "", errors.WithStack(errors.Join(OidcError{Code: 502, Message: "IdPに接続できませんでした。"}, err))
+	}
+
+	sess.Set(oidcStateKey, request.State)
+	sess.Set(oidcNonceKey, request.Nonce)
+	sess.Set(oidcCodeVerifierKey, request.CodeVerifier)
+	sess.Set(oidcExpiresAtKey, request.ExpiresAt.Unix())
+
+	if err := sess.Save(); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return authorizationUrl, nil
+}
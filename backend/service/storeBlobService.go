@@ -0,0 +1,156 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+// StoreBlobService writes new blobs into the storage mounts and registers them as files.
+type StoreBlobService struct {
+	Conn              *sqlx.DB
+	FileRepo          repository.FileRepositoryInterface
+	EnqueueJobService EnqueueJobService
+	QuotaService      QuotaService
+}
+
+// 書き込み中の実体。IDはストレージ上のファイル名から拡張子を除いたもの
+type Blob struct {
+	*os.File
+	ID        string
+	LocalPath string
+}
+
+// 最も空いているマウントに、filenameと同じ拡張子の空の実体を作成する
+func (service *StoreBlobService) Create(filename string) (*Blob, error) {
+	generatedID, err := helper.GenerateSnowflake()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	storagePath, err := service.FileRepo.GetStoreStoragePath()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	localPath := fmt.Sprintf("storage/files/%s/%s%s", storagePath, *generatedID, filepath.Ext(filename))
+	f, err := os.OpenFile(localPath, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0666)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &Blob{File: f, ID: *generatedID, LocalPath: localPath}, nil
+}
+
+// 書き込みに失敗した実体を削除する
+func (blob *Blob) Remove() {
+	blob.File.Close()
+
+	if err := os.Remove(blob.LocalPath); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: Failed to delete blob %s: %v", blob.LocalPath, err)
+	}
+}
+
+// Register registers the closed blob as a file named name in the directory.
+// When existing is given, only its content is replaced and the ID, name and shares are kept.
+// It fails with QuotaExceededError when the blob does not fit in the quota of the user.
+func (service *StoreBlobService) Register(user user.User, parentDirectoryID *string, name string, existing *file.File, blob Blob) (*file.File, error) {
+	info, err := os.Stat(blob.LocalPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	url := service.FileRepo.GetUrl(blob.LocalPath)
+	mimeType := helper.DetectMimeType(blob.LocalPath)
+	kind := file.FileKindFromFilename(name)
+	if kind == file.Unknown {
+		kind = file.FileKindFromMimeType(mimeType)
+	}
+	now := time.Now()
+
+	registered := file.File{
+		ID:                blob.ID,
+		UserID:            user.ID,
+		ParentDirectoryID: parentDirectoryID,
+		Kind:              kind.ToEnString(),
+		Name:              name,
+		CreatedAt:         now,
+	}
+	if existing != nil {
+		registered = *existing
+		registered.Kind = kind.ToEnString()
+		registered.TakenAt = nil
+		registered.Width = nil
+		registered.Height = nil
+		registered.Metadata = nil
+	}
+	registered.Url = &url
+	registered.BlobID = &blob.ID
+	registered.MimeType = &mimeType
+	registered.Size = info.Size()
+	registered.UpdatedAt = now
+
+	// 既存のファイルの実体を差し替える場合は、差し替える前の実体の分を除いて確認する
+	addedBytes := registered.Size
+	if existing != nil {
+		addedBytes -= existing.Size
+	}
+
+	if kind == file.Image {
+		applyImageMetadata(&registered, blob.LocalPath)
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// tusのアップロードは実体と同じIDで予約している
+	if err := service.QuotaService.CheckTx(tx, user, addedBytes, blob.ID); err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if existing != nil {
+		_, err = service.FileRepo.UpdateFileContent(tx, user, registered)
+	} else {
+		_, err = service.FileRepo.RegistrationFile(tx, user, registered)
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	// 既存のファイルの実体を差し替えた場合、行のIDと実体のIDは異なる
+	if _, err := service.EnqueueJobService.ExecuteBlobJobsTx(tx, user.ID, blob.ID, registered); err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// 差し替える前の実体は、他に参照するファイルが無ければ削除する
+	if existing != nil && existing.BlobID != nil && *existing.BlobID != blob.ID {
+		deleteUnreferencedBlobs(service.Conn, service.FileRepo, []string{*existing.BlobID})
+	}
+
+	if err := service.FileRepo.DeleteCache(user.ID); err != nil {
+		log.Printf("Warning: Failed to delete cache of user %s: %v", user.ID, err)
+	}
+
+	service.QuotaService.Warn(user, addedBytes)
+
+	return &registered, nil
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/helper/exif"
+)
+
+const (
+	spriteColumns    = 5
+	spriteRows       = 5
+	spriteTileWidth  = 160
+	pdfRenderMaxSize = 1024
+	thumbnailTimeout = 5 * time.Minute
+)
+
+type ThumbnailService interface {
+	GenerateDerivatives(ctx context.Context, kind file.FileKind, inputPath, derivativeDir string) error
+	GetThumbnail(ctx context.Context, kind file.FileKind, inputPath, derivativeDir string, size int) (string, error)
+	GetSprite(ctx context.Context, inputPath, derivativeDir string) (string, error)
+}
+
+type thumbnailService struct {
+	locks sync.Map
+}
+
+func NewThumbnailService() ThumbnailService {
+	return &thumbnailService{}
+}
+
+// GenerateDerivatives creates every derivative for the file so that the first preview is served without waiting
+func (ts *thumbnailService) GenerateDerivatives(ctx context.Context, kind file.FileKind, inputPath, derivativeDir string) error {
+	log.Printf("Starting derivative generation: %s -> %s", inputPath, derivativeDir)
+
+	for _, size := range file.ThumbnailSizes {
+		if _, err := ts.GetThumbnail(ctx, kind, inputPath, derivativeDir, size); err != nil {
+			return err
+		}
+	}
+
+	if kind == file.Video {
+		if _, err := ts.GetSprite(ctx, inputPath, derivativeDir); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Derivative generation completed: %s", derivativeDir)
+
+	return nil
+}
+
+// GetThumbnail returns the path of the WebP thumbnail, generating it if it is missing
+func (ts *thumbnailService) GetThumbnail(ctx context.Context, kind file.FileKind, inputPath, derivativeDir string, size int) (string, error) {
+	if !kind.HasDerivatives() {
+		return "", fmt.Errorf("thumbnail is not supported for kind: %s", kind.ToEnString())
+	}
+	if !file.IsThumbnailSize(size) {
+		return "", fmt.Errorf("unsupported thumbnail size: %d", size)
+	}
+
+	outputPath := filepath.Join(derivativeDir, fmt.Sprintf("thumbnail_%d.webp", size))
+
+	return outputPath, ts.generateOnce(outputPath, func(tmpPath string) error {
+		sourcePath, err := ts.getThumbnailSource(ctx, kind, inputPath, derivativeDir)
+		if err != nil {
+			return err
+		}
+
+		// 長辺をsizeに収める(元画像より大きくはしない)
+		filter := fmt.Sprintf("scale=w='min(%d,iw)':h='min(%d,ih)':force_original_aspect_ratio=decrease", size, size)
+
+		// 写真はEXIFのOrientationに従って正しい向きに回転してから縮小する
+		if kind == file.Image {
+			if rotate := orientationFilter(sourcePath); rotate != "" {
+				filter = rotate + "," + filter
+			}
+		}
+
+		return runCommand(
+			ctx,
+			thumbnailTimeout,
+			"ffmpeg",
+			// ffmpegのバージョンによってはEXIFの向きを自動で適用するため、二重に回転しないよう無効にする
+			"-noautorotate",
+			"-i", sourcePath,
+			"-vf", filter,
+			"-frames:v", "1",
+			"-c:v", "libwebp",
+			"-quality", "80",
+			"-f", "webp",
+			"-y",
+			tmpPath,
+		)
+	})
+}
+
+// GetSprite returns the path of the sprite sheet used for seek previews of videos
+func (ts *thumbnailService) GetSprite(ctx context.Context, inputPath, derivativeDir string) (string, error) {
+	outputPath := filepath.Join(derivativeDir, "sprite.webp")
+
+	return outputPath, ts.generateOnce(outputPath, func(tmpPath string) error {
+		duration, err := probeDuration(ctx, inputPath)
+		if err != nil {
+			return err
+		}
+
+		// 動画全体から均等にタイル数分のフレームを取り出す
+		fps := 1.0
+		if duration > 0 {
+			fps = float64(spriteColumns*spriteRows) / duration
+		}
+
+		filter := fmt.Sprintf("fps=%f,scale=%d:-2,tile=%dx%d", fps, spriteTileWidth, spriteColumns, spriteRows)
+
+		return runCommand(
+			ctx,
+			thumbnailTimeout,
+			"ffmpeg",
+			"-i", inputPath,
+			"-vf", filter,
+			"-frames:v", "1",
+			"-c:v", "libwebp",
+			"-quality", "70",
+			"-f", "webp",
+			"-y",
+			tmpPath,
+		)
+	})
+}
+
+// getThumbnailSource returns the still image that thumbnails of the kind are scaled from
+func (ts *thumbnailService) getThumbnailSource(ctx context.Context, kind file.FileKind, inputPath, derivativeDir string) (string, error) {
+	switch kind {
+	case file.Video:
+		return ts.getPoster(ctx, inputPath, derivativeDir)
+	case file.PDF:
+		return ts.getPDFFirstPage(ctx, inputPath, derivativeDir)
+	default:
+		return inputPath, nil
+	}
+}
+
+// getPoster extracts a representative frame of the video
+func (ts *thumbnailService) getPoster(ctx context.Context, inputPath, derivativeDir string) (string, error) {
+	outputPath := filepath.Join(derivativeDir, "poster.webp")
+
+	return outputPath, ts.generateOnce(outputPath, func(tmpPath string) error {
+		duration, err := probeDuration(ctx, inputPath)
+		if err != nil {
+			return err
+		}
+
+		// 冒頭の暗転を避けるため、十分な長さがあれば1秒目を使う
+		seek := "0"
+		if duration > 2 {
+			seek = "1"
+		}
+
+		return runCommand(
+			ctx,
+			thumbnailTimeout,
+			"ffmpeg",
+			"-ss", seek,
+			"-i", inputPath,
+			"-frames:v", "1",
+			"-c:v", "libwebp",
+			"-quality", "85",
+			"-f", "webp",
+			"-y",
+			tmpPath,
+		)
+	})
+}
+
+// getPDFFirstPage renders the first page of the PDF with pdftoppm
+func (ts *thumbnailService) getPDFFirstPage(ctx context.Context, inputPath, derivativeDir string) (string, error) {
+	outputPath := filepath.Join(derivativeDir, "page_1.png")
+
+	return outputPath, ts.generateOnce(outputPath, func(tmpPath string) error {
+		// pdftoppmは出力先に拡張子を付与するため、拡張子を除いたパスを渡す
+		return runCommand(
+			ctx,
+			thumbnailTimeout,
+			"pdftoppm",
+			"-png",
+			"-f", "1",
+			"-l", "1",
+			"-singlefile",
+			"-scale-to", strconv.Itoa(pdfRenderMaxSize),
+			inputPath,
+			strings.TrimSuffix(tmpPath, ".png"),
+		)
+	})
+}
+
+// orientationFilter returns the ffmpeg filter that applies the EXIF orientation of the image
+func orientationFilter(inputPath string) string {
+	e, err := exif.Read(inputPath)
+	if err != nil {
+		return ""
+	}
+
+	switch e.Orientation {
+	case 2:
+		return "hflip"
+	case 3:
+		return "hflip,vflip"
+	case 4:
+		return "vflip"
+	case 5:
+		return "transpose=0"
+	case 6:
+		return "transpose=1"
+	case 7:
+		return "transpose=3"
+	case 8:
+		return "transpose=2"
+	default:
+		return ""
+	}
+}
+
+// generateOnce runs generate only when outputPath does not exist yet.
+// The output is written to a temporary file and renamed so that readers never see a partial file.
+func (ts *thumbnailService) generateOnce(outputPath string, generate func(tmpPath string) error) error {
+	lock, _ := ts.locks.LoadOrStore(outputPath, &sync.Mutex{})
+	mutex := lock.(*sync.Mutex)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, err := os.Stat(outputPath); err == nil {
+		return nil
+	}
+
+	ext := filepath.Ext(outputPath)
+	tmpPath := strings.TrimSuffix(outputPath, ext) + ".tmp" + ext
+	defer os.Remove(tmpPath)
+
+	if err := generate(tmpPath); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, outputPath); err != nil {
+		return fmt.Errorf("failed to save derivative: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/job"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/video"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+type TranscodeVideoService struct {
+	Conn                    *sqlx.DB
+	FileRepo                repository.FileRepositoryInterface
+	VideoCompressionService VideoCompressionService
+}
+
+// ジョブの結果として保存される
+type TranscodeVideoResult struct {
+	HLS        bool   `json:"hls"`
+	Compressed bool   `json:"compressed"`
+	Profile    string `json:"profile,omitempty"`
+	SkipReason string `json:"skip_reason,omitempty"`
+}
+
+func (service *TranscodeVideoService) Execute(ctx context.Context, blobID string, localPath string, filename string) (*TranscodeVideoResult, error) {
+	setting := service.FileRepo.GetVideoSetting()
+
+	derivativeDir, err := service.FileRepo.GetDerivativeDir(localPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := service.VideoCompressionService.GenerateHLS(ctx, localPath, filepath.Join(derivativeDir, "hls"), setting.HLS); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	result := &TranscodeVideoResult{HLS: true}
+
+	if setting.KeepOriginal {
+		result.SkipReason = "keep_original is enabled"
+		return result, nil
+	}
+
+	// 元ファイル名が無い場合は保存されているファイル名の拡張子で判定する
+	profile, ok := setting.FindProfile(cmp.Or(filename, localPath), helper.DetectMimeType(localPath))
+	if !ok {
+		result.SkipReason = "no profile matches the file"
+		return result, nil
+	}
+	result.Profile = profile.Name
+
+	disabled, err := service.FileRepo.IsCompressionDisabled(service.Conn, blobID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if disabled {
+		result.SkipReason = "compression is disabled for the file or its directory"
+		return result, nil
+	}
+
+	shouldCompress, reason, err := service.VideoCompressionService.ShouldCompress(ctx, localPath, *profile)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if !shouldCompress {
+		result.SkipReason = reason
+		return result, nil
+	}
+
+	if err := service.replaceOriginal(ctx, blobID, localPath, derivativeDir, *profile); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	result.Compressed = true
+
+	return result, nil
+}
+
+func (service *TranscodeVideoService) Handle(ctx context.Context, j job.Job) (any, error) {
+	var payload job.BlobPayload
+	if err := json.Unmarshal(j.Payload, &payload); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// リトライ時は前回の実行で元ファイルが置き換えられている可能性があるため、実行時に解決する
+	localPath, err := service.FileRepo.FindBlobPath(payload.BlobID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return service.Execute(ctx, payload.BlobID, localPath, payload.Filename)
+}
+
+// 元ファイルを圧縮済みMP4に置き換え、参照しているファイルのURLを同一トランザクションで更新する
+func (service *TranscodeVideoService) replaceOriginal(ctx context.Context, blobID string, localPath string, derivativeDir string, profile video.Profile) error {
+	compressedPath := filepath.Join(filepath.Dir(localPath), service.VideoCompressionService.GetCompressedFilename(filepath.Base(localPath)))
+
+	// 圧縮中のファイルが実体として見つからないよう、派生ファイルのディレクトリで作業する
+	tmpPath := filepath.Join(derivativeDir, "compressing.mp4")
+	defer os.Remove(tmpPath)
+
+	if err := service.VideoCompressionService.CompressVideo(ctx, localPath, tmpPath, profile); err != nil {
+		return errors.WithStack(err)
+	}
+
+	compressedInfo, err := os.Stat(tmpPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// 使用量は圧縮後の大きさにする
+	userIDs, err := service.FileRepo.UpdateBlobFiles(tx, blobID, service.FileRepo.GetUrl(compressedPath), helper.DetectMimeType(tmpPath), compressedInfo.Size())
+	if err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	// 元ファイルと同名の場合はrenameで原子的に置き換わる
+	if err := os.Rename(tmpPath, compressedPath); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		if compressedPath != localPath {
+			os.Remove(compressedPath)
+		}
+		return errors.WithStack(err)
+	}
+
+	if compressedPath != localPath {
+		if err := os.Remove(localPath); err != nil {
+			log.Printf("Warning: Failed to delete original file %s: %v", localPath, err)
+		}
+	}
+
+	for _, userID := range userIDs {
+		if err := service.FileRepo.DeleteCache(userID); err != nil {
+			log.Printf("Warning: Failed to delete cache of user %s: %v", userID, err)
+		}
+	}
+
+	log.Printf("Original video replaced: %s -> %s (%d files updated)", localPath, compressedPath, len(userIDs))
+
+	return nil
+}
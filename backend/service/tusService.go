@@ -0,0 +1,369 @@
+package service
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/apitoken"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/upload"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+const TusVersion = "1.0.0"
+
+// Upload-Checksumで受け付けるアルゴリズム
+var TusChecksumAlgorithms = []string{"sha1", "sha256", "md5", "crc32"}
+
+// 同じアップロードへの書き込みが重ならないようにする
+var tusUploadLocks sync.Map
+
+// TusService implements the tus 1.0 core protocol with the creation, termination, checksum and expiration extensions.
+// The bytes are written straight into a blob, which is registered as a file once the upload is complete.
+type TusService struct {
+	Conn             *sqlx.DB
+	FileRepo         repository.FileRepositoryInterface
+	UploadRepo       repository.UploadRepositoryInterface
+	StoreBlobService StoreBlobService
+
+	AuthorizeApiTokenDirectoryService AuthorizeApiTokenDirectoryService
+}
+
+func newTusChecksumHash(algorithm string) (hash.Hash, bool) {
+	switch algorithm {
+	case "sha1":
+		return sha1.New(), true
+	case "sha256":
+		return sha256.New(), true
+	case "md5":
+		return md5.New(), true
+	case "crc32":
+		return crc32.NewIEEE(), true
+	}
+
+	return nil, false
+}
+
+// ParseTusMetadata parses an Upload-Metadata header: comma separated pairs of a key and a base64 encoded value.
+func ParseTusMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, TusError{Code: 400, Message: "Upload-Metadataが正しくありません。"}
+		}
+
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, TusError{Code: 400, Message: "Upload-Metadataが正しくありません。"}
+		}
+		metadata[key] = string(value)
+	}
+
+	return metadata, nil
+}
+
+// Create registers a new upload of length bytes. The metadata must contain the filename and may contain the parent_directory_id.
+// apiToken is given only when the request was authenticated by an API token.
+func (service *TusService) Create(user user.User, apiToken *apitoken.ApiToken, length int64, metadataHeader string) (*upload.Upload, error) {
+	if length < 0 {
+		return nil, TusError{Code: 400, Message: "Upload-Lengthが正しくありません。"}
+	}
+	if length > upload.MaxSize {
+		return nil, TusError{Code: 413, Message: "ファイルが大きすぎます。"}
+	}
+
+	metadata, err := ParseTusMetadata(metadataHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	name := metadata["filename"]
+	if err := validateFileName(name); err != nil {
+		return nil, err
+	}
+
+	var parentDirectoryID *string
+	if id := metadata["parent_directory_id"]; id != "" {
+		parentDirectoryID = &id
+	}
+	parentDirectoryID, err = service.AuthorizeApiTokenDirectoryService.Execute(user, apiToken, parentDirectoryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkParentDirectory(service.Conn, service.FileRepo, user, parentDirectoryID); err != nil {
+		return nil, err
+	}
+
+	blob, err := service.StoreBlobService.Create(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	blob.Close()
+
+	now := time.Now()
+	u := upload.Upload{
+		ID:                blob.ID,
+		UserID:            user.ID,
+		ParentDirectoryID: parentDirectoryID,
+		Name:              name,
+		Length:            length,
+		Metadata:          metadataHeader,
+		LocalPath:         blob.LocalPath,
+		ExpiresAt:         now.Add(upload.TTL),
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		blob.Remove()
+		return nil, errors.WithStack(err)
+	}
+
+	// 書き込む前にUpload-Lengthの分の容量を予約する。同時に作成しても容量を超えないよう、ロックしてから確認する
+	if err := service.StoreBlobService.QuotaService.CheckTx(tx, user, length, ""); err != nil {
+		tx.Rollback()
+		blob.Remove()
+		return nil, err
+	}
+
+	if _, err := service.UploadRepo.RegistrationUpload(tx, u); err != nil {
+		tx.Rollback()
+		blob.Remove()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		blob.Remove()
+		return nil, errors.WithStack(err)
+	}
+
+	// 空のファイルはそのまま完了する
+	if length == 0 {
+		if err := service.complete(user, &u); err != nil {
+			return nil, err
+		}
+	}
+
+	return &u, nil
+}
+
+// Get returns the upload. Expired uploads are reported as gone.
+func (service *TusService) Get(user user.User, id string) (*upload.Upload, error) {
+	u, err := service.UploadRepo.GetUpload(service.Conn, user, id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if u.IsExpired(time.Now()) {
+		return nil, TusError{Code: 410, Message: "アップロードの期限が切れています。"}
+	}
+
+	return u, nil
+}
+
+// Write appends the body at offset, which must match the current offset of the upload.
+// When checksum is given as "<algorithm> <base64>", the bytes are kept only if it matches.
+// Without a checksum, the bytes received before an interrupted request are kept so the client can resume from there.
+func (service *TusService) Write(user user.User, id string, offset int64, body io.Reader, checksum string) (*upload.Upload, error) {
+	var checksumHash hash.Hash
+	var expected []byte
+	if checksum != "" {
+		algorithm, encoded, _ := strings.Cut(checksum, " ")
+
+		var ok bool
+		checksumHash, ok = newTusChecksumHash(algorithm)
+		if !ok {
+			return nil, TusError{Code: 400, Message: "対応していないチェックサムのアルゴリズムです。"}
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, TusError{Code: 400, Message: "Upload-Checksumが正しくありません。"}
+		}
+		expected = decoded
+	}
+
+	lock, _ := tusUploadLocks.LoadOrStore(id, &sync.Mutex{})
+	if !lock.(*sync.Mutex).TryLock() {
+		return nil, TusError{Code: 423, Message: "アップロード中です。"}
+	}
+	defer lock.(*sync.Mutex).Unlock()
+
+	u, err := service.Get(user, id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != u.Offset {
+		return nil, TusError{Code: 409, Message: "Upload-Offsetが一致しません。"}
+	}
+	if u.IsCompleted() {
+		return u, nil
+	}
+
+	f, err := os.OpenFile(u.LocalPath, os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	// 前回中断した書き込みや完了の登録に失敗した分は捨てる
+	if err := f.Truncate(u.Offset); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if _, err := f.Seek(u.Offset, io.SeekStart); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	reader := io.LimitReader(body, u.Length-u.Offset)
+	if checksumHash != nil {
+		reader = io.TeeReader(reader, checksumHash)
+	}
+
+	written, copyErr := io.Copy(f, reader)
+	if copyErr == nil {
+		if n, _ := body.Read(make([]byte, 1)); n > 0 {
+			f.Truncate(u.Offset)
+			return nil, TusError{Code: 413, Message: "Upload-Lengthを超えて書き込むことはできません。"}
+		}
+	}
+
+	if checksumHash != nil {
+		if copyErr != nil {
+			f.Truncate(u.Offset)
+			return nil, errors.WithStack(copyErr)
+		}
+		if !bytes.Equal(checksumHash.Sum(nil), expected) {
+			f.Truncate(u.Offset)
+			return nil, TusError{Code: 460, Message: "チェックサムが一致しません。"}
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	u.Offset += written
+	if u.Offset == u.Length && copyErr == nil {
+		if err := service.complete(user, u); err != nil {
+			return nil, err
+		}
+	} else if err := service.updateProgress(user, u); err != nil {
+		return nil, err
+	}
+
+	if copyErr != nil {
+		return nil, errors.WithStack(copyErr)
+	}
+
+	return u, nil
+}
+
+// 実体をファイルとして登録する。アップロードは期限まで完了した状態で残し、最後の応答を受け取れなかったクライアントに完了を返す
+func (service *TusService) complete(user user.User, u *upload.Upload) error {
+	// アップロード中に削除された場合はルートに作成する
+	if err := checkParentDirectory(service.Conn, service.FileRepo, user, u.ParentDirectoryID); err != nil {
+		if !errors.As(err, new(repository.NotFoundError)) {
+			return err
+		}
+		u.ParentDirectoryID = nil
+	}
+
+	registered, err := service.StoreBlobService.Register(user, u.ParentDirectoryID, u.Name, nil, Blob{ID: u.ID, LocalPath: u.LocalPath})
+	if err != nil {
+		// 予約した後に容量が減らされた場合は、登録できない実体を期限まで残さない
+		if errors.As(err, new(QuotaExceededError)) {
+			if deleteErr := deleteUpload(service.Conn, service.UploadRepo, user, *u); deleteErr != nil {
+				log.Printf("Warning: Failed to delete upload %s over quota: %v", u.ID, deleteErr)
+			}
+		}
+		return errors.WithStack(err)
+	}
+
+	u.FileID = &registered.ID
+
+	return service.updateProgress(user, u)
+}
+
+func (service *TusService) updateProgress(user user.User, u *upload.Upload) error {
+	now := time.Now()
+	u.ExpiresAt = now.Add(upload.TTL)
+	u.UpdatedAt = now
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := service.UploadRepo.UpdateUploadProgress(tx, user, *u); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// Terminate deletes the upload. The received bytes are removed unless the upload has already become a file.
+func (service *TusService) Terminate(user user.User, id string) error {
+	lock, _ := tusUploadLocks.LoadOrStore(id, &sync.Mutex{})
+	if !lock.(*sync.Mutex).TryLock() {
+		return TusError{Code: 423, Message: "アップロード中です。"}
+	}
+	defer lock.(*sync.Mutex).Unlock()
+
+	u, err := service.Get(user, id)
+	if err != nil {
+		return err
+	}
+
+	return deleteUpload(service.Conn, service.UploadRepo, user, *u)
+}
+
+// tusとWebSocketのアップロードで共通
+func deleteUpload(conn *sqlx.DB, uploadRepo repository.UploadRepositoryInterface, user user.User, u upload.Upload) error {
+	tx, err := conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := uploadRepo.DeleteUpload(tx, user, u.ID); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	tusUploadLocks.Delete(u.ID)
+
+	if !u.IsCompleted() {
+		if err := os.Remove(u.LocalPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("Warning: Failed to delete blob of upload %s: %v", u.ID, err)
+		}
+	}
+
+	return nil
+}
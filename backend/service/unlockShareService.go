@@ -0,0 +1,51 @@
+package service
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+// パスワード入力後の署名の有効期間
+const shareGrantTTL = time.Hour
+
+type UnlockShareService struct {
+	Conn             *sqlx.DB
+	ShareRepo        repository.ShareRepositoryInterface
+	RateLimitService RateLimitService
+}
+
+// パスワードを検証し、共有リンクへのアクセスに付与する署名付きクエリを返す
+func (service *UnlockShareService) Execute(token string, password string, ipAddress string) (string, time.Time, error) {
+	if err := service.RateLimitService.CheckSharePassword(token, ipAddress); err != nil {
+		return "", time.Time{}, err
+	}
+
+	s, err := service.ShareRepo.GetShareByToken(service.Conn, token)
+	if err != nil {
+		return "", time.Time{}, errors.WithStack(err)
+	}
+
+	if err := checkShareAvailable(*s); err != nil {
+		return "", time.Time{}, errors.WithStack(err)
+	}
+
+	if s.PasswordHash != nil {
+		if err := helper.CompareHashPassword(*s.PasswordHash, password); err != nil {
+			service.RateLimitService.FailSharePassword(token)
+			return "", time.Time{}, errors.WithStack(SharePasswordRequiredError{Code: 401, Message: "パスワードが違います。"})
+		}
+	}
+
+	// 共有リンクの有効期限を超えて使えないようにする
+	expiresAt := time.Now().Add(shareGrantTTL)
+	if s.ExpiresAt.Before(expiresAt) {
+		expiresAt = s.ExpiresAt
+	}
+
+	return helper.SignPathQuery(SharePath(token), expiresAt), expiresAt, nil
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+type UpdateCompressionService struct {
+	Conn     *sqlx.DB
+	FileRepo repository.FileRepositoryInterface
+}
+
+func (service *UpdateCompressionService) Execute(user user.User, fileIds []string, compressionDisabled bool) error {
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := service.FileRepo.UpdateCompressionDisabled(tx, user, fileIds, compressionDisabled); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := service.FileRepo.DeleteCache(user.ID); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+type UpdateUserDisabledService struct {
+	Conn            *sqlx.DB
+	UserRepo        repository.UserRepositoryInterface
+	UserSessionRepo repository.UserSessionRepositoryInterface
+}
+
+// ユーザーを無効・有効にする。無効にしたユーザーは全ての端末からログアウトさせる
+func (service *UpdateUserDisabledService) Execute(admin user.User, id string, disabled bool, now time.Time) (*user.User, error) {
+	target, err := adminTargetUser(service.Conn, service.UserRepo, admin, id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var disabledAt *time.Time
+	if disabled {
+		disabledAt = &now
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := service.UserRepo.UpdateDisabledAt(tx, *target, disabledAt); err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if disabled {
+		if err := service.UserSessionRepo.DeleteUserSessions(tx, *target); err != nil {
+			tx.Rollback()
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	log.Printf("Admin %s set disabled of user %s to %t", admin.ID, target.ID, disabled)
+
+	target.DisabledAt = disabledAt
+	return target, nil
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"log"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+type UpdateUserQuotaService struct {
+	Conn     *sqlx.DB
+	UserRepo repository.UserRepositoryInterface
+}
+
+// quotaBytesがnilの場合は、設定の既定値に戻す
+func (service *UpdateUserQuotaService) Execute(admin user.User, id string, quotaBytes *int64) (*user.User, error) {
+	target, err := service.UserRepo.GetUserByID(service.Conn, id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := service.UserRepo.UpdateQuotaBytes(tx, *target, quotaBytes); err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	log.Printf("Admin %s changed quota of user %s", admin.ID, target.ID)
+
+	target.QuotaBytes = quotaBytes
+	return target, nil
+}
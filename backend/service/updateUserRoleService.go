@@ -0,0 +1,42 @@
+package service
+
+import (
+	"log"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+type UpdateUserRoleService struct {
+	Conn     *sqlx.DB
+	UserRepo repository.UserRepositoryInterface
+}
+
+func (service *UpdateUserRoleService) Execute(admin user.User, id string, role user.Role) (*user.User, error) {
+	target, err := adminTargetUser(service.Conn, service.UserRepo, admin, id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := service.UserRepo.UpdateRole(tx, *target, role); err != nil {
+		tx.Rollback()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	log.Printf("Admin %s changed role of user %s to %s", admin.ID, target.ID, role)
+
+	target.Role = role
+	return target, nil
+}
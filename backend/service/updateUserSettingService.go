@@ -8,19 +8,20 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
-type GenerateTokenService struct {
+type UpdateUserSettingService struct {
 	Conn     *sqlx.DB
 	UserRepo repository.UserRepositoryInterface
 }
 
-func (service *GenerateTokenService) Execute(user user.User) (*string, error) {
+func (service *UpdateUserSettingService) Execute(user user.User, stripLocationMetadata bool) (*user.User, error) {
 	tx, err := service.Conn.Beginx()
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	token, err := service.UserRepo.GenerateToken(tx, user)
-	if err != nil {
+	user.StripLocationMetadata = stripLocationMetadata
+
+	if err := service.UserRepo.UpdateUserSetting(tx, user); err != nil {
 		tx.Rollback()
 		return nil, errors.WithStack(err)
 	}
@@ -29,5 +30,5 @@ func (service *GenerateTokenService) Execute(user user.User) (*string, error) {
 		return nil, errors.WithStack(err)
 	}
 
-	return token, nil
+	return &user, nil
 }
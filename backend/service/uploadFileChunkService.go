@@ -1,21 +1,79 @@
 package service
 
 import (
+	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/upload"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
 	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
 )
 
 type UploadFileChunkService struct {
-	FileRepo repository.FileRepositoryInterface
+	Conn         *sqlx.DB
+	FileRepo     repository.FileRepositoryInterface
+	UploadRepo   repository.UploadRepositoryInterface
+	QuotaService QuotaService
 }
 
-func (service *UploadFileChunkService) Execute(file []byte, fileID string, originalFilename string) (*repository.UploadResult, error) {
+// 実体を保存し、POST /files で upload_id を指定して登録できるようアップロードとして記録する
+func (service *UploadFileChunkService) Execute(user user.User, file []byte, fileID string, originalFilename string) (*repository.UploadResult, error) {
+	// 容量を超える場合はストレージに書き込まない
+	if err := service.QuotaService.Check(user, int64(len(file))); err != nil {
+		return nil, err
+	}
+
 	// 拡張子を取得
 	ext := filepath.Ext(originalFilename)
 
 	// ファイルID + 拡張子のファイル名を生成
 	filename := fileID + ext
 
-	return service.FileRepo.UploadFileChunk(file, filename)
+	result, err := service.FileRepo.UploadFileChunk(file, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	u := upload.Upload{
+		ID:        fileID,
+		UserID:    user.ID,
+		Name:      originalFilename,
+		Length:    int64(len(file)),
+		Offset:    int64(len(file)),
+		LocalPath: result.LocalPath,
+		ExpiresAt: now.Add(upload.TTL),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		os.Remove(result.LocalPath)
+		return nil, errors.WithStack(err)
+	}
+
+	// 登録されるまで容量を予約する。同時に完了しても容量を超えないよう、ロックしてから確認する
+	if err := service.QuotaService.CheckTx(tx, user, u.Length, ""); err != nil {
+		tx.Rollback()
+		os.Remove(result.LocalPath)
+		return nil, err
+	}
+
+	if _, err := service.UploadRepo.RegistrationUpload(tx, u); err != nil {
+		tx.Rollback()
+		os.Remove(result.LocalPath)
+		return nil, errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		os.Remove(result.LocalPath)
+		return nil, errors.WithStack(err)
+	}
+
+	return result, nil
 }
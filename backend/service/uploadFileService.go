@@ -0,0 +1,264 @@
+package service
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"io"
+	"log"
+	"mime/multipart"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/apitoken"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/file"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/upload"
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+)
+
+// Digestヘッダで検証できるアルゴリズム
+var uploadDigestAlgorithms = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+	"sha":     sha1.New,
+	"md5":     md5.New,
+}
+
+// クライアントが送った本文のダイジェスト
+type UploadDigests struct {
+	// base64のMD5
+	ContentMD5 string
+	// RFC 3230の "SHA-256=<base64>, MD5=<base64>" の形式
+	Digest string
+}
+
+type uploadDigestCheck struct {
+	name     string
+	hash     hash.Hash
+	expected []byte
+}
+
+func (digests UploadDigests) checks() ([]uploadDigestCheck, error) {
+	checks := []uploadDigestCheck{}
+
+	if digests.ContentMD5 != "" {
+		expected, err := base64.StdEncoding.DecodeString(strings.TrimSpace(digests.ContentMD5))
+		if err != nil || len(expected) != md5.Size {
+			return nil, InvalidUploadError{Code: 400, Message: "Content-MD5が正しくありません。"}
+		}
+		checks = append(checks, uploadDigestCheck{name: "Content-MD5", hash: md5.New(), expected: expected})
+	}
+
+	for _, instance := range strings.Split(digests.Digest, ",") {
+		algorithm, encoded, ok := strings.Cut(strings.TrimSpace(instance), "=")
+		if !ok {
+			continue
+		}
+
+		// 対応していないアルゴリズムは無視する
+		newHash, ok := uploadDigestAlgorithms[strings.ToLower(algorithm)]
+		if !ok {
+			continue
+		}
+
+		expected, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, InvalidUploadError{Code: 400, Message: "Digestが正しくありません。"}
+		}
+		checks = append(checks, uploadDigestCheck{name: "Digest", hash: newHash(), expected: expected})
+	}
+
+	return checks, nil
+}
+
+// UploadFileService stores a file sent as a plain request body or as a part of a multipart form.
+// The body is streamed into a new blob while its SHA-256 and the digests sent by the client are computed.
+type UploadFileService struct {
+	Conn             *sqlx.DB
+	FileRepo         repository.FileRepositoryInterface
+	StoreBlobService StoreBlobService
+
+	AuthorizeApiTokenDirectoryService AuthorizeApiTokenDirectoryService
+}
+
+// Execute stores the body as a new file named name. contentLength is -1 when unknown.
+// apiToken is given only when the request was authenticated by an API token.
+func (service *UploadFileService) Execute(user user.User, apiToken *apitoken.ApiToken, parentDirectoryID *string, name string, body io.Reader, contentLength int64, digests UploadDigests) (*file.File, error) {
+	if err := validateFileName(name); err != nil {
+		return nil, err
+	}
+	if contentLength > upload.MaxSize {
+		return nil, InvalidUploadError{Code: 413, Message: "ファイルが大きすぎます。"}
+	}
+	parentDirectoryID, err := service.AuthorizeApiTokenDirectoryService.Execute(user, apiToken, parentDirectoryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkParentDirectory(service.Conn, service.FileRepo, user, parentDirectoryID); err != nil {
+		return nil, err
+	}
+
+	blob, err := service.write(user, name, body, contentLength, digests)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.register(user, parentDirectoryID, name, blob)
+}
+
+// ExecuteMultipart stores the part named "file" as a new file.
+// The "parent_directory_id" and "name" fields are optional and may come before or after the file.
+// The Content-MD5 and Digest headers of the file part are verified.
+func (service *UploadFileService) ExecuteMultipart(user user.User, apiToken *apitoken.ApiToken, reader *multipart.Reader) (*file.File, error) {
+	var blob *Blob
+	var parentDirectoryID *string
+	var name string
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if blob != nil {
+				blob.Remove()
+			}
+			return nil, InvalidUploadError{Code: 400, Message: "multipart/form-dataの形式が正しくありません。"}
+		}
+
+		switch part.FormName() {
+		case "file":
+			if blob != nil {
+				blob.Remove()
+				return nil, InvalidUploadError{Code: 400, Message: "ファイルは1つだけ指定してください。"}
+			}
+			if name == "" {
+				name = part.FileName()
+			}
+
+			blob, err = service.write(user, name, part, -1, UploadDigests{
+				ContentMD5: part.Header.Get("Content-MD5"),
+				Digest:     part.Header.Get("Digest"),
+			})
+			if err != nil {
+				return nil, err
+			}
+		case "parent_directory_id", "name":
+			value, err := io.ReadAll(io.LimitReader(part, 1024))
+			if err != nil {
+				if blob != nil {
+					blob.Remove()
+				}
+				return nil, errors.WithStack(err)
+			}
+
+			if part.FormName() == "name" {
+				name = string(value)
+			} else if len(value) > 0 {
+				id := string(value)
+				parentDirectoryID = &id
+			}
+		}
+	}
+
+	if blob == nil {
+		return nil, InvalidUploadError{Code: 400, Message: "ファイルを指定してください。"}
+	}
+
+	if err := validateFileName(name); err != nil {
+		blob.Remove()
+		return nil, err
+	}
+	parentDirectoryID, err := service.AuthorizeApiTokenDirectoryService.Execute(user, apiToken, parentDirectoryID)
+	if err != nil {
+		blob.Remove()
+		return nil, err
+	}
+	if err := checkParentDirectory(service.Conn, service.FileRepo, user, parentDirectoryID); err != nil {
+		blob.Remove()
+		return nil, err
+	}
+
+	return service.register(user, parentDirectoryID, name, blob)
+}
+
+// 本文を実体に書き込み、大きさとダイジェストを確認する。容量を超える分は書き込まない
+func (service *UploadFileService) write(user user.User, name string, body io.Reader, contentLength int64, digests UploadDigests) (*Blob, error) {
+	checks, err := digests.checks()
+	if err != nil {
+		return nil, err
+	}
+
+	maxSize := int64(upload.MaxSize)
+	remaining, limited, err := service.StoreBlobService.QuotaService.Remaining(user)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if limited {
+		if contentLength > remaining {
+			return nil, quotaExceededError()
+		}
+		maxSize = min(maxSize, remaining)
+	}
+
+	blob, err := service.StoreBlobService.Create(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	contentHash := sha256.New()
+	writers := []io.Writer{blob, contentHash}
+	for _, check := range checks {
+		writers = append(writers, check.hash)
+	}
+
+	// 上限を1バイト超えて読めた場合は大きすぎる
+	written, err := io.Copy(io.MultiWriter(writers...), io.LimitReader(body, maxSize+1))
+	if err != nil {
+		blob.Remove()
+		return nil, errors.WithStack(err)
+	}
+	if written > maxSize {
+		blob.Remove()
+		if maxSize < upload.MaxSize {
+			return nil, quotaExceededError()
+		}
+		return nil, InvalidUploadError{Code: 413, Message: "ファイルが大きすぎます。"}
+	}
+
+	for _, check := range checks {
+		if !bytes.Equal(check.hash.Sum(nil), check.expected) {
+			blob.Remove()
+			return nil, InvalidUploadError{Code: 400, Message: check.name + "が一致しません。"}
+		}
+	}
+
+	if err := blob.Close(); err != nil {
+		blob.Remove()
+		return nil, errors.WithStack(err)
+	}
+
+	if err := service.FileRepo.SetContentHash(blob.LocalPath, hex.EncodeToString(contentHash.Sum(nil))); err != nil {
+		log.Printf("Warning: Failed to cache content hash of %s: %v", blob.LocalPath, err)
+	}
+
+	return blob, nil
+}
+
+func (service *UploadFileService) register(user user.User, parentDirectoryID *string, name string, blob *Blob) (*file.File, error) {
+	registered, err := service.StoreBlobService.Register(user, parentDirectoryID, name, nil, *blob)
+	if err != nil {
+		blob.Remove()
+		return nil, errors.WithStack(err)
+	}
+
+	return registered, nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"log"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/helper"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/mailer"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+// 以前に発行した同じ用途のトークンを無効にしてから、新しいトークンを発行する
+func issueUserToken(conn *sqlx.DB, userTokenRepo repository.UserTokenRepositoryInterface, owner user.User, purpose user.TokenPurpose, now time.Time) (string, error) {
+	token, err := helper.GenerateRandomToken(32)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	tx, err := conn.Beginx()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	if err := userTokenRepo.DeleteUserTokens(tx, owner, purpose); err != nil {
+		tx.Rollback()
+		return "", errors.WithStack(err)
+	}
+
+	if err := userTokenRepo.RegistrationUserToken(tx, owner, purpose, user.HashToken(token), now.Add(purpose.Timeout())); err != nil {
+		tx.Rollback()
+		return "", errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return token, nil
+}
+
+// メールに記載するフロントエンドのURL。FRONTEND_URL が無い場合は BASE_URL を使う
+func frontendUrl(path string, token string) string {
+	baseUrl := os.Getenv("FRONTEND_URL")
+	if baseUrl == "" {
+		baseUrl = os.Getenv("BASE_URL")
+	}
+
+	return baseUrl + path + "?token=" + url.QueryEscape(token)
+}
+
+// SMTPサーバーの応答を待たず、存在するメールアドレスかを応答時間で推測されないようにする
+func sendMailInBackground(m mailer.Mailer, message mailer.Message) {
+	go func() {
+		if err := m.Send(message); err != nil {
+			log.Printf("Warning: Failed to send mail to %s: %+v", message.To, err)
+		}
+	}()
+}
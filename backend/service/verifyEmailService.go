@@ -0,0 +1,42 @@
+package service
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+type VerifyEmailService struct {
+	Conn          *sqlx.DB
+	UserRepo      repository.UserRepositoryInterface
+	UserTokenRepo repository.UserTokenRepositoryInterface
+}
+
+// メールのリンクのトークンでメールアドレスを確認済みにする
+func (service *VerifyEmailService) Execute(token string, now time.Time) error {
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	usedToken, err := service.UserTokenRepo.UseUserToken(tx, user.TokenPurposeEmailVerification, user.HashToken(token), now)
+	if err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := service.UserRepo.UpdateEmailVerifiedAt(tx, user.User{ID: usedToken.UserID}, now); err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+	"github.com/YahiroRyo/yappi_storage/backend/infrastructure/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+// VerifyTwoFactorService checks a TOTP code or an unused recovery code of a user who has enabled two-factor authentication.
+type VerifyTwoFactorService struct {
+	Conn                 *sqlx.DB
+	UserRepo             repository.UserRepositoryInterface
+	UserRecoveryCodeRepo repository.UserRecoveryCodeRepositoryInterface
+}
+
+// 一度使ったTOTPのコードとリカバリーコードは使えない
+func (service *VerifyTwoFactorService) Execute(owner user.User, code string, now time.Time) error {
+	if !owner.TwoFactorEnabled || owner.TotpSecret == "" {
+		return errors.WithStack(TwoFactorConflictError{Code: 409, Message: "二段階認証が有効ではありません。"})
+	}
+
+	tx, err := service.Conn.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	verified := false
+	if counter, ok := user.VerifyTotp(owner.TotpSecret, code, now, owner.TotpLastCounter); ok {
+		verified, err = service.UserRepo.UpdateTotpLastCounter(tx, owner, counter)
+	} else {
+		verified, err = service.UserRecoveryCodeRepo.UseRecoveryCode(tx, owner, user.HashRecoveryCode(code), now)
+	}
+	if err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+	if !verified {
+		tx.Rollback()
+		return errors.WithStack(InvalidTwoFactorCodeError{Code: 401, Message: "認証コードが違います。"})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
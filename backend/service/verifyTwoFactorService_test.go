@@ -0,0 +1,120 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/user"
+)
+
+// RFC 6238 の付録Bの秘密鍵 "12345678901234567890"
+const testTotpSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func testTotpCode(t *testing.T, now time.Time, steps int64) string {
+	t.Helper()
+
+	code, err := user.TotpCode(testTotpSecret, user.TotpCounter(now)+steps)
+	if err != nil {
+		t.Fatalf("failed to generate code: %v", err)
+	}
+
+	return code
+}
+
+func TestVerifyTwoFactorService(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	type attempt struct {
+		code    string
+		wantErr error
+	}
+
+	tests := []struct {
+		name     string
+		owner    user.User
+		attempts []attempt
+	}{
+		{
+			name: "前後1ステップまでのコードを受け付ける",
+			attempts: []attempt{
+				{code: testTotpCode(t, now, -1)},
+				{code: testTotpCode(t, now, 0)},
+				{code: testTotpCode(t, now, 1)},
+			},
+		},
+		{
+			name: "2ステップずれたコードは受け付けない",
+			attempts: []attempt{
+				{code: testTotpCode(t, now, -2), wantErr: InvalidTwoFactorCodeError{}},
+				{code: testTotpCode(t, now, 2), wantErr: InvalidTwoFactorCodeError{}},
+			},
+		},
+		{
+			name: "使用したコードは再利用できない",
+			attempts: []attempt{
+				{code: testTotpCode(t, now, 0)},
+				{code: testTotpCode(t, now, 0), wantErr: InvalidTwoFactorCodeError{}},
+			},
+		},
+		{
+			name: "使用したステップより前のコードは使えない",
+			attempts: []attempt{
+				{code: testTotpCode(t, now, 1)},
+				{code: testTotpCode(t, now, 0), wantErr: InvalidTwoFactorCodeError{}},
+			},
+		},
+		{
+			name: "リカバリーコードは1度のみ使える",
+			attempts: []attempt{
+				{code: "abcde-12345"},
+				{code: "abcde-12345", wantErr: InvalidTwoFactorCodeError{}},
+				{code: "ABCDE12345", wantErr: InvalidTwoFactorCodeError{}},
+				{code: "fghij-67890"},
+			},
+		},
+		{
+			name: "登録されていないリカバリーコードは使えない",
+			attempts: []attempt{
+				{code: "00000-00000", wantErr: InvalidTwoFactorCodeError{}},
+			},
+		},
+		{
+			name:  "二段階認証が有効でない",
+			owner: user.User{ID: "1", TotpSecret: testTotpSecret},
+			attempts: []attempt{
+				{code: testTotpCode(t, now, 0), wantErr: TwoFactorConflictError{}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner := tt.owner
+			if owner.ID == "" {
+				owner = user.User{ID: "1", TwoFactorEnabled: true, TotpSecret: testTotpSecret}
+			}
+
+			userRepo := newFakeUserRepo(owner)
+			service := VerifyTwoFactorService{
+				Conn:                 newFakeDB(),
+				UserRepo:             userRepo,
+				UserRecoveryCodeRepo: newFakeUserRecoveryCodeRepo("abcde-12345", "fghij-67890"),
+			}
+
+			for i, a := range tt.attempts {
+				// ログインごとにユーザーを取得し直す
+				current, _ := userRepo.GetUserByID(nil, owner.ID)
+
+				err := service.Execute(*current, a.code, now)
+				if a.wantErr == nil && err != nil {
+					t.Fatalf("attempt %d: unexpected error: %v", i, err)
+				}
+				if a.wantErr != nil && !errors.HasType(err, a.wantErr) {
+					t.Fatalf("attempt %d: error = %v, want %T", i, err, a.wantErr)
+				}
+			}
+		})
+	}
+}
@@ -1,19 +1,25 @@
 package service
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/YahiroRyo/yappi_storage/backend/domain/video"
 )
 
 type VideoCompressionService interface {
-	CompressVideo(inputPath, outputPath string) error
-	IsVideoFile(filename string) bool
+	CompressVideo(ctx context.Context, inputPath, outputPath string, profile video.Profile) error
+	ShouldCompress(ctx context.Context, inputPath string, profile video.Profile) (bool, string, error)
+	GenerateHLS(ctx context.Context, inputPath, outputDir string, setting video.HLSSetting) error
 	GetCompressedFilename(originalFilename string) string
 }
 
@@ -23,68 +29,55 @@ func NewVideoCompressionService() VideoCompressionService {
 	return &videoCompressionService{}
 }
 
-// IsVideoFile checks if the file is a video file based on its extension
-func (vcs *videoCompressionService) IsVideoFile(filename string) bool {
-	ext := strings.ToLower(filepath.Ext(filename))
-	videoExtensions := []string{".mp4", ".avi", ".mov", ".wmv", ".flv", ".webm", ".mkv", ".m4v", ".3gp", ".mts", ".m2ts"}
-
-	for _, videoExt := range videoExtensions {
-		if ext == videoExt {
-			return true
-		}
-	}
-	return false
-}
-
-// GetCompressedFilename generates a filename for the compressed video
+// GetCompressedFilename generates the filename that replaces the original after compression
 func (vcs *videoCompressionService) GetCompressedFilename(originalFilename string) string {
 	ext := filepath.Ext(originalFilename)
 	nameWithoutExt := strings.TrimSuffix(originalFilename, ext)
-	return fmt.Sprintf("%s_compressed.mp4", nameWithoutExt)
+	return fmt.Sprintf("%s.mp4", nameWithoutExt)
+}
+
+// ShouldCompress probes the video and reports whether re-encoding it with the profile is worthwhile.
+// When it is not, the reason is returned so that it can be shown in the job result.
+func (vcs *videoCompressionService) ShouldCompress(ctx context.Context, inputPath string, profile video.Profile) (bool, string, error) {
+	stream, err := probeVideoStream(ctx, inputPath)
+	if err != nil {
+		return false, "", err
+	}
+
+	// 解像度の上限を超えている場合は縮小のため必ず圧縮する
+	if profile.MaxHeight > 0 && stream.Height > profile.MaxHeight {
+		return true, "", nil
+	}
+
+	if !slices.Contains(profile.Skip.Codecs, stream.Codec) {
+		return true, "", nil
+	}
+
+	if stream.BitRate == 0 || stream.FrameRate == 0 {
+		return true, "", nil
+	}
+
+	bitsPerPixel := float64(stream.BitRate) / (float64(stream.Width*stream.Height) * stream.FrameRate)
+	if bitsPerPixel > profile.Skip.MaxBitsPerPixel {
+		return true, "", nil
+	}
+
+	reason := fmt.Sprintf("already efficiently encoded (%s, %.3f bits/pixel)", stream.Codec, bitsPerPixel)
+	log.Printf("Skipping compression of %s: %s", inputPath, reason)
+
+	return false, reason, nil
 }
 
-// CompressVideo compresses a video file using ffmpeg
-func (vcs *videoCompressionService) CompressVideo(inputPath, outputPath string) error {
-	log.Printf("Starting video compression: %s -> %s", inputPath, outputPath)
+// CompressVideo compresses a video file using ffmpeg with the settings of the profile
+func (vcs *videoCompressionService) CompressVideo(ctx context.Context, inputPath, outputPath string, profile video.Profile) error {
+	log.Printf("Starting video compression with profile %s: %s -> %s", profile.Name, inputPath, outputPath)
 
 	// Check if input file exists
 	if _, err := os.Stat(inputPath); os.IsNotExist(err) {
 		return fmt.Errorf("input file does not exist: %s", inputPath)
 	}
 
-	// Create context with timeout to prevent hanging
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
-	defer cancel()
-
-	// Build ffmpeg command for compression
-	// -i: input file
-	// -c:v libx264: use H.264 video codec
-	// -crf 23: quality setting (18-28 is usually good, 23 is default)
-	// -preset medium: compression speed/efficiency balance
-	// -c:a aac: use AAC audio codec
-	// -b:a 128k: audio bitrate
-	// -movflags +faststart: optimize for web streaming
-	cmd := exec.CommandContext(ctx,
-		"ffmpeg",
-		"-i", inputPath, // Input file
-		"-c:v", "libx264", // Video codec
-		"-crf", "23", // Quality setting
-		"-preset", "medium", // Compression preset
-		"-c:a", "aac", // Audio codec
-		"-b:a", "128k", // Audio bitrate
-		"-movflags", "+faststart", // Web optimization
-		"-y",       // Overwrite output file if exists
-		outputPath, // Output file
-	)
-
-	// Log the command being executed
-	log.Printf("Executing ffmpeg command: %s", cmd.String())
-
-	// Capture output for debugging
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		log.Printf("FFmpeg error: %v", err)
-		log.Printf("FFmpeg output: %s", string(output))
+	if err := runCommand(ctx, 30*time.Minute, "ffmpeg", compressionArgs(inputPath, outputPath, profile)...); err != nil {
 		return fmt.Errorf("video compression failed: %v", err)
 	}
 
@@ -108,12 +101,162 @@ func (vcs *videoCompressionService) CompressVideo(inputPath, outputPath string)
 	return nil
 }
 
-// CompressVideoAsync compresses video in background and calls callback when done
-func (vcs *videoCompressionService) CompressVideoAsync(inputPath, outputPath string, onComplete func(error)) {
-	go func() {
-		err := vcs.CompressVideo(inputPath, outputPath)
-		if onComplete != nil {
-			onComplete(err)
+// compressionArgs builds the ffmpeg arguments for the profile
+func compressionArgs(inputPath, outputPath string, profile video.Profile) []string {
+	args := []string{}
+
+	defaultProfile := video.DefaultProfile()
+	videoCodec := cmp.Or(profile.VideoCodec, defaultProfile.VideoCodec)
+	hardware := profile.HardwareAcceleration.Enabled
+	if hardware {
+		if profile.HardwareAcceleration.Hwaccel != "" {
+			args = append(args, "-hwaccel", profile.HardwareAcceleration.Hwaccel)
+		}
+		if profile.HardwareAcceleration.VideoCodec != "" {
+			videoCodec = profile.HardwareAcceleration.VideoCodec
+		}
+	}
+
+	args = append(args, "-i", inputPath, "-c:v", videoCodec)
+
+	switch {
+	case profile.VideoBitrate != "":
+		args = append(args, "-b:v", profile.VideoBitrate)
+	case hardware:
+		// ハードウェアエンコーダーの多くはCRFに対応していないため、固定QPとして渡す
+		args = append(args, "-qp", strconv.Itoa(profile.CRF))
+	default:
+		args = append(args, "-crf", strconv.Itoa(profile.CRF))
+	}
+
+	if profile.Preset != "" {
+		args = append(args, "-preset", profile.Preset)
+	}
+
+	if profile.MaxHeight > 0 {
+		// 上限より小さい動画は拡大しない。幅はアスペクト比を保った偶数にする
+		args = append(args, "-vf", fmt.Sprintf("scale=-2:'min(%d,ih)'", profile.MaxHeight))
+	}
+
+	args = append(args, "-c:a", cmp.Or(profile.AudioCodec, defaultProfile.AudioCodec))
+	if profile.AudioBitrate != "" {
+		args = append(args, "-b:a", profile.AudioBitrate)
+	}
+	if profile.AudioChannels > 0 {
+		args = append(args, "-ac", strconv.Itoa(profile.AudioChannels))
+	}
+
+	// Web再生向けにmoovを先頭に置く
+	return append(args, "-movflags", "+faststart", "-y", outputPath)
+}
+
+// GenerateHLS transcodes the video into an HLS ladder and writes the master playlist.
+// The ladder is built in a temporary directory and swapped in at the end so that players never see a half-written ladder.
+func (vcs *videoCompressionService) GenerateHLS(ctx context.Context, inputPath, outputDir string, setting video.HLSSetting) error {
+	log.Printf("Starting HLS generation: %s -> %s", inputPath, outputDir)
+
+	width, height, err := probeResolution(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+
+	hasAudio, err := probeHasAudio(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+
+	tmpDir := outputDir + ".tmp"
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return fmt.Errorf("failed to clean up temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	renditions := selectRenditions(setting.Renditions, height)
+
+	var master strings.Builder
+	master.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+
+	for _, rendition := range renditions {
+		// 元動画より大きな解像度には拡大しない
+		targetHeight := min(rendition.Height, height)
+		targetWidth := (width*targetHeight/height + 1) / 2 * 2
+
+		renditionDir := filepath.Join(tmpDir, rendition.Name)
+		if err := os.MkdirAll(renditionDir, 0755); err != nil {
+			return fmt.Errorf("failed to create rendition directory: %v", err)
+		}
+
+		args := []string{
+			"-i", inputPath,
+			"-map", "0:v:0",
+		}
+		if hasAudio {
+			args = append(args, "-map", "0:a:0")
+		}
+		args = append(args,
+			"-vf", fmt.Sprintf("scale=-2:%d", targetHeight),
+			"-c:v", "libx264",
+			"-preset", "veryfast",
+			"-profile:v", "main",
+			"-b:v", rendition.VideoBitrate,
+			"-maxrate", rendition.VideoBitrate,
+			"-bufsize", rendition.VideoBitrate,
+			// セグメント境界を揃えるためにキーフレームを強制する
+			"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", setting.SegmentSeconds),
+		)
+		if hasAudio {
+			args = append(args,
+				"-c:a", "aac",
+				"-b:a", rendition.AudioBitrate,
+				"-ac", "2",
+			)
+		}
+		args = append(args,
+			"-f", "hls",
+			"-hls_time", fmt.Sprint(setting.SegmentSeconds),
+			"-hls_playlist_type", "vod",
+			"-hls_segment_filename", filepath.Join(renditionDir, "segment_%05d.ts"),
+			"-y",
+			filepath.Join(renditionDir, "index.m3u8"),
+		)
+
+		if err := runCommand(ctx, 2*time.Hour, "ffmpeg", args...); err != nil {
+			return fmt.Errorf("HLS generation failed for %s: %v", rendition.Name, err)
+		}
+
+		master.WriteString(fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%dx%d\n", rendition.Bandwidth(), targetWidth, targetHeight))
+		master.WriteString(fmt.Sprintf("%s/index.m3u8\n", rendition.Name))
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "master.m3u8"), []byte(master.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write master playlist: %v", err)
+	}
+
+	if err := os.RemoveAll(outputDir); err != nil {
+		return fmt.Errorf("failed to remove previous HLS output: %v", err)
+	}
+	if err := os.Rename(tmpDir, outputDir); err != nil {
+		return fmt.Errorf("failed to save HLS output: %v", err)
+	}
+
+	log.Printf("HLS generation completed: %s (%d renditions)", outputDir, len(renditions))
+
+	return nil
+}
+
+// selectRenditions drops renditions taller than the source, keeping at least the smallest one
+func selectRenditions(renditions []video.Rendition, sourceHeight int) []video.Rendition {
+	sorted := append([]video.Rendition{}, renditions...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Height < sorted[j].Height
+	})
+
+	selected := []video.Rendition{}
+	for i, rendition := range sorted {
+		if i == 0 || rendition.Height <= sourceHeight {
+			selected = append(selected, rendition)
 		}
-	}()
+	}
+
+	return selected
 }
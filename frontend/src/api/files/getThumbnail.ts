@@ -0,0 +1,9 @@
+export type ThumbnailSize = 64 | 256 | 1024;
+
+export const getThumbnailUrl = (fileId: string, size: ThumbnailSize): string => {
+  return `${process.env.NEXT_PUBLIC_API_URL}/files/file/${fileId}/thumbnail?size=${size}`;
+};
+
+export const getSpriteUrl = (fileId: string): string => {
+  return `${process.env.NEXT_PUBLIC_API_URL}/files/file/${fileId}/sprite`;
+};
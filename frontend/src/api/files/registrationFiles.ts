@@ -13,7 +13,9 @@ type Response = {
 };
 
 type RegistrationFile = {
-  url: string;
+  // 外部のURL。アップロードしたファイルは upload_id で指定する
+  url?: string;
+  upload_id?: string;
   name: string;
   kind: FileKind;
   parent_directory_id?: string;
@@ -111,14 +111,14 @@ const waitForConnection = (client: WebSocket): Promise<void> => {
 };
 
 // 各ファイルアップロード前の初期化を保証する関数
-const ensureFileInitialization = (client: WebSocket, fileName: string): Promise<string> => {
+const ensureFileInitialization = (client: WebSocket, fileName: string, fileSize: number): Promise<string> => {
   return new Promise((resolve, reject) => {
     console.log(`Initializing new file upload session for: ${fileName}`);
     
     // 初期化リクエストを送信
     const initMessage = {
       Event: "initialize_file_name",
-      Data: { filename: fileName }
+      Data: { filename: fileName, size: fileSize }
     };
     
     const initHandler = (event: MessageEvent) => {
@@ -274,7 +274,8 @@ export const uploadFile = async (
               
               isCompleted = true;
               cleanup();
-              resolve(response.Data.file_path || response.Data.filename);
+              // 登録に使うアップロードID
+              resolve(response.Data.upload_id);
             } else {
               reject(new Error(`Upload completion failed: ${response.Data.message || 'Unknown error'}`));
             }
@@ -323,7 +324,7 @@ export const uploadFile = async (
     // ファイルアップロードの初期化
     const initMessage = {
       Event: "initialize_file_name",
-      Data: { filename: file.name }
+      Data: { filename: file.name, size: file.size }
     };
     
     console.log("Sending initialization message:", initMessage);
@@ -13,6 +13,7 @@ import { useState } from "react";
 import 'react-pdf/dist/esm/Page/TextLayer.css'
 import 'react-pdf/dist/esm/Page/AnnotationLayer.css'
 import { downloadFile } from "@/helpers/fileDownload";
+import { getThumbnailUrl } from "@/api/files/getThumbnail";
 
 type FilePreviewProps = {
   file?: GetFileSuccessedResponse;
@@ -67,7 +68,7 @@ export const FilePreview = ({ file, onClose }: FilePreviewProps) => {
             </Button>
             <div className={styles.filePreview__image}>
               {file.url && (
-                <img className={styles.filePreview__image} src={file.url} />
+                <img className={styles.filePreview__image} src={getThumbnailUrl(file.id, 1024)} />
               )}
             </div>
           </GridVerticalRow>
@@ -111,7 +112,7 @@ export const FilePreview = ({ file, onClose }: FilePreviewProps) => {
                 ダウンロード
               </Text>
             </Button>
-            <MuxPlayer  src={file.url!} className={styles.filePreview__video} />
+            <MuxPlayer  src={file.url!} poster={getThumbnailUrl(file.id, 1024)} className={styles.filePreview__video} />
           </GridVerticalRow>
         </div>
       </div>
@@ -79,7 +79,7 @@ export const UploadFileModal = ({
       const completedUploads: string[] = [];
       const allFiles = uploadFileFormData.files;
       
-      const urls = await uploadFiles(
+      const uploadIds = await uploadFiles(
         wsClient, 
         uploadFileFormData.files, 
         uploadConfig,
@@ -104,14 +104,14 @@ export const UploadFileModal = ({
             // アップロード完了時（finished_uploadイベント受信後）
             if (progress.status === 'completed') {
               completeFileUpload(fileId);
-              completedUploads.push(urls[fileIndex] || fileName);
+              completedUploads.push(uploadIds[fileIndex] || fileName);
               
               // このファイルをDBに登録
               const fileForRegistration = {
                 name: allFiles[fileIndex].name,
                 size: allFiles[fileIndex].size,
                 type: allFiles[fileIndex].type,
-                url: urls[fileIndex],
+                upload_id: uploadIds[fileIndex],
                 kind: fileToFileKind(allFiles[fileIndex]),
                 parent_directory_id: parentDirectoryId,
               };
@@ -9,6 +9,18 @@ export type FileKind =
   | "Image"
   | "Zip";
 
+export type FileMetadata = {
+  make?: string;
+  model?: string;
+  lens_model?: string;
+  orientation?: number;
+  gps?: {
+    latitude: number;
+    longitude: number;
+    altitude?: number;
+  };
+};
+
 export type File = {
   id: string;
   user_id: string;
@@ -17,6 +29,12 @@ export type File = {
   kind: FileKind;
   url?: string;
   name: string;
+  mime_type?: string;
+  compression_disabled: boolean;
+  taken_at?: DateTime;
+  width?: number;
+  height?: number;
+  metadata?: FileMetadata;
   created_at: DateTime;
   updated_at: DateTime;
 };
@@ -3,5 +3,6 @@ export type User = {
   email: string;
   password: string;
   icon: string;
+  strip_location_metadata: boolean;
   created_at: string;
 };